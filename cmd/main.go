@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -11,10 +12,21 @@ import (
 	_ "github.com/markraiter/spycat/docs"
 	"github.com/markraiter/spycat/internal/app/api"
 	"github.com/markraiter/spycat/internal/app/api/handler"
+	"github.com/markraiter/spycat/internal/app/chat"
+	"github.com/markraiter/spycat/internal/app/events"
+	"github.com/markraiter/spycat/internal/app/lockout"
+	"github.com/markraiter/spycat/internal/app/notify"
+	"github.com/markraiter/spycat/internal/app/scheduler"
 	"github.com/markraiter/spycat/internal/app/service"
 	"github.com/markraiter/spycat/internal/app/storage/postgres"
+	"github.com/markraiter/spycat/internal/app/stream"
+	"github.com/markraiter/spycat/internal/app/webhook"
 	"github.com/markraiter/spycat/internal/config"
 	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/egress"
+	"github.com/markraiter/spycat/internal/lib/jwt"
+	"github.com/markraiter/spycat/internal/lib/oidc"
+	"github.com/markraiter/spycat/internal/lib/password"
 )
 
 // @title SpyCat API
@@ -32,10 +44,6 @@ func main() {
 	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
 	validate := validator.New()
-	validate.RegisterValidation("number", domain.ValidateContainsNumber, false)   // nolint: errcheck
-	validate.RegisterValidation("upper", domain.ValidateContainsUpper, false)     // nolint: errcheck
-	validate.RegisterValidation("lower", domain.ValidateContainsLower, false)     // nolint: errcheck
-	validate.RegisterValidation("special", domain.ValidateContainsSpecial, false) // nolint: errcheck
 
 	log.Info("Starting application...")
 	log.Info("port: " + cfg.Server.Port)
@@ -44,21 +52,112 @@ func main() {
 	storage := postgres.New(cfg.Postgres)
 	defer storage.Close()
 
+	bus := events.New(log, cfg.Events)
+	defer bus.Close()
+
+	dispatcher := webhook.New(log, cfg.Webhook, storage)
+	bus.SubscribeAsync("webhook.wake", dispatcher.Wake)
+
+	mailer, err := notify.NewMailer(cfg.Notify, notify.NewSender(cfg.Notify))
+	if err != nil {
+		log.Error("notify.NewMailer", "error", err)
+		os.Exit(1)
+	}
+
+	keys, err := jwt.NewKeySet(cfg.Auth)
+	if err != nil {
+		log.Error("jwt.NewKeySet", "error", err)
+		os.Exit(1)
+	}
+
+	attempts, err := lockout.NewStore(cfg.Lockout, storage)
+	if err != nil {
+		log.Error("lockout.NewStore", "error", err)
+		os.Exit(1)
+	}
+	logins := lockout.New(cfg.Lockout, attempts)
+
+	// Login with the identity provider stays off without OIDC_ISSUER.
+	var idp service.IdentityProvider
+	if cfg.OIDC.Issuer != "" {
+		idp = oidc.New(cfg.OIDC)
+		log.Info("oidc issuer: " + cfg.OIDC.Issuer)
+	}
+
+	passwords, err := password.New(cfg.Password)
+	if err != nil {
+		log.Error("password.New", "error", err)
+		os.Exit(1)
+	}
+
+	hasher, err := password.NewHasher(cfg.PasswordHash)
+	if err != nil {
+		log.Error("password.NewHasher", "error", err)
+		os.Exit(1)
+	}
+
 	service := service.New(
 		storage,
 		storage,
 		storage,
 		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		bus,
+		mailer,
+		logins,
+		idp,
+		keys,
+		passwords,
+		hasher,
+		egress.New(cfg.Webhook.AllowPrivate),
 	)
 
+	listener, err := postgres.NewListener(cfg.Postgres, postgres.EventsChannel)
+	if err != nil {
+		log.Error("NewListener", "error", err)
+		os.Exit(1)
+	}
+	defer listener.Close()
+
+	hub := stream.NewHub(cfg.Stream.BufferSize)
+
+	chatListener, err := postgres.NewListener(cfg.Postgres, postgres.ChatChannel)
+	if err != nil {
+		log.Error("NewListener", "error", err)
+		os.Exit(1)
+	}
+	defer chatListener.Close()
+
+	rooms := chat.NewHub(log, storage, postgres.ChatChannel)
+
+	notifier := notify.New(log, mailer, service)
+	bus.SubscribeAsync("notify.email", notifier.Handle, domain.NotifiableEvents...)
+
 	handler := handler.New(
 		log,
 		validate,
 		cfg,
 		service,
+		hub,
+		rooms,
+		bus,
 	)
 
-	server := api.New(cfg, handler)
+	server := api.New(cfg, handler, service, keys)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go scheduler.New(log, cfg.Scheduler, service, service, logins).Run(ctx)
+	go dispatcher.Run(ctx)
+	go stream.NewRelay(log, hub, storage, listener, cfg.Stream.BufferSize).Run(ctx)
+	go rooms.Run(ctx, chatListener)
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
@@ -71,6 +170,8 @@ func main() {
 
 	<-stop
 
+	cancel()
+
 	if err := server.HTTPServer.ShutdownWithTimeout(5 * time.Second); err != nil {
 		log.Error("ShutdownWithTimeout", "error", err)
 	}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/go-playground/validator"
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/app/service"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, userID int, kr *domain.APIKeyRequest) (*domain.NewAPIKey, error)
+	APIKeys(ctx context.Context, userID int) ([]*domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID int, id int64) error
+}
+
+type APIKeyHandler struct {
+	log     *slog.Logger
+	val     *validator.Validate
+	service APIKeyService
+}
+
+// @Summary Create API key
+// @Description Create an API key of the current user for scripts, sent as "Authorization: ApiKey <key>".
+// @Description The key is returned this once. The read scope allows GET requests, the write scope any other.
+// @Security ApiKeyAuth
+// @Tags APIKey
+// @Accept json
+// @Produce json
+// @Param API_key_request body domain.APIKeyRequest true "API key data"
+// @Success 201 {object} domain.NewAPIKey
+// @Failure 400 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /me/api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	const op = "handler.CreateAPIKey"
+	log := h.log.With(slog.String("operation", op))
+
+	var kr domain.APIKeyRequest
+	if err := c.BodyParser(&kr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(kr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	key, err := h.service.CreateAPIKey(c.Context(), userID(c), &kr)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidExpiry) {
+			log.Warn("invalid expiry", sl.Err(err))
+			return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: service.ErrInvalidExpiry.Error()})
+		}
+		log.Error("error while creating API key", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(key)
+}
+
+// @Summary Get API keys
+// @Description Get the API keys of the current user, revoked ones included. Secrets are never returned.
+// @Security ApiKeyAuth
+// @Tags APIKey
+// @Produce json
+// @Success 200 {array} domain.APIKey
+// @Failure 500 {object} domain.Response
+// @Router /me/api-keys [get]
+func (h *APIKeyHandler) GetAPIKeys(c *fiber.Ctx) error {
+	const op = "handler.GetAPIKeys"
+	log := h.log.With(slog.String("operation", op))
+
+	keys, err := h.service.APIKeys(c.Context(), userID(c))
+	if err != nil {
+		log.Error("error while getting API keys", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(keys)
+}
+
+// @Summary Revoke API key
+// @Description Revoke an API key of the current user, it stops working at once.
+// @Security ApiKeyAuth
+// @Tags APIKey
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /me/api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	const op = "handler.RevokeAPIKey"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		log.Warn("invalid API key id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: "invalid API key id"})
+	}
+
+	if err := h.service.RevokeAPIKey(c.Context(), userID(c), id); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: "API key not found"})
+		}
+		log.Error("error while revoking API key", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "API key revoked"})
+}
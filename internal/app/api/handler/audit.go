@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+type AuditService interface {
+	AuditEntries(ctx context.Context, entity string, entityID int) ([]*domain.AuditEntry, error)
+}
+
+type AuditHandler struct {
+	log     *slog.Logger
+	service AuditService
+}
+
+// @Summary Get audit log
+// @Description Get audit log entries of an entity
+// @Security ApiKeyAuth
+// @Tags Audit
+// @Accept json
+// @Produce json
+// @Param entity query string true "Entity" example(mission)
+// @Param entity_id query int true "Entity ID"
+// @Success 200 {array} domain.AuditEntry
+// @Failure 400 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /audit [get]
+func (h *AuditHandler) GetAuditEntries(c *fiber.Ctx) error {
+	const op = "handler.GetAuditEntries"
+	log := h.log.With(slog.String("operation", op))
+
+	q := struct {
+		Entity   string `query:"entity"`
+		EntityID int    `query:"entity_id"`
+	}{}
+
+	if err := c.QueryParser(&q); err != nil {
+		log.Warn("error while parsing query", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if q.Entity == "" || q.EntityID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: "entity and entity_id are required"})
+	}
+
+	entries, err := h.service.AuditEntries(c.Context(), q.Entity, q.EntityID)
+	if err != nil {
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(entries)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"math"
+	"strconv"
 
 	"github.com/go-playground/validator"
 	"github.com/gofiber/fiber/v2"
@@ -14,8 +16,35 @@ import (
 )
 
 type AuthService interface {
-	Register(ctx context.Context, user *domain.UserRequest) (int, error)
-	Login(ctx context.Context, cfg config.Auth, email, password string) (string, error)
+	Register(ctx context.Context, cfg config.Auth, user *domain.UserRequest) (int, error)
+	Login(ctx context.Context, cfg config.Auth, email, password string, client domain.Client) (*domain.LoginResult, error)
+	LoginMFA(ctx context.Context, cfg config.Auth, req *domain.MFALoginRequest, client domain.Client) (string, error)
+	Unlock(ctx context.Context, userID int, ur *domain.UnlockRequest) error
+	StartOIDC(ctx context.Context, cfg config.OIDC) (*domain.OIDCRedirect, error)
+	LoginOIDC(ctx context.Context, cfg config.Auth, oc config.OIDC, flowToken string, cb *domain.OIDCCallback, client domain.Client) (*domain.LoginResult, error)
+	JWKS() *domain.JWKS
+	VerifyEmail(ctx context.Context, cfg config.Auth, token string) error
+	ResendVerification(ctx context.Context, cfg config.Auth, email string) error
+	ForgotPassword(ctx context.Context, cfg config.Auth, email string) error
+	ResetPassword(ctx context.Context, rr *domain.ResetPasswordRequest) error
+	EnrollTOTP(ctx context.Context, cfg config.Auth, userID int) (*domain.TOTPEnrollment, error)
+	TOTPQRCode(ctx context.Context, cfg config.Auth, userID int) ([]byte, error)
+	ActivateTOTP(ctx context.Context, userID int, code string) (*domain.RecoveryCodes, error)
+	DisableTOTP(ctx context.Context, userID int, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID int, code string) (*domain.RecoveryCodes, error)
+	MFAPolicies(ctx context.Context) ([]domain.MFAPolicy, error)
+	SetMFAPolicy(ctx context.Context, userID int, policy *domain.MFAPolicy) error
+	Sessions(ctx context.Context, userID int, currentID int64) ([]*domain.Session, error)
+	RevokeSession(ctx context.Context, userID int, id int64) error
+	RevokeSessions(ctx context.Context, userID int) (int, error)
+	Profile(ctx context.Context, userID int) (*domain.Profile, error)
+	UpdateProfile(ctx context.Context, cfg config.Auth, userID int, sessionID int64, pu *domain.ProfileUpdate) (*domain.Profile, error)
+	ChangePassword(ctx context.Context, userID int, sessionID int64, pr *domain.PasswordChangeRequest) error
+	DeleteAccount(ctx context.Context, cfg config.Auth, userID int, sessionID int64, password string) error
+	Users(ctx context.Context, userID int, filter *domain.UserFilter) ([]*domain.Profile, error)
+	DisableUser(ctx context.Context, userID, id int) error
+	EnableUser(ctx context.Context, userID, id int) error
+	ForcePasswordReset(ctx context.Context, cfg config.Auth, userID, id int) error
 }
 
 type AuthHandler struct {
@@ -26,7 +55,9 @@ type AuthHandler struct {
 }
 
 // @Summary Register user
-// @Description Register user
+// @Description Register user and email a link to verify the email address.
+// @Description Depending on the configuration, login is refused until the address is verified.
+// @Description The password must meet the configured policy, 406 tells which rules it breaks.
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -52,12 +83,21 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
 	}
 
-	id, err := h.service.Register(c.Context(), &rr)
+	id, err := h.service.Register(c.Context(), h.cfg.Auth, &rr)
 	if err != nil {
+		if errors.Is(err, service.ErrVerificationNotSent) {
+			// The user exists, a new link can be asked for with /auth/verify/resend.
+			log.Error("verification email was not sent", sl.Err(err))
+			return c.Status(fiber.StatusCreated).JSON(id)
+		}
 		if errors.Is(err, service.ErrAlreadyExists) {
 			log.Warn("user already exists", sl.Err(err))
 			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: err.Error()})
 		}
+		if errors.Is(err, service.ErrWeakPassword) {
+			log.Warn("weak password", sl.Err(err))
+			return weakPassword(c, err)
+		}
 		log.Warn("internal error", sl.Err(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
 	}
@@ -67,14 +107,20 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 
 // @Summary Login
 // @Tags Auth
-// @Description	Logs user in
+// @Description	Logs user in. Users with two-factor authentication enabled get a challenge
+// @Description	to answer at /auth/login/mfa instead of the token.
+// @Description	Failed logins slow down further attempts on the account and lock it for a while
+// @Description	once there are too many, the Retry-After header says when to try again.
 // @ID login
 // @Accept json
 // @Produce json
 // @Param input	body domain.LoginRequest true "credentials"
 // @Success	200	{string} string "Token"
+// @Success	202	{object} domain.MFAChallenge
 // @Failure	400	{object} domain.Response
+// @Failure	403	{object} domain.Response "Invalid credentials, email not verified, password reset required or account disabled"
 // @Failure	406	{object} domain.Response
+// @Failure	429	{object} domain.Response
 // @Failure	500	{object} domain.Response
 // @Router /auth/login [post].
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
@@ -92,29 +138,267 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
 	}
 
-	token, err := h.service.Login(c.UserContext(), h.cfg.Auth, loginReq.Email, loginReq.Password)
+	result, err := h.service.Login(c.UserContext(), h.cfg.Auth, loginReq.Email, loginReq.Password, client(c))
 	if err != nil {
+		if errors.Is(err, service.ErrTooManyAttempts) {
+			log.Warn("login held back", sl.Err(err), slog.String("ip", c.IP()))
+			return tooManyAttempts(c, err)
+		}
 		if errors.Is(err, service.ErrInvalidCredentials) {
 			log.Warn("invalid credentials", sl.Err(err))
-			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: err.Error()})
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrInvalidCredentials.Error()})
+		}
+		if errors.Is(err, service.ErrEmailNotVerified) {
+			log.Warn("email not verified", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrEmailNotVerified.Error()})
+		}
+		if errors.Is(err, service.ErrPasswordResetRequired) {
+			log.Warn("password reset required", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrPasswordResetRequired.Error()})
+		}
+		if errors.Is(err, service.ErrAccountDisabled) {
+			log.Warn("account disabled", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrAccountDisabled.Error()})
 		}
 		log.Error("internal error", sl.Err(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(token)
+	if result.Challenge != nil {
+		return c.Status(fiber.StatusAccepted).JSON(result.Challenge)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result.Token)
 }
 
 // @Summary Logout
-// @Description	Logs user out
+// @Description	Logs user out, the session of the token is revoked.
 // @Security ApiKeyAuth
 // @Tags Auth
 // @Produce json
 // @Success 200	{object} domain.Response
+// @Failure 500	{object} domain.Response
 // @Router /auth/logout [post].
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	const op = "handler.Logout"
+	log := h.log.With(slog.String("operation", op))
+
+	if err := h.service.RevokeSession(c.UserContext(), userID(c), sessionID(c)); err != nil && !errors.Is(err, service.ErrNotFound) {
+		log.Error("error while revoking session", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
 	c.Request().Header.Del("Authorization")
 	c.Response().Header.Del("Authorization")
 
 	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "you are logged out"})
 }
+
+// @Summary Verify email
+// @Description Verify the email address with the token from the verification email.
+// @Tags Auth
+// @Produce json
+// @Param token query string true "Verification token"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/verify [get]
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	const op = "handler.VerifyEmail"
+	log := h.log.With(slog.String("operation", op))
+
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: "missing token"})
+	}
+
+	if err := h.service.VerifyEmail(c.Context(), h.cfg.Auth, token); err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			log.Warn("invalid verification token", sl.Err(err))
+			return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: service.ErrInvalidToken.Error()})
+		}
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "email verified"})
+}
+
+// @Summary Resend verification email
+// @Description Email a new verification link. The response is the same whether the account exists or not.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param Email_request body domain.EmailRequest true "Email"
+// @Success 202 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/verify/resend [post]
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	const op = "handler.ResendVerification"
+	log := h.log.With(slog.String("operation", op))
+
+	var er domain.EmailRequest
+	if err := c.BodyParser(&er); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(er); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.ResendVerification(c.Context(), h.cfg.Auth, er.Email); err != nil {
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: "verification email was not sent"})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(domain.Response{Message: "if the account exists and is not verified, a verification email is on its way"})
+}
+
+// @Summary Forgot password
+// @Description Email a single-use password reset token. The response is the same whether the account exists or not.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param Email_request body domain.EmailRequest true "Email"
+// @Success 202 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/password/forgot [post]
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	const op = "handler.ForgotPassword"
+	log := h.log.With(slog.String("operation", op))
+
+	var er domain.EmailRequest
+	if err := c.BodyParser(&er); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(er); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.ForgotPassword(c.Context(), h.cfg.Auth, er.Email); err != nil {
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: "password reset email was not sent"})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(domain.Response{Message: "if the account exists, a password reset email is on its way"})
+}
+
+// @Summary Reset password
+// @Description Set a new password with a reset token. The token can be used once
+// @Description and every session of the user is revoked. The password must meet the configured policy.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param Reset_request body domain.ResetPasswordRequest true "Token and new password"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/password/reset [post]
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	const op = "handler.ResetPassword"
+	log := h.log.With(slog.String("operation", op))
+
+	var rr domain.ResetPasswordRequest
+	if err := c.BodyParser(&rr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(rr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.ResetPassword(c.Context(), &rr); err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			log.Warn("invalid reset token", sl.Err(err))
+			return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: service.ErrInvalidToken.Error()})
+		}
+		if errors.Is(err, service.ErrWeakPassword) {
+			log.Warn("weak password", sl.Err(err))
+			return weakPassword(c, err)
+		}
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "password changed, sign in again"})
+}
+
+// @Summary Unlock logins
+// @Description Clear the failed logins of an account, an IP address or both, admins only.
+// @Security ApiKeyAuth
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param Unlock_request body domain.UnlockRequest true "Email and/or IP address"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/unlock [post]
+func (h *AuthHandler) Unlock(c *fiber.Ctx) error {
+	const op = "handler.Unlock"
+	log := h.log.With(slog.String("operation", op))
+
+	var ur domain.UnlockRequest
+	if err := c.BodyParser(&ur); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(ur); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.Unlock(c.Context(), userID(c), &ur); err != nil {
+		if errors.Is(err, service.ErrForbidden) {
+			log.Warn("forbidden", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrForbidden.Error()})
+		}
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "logins unlocked"})
+}
+
+// GetJWKS serves the public keys access tokens can be verified with at
+// /.well-known/jwks.json, outside of the API base path.
+func (h *AuthHandler) GetJWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+
+	return c.Status(fiber.StatusOK).JSON(h.service.JWKS())
+}
+
+// tooManyAttempts writes a 429 with the Retry-After of a service.TooManyAttemptsError in whole seconds.
+func tooManyAttempts(c *fiber.Ctx, err error) error {
+	var tooMany *service.TooManyAttemptsError
+	if errors.As(err, &tooMany) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(tooMany.RetryAfter.Seconds()))))
+	}
+
+	return c.Status(fiber.StatusTooManyRequests).JSON(domain.Response{Message: service.ErrTooManyAttempts.Error()})
+}
+
+// weakPassword writes which rules of the password policy the new password breaks.
+func weakPassword(c *fiber.Ctx, err error) error {
+	var weak *service.WeakPasswordError
+	if errors.As(err, &weak) {
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: weak.Reason})
+	}
+
+	return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: service.ErrWeakPassword.Error()})
+}
@@ -18,6 +18,8 @@ type CatService interface {
 	Cats(ctx context.Context) ([]*domain.Cat, error)
 	UpdateCat(ctx context.Context, catID int, cr *domain.CatRequest) error
 	DeleteCat(ctx context.Context, id int) error
+	Salaries(ctx context.Context, catID int) ([]*domain.Salary, error)
+	SaveSalary(ctx context.Context, catID int, sr *domain.SalaryRequest) (int, error)
 }
 
 type CatHandler struct {
@@ -60,6 +62,10 @@ func (h *CatHandler) CreateCat(c *fiber.Ctx) error {
 			log.Warn("cat breed not found", sl.Err(err))
 			return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
 		}
+		if errors.Is(err, service.ErrHandlerNotFound) {
+			log.Warn("handler not found", sl.Err(err))
+			return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+		}
 		{
 			if errors.Is(err, service.ErrAlreadyExists) {
 				log.Warn("cat already exists", sl.Err(err))
@@ -140,7 +146,7 @@ func (h *CatHandler) GetCats(c *fiber.Ctx) error {
 }
 
 // @Summary Update cat by ID
-// @Description Update cat by ID
+// @Description Update cat by ID, a request without a currency keeps the one of the cat
 // @Security ApiKeyAuth
 // @Tags Cat
 // @Accept json
@@ -187,6 +193,10 @@ func (h *CatHandler) UpdateCat(c *fiber.Ctx) error {
 			log.Warn("cat breed not found", sl.Err(err))
 			return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
 		}
+		if errors.Is(err, service.ErrHandlerNotFound) {
+			log.Warn("handler not found", sl.Err(err))
+			return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+		}
 
 		log.Error("internal error", sl.Err(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
@@ -196,7 +206,7 @@ func (h *CatHandler) UpdateCat(c *fiber.Ctx) error {
 }
 
 // @Summary Delete cat by ID
-// @Description Delete cat by ID
+// @Description Delete cat by ID. Its salary history and bonuses stay in the payroll under its name.
 // @Security ApiKeyAuth
 // @Tags Cat
 // @Accept json
@@ -233,3 +243,89 @@ func (h *CatHandler) DeleteCat(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "Cat deleted"})
 }
+
+// @Summary Get cat salary history
+// @Description Get salary history of the cat, amounts are in minor units
+// @Security ApiKeyAuth
+// @Tags Cat
+// @Accept json
+// @Produce json
+// @Param id path int true "Cat ID"
+// @Success 200 {array} domain.Salary
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /cats/{id}/salaries [get]
+func (h *CatHandler) GetSalaries(c *fiber.Ctx) error {
+	const op = "handler.GetSalaries"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	salaries, err := h.service.Salaries(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("cat not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(salaries)
+}
+
+// @Summary Change cat salary
+// @Description Record a salary change effective from the given date, amount is in minor units.
+// @Description The salary of the cat changes on that date, also when it is in the future.
+// @Security ApiKeyAuth
+// @Tags Cat
+// @Accept json
+// @Produce json
+// @Param id path int true "Cat ID"
+// @Param Salary_request body domain.SalaryRequest true "Salary data"
+// @Success 201 {integer} int "Salary ID"
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /cats/{id}/salaries [post]
+func (h *CatHandler) CreateSalary(c *fiber.Ctx) error {
+	const op = "handler.CreateSalary"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var sr domain.SalaryRequest
+	if err := c.BodyParser(&sr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(sr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	salaryID, err := h.service.SaveSalary(c.Context(), id, &sr)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("cat not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("error while saving salary", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(salaryID)
+}
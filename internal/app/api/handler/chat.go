@@ -0,0 +1,271 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/app/chat"
+	"github.com/markraiter/spycat/internal/app/service"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+const (
+	chatWriteWait  = 10 * time.Second
+	chatPongWait   = 60 * time.Second
+	chatPingPeriod = chatPongWait * 9 / 10
+	chatReadLimit  = 8 << 10
+
+	messagesPageSize    = 50
+	messagesMaxPageSize = 100
+)
+
+type ChatService interface {
+	JoinChat(ctx context.Context, userID, missionID int) (*domain.User, error)
+	SaveMessage(ctx context.Context, user *domain.User, missionID int, body string) (*domain.Message, error)
+	Messages(ctx context.Context, userID, missionID int, before int64, limit int) ([]*domain.Message, error)
+}
+
+type ChatRooms interface {
+	Join(ctx context.Context, missionID int, c *chat.Client) ([]domain.ChatUser, error)
+	Leave(ctx context.Context, missionID int, c *chat.Client) error
+	Publish(ctx context.Context, frame *domain.ChatFrame) error
+}
+
+type ChatHandler struct {
+	log     *slog.Logger
+	service ChatService
+	rooms   ChatRooms
+}
+
+// @Summary Get mission messages
+// @Description Get a page of the mission chat history, newest first.
+// @Description Pass the ID of the oldest message received as before to get the next page.
+// @Security ApiKeyAuth
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Mission ID"
+// @Param before query int false "Only messages older than the message with this ID"
+// @Param limit query int false "Page size, 50 by default, 100 at most"
+// @Success 200 {array} domain.Message
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /missions/{id}/messages [get]
+func (h *ChatHandler) GetMessages(c *fiber.Ctx) error {
+	const op = "handler.GetMessages"
+	log := h.log.With(slog.String("operation", op))
+
+	missionID, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var before int64
+	if v := c.Query("before"); v != "" {
+		if before, err = strconv.ParseInt(v, 10, 64); err != nil || before < 1 {
+			log.Warn("error while parsing query", sl.Err(err))
+			return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: "invalid before: " + v})
+		}
+	}
+
+	limit := c.QueryInt("limit", messagesPageSize)
+	if limit < 1 || limit > messagesMaxPageSize {
+		limit = messagesPageSize
+	}
+
+	messages, err := h.service.Messages(c.Context(), userID(c), missionID, before, limit)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("mission not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			log.Warn("no access to mission chat", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(messages)
+}
+
+// ChatUpgrade checks the request is a WebSocket upgrade by a user allowed
+// in the chat of the mission before the connection is upgraded.
+func (h *ChatHandler) ChatUpgrade(c *fiber.Ctx) error {
+	const op = "handler.ChatUpgrade"
+	log := h.log.With(slog.String("operation", op))
+
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(domain.Response{Message: "websocket upgrade required"})
+	}
+
+	missionID, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	user, err := h.service.JoinChat(c.Context(), userID(c), missionID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("mission not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			log.Warn("no access to mission chat", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	c.Locals("chat_user", user)
+	c.Locals("mission_id", missionID)
+
+	return c.Next()
+}
+
+// Chat serves the chat socket of a mission, see domain.ChatFrame for the protocol.
+func (h *ChatHandler) Chat(conn *websocket.Conn) {
+	const op = "handler.Chat"
+
+	user := conn.Locals("chat_user").(*domain.User)
+	missionID := conn.Locals("mission_id").(int)
+	log := h.log.With(slog.String("operation", op), slog.Int("mission_id", missionID), slog.Int("user_id", user.ID))
+
+	ctx := context.Background()
+	client := chat.NewClient(user)
+
+	users, err := h.rooms.Join(ctx, missionID, client)
+	if err != nil {
+		log.Error("error while joining chat", sl.Err(err))
+		conn.SetWriteDeadline(time.Now().Add(chatWriteWait))                                     // nolint: errcheck
+		conn.WriteJSON(&domain.ChatFrame{Type: domain.FrameError, Error: "chat is unavailable"}) // nolint: errcheck
+		h.rooms.Leave(ctx, missionID, client)                                                    // nolint: errcheck
+		return
+	}
+
+	// Replies go to this socket only, frames of the room come from the hub.
+	replies := make(chan *domain.ChatFrame, 8)
+	replies <- &domain.ChatFrame{Type: domain.FramePresence, MissionID: missionID, Users: users}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.writeFrames(conn, client, replies)
+	}()
+
+	conn.SetReadLimit(chatReadLimit)
+	conn.SetReadDeadline(time.Now().Add(chatPongWait)) // nolint: errcheck
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(chatPongWait))
+	})
+
+	for {
+		var frame domain.ChatFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Warn("chat connection closed", sl.Err(err))
+			}
+			break
+		}
+
+		if reply := h.handleFrame(ctx, user, missionID, &frame); reply != nil {
+			select {
+			case replies <- reply:
+			default:
+			}
+		}
+	}
+
+	if err := h.rooms.Leave(ctx, missionID, client); err != nil {
+		log.Error("error while leaving chat", sl.Err(err))
+	}
+	<-done
+}
+
+// handleFrame acts on a frame sent by the client and returns an error frame for the client, if any.
+func (h *ChatHandler) handleFrame(ctx context.Context, user *domain.User, missionID int, frame *domain.ChatFrame) *domain.ChatFrame {
+	const op = "handler.handleFrame"
+	log := h.log.With(slog.String("operation", op))
+
+	out := &domain.ChatFrame{Type: frame.Type, MissionID: missionID, UserID: user.ID, Username: user.Username}
+
+	switch frame.Type {
+	case domain.FrameMessage:
+		body := strings.TrimSpace(frame.Body)
+		if body == "" || utf8.RuneCountInString(body) > domain.MessageMaxLength {
+			return &domain.ChatFrame{Type: domain.FrameError, Error: fmt.Sprintf("message body must be 1 to %d characters", domain.MessageMaxLength)}
+		}
+
+		message, err := h.service.SaveMessage(ctx, user, missionID, body)
+		if err != nil {
+			log.Error("error while saving message", sl.Err(err))
+			return &domain.ChatFrame{Type: domain.FrameError, Error: "message was not saved"}
+		}
+
+		out.Message = message
+	case domain.FrameTyping:
+	default:
+		return &domain.ChatFrame{Type: domain.FrameError, Error: "unknown frame type: " + string(frame.Type)}
+	}
+
+	if err := h.rooms.Publish(ctx, out); err != nil {
+		log.Error("error while publishing chat frame", sl.Err(err))
+		return &domain.ChatFrame{Type: domain.FrameError, Error: "frame was not delivered"}
+	}
+
+	return nil
+}
+
+// writeFrames writes frames of the room and replies to the socket and keeps it alive with pings.
+// It closes the socket once the client has left the room.
+func (h *ChatHandler) writeFrames(conn *websocket.Conn, client *chat.Client, replies <-chan *domain.ChatFrame) {
+	ticker := time.NewTicker(chatPingPeriod)
+	defer ticker.Stop()
+	defer conn.Close()
+
+	frames := client.Frames()
+	for {
+		var err error
+
+		select {
+		case frame, ok := <-frames:
+			conn.SetWriteDeadline(time.Now().Add(chatWriteWait)) // nolint: errcheck
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")) // nolint: errcheck
+				return
+			}
+			err = conn.WriteJSON(frame)
+		case frame := <-replies:
+			conn.SetWriteDeadline(time.Now().Add(chatWriteWait)) // nolint: errcheck
+			err = conn.WriteJSON(frame)
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(chatWriteWait)) // nolint: errcheck
+			err = conn.WriteMessage(websocket.PingMessage, nil)
+		}
+
+		if err != nil {
+			// Unblock the reader, it leaves the room and closes the frames.
+			conn.Close()
+			for range frames {
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/app/service"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+// @Summary Log mission expense
+// @Description Log an expense against the mission, amount is in minor units of the mission currency
+// @Security ApiKeyAuth
+// @Tags Mission
+// @Accept json
+// @Produce json
+// @Param id path int true "Mission ID"
+// @Param Expense_request body domain.ExpenseRequest true "Expense data"
+// @Success 201 {object} domain.ExpenseResponse
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /missions/{id}/expenses [post]
+func (h *MissionHandler) CreateExpense(c *fiber.Ctx) error {
+	const op = "handler.CreateExpense"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var er domain.ExpenseRequest
+	if err := c.BodyParser(&er); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(er); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	resp, err := h.service.SaveExpense(c.Context(), id, &er)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("mission not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+		if errors.Is(err, service.ErrCurrencyMismatch) {
+			log.Warn("currency mismatch", sl.Err(err))
+			return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if resp.OverBudget {
+		log.Warn("mission is over budget", slog.Int("mission_id", id), slog.Int64("remaining", resp.Remaining))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+// @Summary Update mission budget
+// @Description Update budget and completion bonus of the mission, amounts are in minor units of the mission currency
+// @Security ApiKeyAuth
+// @Tags Mission
+// @Accept json
+// @Produce json
+// @Param id path int true "Mission ID"
+// @Param Budget_request body domain.BudgetRequest true "Budget data"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /missions/{id}/budget [put]
+func (h *MissionHandler) UpdateBudget(c *fiber.Ctx) error {
+	const op = "handler.UpdateBudget"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var br domain.BudgetRequest
+	if err := c.BodyParser(&br); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(br); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.UpdateBudget(c.Context(), id, &br); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("mission not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+		if errors.Is(err, service.ErrCurrencyMismatch) {
+			log.Warn("currency mismatch", sl.Err(err))
+			return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("mission budget updated: %d", id)})
+}
+
+// @Summary Get mission financials
+// @Description Get budget, expenses and bonus of the mission, amounts are in minor units
+// @Security ApiKeyAuth
+// @Tags Mission
+// @Accept json
+// @Produce json
+// @Param id path int true "Mission ID"
+// @Success 200 {object} domain.MissionFinancials
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /missions/{id}/financials [get]
+func (h *MissionHandler) GetFinancials(c *fiber.Ctx) error {
+	const op = "handler.GetFinancials"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	financials, err := h.service.Financials(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("mission not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(financials)
+}
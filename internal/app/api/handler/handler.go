@@ -2,9 +2,13 @@ package handler
 
 import (
 	"log/slog"
+	"strconv"
 
 	"github.com/go-playground/validator"
+	"github.com/gofiber/fiber/v2"
 	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/jwt"
 )
 
 type IService interface {
@@ -12,6 +16,13 @@ type IService interface {
 	CatService
 	MissionService
 	TargetService
+	PayrollService
+	SkillService
+	AuditService
+	WebhookService
+	ChatService
+	NotificationService
+	APIKeyService
 }
 
 type Handler struct {
@@ -19,6 +30,14 @@ type Handler struct {
 	CatHandler
 	MissionHandler
 	TargetHandler
+	PayrollHandler
+	SkillHandler
+	AuditHandler
+	WebhookHandler
+	StreamHandler
+	ChatHandler
+	NotificationHandler
+	APIKeyHandler
 }
 
 // New returns new instance of the Handler.
@@ -27,6 +46,9 @@ func New(
 	val *validator.Validate,
 	cfg *config.Config,
 	i IService,
+	s EventStream,
+	r ChatRooms,
+	b EventBus,
 ) *Handler {
 	return &Handler{
 		AuthHandler: AuthHandler{
@@ -50,5 +72,74 @@ func New(
 			val:     val,
 			service: i,
 		},
+		PayrollHandler: PayrollHandler{
+			log:     log,
+			service: i,
+		},
+		SkillHandler: SkillHandler{
+			log:     log,
+			val:     val,
+			service: i,
+		},
+		AuditHandler: AuditHandler{
+			log:     log,
+			service: i,
+		},
+		WebhookHandler: WebhookHandler{
+			log:     log,
+			val:     val,
+			service: i,
+		},
+		StreamHandler: StreamHandler{
+			log:    log,
+			cfg:    cfg,
+			stream: s,
+			bus:    b,
+		},
+		ChatHandler: ChatHandler{
+			log:     log,
+			service: i,
+			rooms:   r,
+		},
+		NotificationHandler: NotificationHandler{
+			log:     log,
+			val:     val,
+			service: i,
+		},
+		APIKeyHandler: APIKeyHandler{
+			log:     log,
+			val:     val,
+			service: i,
+		},
+	}
+}
+
+// userID returns the ID of the user authenticated by the middleware, or 0 if there is none.
+func userID(c *fiber.Ctx) int {
+	claims, ok := c.Locals("uid").(*jwt.TokenClaims)
+	if !ok {
+		return 0
 	}
+
+	id, err := strconv.Atoi(claims.UID)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+// sessionID returns the session of the access token of the request, or 0 for an API key.
+func sessionID(c *fiber.Ctx) int64 {
+	claims, ok := c.Locals("uid").(*jwt.TokenClaims)
+	if !ok {
+		return 0
+	}
+
+	return claims.SessionID
+}
+
+// client returns where the request comes from, for the session a login starts.
+func client(c *fiber.Ctx) domain.Client {
+	return domain.Client{IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)}
 }
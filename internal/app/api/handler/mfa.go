@@ -0,0 +1,293 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/app/service"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+// @Summary Login with second factor
+// @Description Answer the challenge of /auth/login with a TOTP code or a recovery code.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param MFA_login_request body domain.MFALoginRequest true "MFA token and code"
+// @Success 200 {string} string "Token"
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 429 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/login/mfa [post]
+func (h *AuthHandler) LoginMFA(c *fiber.Ctx) error {
+	const op = "handler.LoginMFA"
+	log := h.log.With(slog.String("operation", op))
+
+	var mr domain.MFALoginRequest
+	if err := c.BodyParser(&mr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(mr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	token, err := h.service.LoginMFA(c.UserContext(), h.cfg.Auth, &mr, client(c))
+	if err != nil {
+		return h.mfaError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(token)
+}
+
+// @Summary Enroll in TOTP
+// @Description Generate a TOTP secret for the current user. Add it to an authenticator app
+// @Description from the URI or the QR code, then confirm it at /auth/mfa/totp/activate.
+// @Description Enrolling again replaces a pending secret.
+// @Security ApiKeyAuth
+// @Tags MFA
+// @Produce json
+// @Success 201 {object} domain.TOTPEnrollment
+// @Failure 409 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/mfa/totp [post]
+func (h *AuthHandler) EnrollTOTP(c *fiber.Ctx) error {
+	const op = "handler.EnrollTOTP"
+	log := h.log.With(slog.String("operation", op))
+
+	enrollment, err := h.service.EnrollTOTP(c.Context(), h.cfg.Auth, userID(c))
+	if err != nil {
+		return h.mfaError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(enrollment)
+}
+
+// @Summary Get TOTP QR code
+// @Description Get the pending TOTP enrollment of the current user as a PNG QR code.
+// @Security ApiKeyAuth
+// @Tags MFA
+// @Produce png
+// @Success 200 {file} binary
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/mfa/totp/qr [get]
+func (h *AuthHandler) GetTOTPQRCode(c *fiber.Ctx) error {
+	const op = "handler.GetTOTPQRCode"
+	log := h.log.With(slog.String("operation", op))
+
+	png, err := h.service.TOTPQRCode(c.Context(), h.cfg.Auth, userID(c))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: "no pending TOTP enrollment"})
+		}
+		return h.mfaError(c, log, err)
+	}
+
+	c.Set(fiber.HeaderContentType, "image/png")
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	return c.Status(fiber.StatusOK).Send(png)
+}
+
+// @Summary Activate TOTP
+// @Description Confirm the pending TOTP enrollment with a code from the authenticator app.
+// @Description The recovery codes are returned this once, each can replace a code one time.
+// @Security ApiKeyAuth
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param Code_request body domain.CodeRequest true "TOTP code"
+// @Success 200 {object} domain.RecoveryCodes
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 409 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/mfa/totp/activate [post]
+func (h *AuthHandler) ActivateTOTP(c *fiber.Ctx) error {
+	const op = "handler.ActivateTOTP"
+	log := h.log.With(slog.String("operation", op))
+
+	cr, err := h.codeRequest(c, log)
+	if cr == nil {
+		return err
+	}
+
+	codes, err := h.service.ActivateTOTP(c.Context(), userID(c), cr.Code)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: "no pending TOTP enrollment"})
+		}
+		return h.mfaError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(codes)
+}
+
+// @Summary Disable TOTP
+// @Description Turn TOTP off for the current user, a TOTP code or a recovery code is required.
+// @Security ApiKeyAuth
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param Code_request body domain.CodeRequest true "TOTP or recovery code"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 409 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/mfa/totp/disable [post]
+func (h *AuthHandler) DisableTOTP(c *fiber.Ctx) error {
+	const op = "handler.DisableTOTP"
+	log := h.log.With(slog.String("operation", op))
+
+	cr, err := h.codeRequest(c, log)
+	if cr == nil {
+		return err
+	}
+
+	if err := h.service.DisableTOTP(c.Context(), userID(c), cr.Code); err != nil {
+		return h.mfaError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "two-factor authentication disabled"})
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replace the recovery codes of the current user, a TOTP code or a recovery code is required.
+// @Security ApiKeyAuth
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param Code_request body domain.CodeRequest true "TOTP or recovery code"
+// @Success 200 {object} domain.RecoveryCodes
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 409 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/mfa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	const op = "handler.RegenerateRecoveryCodes"
+	log := h.log.With(slog.String("operation", op))
+
+	cr, err := h.codeRequest(c, log)
+	if cr == nil {
+		return err
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(c.Context(), userID(c), cr.Code)
+	if err != nil {
+		return h.mfaError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(codes)
+}
+
+// @Summary Get MFA policies
+// @Description Get whether two-factor authentication is required, per role.
+// @Security ApiKeyAuth
+// @Tags MFA
+// @Produce json
+// @Success 200 {array} domain.MFAPolicy
+// @Failure 500 {object} domain.Response
+// @Router /auth/mfa/policies [get]
+func (h *AuthHandler) GetMFAPolicies(c *fiber.Ctx) error {
+	const op = "handler.GetMFAPolicies"
+	log := h.log.With(slog.String("operation", op))
+
+	policies, err := h.service.MFAPolicies(c.Context())
+	if err != nil {
+		log.Error("error while getting MFA policies", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(policies)
+}
+
+// @Summary Set MFA policy
+// @Description Require or stop requiring two-factor authentication for a role, admins only.
+// @Description Users of the role without TOTP can then only use the MFA routes until they enroll.
+// @Security ApiKeyAuth
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param Policy_request body domain.MFAPolicy true "Policy"
+// @Success 200 {object} domain.MFAPolicy
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/mfa/policies [put]
+func (h *AuthHandler) SetMFAPolicy(c *fiber.Ctx) error {
+	const op = "handler.SetMFAPolicy"
+	log := h.log.With(slog.String("operation", op))
+
+	var policy domain.MFAPolicy
+	if err := c.BodyParser(&policy); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(policy); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.SetMFAPolicy(c.Context(), userID(c), &policy); err != nil {
+		return h.mfaError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(policy)
+}
+
+// codeRequest parses and validates a CodeRequest, on failure it writes the
+// response and returns nil with the error of writing it.
+func (h *AuthHandler) codeRequest(c *fiber.Ctx, log *slog.Logger) (*domain.CodeRequest, error) {
+	var cr domain.CodeRequest
+	if err := c.BodyParser(&cr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return nil, c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(cr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return nil, c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return &cr, nil
+}
+
+// mfaError writes the response of an error of the MFA service methods.
+func (h *AuthHandler) mfaError(c *fiber.Ctx, log *slog.Logger, err error) error {
+	switch {
+	case errors.Is(err, service.ErrTooManyAttempts):
+		log.Warn("login held back", sl.Err(err), slog.String("ip", c.IP()))
+		return tooManyAttempts(c, err)
+	case errors.Is(err, service.ErrInvalidToken):
+		log.Warn("invalid MFA token", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: service.ErrInvalidToken.Error()})
+	case errors.Is(err, service.ErrInvalidMFACode):
+		log.Warn("invalid MFA code", sl.Err(err))
+		return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrInvalidMFACode.Error()})
+	case errors.Is(err, service.ErrForbidden):
+		log.Warn("forbidden", sl.Err(err))
+		return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrForbidden.Error()})
+	case errors.Is(err, service.ErrMFAEnabled):
+		return c.Status(fiber.StatusConflict).JSON(domain.Response{Message: service.ErrMFAEnabled.Error()})
+	case errors.Is(err, service.ErrMFANotEnabled):
+		return c.Status(fiber.StatusConflict).JSON(domain.Response{Message: service.ErrMFANotEnabled.Error()})
+	}
+
+	log.Error("internal error", sl.Err(err))
+	return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+}
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator"
 	"github.com/gofiber/fiber/v2"
@@ -15,11 +18,19 @@ import (
 
 type MissionService interface {
 	SaveMission(ctx context.Context, mr *domain.MissionRequest) (int, error)
-	Missions(ctx context.Context) ([]*domain.Mission, error)
+	Missions(ctx context.Context, filter domain.MissionFilter) ([]*domain.Mission, error)
 	MissionByID(ctx context.Context, id int) (*domain.Mission, error)
-	AssignMissionToCat(ctx context.Context, catID, missionID int) error
+	MissionFrom(ctx context.Context, id int, from domain.GeoPoint) (*domain.Mission, error)
+	MissionGeoJSON(ctx context.Context, id int, layers ...string) (*domain.FeatureCollection, error)
+	AssignMissionToCat(ctx context.Context, userID, catID, missionID int, override bool) ([]domain.SkillGap, error)
 	CompleteMission(ctx context.Context, id int) error
 	DeleteMission(ctx context.Context, id int) error
+	SaveExpense(ctx context.Context, missionID int, er *domain.ExpenseRequest) (*domain.ExpenseResponse, error)
+	UpdateBudget(ctx context.Context, missionID int, br *domain.BudgetRequest) error
+	Financials(ctx context.Context, missionID int) (*domain.MissionFinancials, error)
+	Candidates(ctx context.Context, missionID int) ([]domain.Candidate, error)
+	ProposeAssignments(ctx context.Context) (*domain.AssignmentProposal, error)
+	UpdateSchedule(ctx context.Context, id int, sr *domain.ScheduleRequest) error
 }
 
 type MissionHandler struct {
@@ -29,7 +40,8 @@ type MissionHandler struct {
 }
 
 // @Summary Create mission
-// @Description Create mission
+// @Description Create mission. Targets with an id are registered targets the mission is linked to,
+// @Description the others are registered with the mission.
 // @Security ApiKeyAuth
 // @Tags Mission
 // @Accept json
@@ -38,6 +50,7 @@ type MissionHandler struct {
 // @Success 201 {integer} int "Mission ID"
 // @Failure 400 {object} domain.Response
 // @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
 // @Failure 406 {object} domain.Response
 // @Failure 500 {object} domain.Response
 // @Router /missions [post]
@@ -57,6 +70,18 @@ func (h *MissionHandler) CreateMission(c *fiber.Ctx) error {
 			log.Warn("too many targets", sl.Err(err))
 			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: err.Error()})
 		}
+		if errors.Is(err, service.ErrInvalidSchedule) {
+			log.Warn("invalid schedule", sl.Err(err))
+			return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+		}
+		if errors.Is(err, service.ErrInvalidLocation) {
+			log.Warn("invalid target location", sl.Err(err))
+			return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+		}
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("target not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: "target not found"})
+		}
 
 		log.Warn("internal error", sl.Err(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
@@ -66,19 +91,34 @@ func (h *MissionHandler) CreateMission(c *fiber.Ctx) error {
 }
 
 // @Summary Get missions
-// @Description Get missions
+// @Description Get missions, optionally filtered and sorted
 // @Security ApiKeyAuth
 // @Tags Mission
 // @Accept json
 // @Produce json
+// @Param priority query int false "Priority (1-5)"
+// @Param completed query bool false "Completed"
+// @Param overdue query bool false "Overdue"
+// @Param due_before query string false "Due before (RFC 3339)"
+// @Param due_after query string false "Due at or after (RFC 3339)"
+// @Param sort query string false "Sort by" Enums(created_at, priority, starts_at, due_at)
+// @Param order query string false "Sort order" Enums(asc, desc)
+// @Param from query string false "Reference point as lat,lng, located targets get their distance from it"
 // @Success 200 {array} domain.Mission "Missions"
+// @Failure 400 {object} domain.Response
 // @Failure 500 {object} domain.Response
 // @Router /missions [get]
 func (h *MissionHandler) GetMissions(c *fiber.Ctx) error {
 	const op = "handler.GetMissions"
 	log := h.log.With(slog.String("operation", op))
 
-	missions, err := h.service.Missions(c.Context())
+	filter, err := missionFilter(c)
+	if err != nil {
+		log.Warn("error while parsing query", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	missions, err := h.service.Missions(c.Context(), filter)
 	if err != nil {
 		log.Warn("internal error", sl.Err(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
@@ -94,6 +134,7 @@ func (h *MissionHandler) GetMissions(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Param id path int true "Mission ID"
+// @Param from query string false "Reference point as lat,lng, located targets get their distance from it"
 // @Success 200 {object} domain.Mission "Mission"
 // @Failure 400 {object} domain.Response
 // @Failure 404 {object} domain.Response
@@ -109,7 +150,18 @@ func (h *MissionHandler) GetMission(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
 	}
 
-	mission, err := h.service.MissionByID(c.Context(), id)
+	from, err := geoPoint(c, "from")
+	if err != nil {
+		log.Warn("error while parsing query", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var mission *domain.Mission
+	if from != nil {
+		mission, err = h.service.MissionFrom(c.Context(), id, *from)
+	} else {
+		mission, err = h.service.MissionByID(c.Context(), id)
+	}
 	if err != nil {
 		if errors.Is(err, service.ErrNotFound) {
 			log.Warn("mission not found", sl.Err(err))
@@ -123,6 +175,49 @@ func (h *MissionHandler) GetMission(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(mission)
 }
 
+// @Summary Get mission targets as GeoJSON
+// @Description Get the targets of the mission as a GeoJSON feature collection for map tools,
+// @Description with the same layers as /targets.geojson.
+// @Security ApiKeyAuth
+// @Tags Mission
+// @Produce application/geo+json
+// @Param id path int true "Mission ID"
+// @Param layer query string false "Layer, both by default" Enums(targets, countries)
+// @Success 200 {object} domain.FeatureCollection
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /missions/{id}.geojson [get]
+func (h *MissionHandler) GetMissionGeoJSON(c *fiber.Ctx) error {
+	const op = "handler.GetMissionGeoJSON"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	layers, err := geoJSONLayers(c)
+	if err != nil {
+		log.Warn("error while parsing query", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	fc, err := h.service.MissionGeoJSON(c.Context(), id, layers...)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("mission not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Warn("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fc, mimeGeoJSON)
+}
+
 // @Summary Assign mission to cat
 // @Description Assign mission to cat
 // @Security ApiKeyAuth
@@ -131,6 +226,7 @@ func (h *MissionHandler) GetMission(c *fiber.Ctx) error {
 // @Produce json
 // @Param cat_id path int true "Cat ID"
 // @Param mission_id path int true "Mission ID"
+// @Param override query bool false "Assign even if the cat lacks required skills"
 // @Success 200 {object} domain.Response
 // @Failure 400 {object} domain.Response
 // @Failure 403 {object} domain.Response
@@ -153,22 +249,33 @@ func (h *MissionHandler) AssignMissionToCat(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
 	}
 
-	err = h.service.AssignMissionToCat(c.Context(), catID, missionID)
+	gaps, err := h.service.AssignMissionToCat(c.Context(), userID(c), catID, missionID, c.QueryBool("override"))
 	if err != nil {
 		if errors.Is(err, service.ErrNotFound) {
 			log.Warn("mission or cat not found", sl.Err(err))
 			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
 		}
+		if errors.Is(err, service.ErrMissingSkills) {
+			log.Warn("cat lacks required skills", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: fmt.Sprintf("%s: %s", err.Error(), skillGaps(gaps))})
+		}
 
 		log.Warn("internal error", sl.Err(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
 	}
 
+	if len(gaps) > 0 {
+		log.Warn("skill requirements overridden", slog.Int("mission_id", missionID), slog.Int("cat_id", catID))
+		return c.Status(fiber.StatusOK).JSON(domain.Response{
+			Message: fmt.Sprintf("mission assigned to cat: %d, skill requirements overridden: %s", catID, skillGaps(gaps)),
+		})
+	}
+
 	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("mission assigned to cat: %d", catID)})
 }
 
 // @Summary Complete mission
-// @Description Complete mission
+// @Description Complete mission and pay its bonus to the assigned cat, a completed mission cannot be completed again
 // @Security ApiKeyAuth
 // @Tags Mission
 // @Accept json
@@ -176,6 +283,7 @@ func (h *MissionHandler) AssignMissionToCat(c *fiber.Ctx) error {
 // @Param id path int true "Mission ID"
 // @Success 200 {object} domain.Response
 // @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
 // @Failure 404 {object} domain.Response
 // @Failure 500 {object} domain.Response
 // @Router /missions/{id} [patch]
@@ -195,6 +303,10 @@ func (h *MissionHandler) CompleteMission(c *fiber.Ctx) error {
 			log.Warn("mission not found", sl.Err(err))
 			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
 		}
+		if errors.Is(err, service.ErrMissionCompleted) {
+			log.Warn("mission completed", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: err.Error()})
+		}
 
 		log.Warn("internal error", sl.Err(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
@@ -237,3 +349,176 @@ func (h *MissionHandler) DeleteMission(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("mission deleted: %d", id)})
 }
+
+func skillGaps(gaps []domain.SkillGap) string {
+	parts := make([]string, 0, len(gaps))
+	for _, g := range gaps {
+		parts = append(parts, fmt.Sprintf("%s (required %d, has %d)", g.Name, g.Required, g.Actual))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// @Summary Get mission candidates
+// @Description Rank the cats not on an active mission by experience, skills match, workload, salary cost and success rate
+// @Security ApiKeyAuth
+// @Tags Mission
+// @Accept json
+// @Produce json
+// @Param id path int true "Mission ID"
+// @Success 200 {array} domain.Candidate
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /missions/{id}/candidates [get]
+func (h *MissionHandler) GetCandidates(c *fiber.Ctx) error {
+	const op = "handler.GetCandidates"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	candidates, err := h.service.Candidates(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("mission not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Warn("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(candidates)
+}
+
+// @Summary Propose mission assignments
+// @Description Propose an optimal assignment of all unassigned missions to cats not on an active mission without committing it
+// @Security ApiKeyAuth
+// @Tags Mission
+// @Accept json
+// @Produce json
+// @Success 200 {object} domain.AssignmentProposal
+// @Failure 500 {object} domain.Response
+// @Router /missions/assignments/proposal [get]
+func (h *MissionHandler) GetAssignmentProposal(c *fiber.Ctx) error {
+	const op = "handler.GetAssignmentProposal"
+	log := h.log.With(slog.String("operation", op))
+
+	proposal, err := h.service.ProposeAssignments(c.Context())
+	if err != nil {
+		log.Warn("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(proposal)
+}
+
+// @Summary Update mission schedule
+// @Description Update priority, start and due dates of the mission, the overdue flag is re-evaluated and a mission.rescheduled event recorded
+// @Security ApiKeyAuth
+// @Tags Mission
+// @Accept json
+// @Produce json
+// @Param id path int true "Mission ID"
+// @Param Schedule_request body domain.ScheduleRequest true "Schedule data"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /missions/{id}/schedule [put]
+func (h *MissionHandler) UpdateSchedule(c *fiber.Ctx) error {
+	const op = "handler.UpdateSchedule"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var sr domain.ScheduleRequest
+	if err := c.BodyParser(&sr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(sr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.UpdateSchedule(c.Context(), id, &sr); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("mission not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+		if errors.Is(err, service.ErrInvalidSchedule) {
+			log.Warn("invalid schedule", sl.Err(err))
+			return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Warn("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("mission schedule updated: %d", id)})
+}
+
+func missionFilter(c *fiber.Ctx) (domain.MissionFilter, error) {
+	var filter domain.MissionFilter
+
+	if v := c.Query("priority"); v != "" {
+		priority, err := strconv.Atoi(v)
+		if err != nil || priority < domain.PriorityLowest || priority > domain.PriorityHighest {
+			return filter, fmt.Errorf("invalid priority: %s", v)
+		}
+		filter.Priority = priority
+	}
+
+	for name, dst := range map[string]**bool{"completed": &filter.Completed, "overdue": &filter.Overdue} {
+		if v := c.Query(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %s", name, v)
+			}
+			*dst = &b
+		}
+	}
+
+	for name, dst := range map[string]**time.Time{"due_before": &filter.DueBefore, "due_after": &filter.DueAfter} {
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %s", name, v)
+			}
+			*dst = &t
+		}
+	}
+
+	switch filter.Sort = c.Query("sort"); filter.Sort {
+	case "", "created_at", "priority", "starts_at", "due_at":
+	default:
+		return filter, fmt.Errorf("invalid sort: %s", filter.Sort)
+	}
+
+	switch order := c.Query("order"); order {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return filter, fmt.Errorf("invalid order: %s", order)
+	}
+
+	from, err := geoPoint(c, "from")
+	if err != nil {
+		return filter, err
+	}
+	filter.From = from
+
+	return filter, nil
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/go-playground/validator"
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+type NotificationService interface {
+	NotificationPreferences(ctx context.Context, userID int) ([]domain.NotificationPreference, error)
+	UpdateNotificationPreferences(ctx context.Context, userID int, nr *domain.NotificationPreferencesRequest) ([]domain.NotificationPreference, error)
+}
+
+type NotificationHandler struct {
+	log     *slog.Logger
+	val     *validator.Validate
+	service NotificationService
+}
+
+// @Summary Get notification preferences
+// @Description Get the email notification preferences of the current user, one per event type.
+// @Security ApiKeyAuth
+// @Tags Notification
+// @Accept json
+// @Produce json
+// @Success 200 {array} domain.NotificationPreference
+// @Failure 500 {object} domain.Response
+// @Router /notifications/preferences [get]
+func (h *NotificationHandler) GetNotificationPreferences(c *fiber.Ctx) error {
+	const op = "handler.GetNotificationPreferences"
+	log := h.log.With(slog.String("operation", op))
+
+	preferences, err := h.service.NotificationPreferences(c.Context(), userID(c))
+	if err != nil {
+		log.Error("error while getting notification preferences", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(preferences)
+}
+
+// @Summary Update notification preferences
+// @Description Turn email notifications of the current user on or off per event type.
+// @Description Event types left out of the request keep their preference.
+// @Security ApiKeyAuth
+// @Tags Notification
+// @Accept json
+// @Produce json
+// @Param Preferences_request body domain.NotificationPreferencesRequest true "Preferences"
+// @Success 200 {array} domain.NotificationPreference
+// @Failure 400 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /notifications/preferences [put]
+func (h *NotificationHandler) UpdateNotificationPreferences(c *fiber.Ctx) error {
+	const op = "handler.UpdateNotificationPreferences"
+	log := h.log.With(slog.String("operation", op))
+
+	var nr domain.NotificationPreferencesRequest
+	if err := c.BodyParser(&nr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(nr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	preferences, err := h.service.UpdateNotificationPreferences(c.Context(), userID(c), &nr)
+	if err != nil {
+		log.Error("error while updating notification preferences", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(preferences)
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/app/service"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+// oidcCookie holds the signed login flow from /auth/oidc/login to the callback.
+const (
+	oidcCookie     = "spycat_oidc"
+	oidcCookiePath = "/api/v1/auth/oidc"
+)
+
+// @Summary Login with the identity provider
+// @Description Redirect to the OpenID Connect provider to sign in there, it redirects back
+// @Description to /auth/oidc/callback. Open it in the browser, the flow is kept in a cookie.
+// @Tags Auth
+// @Success 302
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/oidc/login [get]
+func (h *AuthHandler) OIDCLogin(c *fiber.Ctx) error {
+	const op = "handler.OIDCLogin"
+	log := h.log.With(slog.String("operation", op))
+
+	redirect, err := h.service.StartOIDC(c.UserContext(), h.cfg.OIDC)
+	if err != nil {
+		if errors.Is(err, service.ErrOIDCDisabled) {
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: service.ErrOIDCDisabled.Error()})
+		}
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	h.setOIDCCookie(c, redirect.FlowToken, time.Now().Add(h.cfg.OIDC.StateTTL))
+
+	return c.Redirect(redirect.URL, fiber.StatusFound)
+}
+
+// @Summary Identity provider callback
+// @Description The OpenID Connect provider redirects here after the login. The user is linked
+// @Description by email or provisioned, and gets a token like from /auth/login, or a challenge
+// @Description to answer at /auth/login/mfa when two-factor authentication is enabled.
+// @Tags Auth
+// @Produce json
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Success 200 {string} string "Token"
+// @Success 202 {object} domain.MFAChallenge
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /auth/oidc/callback [get]
+func (h *AuthHandler) OIDCCallback(c *fiber.Ctx) error {
+	const op = "handler.OIDCCallback"
+	log := h.log.With(slog.String("operation", op))
+
+	flowToken := c.Cookies(oidcCookie)
+	// The flow is used once, whatever the outcome.
+	h.setOIDCCookie(c, "", time.Unix(0, 0))
+
+	if idpErr := c.Query("error"); idpErr != "" {
+		log.Warn("identity provider refused the login", slog.String("error", idpErr), slog.String("description", c.Query("error_description")))
+		return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: "identity provider: " + idpErr})
+	}
+
+	var cb domain.OIDCCallback
+	if err := c.QueryParser(&cb); err != nil {
+		log.Warn("error while parsing query", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(cb); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	result, err := h.service.LoginOIDC(c.UserContext(), h.cfg.Auth, h.cfg.OIDC, flowToken, &cb, client(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOIDCDisabled):
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: service.ErrOIDCDisabled.Error()})
+		case errors.Is(err, service.ErrInvalidToken):
+			log.Warn("invalid login flow", sl.Err(err))
+			return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: "login expired or started in another browser, sign in again"})
+		case errors.Is(err, service.ErrInvalidCredentials):
+			log.Warn("identity provider login rejected", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrInvalidCredentials.Error()})
+		case errors.Is(err, service.ErrEmailNotVerified):
+			log.Warn("email not verified by the identity provider", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrEmailNotVerified.Error()})
+		case errors.Is(err, service.ErrForbidden):
+			log.Warn("no role for the groups of the user", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrForbidden.Error()})
+		case errors.Is(err, service.ErrAccountDisabled):
+			log.Warn("account disabled", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrAccountDisabled.Error()})
+		}
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if result.Challenge != nil {
+		return c.Status(fiber.StatusAccepted).JSON(result.Challenge)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result.Token)
+}
+
+// setOIDCCookie sets the flow cookie, Lax so the browser sends it on the redirect back from the provider.
+func (h *AuthHandler) setOIDCCookie(c *fiber.Ctx, value string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcCookie,
+		Value:    value,
+		Path:     oidcCookiePath,
+		Expires:  expires,
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
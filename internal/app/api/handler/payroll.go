@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/app/service"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/money"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+type PayrollService interface {
+	Payroll(ctx context.Context, period string) (*domain.Payroll, error)
+}
+
+type PayrollHandler struct {
+	log     *slog.Logger
+	service PayrollService
+}
+
+// @Summary Get payroll
+// @Description Get payroll of all active cats for a calendar month, amounts are in minor units.
+// @Description With format=csv the payroll is exported as CSV with decimal amounts.
+// @Security ApiKeyAuth
+// @Tags Payroll
+// @Produce json
+// @Produce text/csv
+// @Param period query string true "Period in YYYY-MM format"
+// @Param format query string false "Response format" Enums(json, csv)
+// @Success 200 {object} domain.Payroll
+// @Failure 400 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /payroll [get]
+func (h *PayrollHandler) GetPayroll(c *fiber.Ctx) error {
+	const op = "handler.GetPayroll"
+	log := h.log.With(slog.String("operation", op))
+
+	payroll, err := h.service.Payroll(c.Context(), c.Query("period"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPeriod) {
+			log.Warn("invalid period", sl.Err(err))
+			return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if c.Query("format") != "csv" {
+		return c.Status(fiber.StatusOK).JSON(payroll)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"payroll-%s.csv\"", payroll.Period))
+
+	w := csv.NewWriter(c.Status(fiber.StatusOK))
+	w.Write([]string{"cat_id", "cat_name", "currency", "days", "base", "bonus", "total"}) // nolint: errcheck
+	for _, e := range payroll.Entries {
+		w.Write([]string{ // nolint: errcheck
+			strconv.Itoa(e.CatID),
+			e.CatName,
+			e.Currency,
+			strconv.Itoa(e.Days),
+			money.Format(e.Base, e.Currency),
+			money.Format(e.Bonus, e.Currency),
+			money.Format(e.Total, e.Currency),
+		})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		log.Error("error while writing csv", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return nil
+}
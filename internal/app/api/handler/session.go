@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/app/service"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+// @Summary Get sessions
+// @Description Get where the current user is signed in: the sessions in use, the last seen first.
+// @Description The session of the request is marked current.
+// @Security ApiKeyAuth
+// @Tags Session
+// @Produce json
+// @Success 200 {array} domain.Session
+// @Failure 500 {object} domain.Response
+// @Router /me/sessions [get]
+func (h *AuthHandler) GetSessions(c *fiber.Ctx) error {
+	const op = "handler.GetSessions"
+	log := h.log.With(slog.String("operation", op))
+
+	sessions, err := h.service.Sessions(c.UserContext(), userID(c), sessionID(c))
+	if err != nil {
+		log.Error("error while getting sessions", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(sessions)
+}
+
+// @Summary Revoke session
+// @Description Sign a session of the current user out, its tokens stop working at once.
+// @Security ApiKeyAuth
+// @Tags Session
+// @Produce json
+// @Param id path int true "Session ID"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /me/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	const op = "handler.RevokeSession"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		log.Warn("invalid session id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: "invalid session id"})
+	}
+
+	if err := h.service.RevokeSession(c.UserContext(), userID(c), id); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: "session not found"})
+		}
+		log.Error("error while revoking session", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "session revoked"})
+}
+
+// @Summary Log out everywhere
+// @Description Sign the current user out of every session, the one of the request included.
+// @Security ApiKeyAuth
+// @Tags Session
+// @Produce json
+// @Success 200 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /me/sessions [delete]
+func (h *AuthHandler) RevokeSessions(c *fiber.Ctx) error {
+	const op = "handler.RevokeSessions"
+	log := h.log.With(slog.String("operation", op))
+
+	n, err := h.service.RevokeSessions(c.UserContext(), userID(c))
+	if err != nil {
+		log.Error("error while revoking sessions", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: strconv.Itoa(n) + " sessions revoked"})
+}
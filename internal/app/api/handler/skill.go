@@ -0,0 +1,386 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-playground/validator"
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/app/service"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+type SkillService interface {
+	SaveSkill(ctx context.Context, sr *domain.SkillRequest) (int, error)
+	Skills(ctx context.Context) ([]*domain.Skill, error)
+	SetCatSkill(ctx context.Context, catID, skillID, level int) error
+	DeleteCatSkill(ctx context.Context, catID, skillID int) error
+	SetMissionSkill(ctx context.Context, missionID, skillID, minLevel int) error
+	DeleteMissionSkill(ctx context.Context, missionID, skillID int) error
+	SetTargetSkill(ctx context.Context, targetID, skillID, minLevel int) error
+	DeleteTargetSkill(ctx context.Context, targetID, skillID int) error
+}
+
+type SkillHandler struct {
+	log     *slog.Logger
+	val     *validator.Validate
+	service SkillService
+}
+
+// @Summary Create skill
+// @Description Add a skill to the skills catalog
+// @Security ApiKeyAuth
+// @Tags Skill
+// @Accept json
+// @Produce json
+// @Param Create_skill_request body domain.SkillRequest true "Skill data"
+// @Success 201 {integer} int "Skill ID"
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /skills [post]
+func (h *SkillHandler) CreateSkill(c *fiber.Ctx) error {
+	const op = "handler.CreateSkill"
+	log := h.log.With(slog.String("operation", op))
+
+	var sr domain.SkillRequest
+	if err := c.BodyParser(&sr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(sr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	id, err := h.service.SaveSkill(c.Context(), &sr)
+	if err != nil {
+		if errors.Is(err, service.ErrAlreadyExists) {
+			log.Warn("skill already exists", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("error while saving skill", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(id)
+}
+
+// @Summary Get skills
+// @Description Get the skills catalog
+// @Security ApiKeyAuth
+// @Tags Skill
+// @Accept json
+// @Produce json
+// @Success 200 {array} domain.Skill
+// @Failure 500 {object} domain.Response
+// @Router /skills [get]
+func (h *SkillHandler) GetSkills(c *fiber.Ctx) error {
+	const op = "handler.GetSkills"
+	log := h.log.With(slog.String("operation", op))
+
+	skills, err := h.service.Skills(c.Context())
+	if err != nil {
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(skills)
+}
+
+// @Summary Set cat skill
+// @Description Set proficiency level (1-5) of the cat in the skill
+// @Security ApiKeyAuth
+// @Tags Skill
+// @Accept json
+// @Produce json
+// @Param id path int true "Cat ID"
+// @Param skill_id path int true "Skill ID"
+// @Param Skill_level_request body domain.SkillLevelRequest true "Proficiency level"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /cats/{id}/skills/{skill_id} [put]
+func (h *SkillHandler) SetCatSkill(c *fiber.Ctx) error {
+	const op = "handler.SetCatSkill"
+	log := h.log.With(slog.String("operation", op))
+
+	catID, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	skillID, err := c.ParamsInt("skill_id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var lr domain.SkillLevelRequest
+	if err := c.BodyParser(&lr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(lr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.SetCatSkill(c.Context(), catID, skillID, lr.Level); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("cat or skill not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("skill %d of cat %d set to level %d", skillID, catID, lr.Level)})
+}
+
+// @Summary Delete cat skill
+// @Description Remove the skill from the cat
+// @Security ApiKeyAuth
+// @Tags Skill
+// @Accept json
+// @Produce json
+// @Param id path int true "Cat ID"
+// @Param skill_id path int true "Skill ID"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /cats/{id}/skills/{skill_id} [delete]
+func (h *SkillHandler) DeleteCatSkill(c *fiber.Ctx) error {
+	const op = "handler.DeleteCatSkill"
+	log := h.log.With(slog.String("operation", op))
+
+	catID, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	skillID, err := c.ParamsInt("skill_id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.DeleteCatSkill(c.Context(), catID, skillID); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("cat skill not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("skill %d removed from cat %d", skillID, catID)})
+}
+
+// @Summary Set mission required skill
+// @Description Require a minimal proficiency level (1-5) in the skill for the mission
+// @Security ApiKeyAuth
+// @Tags Skill
+// @Accept json
+// @Produce json
+// @Param id path int true "Mission ID"
+// @Param skill_id path int true "Skill ID"
+// @Param Skill_level_request body domain.SkillLevelRequest true "Minimal proficiency level"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /missions/{id}/skills/{skill_id} [put]
+func (h *SkillHandler) SetMissionSkill(c *fiber.Ctx) error {
+	const op = "handler.SetMissionSkill"
+	log := h.log.With(slog.String("operation", op))
+
+	missionID, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	skillID, err := c.ParamsInt("skill_id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var lr domain.SkillLevelRequest
+	if err := c.BodyParser(&lr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(lr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.SetMissionSkill(c.Context(), missionID, skillID, lr.Level); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("mission or skill not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("mission %d requires skill %d at level %d", missionID, skillID, lr.Level)})
+}
+
+// @Summary Delete mission required skill
+// @Description Remove the skill requirement from the mission
+// @Security ApiKeyAuth
+// @Tags Skill
+// @Accept json
+// @Produce json
+// @Param id path int true "Mission ID"
+// @Param skill_id path int true "Skill ID"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /missions/{id}/skills/{skill_id} [delete]
+func (h *SkillHandler) DeleteMissionSkill(c *fiber.Ctx) error {
+	const op = "handler.DeleteMissionSkill"
+	log := h.log.With(slog.String("operation", op))
+
+	missionID, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	skillID, err := c.ParamsInt("skill_id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.DeleteMissionSkill(c.Context(), missionID, skillID); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("mission skill not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("skill %d removed from mission %d", skillID, missionID)})
+}
+
+// @Summary Set target required skill
+// @Description Require a minimal proficiency level (1-5) in the skill of the cat of every mission the target is part of
+// @Security ApiKeyAuth
+// @Tags Skill
+// @Accept json
+// @Produce json
+// @Param id path int true "Target ID"
+// @Param skill_id path int true "Skill ID"
+// @Param Skill_level_request body domain.SkillLevelRequest true "Minimal proficiency level"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/{id}/skills/{skill_id} [put]
+func (h *SkillHandler) SetTargetSkill(c *fiber.Ctx) error {
+	const op = "handler.SetTargetSkill"
+	log := h.log.With(slog.String("operation", op))
+
+	targetID, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	skillID, err := c.ParamsInt("skill_id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var lr domain.SkillLevelRequest
+	if err := c.BodyParser(&lr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(lr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.SetTargetSkill(c.Context(), targetID, skillID, lr.Level); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("target or skill not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("target %d requires skill %d at level %d", targetID, skillID, lr.Level)})
+}
+
+// @Summary Delete target required skill
+// @Description Remove the skill requirement from the target
+// @Security ApiKeyAuth
+// @Tags Skill
+// @Accept json
+// @Produce json
+// @Param id path int true "Target ID"
+// @Param skill_id path int true "Skill ID"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/{id}/skills/{skill_id} [delete]
+func (h *SkillHandler) DeleteTargetSkill(c *fiber.Ctx) error {
+	const op = "handler.DeleteTargetSkill"
+	log := h.log.With(slog.String("operation", op))
+
+	targetID, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	skillID, err := c.ParamsInt("skill_id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.DeleteTargetSkill(c.Context(), targetID, skillID); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("target skill not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+
+		log.Error("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("skill %d removed from target %d", skillID, targetID)})
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+type EventStream interface {
+	Subscribe(lastID int64) (missed []*domain.Event, events <-chan *domain.Event, cancel func())
+}
+
+type EventBus interface {
+	Metrics() *domain.BusMetrics
+}
+
+type StreamHandler struct {
+	log    *slog.Logger
+	cfg    *config.Config
+	stream EventStream
+	bus    EventBus
+}
+
+// @Summary Stream events
+// @Description Server-Sent Events stream of mission and target state changes.
+// @Description Every event carries its ID, send it back in the Last-Event-ID header
+// @Description (or the last_event_id query parameter) to resume after a reconnect.
+// @Description Comments are sent as heartbeats while there are no events.
+// @Security ApiKeyAuth
+// @Tags Event
+// @Produce text/event-stream
+// @Param mission_id query int false "Only events of the mission"
+// @Param cat_id query int false "Only events of the cat"
+// @Param last_event_id query int false "Resume after the event"
+// @Param Last-Event-ID header int false "Resume after the event"
+// @Success 200 {object} domain.Event
+// @Failure 400 {object} domain.Response
+// @Router /events/stream [get]
+func (h *StreamHandler) GetEventStream(c *fiber.Ctx) error {
+	const op = "handler.GetEventStream"
+	log := h.log.With(slog.String("operation", op))
+
+	var (
+		filter eventFilter
+		err    error
+	)
+
+	if filter.missionID, err = queryID(c, "mission_id"); err != nil {
+		log.Warn("error while parsing query", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if filter.catID, err = queryID(c, "cat_id"); err != nil {
+		log.Warn("error while parsing query", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	lastID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+	var last int64
+	if lastID != "" {
+		if last, err = strconv.ParseInt(lastID, 10, 64); err != nil {
+			log.Warn("error while parsing last event ID", sl.Err(err))
+			return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: fmt.Sprintf("invalid last event ID: %s", lastID)})
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	missed, events, cancel := h.stream.Subscribe(last)
+
+	// The server write timeout is set once per response, a stream outlives it.
+	// Every write pushes the deadline forward instead, so a stalled client
+	// is still disconnected.
+	conn := c.Context().Conn()
+	heartbeat := h.cfg.Stream.Heartbeat
+	writeTimeout := h.cfg.Server.WriteTimeout
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		flush := func() bool {
+			if writeTimeout > 0 {
+				conn.SetWriteDeadline(time.Now().Add(writeTimeout)) // nolint: errcheck
+			}
+			return w.Flush() == nil
+		}
+
+		fmt.Fprintf(w, "retry: %d\n\n", heartbeat.Milliseconds())
+		for _, e := range missed {
+			if filter.match(e) {
+				writeEvent(w, e)
+			}
+		}
+		if !flush() {
+			return
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if !filter.match(e) {
+					continue
+				}
+				writeEvent(w, e)
+			case <-ticker.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+
+			if !flush() {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// @Summary Get event bus metrics
+// @Description Counters of the in-process event bus since the instance started:
+// @Description events published and, per subscriber, events handled, failed, panicked and dropped.
+// @Security ApiKeyAuth
+// @Tags Event
+// @Produce json
+// @Success 200 {object} domain.BusMetrics
+// @Router /events/metrics [get]
+func (h *StreamHandler) GetEventBusMetrics(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(h.bus.Metrics())
+}
+
+type eventFilter struct {
+	missionID int
+	catID     int
+}
+
+// match reports whether the event is a mission or target state change passing the filter.
+func (f eventFilter) match(e *domain.Event) bool {
+	if !strings.HasPrefix(string(e.Type), "mission.") && !strings.HasPrefix(string(e.Type), "target.") {
+		return false
+	}
+
+	return (f.missionID == 0 || e.MissionID == f.missionID) && (f.catID == 0 || e.CatID == f.catID)
+}
+
+func writeEvent(w *bufio.Writer, e *domain.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
+
+func queryID(c *fiber.Ctx, key string) (int, error) {
+	v := c.Query(key)
+	if v == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.Atoi(v)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid %s: %s", key, v)
+	}
+
+	return id, nil
+}
@@ -1,37 +1,381 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"strconv"
 
 	"github.com/go-playground/validator"
 	"github.com/gofiber/fiber/v2"
 	"github.com/markraiter/spycat/internal/app/service"
 	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/geo"
+	"github.com/markraiter/spycat/internal/lib/geojson"
+	"github.com/markraiter/spycat/internal/lib/graph"
 	"github.com/markraiter/spycat/internal/lib/sl"
 )
 
 type TargetService interface {
-	CompleteTarget(ctx context.Context, id int) error
+	SaveTarget(ctx context.Context, tr *domain.TargetRequest) (int, error)
+	Dossier(ctx context.Context, id int) (*domain.Dossier, error)
+	AddAlias(ctx context.Context, id int, alias string) error
+	DeleteAlias(ctx context.Context, id int, alias string) error
+	AddPhoto(ctx context.Context, userID, id int, pr *domain.TargetPhotoRequest) (*domain.TargetPhoto, error)
+	AddNote(ctx context.Context, userID, id int, nr *domain.TargetNoteRequest) (*domain.TargetNote, error)
+	Targets(ctx context.Context, near *domain.GeoPoint, radiusKm float64) ([]*domain.Target, error)
+	TargetsGeoJSON(ctx context.Context, completed *bool, layers ...string) (*domain.FeatureCollection, error)
+	CompleteTarget(ctx context.Context, id, missionID int) error
 	AddTargetToMission(ctx context.Context, missionID, targetID int) error
+	RemoveTargetFromMission(ctx context.Context, missionID, targetID int) error
+	MergeCandidates(ctx context.Context, filter *domain.MergeCandidateFilter) ([]*domain.MergeCandidate, error)
+	DismissMergeCandidate(ctx context.Context, md *domain.MergeDismissal) error
+	MergeTargets(ctx context.Context, userID int, mr *domain.TargetMergeRequest) (*domain.Dossier, error)
+	AddRelationship(ctx context.Context, userID, id int, rr *domain.RelationshipRequest) (*domain.Relationship, error)
+	DeleteRelationship(ctx context.Context, id, relationshipID int) error
+	Graph(ctx context.Context, id, depth int) (*domain.TargetGraph, error)
 }
 
+const (
+	mergeCandidatesPageSize    = 50
+	mergeCandidatesMaxPageSize = 100
+
+	graphDepth    = 2
+	graphMaxDepth = 4
+)
+
 type TargetHandler struct {
 	log     *slog.Logger
 	val     *validator.Validate
 	service TargetService
 }
 
+// @Summary Create target
+// @Description Register a target outside of any mission, missions can be linked to it later.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Accept json
+// @Produce json
+// @Param Target_request body domain.TargetRequest true "Target data"
+// @Success 201 {integer} int "Target ID"
+// @Failure 400 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets [post]
+func (h *TargetHandler) CreateTarget(c *fiber.Ctx) error {
+	const op = "handler.CreateTarget"
+	log := h.log.With(slog.String("operation", op))
+
+	var tr domain.TargetRequest
+	if err := c.BodyParser(&tr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(tr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	id, err := h.service.SaveTarget(c.Context(), &tr)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidLocation) {
+			log.Warn("invalid target location", sl.Err(err))
+			return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+		}
+		log.Warn("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(id)
+}
+
+// @Summary Get target
+// @Description Get the dossier of a target: its aliases, photos, notes history and every mission it is part of.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Produce json
+// @Param id path int true "Target ID"
+// @Success 200 {object} domain.Dossier
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/{id} [get]
+func (h *TargetHandler) GetTarget(c *fiber.Ctx) error {
+	const op = "handler.GetTarget"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	dossier, err := h.service.Dossier(c.Context(), id)
+	if err != nil {
+		return h.targetError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dossier)
+}
+
+// @Summary Add target alias
+// @Description Add an alias to a target, adding one it has already changes nothing.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Accept json
+// @Produce json
+// @Param id path int true "Target ID"
+// @Param Alias body domain.TargetAliasRequest true "Alias"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/{id}/aliases [post]
+func (h *TargetHandler) AddTargetAlias(c *fiber.Ctx) error {
+	const op = "handler.AddTargetAlias"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var ar domain.TargetAliasRequest
+	if err := c.BodyParser(&ar); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(ar); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.AddAlias(c.Context(), id, ar.Alias); err != nil {
+		return h.targetError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "alias added"})
+}
+
+// @Summary Delete target alias
+// @Description Delete an alias of a target
+// @Security ApiKeyAuth
+// @Tags Target
+// @Produce json
+// @Param id path int true "Target ID"
+// @Param alias path string true "Alias"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/{id}/aliases/{alias} [delete]
+func (h *TargetHandler) DeleteTargetAlias(c *fiber.Ctx) error {
+	const op = "handler.DeleteTargetAlias"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	alias, err := url.PathUnescape(c.Params("alias"))
+	if err != nil {
+		log.Warn("error while parsing input alias", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.DeleteAlias(c.Context(), id, alias); err != nil {
+		return h.targetError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "alias deleted"})
+}
+
+// @Summary Add target photo
+// @Description Add a photo to a target, photos are kept as links.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Accept json
+// @Produce json
+// @Param id path int true "Target ID"
+// @Param Photo body domain.TargetPhotoRequest true "Photo"
+// @Success 201 {object} domain.TargetPhoto
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/{id}/photos [post]
+func (h *TargetHandler) AddTargetPhoto(c *fiber.Ctx) error {
+	const op = "handler.AddTargetPhoto"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var pr domain.TargetPhotoRequest
+	if err := c.BodyParser(&pr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(pr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	photo, err := h.service.AddPhoto(c.Context(), userID(c), id, &pr)
+	if err != nil {
+		return h.targetError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(photo)
+}
+
+// @Summary Add target note
+// @Description Write a note on a target. It becomes the notes of the target, the earlier notes stay in its history.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Accept json
+// @Produce json
+// @Param id path int true "Target ID"
+// @Param Note body domain.TargetNoteRequest true "Note"
+// @Success 201 {object} domain.TargetNote
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/{id}/notes [post]
+func (h *TargetHandler) AddTargetNote(c *fiber.Ctx) error {
+	const op = "handler.AddTargetNote"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var nr domain.TargetNoteRequest
+	if err := c.BodyParser(&nr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(nr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	note, err := h.service.AddNote(c.Context(), userID(c), id, &nr)
+	if err != nil {
+		return h.targetError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(note)
+}
+
+// @Summary Get targets
+// @Description Get every registered target, or with near the located targets nearest to the point first.
+// @Description radius_km keeps those within that many kilometres of it.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Accept json
+// @Produce json
+// @Param near query string false "Point as lat,lng"
+// @Param radius_km query number false "Search radius in kilometres, needs near"
+// @Success 200 {array} domain.Target "Targets"
+// @Failure 400 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets [get]
+func (h *TargetHandler) GetTargets(c *fiber.Ctx) error {
+	const op = "handler.GetTargets"
+	log := h.log.With(slog.String("operation", op))
+
+	near, err := geoPoint(c, "near")
+	if err != nil {
+		log.Warn("error while parsing query", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var radiusKm float64
+	if v := c.Query("radius_km"); v != "" {
+		radiusKm, err = strconv.ParseFloat(v, 64)
+		if err != nil || radiusKm <= 0 || near == nil {
+			log.Warn("invalid radius", slog.String("radius_km", v))
+			return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: "radius_km must be a positive number of kilometres with near"})
+		}
+	}
+
+	targets, err := h.service.Targets(c.Context(), near, radiusKm)
+	if err != nil {
+		log.Warn("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(targets)
+}
+
+// @Summary Get targets as GeoJSON
+// @Description Get the targets as a GeoJSON feature collection for map tools. The targets layer has a point
+// @Description for each located target, the countries layer a feature for each country with the number of its
+// @Description targets placed at the centroid of the located ones. Every feature tells its layer in the "layer" property.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Produce application/geo+json
+// @Param completed query bool false "Completed, false for the active targets"
+// @Param layer query string false "Layer, both by default" Enums(targets, countries)
+// @Success 200 {object} domain.FeatureCollection
+// @Failure 400 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets.geojson [get]
+func (h *TargetHandler) GetTargetsGeoJSON(c *fiber.Ctx) error {
+	const op = "handler.GetTargetsGeoJSON"
+	log := h.log.With(slog.String("operation", op))
+
+	layers, err := geoJSONLayers(c)
+	if err != nil {
+		log.Warn("error while parsing query", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var completed *bool
+	if v := c.Query("completed"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Warn("error while parsing query", sl.Err(err))
+			return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: fmt.Sprintf("invalid completed: %s", v)})
+		}
+		completed = &b
+	}
+
+	fc, err := h.service.TargetsGeoJSON(c.Context(), completed, layers...)
+	if err != nil {
+		log.Warn("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fc, mimeGeoJSON)
+}
+
 // @Summary Complete target
-// @Description Complete target
+// @Description Complete the target in a mission, or in every mission it is part of without mission_id.
 // @Security ApiKeyAuth
 // @Tags Target
 // @Accept json
 // @Produce json
 // @Param id path int true "Target ID"
+// @Param mission_id query int false "Mission ID"
 // @Success 200 {object} domain.Response
 // @Failure 400 {object} domain.Response
 // @Failure 403 {object} domain.Response
@@ -48,7 +392,9 @@ func (h *TargetHandler) CompleteTarget(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
 	}
 
-	err = h.service.CompleteTarget(c.Context(), id)
+	missionID := c.QueryInt("mission_id")
+
+	err = h.service.CompleteTarget(c.Context(), id, missionID)
 	if err != nil {
 		if errors.Is(err, service.ErrNotFound) {
 			log.Warn("target not found", sl.Err(err))
@@ -61,7 +407,7 @@ func (h *TargetHandler) CompleteTarget(c *fiber.Ctx) error {
 }
 
 // @Summary Add target to mission
-// @Description Add target to mission
+// @Description Link a registered target to the mission, the target stays part of its other missions.
 // @Security ApiKeyAuth
 // @Tags Target
 // @Accept json
@@ -105,3 +451,385 @@ func (h *TargetHandler) AddTargetToMission(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("Target %d added to mission %d", targetID, missionID)})
 }
+
+// @Summary Remove target from mission
+// @Description Unlink the target from the mission, the target stays registered and part of its other missions.
+// @Description A target completed in the mission or a completed mission keep the link.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Accept json
+// @Produce json
+// @Param mission_id path int true "Mission ID"
+// @Param target_id path int true "Target ID"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /missions/{mission_id}/targets/{target_id} [delete]
+func (h *TargetHandler) RemoveTargetFromMission(c *fiber.Ctx) error {
+	const op = "handler.RemoveTargetFromMission"
+	log := h.log.With(slog.String("operation", op))
+
+	missionID, err := strconv.Atoi(c.Params("mission_id"))
+	if err != nil {
+		log.Warn("error while parsing input mission_id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	targetID, err := strconv.Atoi(c.Params("target_id"))
+	if err != nil {
+		log.Warn("error while parsing input target_id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	err = h.service.RemoveTargetFromMission(c.Context(), missionID, targetID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("target not part of the mission", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+		}
+		if errors.Is(err, service.ErrMissionCompleted) || errors.Is(err, service.ErrTargetCompleted) {
+			log.Warn("mission or target completed", sl.Err(err))
+			return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("Target %d removed from mission %d", targetID, missionID)})
+}
+
+// @Summary Get merge candidates
+// @Description Get a page of the pairs of targets that look like the same person, the most similar first.
+// @Description The pairs are found by a background job from the normalized names, aliases and countries.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Produce json
+// @Param limit query int false "Page size, 50 by default, 100 at most"
+// @Param offset query int false "Pairs to skip"
+// @Success 200 {array} domain.MergeCandidate
+// @Failure 400 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/duplicates [get]
+func (h *TargetHandler) GetMergeCandidates(c *fiber.Ctx) error {
+	const op = "handler.GetMergeCandidates"
+	log := h.log.With(slog.String("operation", op))
+
+	var filter domain.MergeCandidateFilter
+	if err := c.QueryParser(&filter); err != nil {
+		log.Warn("error while parsing query", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if filter.Limit < 1 || filter.Limit > mergeCandidatesMaxPageSize {
+		filter.Limit = mergeCandidatesPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	candidates, err := h.service.MergeCandidates(c.Context(), &filter)
+	if err != nil {
+		log.Warn("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(candidates)
+}
+
+// @Summary Dismiss merge candidate
+// @Description Tell that a pair of targets are different people, the pair is not suggested again.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Accept json
+// @Produce json
+// @Param Dismissal body domain.MergeDismissal true "Pair of targets"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/duplicates/dismiss [post]
+func (h *TargetHandler) DismissMergeCandidate(c *fiber.Ctx) error {
+	const op = "handler.DismissMergeCandidate"
+	log := h.log.With(slog.String("operation", op))
+
+	var md domain.MergeDismissal
+	if err := c.BodyParser(&md); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(md); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.DismissMergeCandidate(c.Context(), &md); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("merge candidate not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: "merge candidate not found"})
+		}
+		log.Warn("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("Targets %d and %d are not duplicates", md.TargetID, md.DuplicateID)})
+}
+
+// @Summary Merge targets
+// @Description Merge duplicates into a target. Their missions, notes, photos and relationships move to the target,
+// @Description their names and aliases become its aliases and they are deleted. The merge is audited.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Accept json
+// @Produce json
+// @Param Merge_request body domain.TargetMergeRequest true "Target and its duplicates"
+// @Success 200 {object} domain.Dossier
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 409 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/merge [post]
+func (h *TargetHandler) MergeTargets(c *fiber.Ctx) error {
+	const op = "handler.MergeTargets"
+	log := h.log.With(slog.String("operation", op))
+
+	var mr domain.TargetMergeRequest
+	if err := c.BodyParser(&mr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(mr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	dossier, err := h.service.MergeTargets(c.Context(), userID(c), &mr)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMerge) {
+			log.Warn("invalid merge", sl.Err(err))
+			return c.Status(fiber.StatusConflict).JSON(domain.Response{Message: err.Error()})
+		}
+		return h.targetError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dossier)
+}
+
+// @Summary Add target relationship
+// @Description Link a target to another one, read as the other target being the type of this one:
+// @Description the employer of a target is linked from the target. Confidence is 1 when left out.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Accept json
+// @Produce json
+// @Param id path int true "Target ID"
+// @Param Relationship_request body domain.RelationshipRequest true "Relationship data"
+// @Success 201 {object} domain.Relationship
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 409 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/{id}/relationships [post]
+func (h *TargetHandler) AddTargetRelationship(c *fiber.Ctx) error {
+	const op = "handler.AddTargetRelationship"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var rr domain.RelationshipRequest
+	if err := c.BodyParser(&rr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(rr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	relationship, err := h.service.AddRelationship(c.Context(), userID(c), id, &rr)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRelationship) {
+			log.Warn("invalid relationship", sl.Err(err))
+			return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+		}
+		if errors.Is(err, service.ErrAlreadyExists) {
+			log.Warn("relationship already exists", sl.Err(err))
+			return c.Status(fiber.StatusConflict).JSON(domain.Response{Message: "relationship already exists"})
+		}
+		return h.targetError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(relationship)
+}
+
+// @Summary Delete target relationship
+// @Description Delete a relationship the target is either end of.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Produce json
+// @Param id path int true "Target ID"
+// @Param relationship_id path int true "Relationship ID"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/{id}/relationships/{relationship_id} [delete]
+func (h *TargetHandler) DeleteTargetRelationship(c *fiber.Ctx) error {
+	const op = "handler.DeleteTargetRelationship"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	relationshipID, err := c.ParamsInt("relationship_id")
+	if err != nil {
+		log.Warn("error while parsing input relationship id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.DeleteRelationship(c.Context(), id, relationshipID); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			log.Warn("relationship not found", sl.Err(err))
+			return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: "relationship not found"})
+		}
+		log.Warn("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("Relationship %d deleted", relationshipID)})
+}
+
+// @Summary Get target graph
+// @Description Get the targets at most depth relationships away from a target, in either direction,
+// @Description and the relationships between them. format=graphml or format=dot downloads the graph
+// @Description as GraphML or as a Graphviz digraph for offline analysis.
+// @Security ApiKeyAuth
+// @Tags Target
+// @Produce json
+// @Produce application/graphml+xml
+// @Produce text/vnd.graphviz
+// @Param id path int true "Target ID"
+// @Param depth query int false "Relationships to follow, 2 by default, 4 at most"
+// @Param format query string false "json, graphml or dot, json by default"
+// @Success 200 {object} domain.TargetGraph
+// @Failure 400 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /targets/{id}/graph [get]
+func (h *TargetHandler) GetTargetGraph(c *fiber.Ctx) error {
+	const op = "handler.GetTargetGraph"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	depth := graphDepth
+	if v := c.Query("depth"); v != "" {
+		depth, err = strconv.Atoi(v)
+		if err != nil || depth < 1 || depth > graphMaxDepth {
+			log.Warn("invalid depth", slog.String("depth", v))
+			return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: fmt.Sprintf("depth must be within 1 and %d", graphMaxDepth)})
+		}
+	}
+
+	format := c.Query("format", "json")
+	if format != "json" && format != graph.FormatGraphML && format != graph.FormatDOT {
+		log.Warn("invalid format", slog.String("format", format))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: fmt.Sprintf("invalid format: %s", format)})
+	}
+
+	g, err := h.service.Graph(c.Context(), id, depth)
+	if err != nil {
+		return h.targetError(c, log, err)
+	}
+
+	var (
+		buf   bytes.Buffer
+		ctype string
+	)
+	switch format {
+	case graph.FormatGraphML:
+		ctype = mimeGraphML
+		err = graph.WriteGraphML(&buf, g)
+	case graph.FormatDOT:
+		ctype = mimeDOT
+		err = graph.WriteDOT(&buf, g)
+	default:
+		return c.Status(fiber.StatusOK).JSON(g)
+	}
+
+	if err != nil {
+		log.Warn("internal error", sl.Err(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+	}
+
+	c.Attachment(fmt.Sprintf("target-%d.%s", id, format))
+	c.Set(fiber.HeaderContentType, ctype)
+
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+// targetError writes the response of an error of the target registry service methods.
+func (h *TargetHandler) targetError(c *fiber.Ctx, log *slog.Logger, err error) error {
+	if errors.Is(err, service.ErrNotFound) {
+		log.Warn("target not found", sl.Err(err))
+		return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: "target not found"})
+	}
+
+	log.Warn("internal error", sl.Err(err))
+	return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+}
+
+// Media types of the exported formats.
+const (
+	// mimeGeoJSON is the media type of GeoJSON, RFC 7946.
+	mimeGeoJSON = "application/geo+json"
+	mimeGraphML = "application/graphml+xml"
+	mimeDOT     = "text/vnd.graphviz"
+)
+
+// geoJSONLayers parses the layer query parameter, it returns no layers when the
+// parameter is missing.
+func geoJSONLayers(c *fiber.Ctx) ([]string, error) {
+	layer := c.Query("layer")
+	if layer == "" {
+		return nil, nil
+	}
+
+	if !geojson.ValidLayer(layer) {
+		return nil, fmt.Errorf("invalid layer: %s", layer)
+	}
+
+	return []string{layer}, nil
+}
+
+// geoPoint parses the "lat,lng" query parameter, it returns nil when the parameter is missing.
+func geoPoint(c *fiber.Ctx, name string) (*domain.GeoPoint, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	lat, lng, err := geo.ParsePoint(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	return &domain.GeoPoint{Latitude: lat, Longitude: lng}, nil
+}
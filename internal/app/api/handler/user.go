@@ -0,0 +1,316 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/app/service"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+const (
+	usersPageSize    = 50
+	usersMaxPageSize = 100
+)
+
+// @Summary Get profile
+// @Description Get the account of the current user.
+// @Security ApiKeyAuth
+// @Tags User
+// @Produce json
+// @Success 200 {object} domain.Profile
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /me [get]
+func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
+	const op = "handler.GetProfile"
+	log := h.log.With(slog.String("operation", op))
+
+	profile, err := h.service.Profile(c.UserContext(), userID(c))
+	if err != nil {
+		return h.userError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(profile)
+}
+
+// @Summary Update profile
+// @Description Change the username or the email of the current user.
+// @Description A new email needs the current password and is verified again with the link emailed to it,
+// @Description depending on the configuration login is refused until it is. Users without a password,
+// @Description signing in with the identity provider, must have signed in within the last minutes instead.
+// @Security ApiKeyAuth
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param Profile_update body domain.ProfileUpdate true "Fields to change"
+// @Success 200 {object} domain.Profile
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 409 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /me [patch]
+func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
+	const op = "handler.UpdateProfile"
+	log := h.log.With(slog.String("operation", op))
+
+	var pu domain.ProfileUpdate
+	if err := c.BodyParser(&pu); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(pu); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	profile, err := h.service.UpdateProfile(c.UserContext(), h.cfg.Auth, userID(c), sessionID(c), &pu)
+	if err != nil {
+		if errors.Is(err, service.ErrVerificationNotSent) {
+			// The email changed, a new link can be asked for with /auth/verify/resend.
+			log.Error("verification email was not sent", sl.Err(err))
+			return c.Status(fiber.StatusOK).JSON(profile)
+		}
+		return h.userError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(profile)
+}
+
+// @Summary Change password
+// @Description Change the password of the current user, the current password is required.
+// @Description The new password must meet the configured policy. The other sessions of the user are signed out.
+// @Security ApiKeyAuth
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param Password_change body domain.PasswordChangeRequest true "Current and new password"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /me/password [put]
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	const op = "handler.ChangePassword"
+	log := h.log.With(slog.String("operation", op))
+
+	var pr domain.PasswordChangeRequest
+	if err := c.BodyParser(&pr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(pr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.ChangePassword(c.UserContext(), userID(c), sessionID(c), &pr); err != nil {
+		return h.userError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "password changed"})
+}
+
+// @Summary Delete account
+// @Description Delete the account of the current user, the password is required. Users without a password,
+// @Description signing in with the identity provider, must have signed in within the last minutes instead.
+// @Description Chat messages and audit entries are kept without their author and the cats
+// @Description the user handled are left without a handler. Sessions, API keys, linked identities
+// @Description and preferences are deleted. The last admin cannot delete the account.
+// @Security ApiKeyAuth
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param Account_deletion body domain.AccountDeletionRequest true "Password"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 409 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /me [delete]
+func (h *AuthHandler) DeleteAccount(c *fiber.Ctx) error {
+	const op = "handler.DeleteAccount"
+	log := h.log.With(slog.String("operation", op))
+
+	var dr domain.AccountDeletionRequest
+	if err := c.BodyParser(&dr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(dr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.DeleteAccount(c.UserContext(), h.cfg.Auth, userID(c), sessionID(c), dr.Password); err != nil {
+		return h.userError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "account deleted"})
+}
+
+// @Summary Get users
+// @Description Get a page of the users by ID, only admins may.
+// @Security ApiKeyAuth
+// @Tags User
+// @Produce json
+// @Param q query string false "Part of the username or the email"
+// @Param limit query int false "Page size, 50 by default, 100 at most"
+// @Param offset query int false "Users to skip"
+// @Success 200 {array} domain.Profile
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /users [get]
+func (h *AuthHandler) GetUsers(c *fiber.Ctx) error {
+	const op = "handler.GetUsers"
+	log := h.log.With(slog.String("operation", op))
+
+	var filter domain.UserFilter
+	if err := c.QueryParser(&filter); err != nil {
+		log.Warn("error while parsing query", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if filter.Limit < 1 || filter.Limit > usersMaxPageSize {
+		filter.Limit = usersPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	users, err := h.service.Users(c.UserContext(), userID(c), &filter)
+	if err != nil {
+		return h.userError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(users)
+}
+
+// @Summary Disable user
+// @Description Keep a user from signing in, the user is signed out everywhere and API keys
+// @Description of the user stop working. Only admins may, not on themselves.
+// @Security ApiKeyAuth
+// @Tags User
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 409 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /users/{id}/disable [post]
+func (h *AuthHandler) DisableUser(c *fiber.Ctx) error {
+	const op = "handler.DisableUser"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		log.Warn("invalid user id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: "invalid user id"})
+	}
+
+	if err := h.service.DisableUser(c.UserContext(), userID(c), id); err != nil {
+		return h.userError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "user disabled"})
+}
+
+// @Summary Enable user
+// @Description Let a disabled user sign in again, only admins may.
+// @Security ApiKeyAuth
+// @Tags User
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /users/{id}/enable [post]
+func (h *AuthHandler) EnableUser(c *fiber.Ctx) error {
+	const op = "handler.EnableUser"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		log.Warn("invalid user id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: "invalid user id"})
+	}
+
+	if err := h.service.EnableUser(c.UserContext(), userID(c), id); err != nil {
+		return h.userError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "user enabled"})
+}
+
+// @Summary Force password reset
+// @Description Refuse the password of a user until it is reset with the link emailed to the user.
+// @Description The user is signed out everywhere. Only admins may.
+// @Security ApiKeyAuth
+// @Tags User
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /users/{id}/password-reset [post]
+func (h *AuthHandler) ForcePasswordReset(c *fiber.Ctx) error {
+	const op = "handler.ForcePasswordReset"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		log.Warn("invalid user id", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: "invalid user id"})
+	}
+
+	if err := h.service.ForcePasswordReset(c.UserContext(), h.cfg.Auth, userID(c), id); err != nil {
+		return h.userError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: "password reset required"})
+}
+
+// userError writes the response of an error of the account service methods.
+func (h *AuthHandler) userError(c *fiber.Ctx, log *slog.Logger, err error) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: "user not found"})
+	case errors.Is(err, service.ErrInvalidCredentials):
+		log.Warn("invalid credentials", sl.Err(err))
+		return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrInvalidCredentials.Error()})
+	case errors.Is(err, service.ErrReauthRequired):
+		log.Warn("login is not recent", sl.Err(err))
+		return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrReauthRequired.Error()})
+	case errors.Is(err, service.ErrForbidden):
+		log.Warn("forbidden", sl.Err(err))
+		return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrForbidden.Error()})
+	case errors.Is(err, service.ErrAlreadyExists):
+		return c.Status(fiber.StatusConflict).JSON(domain.Response{Message: "email is already in use"})
+	case errors.Is(err, service.ErrLastAdmin):
+		return c.Status(fiber.StatusConflict).JSON(domain.Response{Message: service.ErrLastAdmin.Error()})
+	case errors.Is(err, service.ErrOwnAccount):
+		return c.Status(fiber.StatusConflict).JSON(domain.Response{Message: service.ErrOwnAccount.Error()})
+	case errors.Is(err, service.ErrWeakPassword):
+		log.Warn("weak password", sl.Err(err))
+		return weakPassword(c, err)
+	}
+
+	log.Error("internal error", sl.Err(err))
+	return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+}
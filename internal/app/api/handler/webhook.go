@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/go-playground/validator"
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/app/service"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+type WebhookService interface {
+	SaveWebhook(ctx context.Context, userID int, wr *domain.WebhookRequest) (int, error)
+	Webhooks(ctx context.Context, userID int) ([]*domain.Webhook, error)
+	Webhook(ctx context.Context, userID, id int) (*domain.Webhook, error)
+	UpdateWebhook(ctx context.Context, userID, id int, wr *domain.WebhookRequest) error
+	DeleteWebhook(ctx context.Context, userID, id int) error
+	WebhookDeliveries(ctx context.Context, userID, webhookID int) ([]*domain.WebhookDelivery, error)
+	Redeliver(ctx context.Context, userID int, deliveryID int64) (int64, error)
+}
+
+type WebhookHandler struct {
+	log     *slog.Logger
+	val     *validator.Validate
+	service WebhookService
+}
+
+// @Summary Create webhook
+// @Description Subscribe a URL to domain events. Every delivery is a POST of the event as JSON
+// @Description signed with HMAC-SHA256 of "<X-Spycat-Timestamp>.<body>" keyed with the secret,
+// @Description sent in the X-Spycat-Signature header as "sha256=<hex>". Only admins may, and the
+// @Description URL must reach a public address.
+// @Security ApiKeyAuth
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param Webhook_request body domain.WebhookRequest true "Webhook data"
+// @Success 201 {integer} int "Webhook ID"
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	const op = "handler.CreateWebhook"
+	log := h.log.With(slog.String("operation", op))
+
+	var wr domain.WebhookRequest
+	if err := c.BodyParser(&wr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(wr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	id, err := h.service.SaveWebhook(c.Context(), userID(c), &wr)
+	if err != nil {
+		return h.webhookError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(id)
+}
+
+// @Summary Get webhooks
+// @Description Get webhook subscriptions, secrets are never returned. Only admins may.
+// @Security ApiKeyAuth
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Success 200 {array} domain.Webhook
+// @Failure 403 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /webhooks [get]
+func (h *WebhookHandler) GetWebhooks(c *fiber.Ctx) error {
+	const op = "handler.GetWebhooks"
+	log := h.log.With(slog.String("operation", op))
+
+	webhooks, err := h.service.Webhooks(c.Context(), userID(c))
+	if err != nil {
+		return h.webhookError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(webhooks)
+}
+
+// @Summary Get webhook
+// @Description Get webhook subscription by ID, only admins may.
+// @Security ApiKeyAuth
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} domain.Webhook
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *fiber.Ctx) error {
+	const op = "handler.GetWebhook"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	webhook, err := h.service.Webhook(c.Context(), userID(c), id)
+	if err != nil {
+		return h.webhookError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(webhook)
+}
+
+// @Summary Update webhook
+// @Description Replace URL, event types, secret and active flag of the webhook, only admins may.
+// @Security ApiKeyAuth
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param Webhook_request body domain.WebhookRequest true "Webhook data"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 406 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(c *fiber.Ctx) error {
+	const op = "handler.UpdateWebhook"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	var wr domain.WebhookRequest
+	if err := c.BodyParser(&wr); err != nil {
+		log.Warn("error while parsing input body", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.val.Struct(wr); err != nil {
+		log.Warn("validation error", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.UpdateWebhook(c.Context(), userID(c), id, &wr); err != nil {
+		return h.webhookError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("webhook updated: %d", id)})
+}
+
+// @Summary Delete webhook
+// @Description Delete webhook subscription together with its delivery log, only admins may.
+// @Security ApiKeyAuth
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} domain.Response
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	const op = "handler.DeleteWebhook"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	if err := h.service.DeleteWebhook(c.Context(), userID(c), id); err != nil {
+		return h.webhookError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(domain.Response{Message: fmt.Sprintf("webhook deleted: %d", id)})
+}
+
+// @Summary Get webhook deliveries
+// @Description Get the delivery log of the webhook, newest first. Only admins may.
+// @Security ApiKeyAuth
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {array} domain.WebhookDelivery
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) GetWebhookDeliveries(c *fiber.Ctx) error {
+	const op = "handler.GetWebhookDeliveries"
+	log := h.log.With(slog.String("operation", op))
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	deliveries, err := h.service.WebhookDeliveries(c.Context(), userID(c), id)
+	if err != nil {
+		return h.webhookError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(deliveries)
+}
+
+// @Summary Redeliver webhook delivery
+// @Description Queue the event of the delivery to be sent again as a new delivery, only admins may.
+// @Security ApiKeyAuth
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param id path int true "Delivery ID"
+// @Success 202 {integer} int "New delivery ID"
+// @Failure 400 {object} domain.Response
+// @Failure 403 {object} domain.Response
+// @Failure 404 {object} domain.Response
+// @Failure 500 {object} domain.Response
+// @Router /webhooks/deliveries/{id}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	const op = "handler.Redeliver"
+	log := h.log.With(slog.String("operation", op))
+
+	deliveryID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		log.Warn("error while parsing input params", sl.Err(err))
+		return c.Status(fiber.StatusBadRequest).JSON(domain.Response{Message: err.Error()})
+	}
+
+	id, err := h.service.Redeliver(c.Context(), userID(c), deliveryID)
+	if err != nil {
+		return h.webhookError(c, log, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(id)
+}
+
+// webhookError writes the response of an error of the webhook service methods.
+func (h *WebhookHandler) webhookError(c *fiber.Ctx, log *slog.Logger, err error) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		log.Warn("not found", sl.Err(err))
+		return c.Status(fiber.StatusNotFound).JSON(domain.Response{Message: err.Error()})
+	case errors.Is(err, service.ErrForbidden):
+		log.Warn("forbidden", sl.Err(err))
+		return c.Status(fiber.StatusForbidden).JSON(domain.Response{Message: service.ErrForbidden.Error()})
+	case errors.Is(err, service.ErrInvalidWebhookURL):
+		log.Warn("invalid webhook URL", sl.Err(err))
+		return c.Status(fiber.StatusNotAcceptable).JSON(domain.Response{Message: err.Error()})
+	}
+
+	log.Error("internal error", sl.Err(err))
+	return c.Status(fiber.StatusInternalServerError).JSON(domain.Response{Message: err.Error()})
+}
@@ -1,30 +1,145 @@
 package middleware
 
 import (
+	"context"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
 	"github.com/markraiter/spycat/internal/lib/jwt"
 )
 
-func NewUserIdentity(cfg config.Auth) fiber.Handler {
+// SessionStore tells the current session version of a user, tokens issued
+// with an older version are refused, whether the user must enroll in MFA or is
+// disabled and whether a session is still in use. It also authenticates API keys.
+type SessionStore interface {
+	SessionState(ctx context.Context, userID int, sessionID int64) (*domain.SessionState, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (*domain.APIKey, error)
+}
+
+type identityOptions struct {
+	// enrolling lets users who must enroll in MFA through.
+	enrolling bool
+	// apiKeys accepts "Authorization: ApiKey <key>" next to bearer tokens.
+	apiKeys bool
+}
+
+// NewUserIdentity authenticates the request with a bearer token or an API key.
+// Users whose role requires MFA and who have not enabled it yet are refused until they enroll.
+func NewUserIdentity(keys *jwt.KeySet, sessions SessionStore) fiber.Handler {
+	return identity(keys, sessions, identityOptions{apiKeys: true})
+}
+
+// NewEnrollmentIdentity authenticates with a bearer token only but lets users
+// who must enroll in MFA through, it guards the MFA enrollment routes.
+func NewEnrollmentIdentity(keys *jwt.KeySet, sessions SessionStore) fiber.Handler {
+	return identity(keys, sessions, identityOptions{enrolling: true})
+}
+
+// NewSessionIdentity authenticates with a bearer token only, it guards the
+// routes managing credentials, which an API key must not reach.
+func NewSessionIdentity(keys *jwt.KeySet, sessions SessionStore) fiber.Handler {
+	return identity(keys, sessions, identityOptions{})
+}
+
+func identity(keys *jwt.KeySet, sessions SessionStore, opts identityOptions) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
 			return fiber.NewError(fiber.StatusUnauthorized, "missing Authorization header")
 		}
 
+		if key, ok := strings.CutPrefix(authHeader, "ApiKey "); ok {
+			if !opts.apiKeys {
+				return fiber.NewError(fiber.StatusForbidden, "API keys are not accepted here, sign in")
+			}
+			return apiKeyIdentity(c, sessions, key)
+		}
+
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
 
-		uid, err := jwt.ParseToken(tokenString, cfg.SigningKey)
+		uid, err := keys.ParseToken(tokenString)
 		if err != nil {
 			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
 		}
 
+		id, err := strconv.Atoi(uid.UID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, jwt.ErrInvalidToken.Error())
+		}
+
+		state, err := sessions.SessionState(c.UserContext(), id, uid.SessionID)
+		if err != nil || state.Version != uid.SessionVersion || !state.SessionActive {
+			return fiber.NewError(fiber.StatusUnauthorized, "session revoked, sign in again")
+		}
+
+		if state.Disabled {
+			return fiber.NewError(fiber.StatusForbidden, "account disabled")
+		}
+
+		if state.MFAEnrollmentRequired && !opts.enrolling {
+			return fiber.NewError(fiber.StatusForbidden, "two-factor authentication is required, enroll at /api/v1/auth/mfa/totp")
+		}
+
 		c.Locals("uid", uid)
 		c.Locals("refreshString", tokenString)
 
 		return c.Next()
 	}
 }
+
+// apiKeyIdentity authenticates the request as the user of the API key, if the
+// key has the scope of the method: ScopeRead for GET and HEAD, ScopeWrite otherwise.
+func apiKeyIdentity(c *fiber.Ctx, sessions SessionStore, raw string) error {
+	key, err := sessions.AuthenticateAPIKey(c.UserContext(), strings.TrimSpace(raw))
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid API key")
+	}
+
+	scope := domain.ScopeWrite
+	if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+		scope = domain.ScopeRead
+	}
+
+	if !key.HasScope(scope) {
+		return fiber.NewError(fiber.StatusForbidden, "API key lacks the "+scope+" scope")
+	}
+
+	// API keys do not belong to a session.
+	state, err := sessions.SessionState(c.UserContext(), key.UserID, 0)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid API key")
+	}
+
+	if state.Disabled {
+		return fiber.NewError(fiber.StatusForbidden, "account disabled")
+	}
+
+	if state.MFAEnrollmentRequired {
+		return fiber.NewError(fiber.StatusForbidden, "two-factor authentication is required, enroll at /api/v1/auth/mfa/totp")
+	}
+
+	c.Locals("uid", &jwt.TokenClaims{
+		UID:      strconv.Itoa(key.UserID),
+		Username: key.Username,
+		Email:    key.Email,
+	})
+	c.Locals("apiKey", key)
+
+	return c.Next()
+}
+
+// NewSocketIdentity authenticates like NewUserIdentity but also accepts the token
+// in the access_token query parameter, browsers cannot set headers on a WebSocket.
+func NewSocketIdentity(keys *jwt.KeySet, sessions SessionStore) fiber.Handler {
+	userIdentity := NewUserIdentity(keys, sessions)
+
+	return func(c *fiber.Ctx) error {
+		if token := c.Query("access_token"); token != "" && c.Get("Authorization") == "" {
+			c.Request().Header.Set("Authorization", "Bearer "+token)
+		}
+
+		return userIdentity(c)
+	}
+}
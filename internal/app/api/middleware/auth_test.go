@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+type sessions struct{}
+
+const (
+	// revokedSession is the ID of a session signed out.
+	revokedSession = 2
+	// disabledUser is the ID of a user an admin disabled.
+	disabledUser = 9
+)
+
+func (sessions) SessionState(_ context.Context, userID int, sessionID int64) (*domain.SessionState, error) {
+	return &domain.SessionState{SessionActive: sessionID != revokedSession, Disabled: userID == disabledUser}, nil
+}
+
+func (sessions) AuthenticateAPIKey(_ context.Context, key string) (*domain.APIKey, error) {
+	switch key {
+	case "sc_read_secret":
+		return &domain.APIKey{UserID: 7, Scopes: []string{domain.ScopeRead}}, nil
+	case "sc_write_secret":
+		return &domain.APIKey{UserID: 7, Scopes: []string{domain.ScopeWrite}}, nil
+	case "sc_disabled_secret":
+		return &domain.APIKey{UserID: disabledUser, Scopes: []string{domain.ScopeRead}}, nil
+	}
+
+	return nil, errors.New("invalid")
+}
+
+func testApp(t *testing.T) (*fiber.App, *jwt.KeySet) {
+	keys, err := jwt.NewKeySet(config.Auth{SigningKey: "testKey", Issuer: "spycat", Audience: "spycat-api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := func(c *fiber.Ctx) error {
+		claims := c.Locals("uid").(*jwt.TokenClaims)
+		return c.SendString(claims.UID)
+	}
+
+	app := fiber.New()
+	app.Get("/cats", NewUserIdentity(keys, sessions{}), ok)
+	app.Post("/cats", NewUserIdentity(keys, sessions{}), ok)
+	app.Get("/me/api-keys", NewSessionIdentity(keys, sessions{}), ok)
+
+	return app, keys
+}
+
+func TestIdentity(t *testing.T) {
+	app, keys := testApp(t)
+
+	user := &domain.User{ID: 7, Username: "testUser", Email: "test@test.com"}
+
+	token, err := keys.NewToken(user, 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := keys.NewToken(user, revokedSession, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disabled, err := keys.NewToken(&domain.User{ID: disabledUser, Username: "disabledUser", Email: "disabled@test.com"}, 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		method, path  string
+		authorization string
+		want          int
+	}{
+		{"no header", "GET", "/cats", "", fiber.StatusUnauthorized},
+		{"bearer", "GET", "/cats", "Bearer " + token, fiber.StatusOK},
+		{"bad bearer", "GET", "/cats", "Bearer nope", fiber.StatusUnauthorized},
+		{"revoked session", "GET", "/cats", "Bearer " + revoked, fiber.StatusUnauthorized},
+		{"disabled user", "GET", "/cats", "Bearer " + disabled, fiber.StatusForbidden},
+		{"disabled user key", "GET", "/cats", "ApiKey sc_disabled_secret", fiber.StatusForbidden},
+		{"read key reads", "GET", "/cats", "ApiKey sc_read_secret", fiber.StatusOK},
+		{"read key writes", "POST", "/cats", "ApiKey sc_read_secret", fiber.StatusForbidden},
+		{"write key writes", "POST", "/cats", "ApiKey sc_write_secret", fiber.StatusOK},
+		{"unknown key", "GET", "/cats", "ApiKey sc_nope_secret", fiber.StatusUnauthorized},
+		{"key on session route", "GET", "/me/api-keys", "ApiKey sc_read_secret", fiber.StatusForbidden},
+		{"bearer on session route", "GET", "/me/api-keys", "Bearer " + token, fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			resp, err := app.Test(req)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.want, resp.StatusCode)
+		})
+	}
+}
@@ -1,19 +1,30 @@
 package api
 
 import (
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/timeout"
 	"github.com/gofiber/swagger"
 	"github.com/markraiter/spycat/internal/app/api/handler"
 	"github.com/markraiter/spycat/internal/app/api/middleware"
 	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/lib/jwt"
 )
 
 // initRoutes configures the routes for the app.
-func (s Server) initRoutes(app *fiber.App, handler *handler.Handler, cfg *config.Config) {
-	basicAuth := middleware.NewUserIdentity(cfg.Auth)
+func (s Server) initRoutes(app *fiber.App, handler *handler.Handler, sessions middleware.SessionStore, keys *jwt.KeySet, cfg *config.Config) {
+	basicAuth := middleware.NewUserIdentity(keys, sessions)
+	socketAuth := middleware.NewSocketIdentity(keys, sessions)
+	enrollmentAuth := middleware.NewEnrollmentIdentity(keys, sessions)
+	sessionAuth := middleware.NewSessionIdentity(keys, sessions)
 
 	app.Get("/swagger/*", swagger.HandlerDefault)
+	app.Get("/.well-known/jwks.json", timeout.NewWithContext(handler.GetJWKS, cfg.Server.ReadTimeout))
+
+	ws := app.Group("/ws")
+	{
+		ws.Get("/missions/:id", socketAuth, handler.ChatUpgrade, websocket.New(handler.Chat))
+	}
 
 	api := app.Group("/api/v1")
 	{
@@ -21,7 +32,49 @@ func (s Server) initRoutes(app *fiber.App, handler *handler.Handler, cfg *config
 		{
 			authentication.Post("/register", timeout.NewWithContext(handler.Register, cfg.Server.WriteTimeout))
 			authentication.Post("/login", timeout.NewWithContext(handler.Login, cfg.Server.WriteTimeout))
-			authentication.Post("/logout", basicAuth, timeout.NewWithContext(handler.Logout, cfg.Server.WriteTimeout))
+			authentication.Post("/logout", sessionAuth, timeout.NewWithContext(handler.Logout, cfg.Server.WriteTimeout))
+			authentication.Get("/verify", timeout.NewWithContext(handler.VerifyEmail, cfg.Server.WriteTimeout))
+			authentication.Post("/verify/resend", timeout.NewWithContext(handler.ResendVerification, cfg.Server.WriteTimeout))
+			authentication.Post("/password/forgot", timeout.NewWithContext(handler.ForgotPassword, cfg.Server.WriteTimeout))
+			authentication.Post("/password/reset", timeout.NewWithContext(handler.ResetPassword, cfg.Server.WriteTimeout))
+			authentication.Get("/oidc/login", timeout.NewWithContext(handler.OIDCLogin, cfg.Server.WriteTimeout))
+			authentication.Get("/oidc/callback", timeout.NewWithContext(handler.OIDCCallback, cfg.Server.WriteTimeout))
+			authentication.Post("/login/mfa", timeout.NewWithContext(handler.LoginMFA, cfg.Server.WriteTimeout))
+			authentication.Post("/unlock", sessionAuth, timeout.NewWithContext(handler.Unlock, cfg.Server.WriteTimeout))
+
+			// Users held to enroll in MFA by the policy of their role can still reach these.
+			mfa := authentication.Group("/mfa", enrollmentAuth)
+			{
+				mfa.Post("/totp", timeout.NewWithContext(handler.EnrollTOTP, cfg.Server.WriteTimeout))
+				mfa.Get("/totp/qr", timeout.NewWithContext(handler.GetTOTPQRCode, cfg.Server.ReadTimeout))
+				mfa.Post("/totp/activate", timeout.NewWithContext(handler.ActivateTOTP, cfg.Server.WriteTimeout))
+				mfa.Post("/totp/disable", timeout.NewWithContext(handler.DisableTOTP, cfg.Server.WriteTimeout))
+				mfa.Post("/recovery-codes", timeout.NewWithContext(handler.RegenerateRecoveryCodes, cfg.Server.WriteTimeout))
+				mfa.Get("/policies", timeout.NewWithContext(handler.GetMFAPolicies, cfg.Server.ReadTimeout))
+				mfa.Put("/policies", timeout.NewWithContext(handler.SetMFAPolicy, cfg.Server.WriteTimeout))
+			}
+		}
+
+		me := api.Group("/me")
+		{
+			me.Get("/", sessionAuth, timeout.NewWithContext(handler.GetProfile, cfg.Server.ReadTimeout))
+			me.Patch("/", sessionAuth, timeout.NewWithContext(handler.UpdateProfile, cfg.Server.WriteTimeout))
+			me.Delete("/", sessionAuth, timeout.NewWithContext(handler.DeleteAccount, cfg.Server.WriteTimeout))
+			me.Put("/password", sessionAuth, timeout.NewWithContext(handler.ChangePassword, cfg.Server.WriteTimeout))
+			me.Post("/api-keys", sessionAuth, timeout.NewWithContext(handler.CreateAPIKey, cfg.Server.WriteTimeout))
+			me.Get("/api-keys", sessionAuth, timeout.NewWithContext(handler.GetAPIKeys, cfg.Server.ReadTimeout))
+			me.Delete("/api-keys/:id", sessionAuth, timeout.NewWithContext(handler.RevokeAPIKey, cfg.Server.WriteTimeout))
+			me.Get("/sessions", sessionAuth, timeout.NewWithContext(handler.GetSessions, cfg.Server.ReadTimeout))
+			me.Delete("/sessions", sessionAuth, timeout.NewWithContext(handler.RevokeSessions, cfg.Server.WriteTimeout))
+			me.Delete("/sessions/:id", sessionAuth, timeout.NewWithContext(handler.RevokeSession, cfg.Server.WriteTimeout))
+		}
+
+		users := api.Group("/users")
+		{
+			users.Get("/", sessionAuth, timeout.NewWithContext(handler.GetUsers, cfg.Server.ReadTimeout))
+			users.Post("/:id/disable", sessionAuth, timeout.NewWithContext(handler.DisableUser, cfg.Server.WriteTimeout))
+			users.Post("/:id/enable", sessionAuth, timeout.NewWithContext(handler.EnableUser, cfg.Server.WriteTimeout))
+			users.Post("/:id/password-reset", sessionAuth, timeout.NewWithContext(handler.ForcePasswordReset, cfg.Server.WriteTimeout))
 		}
 
 		cats := api.Group("/cats")
@@ -31,23 +84,84 @@ func (s Server) initRoutes(app *fiber.App, handler *handler.Handler, cfg *config
 			cats.Get("/:id", basicAuth, timeout.NewWithContext(handler.GetCat, cfg.Server.ReadTimeout))
 			cats.Put("/:id", basicAuth, timeout.NewWithContext(handler.UpdateCat, cfg.Server.WriteTimeout))
 			cats.Delete("/:id", basicAuth, timeout.NewWithContext(handler.DeleteCat, cfg.Server.WriteTimeout))
+			cats.Get("/:id/salaries", basicAuth, timeout.NewWithContext(handler.GetSalaries, cfg.Server.ReadTimeout))
+			cats.Post("/:id/salaries", basicAuth, timeout.NewWithContext(handler.CreateSalary, cfg.Server.WriteTimeout))
+			cats.Put("/:id/skills/:skill_id", basicAuth, timeout.NewWithContext(handler.SetCatSkill, cfg.Server.WriteTimeout))
+			cats.Delete("/:id/skills/:skill_id", basicAuth, timeout.NewWithContext(handler.DeleteCatSkill, cfg.Server.WriteTimeout))
 		}
 
 		missions := api.Group("/missions")
 		{
 			missions.Post("/", basicAuth, timeout.NewWithContext(handler.CreateMission, cfg.Server.WriteTimeout))
 			missions.Get("/", basicAuth, timeout.NewWithContext(handler.GetMissions, cfg.Server.ReadTimeout))
+			missions.Get("/:id.geojson", basicAuth, timeout.NewWithContext(handler.GetMissionGeoJSON, cfg.Server.ReadTimeout))
+			missions.Get("/assignments/proposal", basicAuth, timeout.NewWithContext(handler.GetAssignmentProposal, cfg.Server.ReadTimeout))
 			missions.Get("/:id", basicAuth, timeout.NewWithContext(handler.GetMission, cfg.Server.ReadTimeout))
+			missions.Get("/:id/candidates", basicAuth, timeout.NewWithContext(handler.GetCandidates, cfg.Server.ReadTimeout))
+			missions.Get("/:id/messages", basicAuth, timeout.NewWithContext(handler.GetMessages, cfg.Server.ReadTimeout))
+			missions.Get("/:id/financials", basicAuth, timeout.NewWithContext(handler.GetFinancials, cfg.Server.ReadTimeout))
+			missions.Post("/:id/expenses", basicAuth, timeout.NewWithContext(handler.CreateExpense, cfg.Server.WriteTimeout))
+			missions.Put("/:id/schedule", basicAuth, timeout.NewWithContext(handler.UpdateSchedule, cfg.Server.WriteTimeout))
+			missions.Put("/:id/budget", basicAuth, timeout.NewWithContext(handler.UpdateBudget, cfg.Server.WriteTimeout))
+			missions.Put("/:id/skills/:skill_id", basicAuth, timeout.NewWithContext(handler.SetMissionSkill, cfg.Server.WriteTimeout))
+			missions.Delete("/:id/skills/:skill_id", basicAuth, timeout.NewWithContext(handler.DeleteMissionSkill, cfg.Server.WriteTimeout))
 			missions.Patch("/:mission_id/cats/:cat_id", basicAuth, timeout.NewWithContext(handler.AssignMissionToCat, cfg.Server.WriteTimeout))
 			missions.Patch("/:id", basicAuth, timeout.NewWithContext(handler.CompleteMission, cfg.Server.WriteTimeout))
 			missions.Delete("/:id", basicAuth, timeout.NewWithContext(handler.DeleteMission, cfg.Server.WriteTimeout))
 			missions.Patch("/:mission_id/targets/:target_id", basicAuth, timeout.NewWithContext(handler.AddTargetToMission, cfg.Server.WriteTimeout))
+			missions.Delete("/:mission_id/targets/:target_id", basicAuth, timeout.NewWithContext(handler.RemoveTargetFromMission, cfg.Server.WriteTimeout))
 		}
 
+		api.Get("/targets.geojson", basicAuth, timeout.NewWithContext(handler.GetTargetsGeoJSON, cfg.Server.ReadTimeout))
 		targets := api.Group("/targets")
 		{
+			targets.Post("/", basicAuth, timeout.NewWithContext(handler.CreateTarget, cfg.Server.WriteTimeout))
+			targets.Get("/", basicAuth, timeout.NewWithContext(handler.GetTargets, cfg.Server.ReadTimeout))
+			targets.Get("/duplicates", basicAuth, timeout.NewWithContext(handler.GetMergeCandidates, cfg.Server.ReadTimeout))
+			targets.Post("/duplicates/dismiss", basicAuth, timeout.NewWithContext(handler.DismissMergeCandidate, cfg.Server.WriteTimeout))
+			targets.Post("/merge", basicAuth, timeout.NewWithContext(handler.MergeTargets, cfg.Server.WriteTimeout))
+			targets.Get("/:id", basicAuth, timeout.NewWithContext(handler.GetTarget, cfg.Server.ReadTimeout))
+			targets.Post("/:id/aliases", basicAuth, timeout.NewWithContext(handler.AddTargetAlias, cfg.Server.WriteTimeout))
+			targets.Delete("/:id/aliases/:alias", basicAuth, timeout.NewWithContext(handler.DeleteTargetAlias, cfg.Server.WriteTimeout))
+			targets.Post("/:id/photos", basicAuth, timeout.NewWithContext(handler.AddTargetPhoto, cfg.Server.WriteTimeout))
+			targets.Post("/:id/notes", basicAuth, timeout.NewWithContext(handler.AddTargetNote, cfg.Server.WriteTimeout))
+			targets.Post("/:id/relationships", basicAuth, timeout.NewWithContext(handler.AddTargetRelationship, cfg.Server.WriteTimeout))
+			targets.Delete("/:id/relationships/:relationship_id", basicAuth, timeout.NewWithContext(handler.DeleteTargetRelationship, cfg.Server.WriteTimeout))
+			targets.Get("/:id/graph", basicAuth, timeout.NewWithContext(handler.GetTargetGraph, cfg.Server.ReadTimeout))
+			targets.Put("/:id/skills/:skill_id", basicAuth, timeout.NewWithContext(handler.SetTargetSkill, cfg.Server.WriteTimeout))
+			targets.Delete("/:id/skills/:skill_id", basicAuth, timeout.NewWithContext(handler.DeleteTargetSkill, cfg.Server.WriteTimeout))
 			targets.Patch("/:id", basicAuth, timeout.NewWithContext(handler.CompleteTarget, cfg.Server.WriteTimeout))
 		}
 
+		skills := api.Group("/skills")
+		{
+			skills.Post("/", basicAuth, timeout.NewWithContext(handler.CreateSkill, cfg.Server.WriteTimeout))
+			skills.Get("/", basicAuth, timeout.NewWithContext(handler.GetSkills, cfg.Server.ReadTimeout))
+		}
+
+		webhooks := api.Group("/webhooks")
+		{
+			webhooks.Post("/", sessionAuth, timeout.NewWithContext(handler.CreateWebhook, cfg.Server.WriteTimeout))
+			webhooks.Get("/", sessionAuth, timeout.NewWithContext(handler.GetWebhooks, cfg.Server.ReadTimeout))
+			webhooks.Post("/deliveries/:id/redeliver", sessionAuth, timeout.NewWithContext(handler.Redeliver, cfg.Server.WriteTimeout))
+			webhooks.Get("/:id", sessionAuth, timeout.NewWithContext(handler.GetWebhook, cfg.Server.ReadTimeout))
+			webhooks.Put("/:id", sessionAuth, timeout.NewWithContext(handler.UpdateWebhook, cfg.Server.WriteTimeout))
+			webhooks.Delete("/:id", sessionAuth, timeout.NewWithContext(handler.DeleteWebhook, cfg.Server.WriteTimeout))
+			webhooks.Get("/:id/deliveries", sessionAuth, timeout.NewWithContext(handler.GetWebhookDeliveries, cfg.Server.ReadTimeout))
+		}
+
+		notifications := api.Group("/notifications")
+		{
+			notifications.Get("/preferences", basicAuth, timeout.NewWithContext(handler.GetNotificationPreferences, cfg.Server.ReadTimeout))
+			notifications.Put("/preferences", basicAuth, timeout.NewWithContext(handler.UpdateNotificationPreferences, cfg.Server.WriteTimeout))
+		}
+
+		// The stream is long-lived, it is not wrapped in the timeout middleware.
+		api.Get("/events/stream", basicAuth, handler.GetEventStream)
+		api.Get("/events/metrics", basicAuth, timeout.NewWithContext(handler.GetEventBusMetrics, cfg.Server.ReadTimeout))
+
+		api.Get("/payroll", basicAuth, timeout.NewWithContext(handler.GetPayroll, cfg.Server.ReadTimeout))
+		api.Get("/audit", basicAuth, timeout.NewWithContext(handler.GetAuditEntries, cfg.Server.ReadTimeout))
+
 	}
 }
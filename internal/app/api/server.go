@@ -10,8 +10,10 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/markraiter/spycat/internal/app/api/handler"
+	"github.com/markraiter/spycat/internal/app/api/middleware"
 	"github.com/markraiter/spycat/internal/config"
 	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/jwt"
 )
 
 type Server struct {
@@ -19,13 +21,17 @@ type Server struct {
 }
 
 // New returns new instance of the Server.
-func New(cfg *config.Config, handler *handler.Handler) *Server {
+func New(cfg *config.Config, handler *handler.Handler, sessions middleware.SessionStore, keys *jwt.KeySet) *Server {
 	server := new(Server)
 
 	fconfig := fiber.Config{
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
+		// Login throttling counts failures per client IP address.
+		ProxyHeader:             cfg.Server.ProxyHeader,
+		EnableTrustedProxyCheck: cfg.Server.ProxyHeader != "",
+		TrustedProxies:          cfg.Server.TrustedProxies,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 
@@ -47,7 +53,7 @@ func New(cfg *config.Config, handler *handler.Handler) *Server {
 	server.HTTPServer.Use(recover.New())
 	server.HTTPServer.Use(logger.New())
 	server.HTTPServer.Use(cors.New(corsConfig()))
-	server.initRoutes(server.HTTPServer, handler, cfg)
+	server.initRoutes(server.HTTPServer, handler, sessions, keys, cfg)
 
 	return server
 }
@@ -61,7 +67,7 @@ func (s *Server) Shutdown(ctx context.Context) error {
 func corsConfig() cors.Config {
 	return cors.Config{
 		AllowOrigins:     "*",
-		AllowHeaders:     "Origin, Content-Type, Accept, Access-Control-Allow-Credentials, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Access-Control-Allow-Credentials, Authorization, Last-Event-ID",
 		AllowMethods:     "GET, POST, PUT, PATCH, DELETE",
 		AllowCredentials: false,
 	}
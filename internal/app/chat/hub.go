@@ -0,0 +1,213 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+// clientBuffer is how many frames a client may fall behind before it is dropped.
+const clientBuffer = 32
+
+type Notifier interface {
+	Notify(ctx context.Context, channel, payload string) error
+}
+
+type Listener interface {
+	NotificationChannel() <-chan *pq.Notification
+}
+
+// Client is a socket connected to the chat of a mission.
+type Client struct {
+	User *domain.User
+	send chan *domain.ChatFrame
+}
+
+func NewClient(user *domain.User) *Client {
+	return &Client{
+		User: user,
+		send: make(chan *domain.ChatFrame, clientBuffer),
+	}
+}
+
+// Frames returns the frames to write to the socket. The channel is closed
+// when the client leaves or falls behind.
+func (c *Client) Frames() <-chan *domain.ChatFrame {
+	return c.send
+}
+
+type room struct {
+	clients map[*Client]struct{}
+	// online counts connections per user across all replicas.
+	online map[int]*presence
+}
+
+type presence struct {
+	username string
+	count    int
+}
+
+// Hub routes chat frames between the clients of mission rooms.
+//
+// Frames are published through Postgres notifications and delivered to the local
+// clients when the notification comes back, so clients connected to different
+// replicas share the same rooms. Presence is tracked from presence frames.
+type Hub struct {
+	log      *slog.Logger
+	notifier Notifier
+	channel  string
+
+	mu    sync.Mutex
+	rooms map[int]*room
+}
+
+func NewHub(log *slog.Logger, notifier Notifier, channel string) *Hub {
+	return &Hub{
+		log:      log,
+		notifier: notifier,
+		channel:  channel,
+		rooms:    make(map[int]*room),
+	}
+}
+
+// Join adds the client to the room of the mission, announces it and returns
+// the users connected to the room.
+func (h *Hub) Join(ctx context.Context, missionID int, c *Client) ([]domain.ChatUser, error) {
+	h.mu.Lock()
+	r, ok := h.rooms[missionID]
+	if !ok {
+		r = &room{clients: make(map[*Client]struct{}), online: make(map[int]*presence)}
+		h.rooms[missionID] = r
+	}
+	r.clients[c] = struct{}{}
+
+	users := make([]domain.ChatUser, 0, len(r.online)+1)
+	for id, p := range r.online {
+		users = append(users, domain.ChatUser{UserID: id, Username: p.username})
+	}
+	if _, ok := r.online[c.User.ID]; !ok {
+		users = append(users, domain.ChatUser{UserID: c.User.ID, Username: c.User.Username})
+	}
+	h.mu.Unlock()
+
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+
+	return users, h.Publish(ctx, h.presence(missionID, c, domain.PresenceOnline))
+}
+
+// Leave removes the client from the room of the mission and announces it.
+func (h *Hub) Leave(ctx context.Context, missionID int, c *Client) error {
+	h.mu.Lock()
+	if r, ok := h.rooms[missionID]; ok {
+		if _, ok := r.clients[c]; ok {
+			delete(r.clients, c)
+			close(c.send)
+		}
+	}
+	h.mu.Unlock()
+
+	return h.Publish(ctx, h.presence(missionID, c, domain.PresenceOffline))
+}
+
+// Publish sends the frame to every client in the room of frame.MissionID on every replica.
+func (h *Hub) Publish(ctx context.Context, frame *domain.ChatFrame) error {
+	const op = "chat.Publish"
+
+	payload, err := json.Marshal(frame)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := h.notifier.Notify(ctx, h.channel, string(payload)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Run delivers frames published by any replica to the local clients until ctx is cancelled.
+func (h *Hub) Run(ctx context.Context, listener Listener) {
+	const op = "chat.Run"
+	log := h.log.With(slog.String("operation", op))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.NotificationChannel():
+			// Frames sent while the connection was down are lost, chat history
+			// is still available through the REST endpoint.
+			if n == nil {
+				continue
+			}
+
+			var frame domain.ChatFrame
+			if err := json.Unmarshal([]byte(n.Extra), &frame); err != nil {
+				log.Error("error while decoding chat frame", sl.Err(err))
+				continue
+			}
+
+			h.deliver(&frame)
+		}
+	}
+}
+
+func (h *Hub) deliver(frame *domain.ChatFrame) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.rooms[frame.MissionID]
+	if !ok {
+		if frame.Type == domain.FramePresence && frame.Status == domain.PresenceOnline {
+			r = &room{clients: make(map[*Client]struct{}), online: make(map[int]*presence)}
+			h.rooms[frame.MissionID] = r
+		} else {
+			return
+		}
+	}
+
+	if frame.Type == domain.FramePresence {
+		p, ok := r.online[frame.UserID]
+		switch {
+		case frame.Status == domain.PresenceOnline && ok:
+			p.count++
+		case frame.Status == domain.PresenceOnline:
+			r.online[frame.UserID] = &presence{username: frame.Username, count: 1}
+		case ok:
+			p.count--
+			if p.count <= 0 {
+				delete(r.online, frame.UserID)
+			}
+		}
+	}
+
+	for c := range r.clients {
+		select {
+		case c.send <- frame:
+		default:
+			delete(r.clients, c)
+			close(c.send)
+		}
+	}
+
+	if len(r.clients) == 0 && len(r.online) == 0 {
+		delete(h.rooms, frame.MissionID)
+	}
+}
+
+func (h *Hub) presence(missionID int, c *Client, status domain.PresenceStatus) *domain.ChatFrame {
+	return &domain.ChatFrame{
+		Type:      domain.FramePresence,
+		MissionID: missionID,
+		UserID:    c.User.ID,
+		Username:  c.User.Username,
+		Status:    status,
+	}
+}
@@ -0,0 +1,111 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// loopback delivers published frames straight back to the hub, as the
+// Postgres notification would.
+type loopback struct {
+	hub *Hub
+}
+
+func (l *loopback) Notify(_ context.Context, _, payload string) error {
+	var frame domain.ChatFrame
+	if err := json.Unmarshal([]byte(payload), &frame); err != nil {
+		return err
+	}
+	l.hub.deliver(&frame)
+
+	return nil
+}
+
+func newHub() *Hub {
+	l := &loopback{}
+	l.hub = NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)), l, "mission_chat")
+
+	return l.hub
+}
+
+func TestHubPresence(t *testing.T) {
+	hub := newHub()
+	ctx := context.Background()
+
+	alice := NewClient(&domain.User{ID: 1, Username: "alice"})
+	bob := NewClient(&domain.User{ID: 2, Username: "bob"})
+
+	users, err := hub.Join(ctx, 7, alice)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.ChatUser{{UserID: 1, Username: "alice"}}, users)
+
+	users, err = hub.Join(ctx, 7, bob)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.ChatUser{{UserID: 1, Username: "alice"}, {UserID: 2, Username: "bob"}}, users)
+
+	// alice sees her own presence and then bob joining.
+	frame := <-alice.Frames()
+	assert.Equal(t, domain.PresenceOnline, frame.Status)
+	assert.Equal(t, 1, frame.UserID)
+	frame = <-alice.Frames()
+	assert.Equal(t, domain.PresenceOnline, frame.Status)
+	assert.Equal(t, 2, frame.UserID)
+
+	assert.NoError(t, hub.Leave(ctx, 7, bob))
+	frame = <-alice.Frames()
+	assert.Equal(t, domain.PresenceOffline, frame.Status)
+	assert.Equal(t, 2, frame.UserID)
+
+	_, ok := <-bob.Frames()
+	for ok {
+		_, ok = <-bob.Frames()
+	}
+	assert.False(t, ok, "frames of a client that left are not closed")
+}
+
+func TestHubRoomsAreIsolated(t *testing.T) {
+	hub := newHub()
+	ctx := context.Background()
+
+	alice := NewClient(&domain.User{ID: 1, Username: "alice"})
+	bob := NewClient(&domain.User{ID: 2, Username: "bob"})
+
+	_, err := hub.Join(ctx, 1, alice)
+	assert.NoError(t, err)
+	_, err = hub.Join(ctx, 2, bob)
+	assert.NoError(t, err)
+	<-alice.Frames()
+	<-bob.Frames()
+
+	err = hub.Publish(ctx, &domain.ChatFrame{Type: domain.FrameMessage, MissionID: 1, UserID: 1, Body: "hi"})
+	assert.NoError(t, err)
+
+	frame := <-alice.Frames()
+	assert.Equal(t, domain.FrameMessage, frame.Type)
+	assert.Empty(t, bob.Frames())
+}
+
+func TestHubDropsSlowClient(t *testing.T) {
+	hub := newHub()
+	ctx := context.Background()
+
+	c := NewClient(&domain.User{ID: 1, Username: "alice"})
+	_, err := hub.Join(ctx, 1, c)
+	assert.NoError(t, err)
+
+	for i := 0; i < clientBuffer; i++ {
+		assert.NoError(t, hub.Publish(ctx, &domain.ChatFrame{Type: domain.FrameTyping, MissionID: 1, UserID: 1}))
+	}
+
+	n := 0
+	for range c.Frames() {
+		n++
+	}
+	assert.Equal(t, clientBuffer, n)
+}
@@ -0,0 +1,231 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+// Handler reacts to a domain event. Returned errors are logged and counted,
+// they never reach the publisher.
+type Handler func(ctx context.Context, event *domain.Event) error
+
+// Publisher is what services publish committed events to.
+type Publisher interface {
+	Publish(ctx context.Context, events ...*domain.Event)
+}
+
+type subscriber struct {
+	name    string
+	handler Handler
+	types   map[domain.EventType]struct{}
+	// queue is nil for synchronous subscribers.
+	queue chan delivery
+
+	delivered atomic.Int64
+	failed    atomic.Int64
+	panics    atomic.Int64
+	dropped   atomic.Int64
+}
+
+type delivery struct {
+	ctx   context.Context
+	event *domain.Event
+}
+
+func (s *subscriber) wants(t domain.EventType) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	_, ok := s.types[t]
+
+	return ok
+}
+
+// Bus is the in-process event bus of the service layer.
+//
+// Services publish events once their transaction is committed. Synchronous
+// subscribers run in the publishing goroutine, asynchronous ones get their own
+// queue and worker so a slow subscriber never holds up a request; events that
+// do not fit in the queue are dropped and counted. A subscriber that fails or
+// panics does not affect the publisher or the other subscribers.
+type Bus struct {
+	log       *slog.Logger
+	queueSize int
+
+	mu          sync.RWMutex
+	subscribers []*subscriber
+	closed      bool
+	wg          sync.WaitGroup
+
+	published atomic.Int64
+}
+
+func New(log *slog.Logger, cfg config.Events) *Bus {
+	return &Bus{
+		log:       log,
+		queueSize: cfg.QueueSize,
+	}
+}
+
+// Subscribe registers a handler run synchronously for events of the given types,
+// or for every event when no type is given.
+func (b *Bus) Subscribe(name string, h Handler, types ...domain.EventType) {
+	b.subscribe(&subscriber{name: name, handler: h, types: typeSet(types)})
+}
+
+// SubscribeAsync registers a handler run on its own worker for events of the given
+// types, or for every event when no type is given.
+func (b *Bus) SubscribeAsync(name string, h Handler, types ...domain.EventType) {
+	s := &subscriber{name: name, handler: h, types: typeSet(types), queue: make(chan delivery, b.queueSize)}
+	if !b.subscribe(s) {
+		return
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for d := range s.queue {
+			b.handle(d.ctx, s, d.event)
+		}
+	}()
+}
+
+func (b *Bus) subscribe(s *subscriber) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return false
+	}
+	b.subscribers = append(b.subscribers, s)
+
+	return true
+}
+
+// Publish hands the events to the subscribers. It must only be called after
+// the transaction recording the events is committed.
+func (b *Bus) Publish(ctx context.Context, events ...*domain.Event) {
+	const op = "events.Publish"
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return
+	}
+
+	// Async handlers outlive the request that published the event.
+	detached := context.WithoutCancel(ctx)
+
+	for _, event := range events {
+		b.published.Add(1)
+
+		for _, s := range b.subscribers {
+			if !s.wants(event.Type) {
+				continue
+			}
+
+			if s.queue == nil {
+				b.handle(ctx, s, event)
+				continue
+			}
+
+			select {
+			case s.queue <- delivery{ctx: detached, event: event}:
+			default:
+				s.dropped.Add(1)
+				b.log.Warn("event dropped, subscriber queue is full",
+					slog.String("operation", op),
+					slog.String("subscriber", s.name),
+					slog.Int64("event_id", event.ID),
+				)
+			}
+		}
+	}
+}
+
+// handle runs the handler of the subscriber, recovering from a panic.
+func (b *Bus) handle(ctx context.Context, s *subscriber, event *domain.Event) {
+	const op = "events.handle"
+	log := b.log.With(
+		slog.String("operation", op),
+		slog.String("subscriber", s.name),
+		slog.Int64("event_id", event.ID),
+		slog.String("event_type", string(event.Type)),
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.panics.Add(1)
+			log.Error("event subscriber panicked", sl.Err(fmt.Errorf("%v", r)), slog.String("stack", string(debug.Stack())))
+		}
+	}()
+
+	if err := s.handler(ctx, event); err != nil {
+		s.failed.Add(1)
+		log.Error("event subscriber failed", sl.Err(err))
+		return
+	}
+
+	s.delivered.Add(1)
+}
+
+// Close stops accepting events and waits for the async subscribers to drain their queues.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	for _, s := range b.subscribers {
+		if s.queue != nil {
+			close(s.queue)
+		}
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+// Metrics returns the counters of the bus and of every subscriber.
+func (b *Bus) Metrics() *domain.BusMetrics {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	metrics := &domain.BusMetrics{
+		Published:   b.published.Load(),
+		Subscribers: make([]domain.SubscriberMetrics, 0, len(b.subscribers)),
+	}
+
+	for _, s := range b.subscribers {
+		metrics.Subscribers = append(metrics.Subscribers, domain.SubscriberMetrics{
+			Name:      s.name,
+			Async:     s.queue != nil,
+			Delivered: s.delivered.Load(),
+			Failed:    s.failed.Load(),
+			Panics:    s.panics.Load(),
+			Dropped:   s.dropped.Load(),
+			Queued:    len(s.queue),
+		})
+	}
+
+	return metrics
+}
+
+func typeSet(types []domain.EventType) map[domain.EventType]struct{} {
+	set := make(map[domain.EventType]struct{}, len(types))
+	for _, t := range types {
+		set[t] = struct{}{}
+	}
+
+	return set
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBus(queueSize int) *Bus {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), config.Events{QueueSize: queueSize})
+}
+
+func metricsOf(b *Bus, name string) domain.SubscriberMetrics {
+	for _, m := range b.Metrics().Subscribers {
+		if m.Name == name {
+			return m
+		}
+	}
+
+	return domain.SubscriberMetrics{}
+}
+
+func TestBusSync(t *testing.T) {
+	bus := newBus(10)
+	defer bus.Close()
+
+	var got []int64
+	bus.Subscribe("completed", func(_ context.Context, e *domain.Event) error {
+		got = append(got, e.ID)
+		return nil
+	}, domain.EventMissionCompleted)
+
+	bus.Publish(context.Background(),
+		&domain.Event{ID: 1, Type: domain.EventMissionCompleted},
+		&domain.Event{ID: 2, Type: domain.EventTargetCompleted},
+		&domain.Event{ID: 3, Type: domain.EventMissionCompleted},
+	)
+
+	assert.Equal(t, []int64{1, 3}, got)
+	assert.Equal(t, int64(3), bus.Metrics().Published)
+	assert.Equal(t, int64(2), metricsOf(bus, "completed").Delivered)
+}
+
+func TestBusIsolatesFailures(t *testing.T) {
+	bus := newBus(10)
+	defer bus.Close()
+
+	bus.Subscribe("panics", func(context.Context, *domain.Event) error {
+		panic("boom")
+	})
+	bus.Subscribe("fails", func(context.Context, *domain.Event) error {
+		return errors.New("failed")
+	})
+
+	var delivered bool
+	bus.Subscribe("works", func(context.Context, *domain.Event) error {
+		delivered = true
+		return nil
+	})
+
+	assert.NotPanics(t, func() {
+		bus.Publish(context.Background(), &domain.Event{ID: 1, Type: domain.EventCatCreated})
+	})
+
+	assert.True(t, delivered)
+	assert.Equal(t, int64(1), metricsOf(bus, "panics").Panics)
+	assert.Equal(t, int64(1), metricsOf(bus, "fails").Failed)
+	assert.Equal(t, int64(1), metricsOf(bus, "works").Delivered)
+}
+
+func TestBusAsync(t *testing.T) {
+	bus := newBus(10)
+
+	var (
+		mu  sync.Mutex
+		got []int64
+	)
+	bus.SubscribeAsync("async", func(ctx context.Context, e *domain.Event) error {
+		assert.NoError(t, ctx.Err(), "async handler got the cancelled request context")
+		mu.Lock()
+		got = append(got, e.ID)
+		mu.Unlock()
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bus.Publish(ctx, &domain.Event{ID: 1}, &domain.Event{ID: 2})
+	cancel()
+
+	bus.Close()
+
+	assert.Equal(t, []int64{1, 2}, got)
+	assert.Equal(t, int64(2), metricsOf(bus, "async").Delivered)
+
+	// Events published after Close are ignored.
+	bus.Publish(context.Background(), &domain.Event{ID: 3})
+	assert.Equal(t, int64(2), bus.Metrics().Published)
+}
+
+func TestBusDropsWhenQueueIsFull(t *testing.T) {
+	bus := newBus(1)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	bus.SubscribeAsync("slow", func(context.Context, *domain.Event) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	})
+
+	bus.Publish(context.Background(), &domain.Event{ID: 1})
+	<-started
+	// The worker is busy with event 1, event 2 fills the queue and event 3 is dropped.
+	bus.Publish(context.Background(), &domain.Event{ID: 2}, &domain.Event{ID: 3})
+
+	m := metricsOf(bus, "slow")
+	assert.Equal(t, int64(1), m.Dropped)
+	assert.Equal(t, 1, m.Queued)
+
+	close(release)
+	bus.Close()
+
+	assert.Equal(t, int64(2), metricsOf(bus, "slow").Delivered)
+}
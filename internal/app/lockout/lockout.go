@@ -0,0 +1,213 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// Guard throttles logins. Every failed login of an account makes the next
+// attempt wait longer, from cfg.DelayBase doubling up to cfg.DelayMax, and after
+// cfg.AccountThreshold failures within cfg.Window the account is locked for
+// cfg.Duration. An IP address is locked after cfg.IPThreshold failures, whatever
+// the accounts tried, so spreading guesses over many accounts does not help.
+type Guard struct {
+	cfg   config.Lockout
+	store Store
+	now   func() time.Time
+}
+
+func New(cfg config.Lockout, store Store) *Guard {
+	return &Guard{
+		cfg:   cfg,
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// Reserve returns how long a login of the email from the IP address has to wait,
+// 0 if it may go ahead. A login going ahead counts as failed right away, before
+// the credentials are checked, so guesses sent at once cannot all get through
+// before the first of them fails. Release takes the attempt back.
+func (g *Guard) Reserve(ctx context.Context, email, ip string) (time.Duration, error) {
+	const op = "lockout.Reserve"
+
+	now := g.now()
+
+	wait, err := g.reserve(ctx, accountKey(email), g.cfg.AccountThreshold, true, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if wait > 0 || ip == "" {
+		return wait, nil
+	}
+
+	wait, err = g.reserve(ctx, ipKey(ip), g.cfg.IPThreshold, false, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if wait > 0 {
+		// The attempt is not made after all.
+		if err := g.release(ctx, accountKey(email), g.cfg.AccountThreshold); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return wait, nil
+}
+
+// Release takes back the attempt reserved for a login whose credentials were right.
+func (g *Guard) Release(ctx context.Context, email, ip string) error {
+	const op = "lockout.Release"
+
+	if err := g.release(ctx, accountKey(email), g.cfg.AccountThreshold); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if ip == "" {
+		return nil
+	}
+
+	if err := g.release(ctx, ipKey(ip), g.cfg.IPThreshold); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// reserve counts an attempt of the key as failed unless it is locked or, when
+// delayed, the delay after the last failure has not passed yet. The key is
+// locked once its failures reach the threshold.
+func (g *Guard) reserve(ctx context.Context, key string, threshold int, delayed bool, now time.Time) (time.Duration, error) {
+	var wait time.Duration
+
+	err := g.store.UpdateLoginAttempts(ctx, key, func(attempts *domain.LoginAttempts) bool {
+		recent := !attempts.LastFailure.Before(now.Add(-g.cfg.Window))
+
+		wait = lockedFor(attempts.LockedUntil, now)
+		if delayed && attempts.Failures > 0 && recent {
+			wait = max(wait, attempts.LastFailure.Add(g.delay(attempts.Failures)).Sub(now))
+		}
+
+		if wait > 0 {
+			return false
+		}
+
+		// Failures older than the window are forgotten, the count starts over.
+		if !recent {
+			attempts.Failures = 0
+		}
+
+		attempts.Failures++
+		attempts.LastFailure = now
+
+		if threshold > 0 && attempts.Failures >= threshold {
+			until := now.Add(g.cfg.Duration)
+			attempts.LockedUntil = &until
+		}
+
+		return true
+	})
+
+	return max(wait, 0), err
+}
+
+// release uncounts a reserved attempt of the key, and the lock it may have set.
+// Past the threshold every attempt locks again, so a lock ending Duration after
+// the last failure was set by the attempt released.
+func (g *Guard) release(ctx context.Context, key string, threshold int) error {
+	return g.store.UpdateLoginAttempts(ctx, key, func(attempts *domain.LoginAttempts) bool {
+		if attempts.Failures == 0 {
+			return false
+		}
+
+		attempts.Failures--
+
+		locked := attempts.LockedUntil != nil
+		if locked && (attempts.Failures < threshold || attempts.LockedUntil.Equal(attempts.LastFailure.Add(g.cfg.Duration))) {
+			attempts.LockedUntil = nil
+		}
+
+		return true
+	})
+}
+
+// Succeed forgets the failed logins of the email. Those of the IP address are
+// kept, a correct password for one account says nothing of the others tried.
+func (g *Guard) Succeed(ctx context.Context, email string) error {
+	const op = "lockout.Succeed"
+
+	if err := g.store.DeleteLoginAttempts(ctx, accountKey(email)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Unlock forgets the failed logins of the email and of the IP address, either may be empty.
+func (g *Guard) Unlock(ctx context.Context, email, ip string) error {
+	const op = "lockout.Unlock"
+
+	if email != "" {
+		if err := g.store.DeleteLoginAttempts(ctx, accountKey(email)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if ip != "" {
+		if err := g.store.DeleteLoginAttempts(ctx, ipKey(ip)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// Purge deletes the failed logins that neither count nor lock anymore, so the
+// store does not keep every account and IP address that ever failed a login.
+func (g *Guard) Purge(ctx context.Context) (int, error) {
+	const op = "lockout.Purge"
+
+	n, err := g.store.PurgeLoginAttempts(ctx, g.now(), g.cfg.Window)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// delay returns how long after the last of the failures the next attempt may be made.
+func (g *Guard) delay(failures int) time.Duration {
+	if g.cfg.DelayBase <= 0 {
+		return 0
+	}
+
+	delay := g.cfg.DelayBase
+	for i := 1; i < failures && delay < g.cfg.DelayMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, g.cfg.DelayMax)
+}
+
+func lockedFor(until *time.Time, now time.Time) time.Duration {
+	if until == nil {
+		return 0
+	}
+
+	return until.Sub(now)
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,205 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func testGuard() (*Guard, *time.Time) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	g := New(config.Lockout{
+		Window:           15 * time.Minute,
+		AccountThreshold: 3,
+		IPThreshold:      5,
+		Duration:         10 * time.Minute,
+		DelayBase:        time.Second,
+		DelayMax:         4 * time.Second,
+	}, NewMemoryStore())
+	g.now = func() time.Time { return now }
+
+	return g, &now
+}
+
+func TestGuardAccount(t *testing.T) {
+	ctx := context.Background()
+	g, now := testGuard()
+
+	wait, err := g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Zero(t, wait)
+
+	wait, _ = g.Reserve(ctx, "Alice@Example.com", "10.0.0.2")
+	assert.Equal(t, time.Second, wait, "email is not normalized")
+
+	*now = now.Add(time.Second)
+	wait, _ = g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.Zero(t, wait)
+
+	wait, _ = g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.Equal(t, 2*time.Second, wait, "delay does not double")
+
+	*now = now.Add(2 * time.Second)
+	wait, _ = g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.Zero(t, wait)
+
+	wait, _ = g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.Equal(t, 10*time.Minute, wait, "account not locked at the threshold")
+
+	wait, _ = g.Reserve(ctx, "bob@example.com", "10.0.0.1")
+	assert.Zero(t, wait, "lock of an account held back another")
+
+	// Failures older than the window are forgotten.
+	*now = now.Add(16 * time.Minute)
+	wait, _ = g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.Zero(t, wait)
+	wait, _ = g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.Equal(t, time.Second, wait)
+
+	assert.NoError(t, g.Succeed(ctx, "alice@example.com"))
+	wait, _ = g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.Zero(t, wait)
+}
+
+func TestGuardRelease(t *testing.T) {
+	ctx := context.Background()
+	g, now := testGuard()
+
+	for i := 0; i < 3; i++ {
+		wait, err := g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+		assert.NoError(t, err)
+		assert.Zero(t, wait)
+		*now = now.Add(time.Minute)
+	}
+
+	// The third attempt had the right password.
+	assert.NoError(t, g.Release(ctx, "alice@example.com", "10.0.0.1"))
+
+	attempts := g.store.(*MemoryStore).attempts
+	assert.Equal(t, 2, attempts[accountKey("alice@example.com")].Failures)
+	assert.Nil(t, attempts[accountKey("alice@example.com")].LockedUntil, "lock of the released attempt kept")
+	assert.Equal(t, 2, attempts[ipKey("10.0.0.1")].Failures)
+
+	wait, _ := g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.Zero(t, wait)
+	wait, _ = g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.Equal(t, 10*time.Minute, wait)
+
+	// Past the threshold the attempt made when the lock ends locks again, and
+	// releasing it unlocks.
+	*now = now.Add(10 * time.Minute)
+	wait, _ = g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.Zero(t, wait)
+	assert.NoError(t, g.Release(ctx, "alice@example.com", "10.0.0.1"))
+	assert.Nil(t, attempts[accountKey("alice@example.com")].LockedUntil)
+}
+
+func TestReserveConcurrent(t *testing.T) {
+	ctx := context.Background()
+	g, _ := testGuard()
+
+	const n = 50
+
+	var (
+		wg      sync.WaitGroup
+		allowed atomic.Int32
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			wait, err := g.Reserve(ctx, "alice@example.com", "10.0.0.1")
+			assert.NoError(t, err)
+			if wait == 0 {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), allowed.Load(), "guesses sent at once all went ahead")
+
+	attempts := g.store.(*MemoryStore).attempts
+	assert.Equal(t, 1, attempts[accountKey("alice@example.com")].Failures)
+	assert.Equal(t, 1, attempts[ipKey("10.0.0.1")].Failures, "attempts held back counted against the IP address")
+}
+
+func TestGuardIP(t *testing.T) {
+	ctx := context.Background()
+	g, _ := testGuard()
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+		wait, err := g.Reserve(ctx, email, "10.0.0.1")
+		assert.NoError(t, err)
+		assert.Zero(t, wait)
+	}
+
+	wait, _ := g.Reserve(ctx, "f@example.com", "10.0.0.1")
+	assert.Equal(t, 10*time.Minute, wait, "IP address not locked at the threshold")
+
+	wait, _ = g.Reserve(ctx, "f@example.com", "10.0.0.2")
+	assert.Zero(t, wait, "attempt held back by the IP address counted against the account")
+
+	assert.NoError(t, g.Unlock(ctx, "", "10.0.0.1"))
+	wait, _ = g.Reserve(ctx, "g@example.com", "10.0.0.1")
+	assert.Zero(t, wait)
+}
+
+func TestDelayCapped(t *testing.T) {
+	g, _ := testGuard()
+
+	assert.Equal(t, time.Second, g.delay(1))
+	assert.Equal(t, 4*time.Second, g.delay(3))
+	assert.Equal(t, 4*time.Second, g.delay(100))
+}
+
+func TestNewStore(t *testing.T) {
+	db := NewMemoryStore()
+
+	store, err := NewStore(config.Lockout{Store: StorePostgres}, db)
+	assert.NoError(t, err)
+	assert.Same(t, db, store)
+
+	_, err = NewStore(config.Lockout{Store: "redis"}, db)
+	assert.Error(t, err)
+}
+
+func TestGuardPurge(t *testing.T) {
+	ctx := context.Background()
+	g, now := testGuard()
+
+	g.Reserve(ctx, "alice@example.com", "") // nolint: errcheck
+	g.Reserve(ctx, "bob@example.com", "")   // nolint: errcheck
+	assert.NoError(t, g.store.UpdateLoginAttempts(ctx, accountKey("bob@example.com"), func(attempts *domain.LoginAttempts) bool {
+		until := now.Add(time.Hour)
+		attempts.LockedUntil = &until
+		return true
+	}))
+
+	*now = now.Add(5 * time.Minute)
+	g.Reserve(ctx, "carol@example.com", "") // nolint: errcheck
+
+	n, err := g.Purge(ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, n, "failures within the window purged")
+
+	*now = now.Add(15 * time.Minute)
+	n, _ = g.Purge(ctx)
+	assert.Equal(t, 1, n, "only alice is neither counted nor locked")
+
+	wait, _ := g.Reserve(ctx, "bob@example.com", "")
+	assert.Equal(t, 40*time.Minute, wait, "lock purged")
+
+	*now = now.Add(time.Hour)
+	n, _ = g.Purge(ctx)
+	assert.Equal(t, 2, n)
+}
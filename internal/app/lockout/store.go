@@ -0,0 +1,91 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+)
+
+// Store keeps the failed logins per key.
+type Store interface {
+	// UpdateLoginAttempts calls update with the failed logins of the key, a zero
+	// record if there are none, and saves the record unless update returns false.
+	// Updates of the same key run one after the other, never interleaved.
+	UpdateLoginAttempts(ctx context.Context, key string, update func(attempts *domain.LoginAttempts) bool) error
+	DeleteLoginAttempts(ctx context.Context, key string) error
+	// PurgeLoginAttempts deletes the records whose last failure is older than
+	// the window and that are not locked anymore, and returns how many.
+	PurgeLoginAttempts(ctx context.Context, now time.Time, window time.Duration) (int, error)
+}
+
+// NewStore returns a MemoryStore or db, as cfg.Store says. The memory store is
+// not shared between instances of the API and is emptied on restart.
+func NewStore(cfg config.Lockout, db Store) (Store, error) {
+	switch cfg.Store {
+	case StoreMemory:
+		return NewMemoryStore(), nil
+	case StorePostgres:
+		return db, nil
+	}
+
+	return nil, fmt.Errorf("lockout.NewStore: unknown store %q, expected %s or %s", cfg.Store, StoreMemory, StorePostgres)
+}
+
+// MemoryStore keeps the failed logins in the process.
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]domain.LoginAttempts
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: make(map[string]domain.LoginAttempts)}
+}
+
+func (s *MemoryStore) UpdateLoginAttempts(_ context.Context, key string, update func(attempts *domain.LoginAttempts) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts, ok := s.attempts[key]
+	if !ok {
+		attempts = domain.LoginAttempts{Key: key}
+	}
+
+	if update(&attempts) {
+		s.attempts[key] = attempts
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteLoginAttempts(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+
+	return nil
+}
+
+func (s *MemoryStore) PurgeLoginAttempts(_ context.Context, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for key, attempts := range s.attempts {
+		if attempts.LastFailure.Before(now.Add(-window)) && (attempts.LockedUntil == nil || attempts.LockedUntil.Before(now)) {
+			delete(s.attempts, key)
+			n++
+		}
+	}
+
+	return n, nil
+}
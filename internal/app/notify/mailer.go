@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// Mailer renders templated emails and hands them to a Sender.
+type Mailer struct {
+	from      string
+	appURL    string
+	sender    Sender
+	templates *Templates
+}
+
+func NewMailer(cfg config.Notify, sender Sender) (*Mailer, error) {
+	const op = "notify.NewMailer"
+
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("%s: invalid from address: %w", op, err)
+	}
+
+	if cfg.SMTPSecurity != SecurityPlain && cfg.SMTPSecurity != SecurityStartTLS {
+		return nil, fmt.Errorf("%s: unknown SMTP security %q, expected %s or %s", op, cfg.SMTPSecurity, SecurityPlain, SecurityStartTLS)
+	}
+
+	if _, err := url.Parse(cfg.AppURL); err != nil {
+		return nil, fmt.Errorf("%s: invalid app URL: %w", op, err)
+	}
+
+	templates, err := LoadTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Mailer{
+		from:      cfg.From,
+		appURL:    strings.TrimRight(cfg.AppURL, "/"),
+		sender:    sender,
+		templates: templates,
+	}, nil
+}
+
+// Send renders the named template with data and emails it to the recipient.
+func (m *Mailer) Send(ctx context.Context, template string, to domain.Recipient, data any) error {
+	const op = "notify.Mailer.Send"
+
+	msg := &Message{
+		From: m.from,
+		To:   []string{(&mail.Address{Name: to.Username, Address: to.Email}).String()},
+		Date: time.Now(),
+	}
+
+	if err := m.templates.Render(template, data, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.sender.Send(ctx, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+type accountData struct {
+	Recipient domain.Recipient
+	Link      string
+	Token     string
+	TTL       time.Duration
+}
+
+// SendVerificationEmail emails the user a link to verify the email address.
+func (m *Mailer) SendVerificationEmail(ctx context.Context, user *domain.User, token string, ttl time.Duration) error {
+	to := recipient(user)
+
+	return m.Send(ctx, TemplateVerifyEmail, to, accountData{
+		Recipient: to,
+		Link:      m.appURL + "/api/v1/auth/verify?token=" + url.QueryEscape(token),
+		Token:     token,
+		TTL:       ttl,
+	})
+}
+
+// SendPasswordResetEmail emails the user a password reset token.
+func (m *Mailer) SendPasswordResetEmail(ctx context.Context, user *domain.User, token string, ttl time.Duration) error {
+	to := recipient(user)
+
+	return m.Send(ctx, TemplatePasswordReset, to, accountData{
+		Recipient: to,
+		Link:      m.appURL + "/reset-password?token=" + url.QueryEscape(token),
+		Token:     token,
+		TTL:       ttl,
+	})
+}
+
+func recipient(user *domain.User) domain.Recipient {
+	return domain.Recipient{UserID: user.ID, Username: user.Username, Email: user.Email}
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/markraiter/spycat/internal/app/service"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+type Store interface {
+	NotificationRecipients(ctx context.Context, eventType domain.EventType, catID int) ([]domain.Recipient, error)
+	MissionByID(ctx context.Context, id int) (*domain.Mission, error)
+	Cat(ctx context.Context, id int) (*domain.Cat, error)
+}
+
+// Notifier emails the users concerned by an event, see Store.NotificationRecipients.
+type Notifier struct {
+	log    *slog.Logger
+	mailer *Mailer
+	store  Store
+}
+
+func New(log *slog.Logger, mailer *Mailer, store Store) *Notifier {
+	return &Notifier{
+		log:    log,
+		mailer: mailer,
+		store:  store,
+	}
+}
+
+type templateData struct {
+	Recipient domain.Recipient
+	Event     *domain.Event
+	Mission   *domain.Mission
+	// Cat is nil when the mission has no cat.
+	Cat *domain.Cat
+}
+
+// Handle emails every recipient of the event. It is meant to be subscribed
+// to the event bus for domain.NotifiableEvents.
+func (n *Notifier) Handle(ctx context.Context, event *domain.Event) error {
+	const op = "notify.Handle"
+	log := n.log.With(slog.String("operation", op), slog.Int64("event_id", event.ID))
+
+	mission, err := n.store.MissionByID(ctx, event.MissionID)
+	if err != nil {
+		// The mission was deleted since, there is nothing left to tell about.
+		if errors.Is(err, service.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var cat *domain.Cat
+	if event.CatID != 0 {
+		cat, err = n.store.Cat(ctx, event.CatID)
+		if err != nil && !errors.Is(err, service.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	recipients, err := n.store.NotificationRecipients(ctx, event.Type, event.CatID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// One email per recipient, so the greeting is personal and addresses are not shared.
+	var errs []error
+	for _, r := range recipients {
+		data := templateData{Recipient: r, Event: event, Mission: mission, Cat: cat}
+		if err := n.mailer.Send(ctx, string(event.Type), r, data); err != nil {
+			log.Warn("error while sending email", slog.Int("user_id", r.UserID), sl.Err(err))
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,307 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markraiter/spycat/internal/app/service"
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+type store struct {
+	recipients []domain.Recipient
+}
+
+func (s *store) NotificationRecipients(context.Context, domain.EventType, int) ([]domain.Recipient, error) {
+	return s.recipients, nil
+}
+
+func (s *store) MissionByID(_ context.Context, id int) (*domain.Mission, error) {
+	if id != 1 {
+		return nil, service.ErrNotFound
+	}
+
+	due := time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC)
+
+	return &domain.Mission{
+		ID:       1,
+		CatID:    2,
+		Bonus:    5000,
+		Currency: "USD",
+		DueAt:    &due,
+		Targets:  []domain.Target{{Name: "John <Doe>", Country: "USA"}},
+	}, nil
+}
+
+func (s *store) Cat(context.Context, int) (*domain.Cat, error) {
+	return &domain.Cat{ID: 2, Name: "Tom", Breed: "Siamese"}, nil
+}
+
+func testConfig() config.Notify {
+	return config.Notify{
+		SMTPSecurity: SecurityPlain,
+		SMTPTimeout:  time.Second,
+		From:         "SpyCat <noreply@spycat.local>",
+		AppURL:       "https://spycat.example.com/",
+	}
+}
+
+// parts returns the subject and the decoded parts of a MIME message by content type.
+func parts(t *testing.T, data []byte) (string, map[string]string) {
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if !assert.NoError(t, err) {
+		return "", nil
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	assert.NoError(t, err)
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if !assert.NoError(t, err) {
+		return "", nil
+	}
+
+	bodies := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			break
+		}
+
+		body, _ := io.ReadAll(p)
+		mediaType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		bodies[mediaType] = string(body)
+	}
+
+	return subject, bodies
+}
+
+func TestTemplates(t *testing.T) {
+	templates, err := LoadTemplates()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	mission, _ := (&store{}).MissionByID(context.Background(), 1)
+	cat, _ := (&store{}).Cat(context.Background(), 2)
+
+	for _, eventType := range domain.NotifiableEvents {
+		t.Run(string(eventType), func(t *testing.T) {
+			msg := &Message{}
+			data := templateData{
+				Recipient: domain.Recipient{Username: "alice"},
+				Event:     &domain.Event{Type: eventType, MissionID: 1, CatID: 2},
+				Mission:   mission,
+				Cat:       cat,
+			}
+
+			assert.NoError(t, templates.Render(string(eventType), data, msg))
+			assert.Contains(t, msg.Subject, "Mission #1")
+			assert.NotContains(t, msg.Subject, "\n")
+			assert.Contains(t, msg.Text, "Hello alice")
+			assert.Contains(t, msg.HTML, "Hello alice")
+		})
+	}
+
+	msg := &Message{}
+	data := templateData{Event: &domain.Event{}, Mission: mission, Cat: cat}
+	assert.NoError(t, templates.Render(string(domain.EventMissionAssigned), data, msg))
+	assert.Contains(t, msg.Text, "John <Doe>")
+	assert.Contains(t, msg.HTML, "John &lt;Doe&gt;", "HTML body is not escaped")
+
+	assert.NoError(t, templates.Render(string(domain.EventMissionCompleted), data, msg))
+	assert.Contains(t, msg.Text, "50.00 USD")
+}
+
+func TestNotifierDevDir(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig()
+	cfg.DevDir = dir
+
+	recipients := []domain.Recipient{
+		{UserID: 1, Username: "alice", Email: "alice@example.com"},
+		{UserID: 2, Username: "bob", Email: "bob@example.com"},
+	}
+
+	mailer, err := NewMailer(cfg, NewSender(cfg))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	n := New(slog.New(slog.NewTextHandler(io.Discard, nil)), mailer, &store{recipients: recipients})
+
+	err = n.Handle(context.Background(), &domain.Event{ID: 1, Type: domain.EventMissionAssigned, MissionID: 1, CatID: 2})
+	assert.NoError(t, err)
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	if !assert.Len(t, files, 2) {
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	assert.NoError(t, err)
+
+	subject, bodies := parts(t, data)
+	assert.Equal(t, "Mission #1 assigned to Tom", subject)
+	assert.Contains(t, bodies["text/plain"], "Hello alice")
+	assert.Contains(t, bodies["text/html"], "<strong>Tom</strong>")
+
+	// Events of deleted missions are skipped.
+	err = n.Handle(context.Background(), &domain.Event{ID: 2, Type: domain.EventMissionAssigned, MissionID: 9})
+	assert.NoError(t, err)
+}
+
+func TestNewMailerRejectsConfig(t *testing.T) {
+	cfg := testConfig()
+	cfg.SMTPSecurity = "ssl"
+	_, err := NewMailer(cfg, nil)
+	assert.Error(t, err)
+
+	cfg = testConfig()
+	cfg.From = "not an address"
+	_, err = NewMailer(cfg, nil)
+	assert.Error(t, err)
+}
+
+func TestMailerAccountEmails(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig()
+	cfg.DevDir = dir
+
+	mailer, err := NewMailer(cfg, NewSender(cfg))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	user := &domain.User{ID: 1, Username: "alice", Email: "alice@example.com"}
+
+	assert.NoError(t, mailer.SendVerificationEmail(context.Background(), user, "a.b+c", 48*time.Hour))
+	assert.NoError(t, mailer.SendPasswordResetEmail(context.Background(), user, "reset-token", time.Hour))
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	if !assert.Len(t, files, 2) {
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	assert.NoError(t, err)
+
+	subject, bodies := parts(t, data)
+	assert.Equal(t, "Verify your SpyCat email address", subject)
+	assert.Contains(t, bodies["text/plain"], "https://spycat.example.com/api/v1/auth/verify?token=a.b%2Bc")
+	assert.Contains(t, bodies["text/plain"], "expires in 48 hours")
+
+	data, err = os.ReadFile(filepath.Join(dir, files[1].Name()))
+	assert.NoError(t, err)
+
+	subject, bodies = parts(t, data)
+	assert.Equal(t, "Reset your SpyCat password", subject)
+	assert.Contains(t, bodies["text/plain"], "reset-token")
+	assert.Contains(t, bodies["text/html"], "expires in 1 hour")
+}
+
+// smtpServer accepts a single plain SMTP session and returns the recipients and data received.
+func smtpServer(t *testing.T) (string, <-chan []string, <-chan []byte) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	rcpts := make(chan []string, 1)
+	data := make(chan []byte, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP") // nolint: errcheck
+
+		var to []string
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost") // nolint: errcheck
+			case "MAIL":
+				tp.PrintfLine("250 OK") // nolint: errcheck
+			case "RCPT":
+				to = append(to, line)
+				tp.PrintfLine("250 OK") // nolint: errcheck
+			case "DATA":
+				tp.PrintfLine("354 go ahead") // nolint: errcheck
+				body, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				rcpts <- to
+				data <- body
+				tp.PrintfLine("250 OK") // nolint: errcheck
+			case "QUIT":
+				tp.PrintfLine("221 bye") // nolint: errcheck
+				return
+			default:
+				tp.PrintfLine("502 not implemented") // nolint: errcheck
+			}
+		}
+	}()
+
+	return l.Addr().String(), rcpts, data
+}
+
+func TestSMTPSender(t *testing.T) {
+	addr, rcpts, data := smtpServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+
+	cfg := testConfig()
+	cfg.SMTPHost = host
+	cfg.SMTPPort = port
+
+	msg := &Message{
+		From:    cfg.From,
+		To:      []string{`"Alice" <alice@example.com>`},
+		Subject: "Mission #1 completed",
+		Text:    "done",
+		HTML:    "<p>done</p>",
+		Date:    time.Now(),
+	}
+
+	err := NewSender(cfg).Send(context.Background(), msg)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"RCPT TO:<alice@example.com>"}, <-rcpts)
+
+	subject, bodies := parts(t, <-data)
+	assert.Equal(t, "Mission #1 completed", subject)
+	assert.Equal(t, "done", bodies["text/plain"])
+	assert.Equal(t, "<p>done</p>", bodies["text/html"])
+}
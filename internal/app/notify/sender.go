@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/markraiter/spycat/internal/config"
+)
+
+const (
+	SecurityPlain    = "plain"
+	SecurityStartTLS = "starttls"
+)
+
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewSender returns a DirSender when cfg.DevDir is set and an SMTPSender otherwise.
+func NewSender(cfg config.Notify) Sender {
+	if cfg.DevDir != "" {
+		return &DirSender{dir: cfg.DevDir}
+	}
+
+	return &SMTPSender{cfg: cfg}
+}
+
+// SMTPSender sends emails through an SMTP server, in plain text or upgraded with STARTTLS.
+type SMTPSender struct {
+	cfg config.Notify
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	const op = "notify.SMTPSender.Send"
+
+	data, err := msg.Bytes()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	dialer := net.Dialer{Timeout: s.cfg.SMTPTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.SMTPHost, s.cfg.SMTPPort))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	conn.SetDeadline(time.Now().Add(s.cfg.SMTPTimeout)) // nolint: errcheck
+
+	c, err := smtp.NewClient(conn, s.cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer c.Close()
+
+	if s.cfg.SMTPSecurity == SecurityStartTLS {
+		if err := c.StartTLS(&tls.Config{ServerName: s.cfg.SMTPHost}); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// PlainAuth refuses to send credentials over an unencrypted connection
+	// to anything but localhost.
+	if s.cfg.SMTPUsername != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.SMTPUsername, s.cfg.SMTPPassword, s.cfg.SMTPHost)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, to := range msg.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := c.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := c.Quit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// DirSender writes every email to a .eml file in a directory, for development and tests.
+type DirSender struct {
+	dir string
+	seq atomic.Int64
+}
+
+func NewDirSender(dir string) *DirSender {
+	return &DirSender{dir: dir}
+}
+
+func (s *DirSender) Send(_ context.Context, msg *Message) error {
+	const op = "notify.DirSender.Send"
+
+	data, err := msg.Bytes()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var to string
+	if len(msg.To) > 0 {
+		to = msg.To[0]
+		if addr, err := mail.ParseAddress(to); err == nil {
+			to = addr.Address
+		}
+	}
+
+	name := fmt.Sprintf("%s-%04d-%s.eml", msg.Date.UTC().Format("20060102T150405"), s.seq.Add(1), unsafeChars.ReplaceAllString(to, "_"))
+
+	if err := os.WriteFile(filepath.Join(s.dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/money"
+)
+
+// Every template, named after a notifiable event type or one of the account
+// templates, has a <name>.txt and a <name>.html file. The text template also
+// defines the "subject" template.
+//
+//go:embed templates
+var templateFS embed.FS
+
+var funcs = map[string]any{
+	"money":    money.Format,
+	"duration": humanDuration,
+}
+
+// humanDuration renders a duration in whole hours or minutes, "48 hours" rather than "48h0m0s".
+func humanDuration(d time.Duration) string {
+	n, unit := int(d.Minutes()), "minute"
+	if d >= time.Hour && d%time.Hour == 0 {
+		n, unit = int(d.Hours()), "hour"
+	}
+
+	if n == 1 {
+		return "1 " + unit
+	}
+
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+)
+
+type templateSet struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Templates renders the emails of the notifiable event types and of the account flows.
+type Templates struct {
+	sets map[string]templateSet
+}
+
+// LoadTemplates parses the embedded templates.
+func LoadTemplates() (*Templates, error) {
+	const op = "notify.LoadTemplates"
+
+	names := []string{TemplateVerifyEmail, TemplatePasswordReset}
+	for _, eventType := range domain.NotifiableEvents {
+		names = append(names, string(eventType))
+	}
+
+	t := &Templates{sets: make(map[string]templateSet, len(names))}
+
+	for _, name := range names {
+		path := "templates/" + name
+
+		text, err := texttemplate.New(name+".txt").Funcs(funcs).ParseFS(templateFS, path+".txt")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("%s: %s.txt does not define a subject", op, name)
+		}
+
+		html, err := htmltemplate.New(name+".html").Funcs(funcs).ParseFS(templateFS, path+".html")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		t.sets[name] = templateSet{text: text, html: html}
+	}
+
+	return t, nil
+}
+
+// Render fills the subject and the bodies of the message with the named template.
+func (t *Templates) Render(name string, data any, msg *Message) error {
+	const op = "notify.Render"
+
+	set, ok := t.sets[name]
+	if !ok {
+		return fmt.Errorf("%s: no template %s", op, name)
+	}
+
+	var subject, text, html bytes.Buffer
+
+	if err := set.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := set.text.Execute(&text, data); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := set.html.Execute(&html, data); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	msg.Subject = strings.TrimSpace(subject.String())
+	msg.Text = text.String()
+	msg.HTML = html.String()
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+type OverdueMarker interface {
+	MarkOverdue(ctx context.Context) (int, error)
+}
+
+type DuplicateDetector interface {
+	DetectDuplicates(ctx context.Context, threshold float64) (int, error)
+}
+
+// LoginPurger deletes stale failed logins. Deleting is idempotent, so it needs
+// no advisory lock.
+type LoginPurger interface {
+	Purge(ctx context.Context) (int, error)
+}
+
+// Scheduler runs periodic background jobs inside the server process.
+// Jobs guard themselves with Postgres advisory locks, so it is safe to run
+// a scheduler on every replica.
+type Scheduler struct {
+	log      *slog.Logger
+	cfg      config.Scheduler
+	marker   OverdueMarker
+	detector DuplicateDetector
+	purger   LoginPurger
+}
+
+func New(log *slog.Logger, cfg config.Scheduler, marker OverdueMarker, detector DuplicateDetector, purger LoginPurger) *Scheduler {
+	return &Scheduler{
+		log:      log,
+		cfg:      cfg,
+		marker:   marker,
+		detector: detector,
+		purger:   purger,
+	}
+}
+
+// Run blocks until ctx is cancelled. A job whose interval is not positive is disabled.
+func (s *Scheduler) Run(ctx context.Context) {
+	overdue := s.start(ctx, "overdue", s.cfg.OverdueInterval, s.markOverdue)
+	defer overdue.stop()
+
+	dedupe := s.start(ctx, "dedupe", s.cfg.DedupeInterval, s.detectDuplicates)
+	defer dedupe.stop()
+
+	purge := s.start(ctx, "login purge", s.cfg.LoginPurgeInterval, s.purgeLogins)
+	defer purge.stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-overdue.c:
+			s.markOverdue(ctx)
+		case <-dedupe.c:
+			s.detectDuplicates(ctx)
+		case <-purge.c:
+			s.purgeLogins(ctx)
+		}
+	}
+}
+
+// job ticks at the interval of a job. The channel of a disabled job is nil,
+// it never fires.
+type job struct {
+	ticker *time.Ticker
+	c      <-chan time.Time
+}
+
+// start runs the job once and returns its ticker, time.NewTicker panics on an
+// interval that is not positive so the job is disabled then.
+func (s *Scheduler) start(ctx context.Context, name string, interval time.Duration, run func(context.Context)) job {
+	if interval <= 0 {
+		s.log.Info("scheduled job disabled", slog.String("job", name), slog.Duration("interval", interval))
+		return job{}
+	}
+
+	run(ctx)
+
+	ticker := time.NewTicker(interval)
+
+	return job{ticker: ticker, c: ticker.C}
+}
+
+func (j job) stop() {
+	if j.ticker != nil {
+		j.ticker.Stop()
+	}
+}
+
+func (s *Scheduler) markOverdue(ctx context.Context) {
+	const op = "scheduler.markOverdue"
+	log := s.log.With(slog.String("operation", op))
+
+	n, err := s.marker.MarkOverdue(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("error while marking overdue missions", sl.Err(err))
+		}
+		return
+	}
+
+	if n > 0 {
+		log.Info("missions marked overdue", slog.Int("count", n))
+	}
+}
+
+func (s *Scheduler) detectDuplicates(ctx context.Context) {
+	const op = "scheduler.detectDuplicates"
+	log := s.log.With(slog.String("operation", op))
+
+	n, err := s.detector.DetectDuplicates(ctx, s.cfg.DedupeThreshold)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("error while detecting duplicate targets", sl.Err(err))
+		}
+		return
+	}
+
+	if n > 0 {
+		log.Info("duplicate targets found", slog.Int("candidates", n))
+	}
+}
+
+func (s *Scheduler) purgeLogins(ctx context.Context) {
+	const op = "scheduler.purgeLogins"
+	log := s.log.With(slog.String("operation", op))
+
+	n, err := s.purger.Purge(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("error while purging failed logins", sl.Err(err))
+		}
+		return
+	}
+
+	if n > 0 {
+		log.Info("failed logins purged", slog.Int("count", n))
+	}
+}
@@ -0,0 +1,42 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type jobs struct{ overdue, dedupe, purge int }
+
+func (j *jobs) MarkOverdue(context.Context) (int, error) {
+	j.overdue++
+	return 0, nil
+}
+
+func (j *jobs) DetectDuplicates(context.Context, float64) (int, error) {
+	j.dedupe++
+	return 0, nil
+}
+
+func (j *jobs) Purge(context.Context) (int, error) {
+	j.purge++
+	return 0, nil
+}
+
+func TestRunSkipsDisabledJobs(t *testing.T) {
+	j := &jobs{}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s := New(log, config.Scheduler{OverdueInterval: time.Hour, DedupeInterval: 0, LoginPurgeInterval: -time.Second}, j, j, j)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.NotPanics(t, func() { s.Run(ctx) })
+	assert.Equal(t, jobs{overdue: 1}, *j, "disabled jobs ran")
+}
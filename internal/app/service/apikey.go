@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+type APIKeySaver interface {
+	SaveAPIKey(ctx context.Context, key *domain.APIKey) (int64, error)
+}
+
+type APIKeyProvider interface {
+	APIKeys(ctx context.Context, userID int) ([]*domain.APIKey, error)
+	APIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+}
+
+type APIKeyProcessor interface {
+	TouchAPIKey(ctx context.Context, id int64) error
+	RevokeAPIKey(ctx context.Context, userID int, id int64) error
+}
+
+type APIKeyService struct {
+	saver     APIKeySaver
+	provider  APIKeyProvider
+	processor APIKeyProcessor
+}
+
+// CreateAPIKey creates an API key of the user. The key is returned this once,
+// only the hash of its secret is stored.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, userID int, kr *domain.APIKeyRequest) (*domain.NewAPIKey, error) {
+	const op = "service.CreateAPIKey"
+
+	if kr.ExpiresAt != nil && !kr.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidExpiry)
+	}
+
+	prefix, err := randomString(6)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := randomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key := domain.APIKey{
+		UserID:     userID,
+		Name:       kr.Name,
+		Prefix:     prefix,
+		Scopes:     kr.Scopes,
+		ExpiresAt:  kr.ExpiresAt,
+		SecretHash: hashToken(secret),
+	}
+
+	if _, err := s.saver.SaveAPIKey(ctx, &key); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &domain.NewAPIKey{
+		APIKey: key,
+		Key:    domain.APIKeyPrefix + prefix + "_" + secret,
+	}, nil
+}
+
+func (s *APIKeyService) APIKeys(ctx context.Context, userID int) ([]*domain.APIKey, error) {
+	const op = "service.APIKeys"
+
+	keys, err := s.provider.APIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, userID int, id int64) error {
+	const op = "service.RevokeAPIKey"
+
+	if err := s.processor.RevokeAPIKey(ctx, userID, id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey returns the API key the raw key is of, if it is neither
+// revoked nor expired, and records it was used. Any failure is ErrInvalidToken.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, raw string) (*domain.APIKey, error) {
+	const op = "service.AuthenticateAPIKey"
+
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(raw, domain.APIKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(raw, domain.APIKeyPrefix) {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	key, err := s.provider.APIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if subtle.ConstantTimeCompare(key.SecretHash, hashToken(secret)) != 1 {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now())) {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if err := s.processor.TouchAPIKey(ctx, key.ID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// randomString returns n random bytes encoded as URL-safe base64 without padding
+// or underscores, so "_" can separate the parts of an API key.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return strings.ReplaceAll(base64.RawURLEncoding.EncodeToString(b), "_", "-"), nil
+}
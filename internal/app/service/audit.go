@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+type AuditSaver interface {
+	SaveAuditEntry(ctx context.Context, tx *sql.Tx, entry *domain.AuditEntry) error
+}
+
+type AuditProvider interface {
+	AuditEntries(ctx context.Context, entity string, entityID int) ([]*domain.AuditEntry, error)
+}
+
+type AuditService struct {
+	provider AuditProvider
+}
+
+func (s *AuditService) AuditEntries(ctx context.Context, entity string, entityID int) ([]*domain.AuditEntry, error) {
+	const op = "service.AuditEntries"
+
+	entries, err := s.provider.AuditEntries(ctx, entity, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
@@ -2,40 +2,133 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/markraiter/spycat/internal/app/storage"
 	"github.com/markraiter/spycat/internal/config"
 	"github.com/markraiter/spycat/internal/domain"
 	"github.com/markraiter/spycat/internal/lib/jwt"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UserSaver interface {
 	SaveUser(ctx context.Context, user *domain.User) (int, error)
+	SaveResetToken(ctx context.Context, token *domain.ResetToken) error
+	SaveTOTPSecret(ctx context.Context, userID int, secret string) error
+	SaveMFAPolicy(ctx context.Context, policy *domain.MFAPolicy) error
+	SaveSession(ctx context.Context, session *domain.Session) (int64, error)
 }
 
 type UserProvider interface {
 	User(ctx context.Context, email string) (*domain.User, error)
+	UserByID(ctx context.Context, id int) (*domain.User, error)
+	UserByIdentity(ctx context.Context, issuer, subject string) (*domain.User, error)
+	SessionState(ctx context.Context, userID int, sessionID int64) (*domain.SessionState, error)
+	MFAPolicies(ctx context.Context) ([]domain.MFAPolicy, error)
+	Sessions(ctx context.Context, userID int) ([]*domain.Session, error)
+	Profile(ctx context.Context, userID int) (*domain.Profile, error)
+	Users(ctx context.Context, filter *domain.UserFilter) ([]*domain.Profile, error)
+}
+
+type UserProcessor interface {
+	BeginTx(ctx context.Context) (*sql.Tx, error)
+	VerifyEmail(ctx context.Context, userID int) error
+	ConsumeResetToken(ctx context.Context, tx *sql.Tx, hash []byte) (int, error)
+	ResetPassword(ctx context.Context, tx *sql.Tx, userID int, password string) error
+	UpdatePasswordHash(ctx context.Context, userID int, old, hash string) error
+	EnableTOTP(ctx context.Context, tx *sql.Tx, userID int, step int64) error
+	DisableTOTP(ctx context.Context, tx *sql.Tx, userID int) error
+	UseTOTPStep(ctx context.Context, userID int, step int64) error
+	ReplaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, hashes [][]byte) error
+	UseRecoveryCode(ctx context.Context, userID int, hash []byte) error
+	SaveOIDCUser(ctx context.Context, tx *sql.Tx, user *domain.User) (int, error)
+	LinkIdentity(ctx context.Context, tx *sql.Tx, userID int, issuer, subject string) error
+	SetRole(ctx context.Context, tx *sql.Tx, userID int, role string) error
+	TouchSession(ctx context.Context, id int64) error
+	RevokeSession(ctx context.Context, userID int, id int64) error
+	RevokeSessions(ctx context.Context, userID int) (int, error)
+	UpdateProfile(ctx context.Context, tx *sql.Tx, user *domain.User) error
+	ChangePassword(ctx context.Context, tx *sql.Tx, userID int, password string, keepSessionID int64) error
+	DeleteUser(ctx context.Context, tx *sql.Tx, userID int) error
+	ActiveAdmins(ctx context.Context, tx *sql.Tx) (int, error)
+	SetDisabled(ctx context.Context, tx *sql.Tx, userID int, disabled bool) error
+	RequirePasswordReset(ctx context.Context, tx *sql.Tx, userID int) error
+}
+
+// AccountMailer sends the emails of the account flows.
+type AccountMailer interface {
+	SendVerificationEmail(ctx context.Context, user *domain.User, token string, ttl time.Duration) error
+	SendPasswordResetEmail(ctx context.Context, user *domain.User, token string, ttl time.Duration) error
+}
+
+// LoginGuard throttles logins per account and per IP address.
+// LoginGuard throttles logins. An attempt Reserve lets go ahead counts as
+// failed until Release takes it back.
+type LoginGuard interface {
+	Reserve(ctx context.Context, email, ip string) (time.Duration, error)
+	Release(ctx context.Context, email, ip string) error
+	Succeed(ctx context.Context, email string) error
+	Unlock(ctx context.Context, email, ip string) error
+}
+
+// IdentityProvider is the OpenID Connect provider users can sign in with.
+type IdentityProvider interface {
+	AuthCodeURL(ctx context.Context, flow *domain.OIDCFlow) (string, error)
+	Identify(ctx context.Context, code string, flow *domain.OIDCFlow) (*domain.OIDCIdentity, error)
+}
+
+// PasswordPolicy checks a new password, it may not contain the context words.
+type PasswordPolicy interface {
+	Check(password string, context ...string) error
+}
+
+// PasswordHasher hashes passwords. NeedsRehash tells a hash made with outdated
+// parameters, which is replaced at the next login.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) (bool, error)
+	NeedsRehash(hash string) bool
 }
 
 type AuthService struct {
-	saver    UserSaver
-	provider UserProvider
+	saver     UserSaver
+	provider  UserProvider
+	processor UserProcessor
+	mailer    AccountMailer
+	guard     LoginGuard
+	idp       IdentityProvider
+	keys      *jwt.KeySet
+	passwords PasswordPolicy
+	hasher    PasswordHasher
+	// dummyHash is verified against when the email is unknown, for that to
+	// take as long as a wrong password.
+	dummyHash func() string
 }
 
-func (s *AuthService) Register(ctx context.Context, user *domain.UserRequest) (int, error) {
+// Register creates the user and emails a verification link. The user is created
+// even when the email cannot be sent, the error then wraps ErrVerificationNotSent.
+func (s *AuthService) Register(ctx context.Context, cfg config.Auth, user *domain.UserRequest) (int, error) {
 	const operation = "service.RegisterUser"
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err := s.checkPassword(user.Password, user.Username, user.Email); err != nil {
+		return 0, fmt.Errorf("%s: %w", operation, err)
+	}
+
+	passHash, err := s.hasher.Hash(user.Password)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", operation, err)
 	}
 
 	userResp := domain.User{
 		Username: user.Username,
-		Password: string(passHash),
+		Password: passHash,
 		Email:    user.Email,
 	}
 
@@ -48,29 +141,382 @@ func (s *AuthService) Register(ctx context.Context, user *domain.UserRequest) (i
 		return 0, fmt.Errorf("%s: %w", operation, err)
 	}
 
+	if err := s.sendVerification(ctx, cfg, &userResp); err != nil {
+		return id, fmt.Errorf("%s: %w: %w", operation, ErrVerificationNotSent, err)
+	}
+
 	return id, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, cfg config.Auth, email, password string) (string, error) {
+// Login checks the password of the user. Users with TOTP enabled get a challenge
+// to answer with LoginMFA instead of an access token. An unknown email and a
+// wrong password fail alike, with ErrInvalidCredentials, and both count as a
+// failed login of the account and of the IP address of the client.
+func (s *AuthService) Login(ctx context.Context, cfg config.Auth, email, password string, client domain.Client) (*domain.LoginResult, error) {
 	const operation = "service.Login"
 
+	ip := client.IP
+
+	if err := s.checkGuard(ctx, email, ip); err != nil {
+		return nil, fmt.Errorf("%s: %w", operation, err)
+	}
+
 	user, err := s.provider.User(ctx, email)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", operation, err)
+		}
+
+		s.hasher.Verify(s.dummyHash(), password) // nolint: errcheck
+
+		return nil, fmt.Errorf("%s: %w", operation, ErrInvalidCredentials)
+	}
+
+	ok, err := s.hasher.Verify(user.Password, password)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operation, err)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", operation, ErrInvalidCredentials)
+	}
+
+	if err := s.guard.Release(ctx, email, ip); err != nil {
+		return nil, fmt.Errorf("%s: %w", operation, err)
+	}
+
+	if s.hasher.NeedsRehash(user.Password) {
+		// Best effort, the next login tries again.
+		s.rehash(ctx, user, password) // nolint: errcheck
+	}
+
+	if user.PasswordResetRequired {
+		return nil, fmt.Errorf("%s: %w", operation, ErrPasswordResetRequired)
+	}
+
+	if cfg.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return nil, fmt.Errorf("%s: %w", operation, ErrEmailNotVerified)
+	}
+
+	// With TOTP on, the failures are kept until the second factor is given too.
+	if user.TOTPEnabledAt == nil {
+		if err := s.guard.Succeed(ctx, email); err != nil {
+			return nil, fmt.Errorf("%s: %w", operation, err)
+		}
+	}
+
+	result, err := s.loginResult(ctx, cfg, user, client)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", operation, err)
+	}
+
+	return result, nil
+}
+
+// loginResult returns a challenge for the second factor to users with TOTP
+// enabled and an access token of a new session to the others. Disabled users
+// are refused with ErrAccountDisabled. Users listed in cfg.AdminEmails are
+// made admins.
+func (s *AuthService) loginResult(ctx context.Context, cfg config.Auth, user *domain.User, client domain.Client) (*domain.LoginResult, error) {
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
+	if bootstrapAdmin(cfg, user) {
+		if err := s.setRole(ctx, user, domain.RoleAdmin); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.TOTPEnabledAt != nil {
+		mfaToken, err := s.keys.NewMFAToken(user, cfg.MFATokenTTL)
+		if err != nil {
+			return nil, err
+		}
+
+		return &domain.LoginResult{Challenge: &domain.MFAChallenge{
+			MFAToken:  mfaToken,
+			ExpiresIn: int(cfg.MFATokenTTL.Seconds()),
+		}}, nil
+	}
+
+	token, err := s.newSession(ctx, cfg, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.LoginResult{Token: token}, nil
+}
+
+// bootstrapAdmin tells whether the user is to be made an admin for being
+// listed in cfg.AdminEmails. The email must be verified, anyone may register
+// with an email before its owner does.
+func bootstrapAdmin(cfg config.Auth, user *domain.User) bool {
+	if user.Role == domain.RoleAdmin || user.EmailVerifiedAt == nil {
+		return false
+	}
+
+	return slices.ContainsFunc(cfg.AdminEmails, func(email string) bool {
+		return strings.EqualFold(strings.TrimSpace(email), user.Email)
+	})
+}
+
+// Unlock clears the failed logins of an account, an IP address or both, only admins may.
+func (s *AuthService) Unlock(ctx context.Context, userID int, ur *domain.UnlockRequest) error {
+	const op = "service.Unlock"
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.Role != domain.RoleAdmin {
+		return fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	if err := s.guard.Unlock(ctx, ur.Email, ur.IP); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// JWKS returns the public keys access tokens can be verified with.
+func (s *AuthService) JWKS() *domain.JWKS {
+	return s.keys.JWKS()
+}
+
+// checkGuard reserves a login attempt of the email from the IP address, counted
+// as failed until released. It returns a TooManyAttemptsError while logins of
+// the email or from the IP address are held back.
+func (s *AuthService) checkGuard(ctx context.Context, email, ip string) error {
+	wait, err := s.guard.Reserve(ctx, email, ip)
+	if err != nil {
+		return err
+	}
+
+	if wait > 0 {
+		return &TooManyAttemptsError{RetryAfter: wait}
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the email of the user the verification token was issued to as verified.
+func (s *AuthService) VerifyEmail(ctx context.Context, cfg config.Auth, token string) error {
+	const op = "service.VerifyEmail"
+
+	claims, err := s.keys.ParseVerificationToken(token)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
+	}
+
+	user, err := s.provider.UserByID(ctx, claims.UID)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
-			return "", fmt.Errorf("%s: %w", operation, ErrNotFound)
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
 		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
-		return "", fmt.Errorf("%s: %w", operation, err)
+	// The address changed since the token was issued.
+	if user.Email != claims.Email {
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return "", fmt.Errorf("%s: %w", operation, ErrInvalidCredentials)
+	if err := s.processor.VerifyEmail(ctx, user.ID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	token, err := jwt.NewToken(cfg, user, cfg.AccessTTL)
+	return nil
+}
+
+// ResendVerification emails a new verification link to the user with the email,
+// if there is one and it is not verified yet. Whether the user exists is not revealed.
+func (s *AuthService) ResendVerification(ctx context.Context, cfg config.Auth, email string) error {
+	const op = "service.ResendVerification"
+
+	user, err := s.provider.User(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	if err := s.sendVerification(ctx, cfg, user); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *AuthService) sendVerification(ctx context.Context, cfg config.Auth, user *domain.User) error {
+	token, err := s.keys.NewVerificationToken(user, cfg.VerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.SendVerificationEmail(ctx, user, token, cfg.VerificationTTL)
+}
+
+// ForgotPassword emails a single-use password reset token to the user with the email.
+// Whether the user exists is not revealed.
+func (s *AuthService) ForgotPassword(ctx context.Context, cfg config.Auth, email string) error {
+	const op = "service.ForgotPassword"
+
+	user, err := s.provider.User(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.sendPasswordReset(ctx, cfg, user); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// sendPasswordReset emails a single-use password reset token to the user.
+func (s *AuthService) sendPasswordReset(ctx context.Context, cfg config.Auth, user *domain.User) error {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	resetToken := &domain.ResetToken{
+		UserID:    user.ID,
+		Hash:      hashToken(token),
+		ExpiresAt: time.Now().Add(cfg.PasswordResetTTL),
+	}
+
+	if err := s.saver.SaveResetToken(ctx, resetToken); err != nil {
+		return err
+	}
+
+	return s.mailer.SendPasswordResetEmail(ctx, user, token, cfg.PasswordResetTTL)
+}
+
+// ResetPassword sets a new password with a reset token and signs the user out everywhere.
+func (s *AuthService) ResetPassword(ctx context.Context, rr *domain.ResetPasswordRequest) error {
+	const op = "service.ResetPassword"
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := s.processor.ConsumeResetToken(ctx, tx, hashToken(rr.Token))
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The token stays usable for another try when the password is refused.
+	if err := s.checkPassword(rr.Password, user.Username, user.Email); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := s.hasher.Hash(rr.Password)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.processor.ResetPassword(ctx, tx, userID, passHash); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SessionState returns the current session version of the user, access tokens
+// issued with an older version are revoked, whether the user must enroll in MFA
+// and whether the session is in use, which it is then recorded to be seen.
+func (s *AuthService) SessionState(ctx context.Context, userID int, sessionID int64) (*domain.SessionState, error) {
+	const op = "service.SessionState"
+
+	state, err := s.provider.SessionState(ctx, userID, sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if state.SessionActive {
+		if err := s.processor.TouchSession(ctx, sessionID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return state, nil
+}
+
+// rehash replaces the hash of the password of the user, made with outdated
+// parameters, unless the password changed meanwhile.
+func (s *AuthService) rehash(ctx context.Context, user *domain.User, password string) error {
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	return s.processor.UpdatePasswordHash(ctx, user.ID, user.Password, hash)
+}
+
+// verifyPassword returns ErrInvalidCredentials unless the password is the one of the user.
+func (s *AuthService) verifyPassword(user *domain.User, password string) error {
+	ok, err := s.hasher.Verify(user.Password, password)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", operation, err)
+		return err
+	}
+
+	if !ok {
+		return ErrInvalidCredentials
 	}
 
-	return token, nil
+	return nil
+}
+
+// checkPassword returns a WeakPasswordError when the new password of the user
+// with the username and the email breaks the password policy.
+func (s *AuthService) checkPassword(password, username, email string) error {
+	localPart, _, _ := strings.Cut(email, "@")
+
+	if err := s.passwords.Check(password, username, localPart); err != nil {
+		return &WeakPasswordError{Reason: err.Error()}
+	}
+
+	return nil
+}
+
+// hashToken returns the SHA-256 of a random token, the token has enough entropy
+// not to need a slow hash.
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+
+	return sum[:]
 }
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBootstrapAdmin(t *testing.T) {
+	cfg := config.Auth{AdminEmails: []string{"Alice@Example.com", " bob@example.com"}}
+	verified := time.Now()
+
+	tests := []struct {
+		name string
+		user *domain.User
+		want bool
+	}{
+		{"listed", &domain.User{Email: "alice@example.com", Role: domain.RoleHandler, EmailVerifiedAt: &verified}, true},
+		{"listed with spaces", &domain.User{Email: "bob@example.com", Role: domain.RoleHandler, EmailVerifiedAt: &verified}, true},
+		{"not verified", &domain.User{Email: "alice@example.com", Role: domain.RoleHandler}, false},
+		{"already admin", &domain.User{Email: "alice@example.com", Role: domain.RoleAdmin, EmailVerifiedAt: &verified}, false},
+		{"not listed", &domain.User{Email: "carol@example.com", Role: domain.RoleHandler, EmailVerifiedAt: &verified}, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, bootstrapAdmin(cfg, tt.user), tt.name)
+	}
+
+	assert.False(t, bootstrapAdmin(config.Auth{}, &domain.User{Email: "", EmailVerifiedAt: &verified}), "empty list")
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/assignment"
+)
+
+// Weights of the candidate score components, they sum up to 1.
+const (
+	weightSkills      = 0.35
+	weightExperience  = 0.2
+	weightWorkload    = 0.2
+	weightSuccessRate = 0.15
+	weightCost        = 0.1
+
+	// fullExperience is the number of years after which experience does not add to the score.
+	fullExperience = 10
+	// neutralSuccessRate is used for cats that never finished a mission.
+	neutralSuccessRate = 0.5
+	// catCapacity is how many active missions a cat works on at most, cats
+	// at capacity are not candidates.
+	catCapacity = 1
+)
+
+type CandidateProvider interface {
+	Cats(ctx context.Context) ([]*domain.Cat, error)
+	CatStats(ctx context.Context) ([]domain.CatStats, error)
+	AllCatSkills(ctx context.Context) (map[int][]domain.CatSkill, error)
+}
+
+// Candidates ranks the cats available for the mission, eligible cats first.
+func (s *MissionService) Candidates(ctx context.Context, missionID int) ([]domain.Candidate, error) {
+	const op = "service.Candidates"
+
+	if _, err := s.provider.MissionByID(ctx, missionID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	required, err := s.requiredSkills(ctx, missionID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pool, err := s.candidatePool(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	candidates := pool.score(required)
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Eligible != candidates[j].Eligible {
+			return candidates[i].Eligible
+		}
+		return candidates[i].Score.Total > candidates[j].Score.Total
+	})
+
+	return candidates, nil
+}
+
+// ProposeAssignments suggests the assignment of every unassigned and not completed
+// mission to a distinct available cat maximizing the total candidate score.
+// Cats that lack required skills are only proposed when no eligible cat is left.
+// Nothing is persisted.
+func (s *MissionService) ProposeAssignments(ctx context.Context) (*domain.AssignmentProposal, error) {
+	const op = "service.ProposeAssignments"
+
+	missions, err := s.provider.Missions(ctx, domain.MissionFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pool, err := s.candidatePool(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	proposal := &domain.AssignmentProposal{
+		Assignments: make([]domain.ProposedAssignment, 0),
+		Unassigned:  make([]int, 0),
+	}
+
+	var open []*domain.Mission
+	for _, m := range missions {
+		if m.CatID == 0 && !m.Completed {
+			open = append(open, m)
+		}
+	}
+
+	if len(open) == 0 || len(pool.cats) == 0 {
+		for _, m := range open {
+			proposal.Unassigned = append(proposal.Unassigned, m.ID)
+		}
+		return proposal, nil
+	}
+
+	scores := make([][]domain.Candidate, len(open))
+	cost := make([][]float64, len(open))
+	for i, m := range open {
+		required, err := s.requiredSkills(ctx, m.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		scores[i] = pool.score(required)
+		cost[i] = make([]float64, len(pool.cats))
+		for j, c := range scores[i] {
+			// Scores are in [0, 1], an ineligible cat costs more than any eligible one.
+			cost[i][j] = 1 - c.Score.Total
+			if !c.Eligible {
+				cost[i][j] += 1
+			}
+		}
+	}
+
+	for i, j := range assignment.Solve(cost) {
+		if j < 0 {
+			proposal.Unassigned = append(proposal.Unassigned, open[i].ID)
+			continue
+		}
+
+		c := scores[i][j]
+		proposal.Assignments = append(proposal.Assignments, domain.ProposedAssignment{
+			MissionID: open[i].ID,
+			CatID:     c.CatID,
+			CatName:   c.CatName,
+			Eligible:  c.Eligible,
+			Score:     c.Score.Total,
+		})
+		proposal.TotalScore += c.Score.Total
+	}
+
+	return proposal, nil
+}
+
+type candidatePool struct {
+	cats   []*domain.Cat
+	stats  map[int]domain.CatStats
+	skills map[int][]domain.CatSkill
+
+	minSalary, maxSalary int
+}
+
+func (s *MissionService) candidatePool(ctx context.Context) (*candidatePool, error) {
+	cats, err := s.provider.Cats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.provider.CatStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	skills, err := s.provider.AllCatSkills(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCandidatePool(cats, stats, skills), nil
+}
+
+// newCandidatePool leaves out the cats at capacity. The salaries of the cats
+// left are the range the cost is scored in.
+func newCandidatePool(cats []*domain.Cat, stats []domain.CatStats, skills map[int][]domain.CatSkill) *candidatePool {
+	pool := &candidatePool{
+		stats:  make(map[int]domain.CatStats, len(stats)),
+		skills: skills,
+	}
+
+	for _, st := range stats {
+		pool.stats[st.CatID] = st
+	}
+
+	for _, cat := range cats {
+		if pool.stats[cat.ID].ActiveMissions < catCapacity {
+			pool.cats = append(pool.cats, cat)
+		}
+	}
+
+	for i, cat := range pool.cats {
+		if i == 0 || cat.Salary < pool.minSalary {
+			pool.minSalary = cat.Salary
+		}
+		if i == 0 || cat.Salary > pool.maxSalary {
+			pool.maxSalary = cat.Salary
+		}
+	}
+
+	return pool
+}
+
+// score returns candidates in the order of the pool cats.
+func (p *candidatePool) score(required []domain.RequiredSkill) []domain.Candidate {
+	candidates := make([]domain.Candidate, 0, len(p.cats))
+
+	for _, cat := range p.cats {
+		st := p.stats[cat.ID]
+		gaps := domain.SkillGaps(required, p.skills[cat.ID])
+
+		score := domain.CandidateScore{
+			Experience:  math.Min(float64(cat.YearsOfExperience)/fullExperience, 1),
+			Skills:      skillsMatch(required, p.skills[cat.ID]),
+			Workload:    1 / float64(1+st.ActiveMissions),
+			Cost:        1,
+			SuccessRate: neutralSuccessRate,
+		}
+
+		if p.maxSalary > p.minSalary {
+			score.Cost = 1 - float64(cat.Salary-p.minSalary)/float64(p.maxSalary-p.minSalary)
+		}
+
+		if st.FinishedMissions > 0 {
+			score.SuccessRate = float64(st.CompletedMissions) / float64(st.FinishedMissions)
+		}
+
+		score.Total = round(weightSkills*score.Skills +
+			weightExperience*score.Experience +
+			weightWorkload*score.Workload +
+			weightSuccessRate*score.SuccessRate +
+			weightCost*score.Cost)
+
+		candidates = append(candidates, domain.Candidate{
+			CatID:          cat.ID,
+			CatName:        cat.Name,
+			Eligible:       len(gaps) == 0,
+			ActiveMissions: st.ActiveMissions,
+			MissingSkills:  gaps,
+			Score:          score,
+		})
+	}
+
+	return candidates
+}
+
+// skillsMatch is the average ratio of the cat proficiency to the required level,
+// capped at 1 per skill. A mission without required skills is matched fully.
+func skillsMatch(required []domain.RequiredSkill, skills []domain.CatSkill) float64 {
+	if len(required) == 0 {
+		return 1
+	}
+
+	levels := make(map[int]int, len(skills))
+	for _, s := range skills {
+		levels[s.SkillID] = s.Level
+	}
+
+	var sum float64
+	for _, r := range required {
+		sum += math.Min(float64(levels[r.SkillID])/float64(r.MinLevel), 1)
+	}
+
+	return sum / float64(len(required))
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreSuccessRate(t *testing.T) {
+	pool := &candidatePool{
+		cats: []*domain.Cat{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}},
+		stats: map[int]domain.CatStats{
+			// Three completed, one overdue and two in progress.
+			1: {CatID: 1, ActiveMissions: 2, CompletedMissions: 3, FinishedMissions: 4},
+			// Only missions in progress.
+			2: {CatID: 2, ActiveMissions: 3},
+			3: {CatID: 3, CompletedMissions: 4, FinishedMissions: 4},
+		},
+	}
+
+	candidates := pool.score(nil)
+
+	want := []float64{0.75, neutralSuccessRate, 1, neutralSuccessRate}
+	for i, c := range candidates {
+		assert.Equal(t, want[i], c.Score.SuccessRate, "cat %d", c.CatID)
+	}
+}
+
+func TestScoreFromStats(t *testing.T) {
+	pool := newCandidatePool(
+		[]*domain.Cat{
+			{ID: 1, Name: "Tom", YearsOfExperience: 5, Salary: 1000},
+			{ID: 2, Name: "Felix", YearsOfExperience: 20, Salary: 3000},
+			// On an active mission, and the most expensive.
+			{ID: 3, Name: "Garfield", YearsOfExperience: 1, Salary: 9000},
+		},
+		[]domain.CatStats{
+			{CatID: 1, CompletedMissions: 1, FinishedMissions: 2},
+			{CatID: 3, ActiveMissions: 1},
+		},
+		map[int][]domain.CatSkill{
+			1: {{SkillID: 1, Level: 2}},
+			2: {{SkillID: 1, Level: 5}},
+		},
+	)
+
+	candidates := pool.score([]domain.RequiredSkill{{SkillID: 1, MinLevel: 4}})
+
+	assert.Equal(t, []domain.Candidate{
+		{
+			CatID:         1,
+			CatName:       "Tom",
+			MissingSkills: []domain.SkillGap{{SkillID: 1, Required: 4, Actual: 2}},
+			Score: domain.CandidateScore{
+				Experience:  0.5,
+				Skills:      0.5,
+				Workload:    1,
+				Cost:        1,
+				SuccessRate: 0.5,
+				Total:       0.65, // 0.35*0.5 + 0.2*0.5 + 0.2*1 + 0.15*0.5 + 0.1*1
+			},
+		},
+		{
+			CatID:    2,
+			CatName:  "Felix",
+			Eligible: true,
+			Score: domain.CandidateScore{
+				Experience:  1,
+				Skills:      1,
+				Workload:    1,
+				Cost:        0,
+				SuccessRate: neutralSuccessRate,
+				Total:       0.825,
+			},
+		},
+	}, candidates, "cat on an active mission ranked")
+}
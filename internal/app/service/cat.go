@@ -2,32 +2,45 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/markraiter/spycat/internal/app/events"
 	"github.com/markraiter/spycat/internal/app/storage"
 	"github.com/markraiter/spycat/internal/domain"
-	"github.com/markraiter/spycat/internal/lib/breed"
+	"github.com/markraiter/spycat/internal/lib/money"
 )
 
 type CatSaver interface {
-	SaveCat(ctx context.Context, cat *domain.Cat) (int, error)
+	SaveCat(ctx context.Context, tx *sql.Tx, cat *domain.Cat) (int, error)
+	SaveSalary(ctx context.Context, tx *sql.Tx, salary *domain.Salary) (int, error)
+	EventSaver
 }
 
 type CatProvider interface {
 	Cat(ctx context.Context, id int) (*domain.Cat, error)
 	Cats(ctx context.Context) ([]*domain.Cat, error)
+	Salaries(ctx context.Context, catID int) ([]*domain.Salary, error)
+	CatSkills(ctx context.Context, catID int) ([]domain.CatSkill, error)
+	UserByID(ctx context.Context, id int) (*domain.User, error)
 }
 
 type CatProcessor interface {
-	UpdateCat(ctx context.Context, cat *domain.Cat) error
-	DeleteCat(ctx context.Context, id int) error
+	BeginTx(ctx context.Context) (*sql.Tx, error)
+	CatForUpdate(ctx context.Context, tx *sql.Tx, id int) (*domain.Cat, error)
+	UpdateCat(ctx context.Context, tx *sql.Tx, cat *domain.Cat) error
+	DeleteCat(ctx context.Context, tx *sql.Tx, id int) error
 }
 
 type CatService struct {
 	saver     CatSaver
 	provider  CatProvider
 	processor CatProcessor
+	publisher events.Publisher
+	// validBreed tells whether the breed is a known one.
+	validBreed func(breed string) bool
 }
 
 func (s *CatService) SaveCat(ctx context.Context, cr *domain.CatRequest) (int, error) {
@@ -38,14 +51,26 @@ func (s *CatService) SaveCat(ctx context.Context, cr *domain.CatRequest) (int, e
 		YearsOfExperience: cr.YearsOfExperience,
 		Breed:             cr.Breed,
 		Salary:            cr.Salary,
+		Currency:          money.Normalize(cr.Currency),
+		HandlerID:         cr.HandlerID,
 	}
 
-	if !breed.ValidateCatBreed(cat.Breed) {
+	if !s.validBreed(cat.Breed) {
 		return 0, fmt.Errorf("%s: %w", op, ErrCatBreedNotFound)
 	}
 
-	id, err := s.saver.SaveCat(ctx, cat)
+	if err := s.checkHandler(ctx, cat.HandlerID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := s.saver.SaveCat(ctx, tx, cat)
 	if err != nil {
+		tx.Rollback()
 		if errors.Is(err, storage.ErrAlreadyExists) {
 			return 0, fmt.Errorf("%s: %w", op, ErrAlreadyExists)
 		}
@@ -53,6 +78,28 @@ func (s *CatService) SaveCat(ctx context.Context, cr *domain.CatRequest) (int, e
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if _, err := s.saver.SaveSalary(ctx, tx, catSalary(cat)); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := &domain.Event{
+		Type:    domain.EventCatCreated,
+		CatID:   id,
+		Payload: map[string]any{"name": cat.Name, "breed": cat.Breed},
+	}
+
+	if err := s.saver.SaveEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.publisher.Publish(ctx, event)
+
 	return id, nil
 }
 
@@ -67,6 +114,11 @@ func (s *CatService) Cat(ctx context.Context, id int) (*domain.Cat, error) {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	cat.Skills, err = s.provider.CatSkills(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return cat, nil
 }
 
@@ -81,6 +133,7 @@ func (s *CatService) Cats(ctx context.Context) ([]*domain.Cat, error) {
 	return cats, nil
 }
 
+// UpdateCat replaces the cat. A request without a currency keeps the one of the cat.
 func (s *CatService) UpdateCat(ctx context.Context, catID int, cr *domain.CatRequest) error {
 	const op = "service.UpdateCat"
 
@@ -90,31 +143,169 @@ func (s *CatService) UpdateCat(ctx context.Context, catID int, cr *domain.CatReq
 		YearsOfExperience: cr.YearsOfExperience,
 		Breed:             cr.Breed,
 		Salary:            cr.Salary,
+		HandlerID:         cr.HandlerID,
 	}
 
-	if !breed.ValidateCatBreed(cat.Breed) {
+	if !s.validBreed(cat.Breed) {
 		return fmt.Errorf("%s: %w", op, ErrCatBreedNotFound)
 	}
 
-	if err := s.processor.UpdateCat(ctx, cat); err != nil {
+	if err := s.checkHandler(ctx, cat.HandlerID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Locked until the commit, so concurrent updates compare against what the other wrote.
+	current, err := s.processor.CatForUpdate(ctx, tx, catID)
+	if err != nil {
+		tx.Rollback()
 		if errors.Is(err, storage.ErrNotFound) {
 			return fmt.Errorf("%s: %w", op, ErrNotFound)
 		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	cat.Currency = current.Currency
+	if cr.Currency != "" {
+		cat.Currency = money.Normalize(cr.Currency)
+	}
+
+	if err := s.processor.UpdateCat(ctx, tx, cat); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Salary changes made through the cat itself take effect today,
+	// the previous amount is kept in the salary history.
+	if current.Salary != cat.Salary || current.Currency != cat.Currency {
+		if _, err := s.saver.SaveSalary(ctx, tx, catSalary(cat)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
 func (s *CatService) DeleteCat(ctx context.Context, id int) error {
 	const op = "service.DeleteCat"
 
-	if err := s.processor.DeleteCat(ctx, id); err != nil {
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.processor.DeleteCat(ctx, tx, id); err != nil {
+		tx.Rollback()
 		if errors.Is(err, storage.ErrNotFound) {
 			return fmt.Errorf("%s: %w", op, ErrNotFound)
 		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	event := &domain.Event{Type: domain.EventCatDeleted, CatID: id}
+	if err := s.saver.SaveEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.publisher.Publish(ctx, event)
+
+	return nil
+}
+
+func (s *CatService) Salaries(ctx context.Context, catID int) ([]*domain.Salary, error) {
+	const op = "service.Salaries"
+
+	if _, err := s.provider.Cat(ctx, catID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	salaries, err := s.provider.Salaries(ctx, catID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return salaries, nil
+}
+
+// SaveSalary records a salary change that takes effect on sr.EffectiveFrom.
+// The salary of the cat is read from the history, so a change dated in the
+// future applies from that day on.
+func (s *CatService) SaveSalary(ctx context.Context, catID int, sr *domain.SalaryRequest) (int, error) {
+	const op = "service.SaveSalary"
+
+	salary := &domain.Salary{
+		CatID:         catID,
+		Amount:        sr.Amount,
+		Currency:      money.Normalize(sr.Currency),
+		EffectiveFrom: dateOf(sr.EffectiveFrom),
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := s.saver.SaveSalary(ctx, tx, salary)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, storage.ErrNotFound) {
+			return 0, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// checkHandler makes sure the handler of a cat, if any, is an existing user.
+func (s *CatService) checkHandler(ctx context.Context, handlerID int) error {
+	if handlerID == 0 {
+		return nil
+	}
+
+	if _, err := s.provider.UserByID(ctx, handlerID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrHandlerNotFound
+		}
+		return err
+	}
+
 	return nil
 }
+
+// catSalary converts the salary of the cat into a salary history record effective today.
+func catSalary(cat *domain.Cat) *domain.Salary {
+	return &domain.Salary{
+		CatID:         cat.ID,
+		Amount:        money.ToMinor(int64(cat.Salary), cat.Currency),
+		Currency:      cat.Currency,
+		EffectiveFrom: dateOf(time.Now()),
+	}
+}
+
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
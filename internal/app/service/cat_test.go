@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+type catStore struct {
+	*txDB
+	CatSaver
+	CatProvider
+	cat      domain.Cat
+	salaries []*domain.Salary
+}
+
+func (s *catStore) CatForUpdate(ctx context.Context, tx *sql.Tx, id int) (*domain.Cat, error) {
+	cat := s.cat
+	return &cat, nil
+}
+
+func (s *catStore) UpdateCat(ctx context.Context, tx *sql.Tx, cat *domain.Cat) error {
+	s.cat = *cat
+	return nil
+}
+
+func (s *catStore) DeleteCat(ctx context.Context, tx *sql.Tx, id int) error {
+	return nil
+}
+
+func (s *catStore) SaveSalary(ctx context.Context, tx *sql.Tx, salary *domain.Salary) (int, error) {
+	s.salaries = append(s.salaries, salary)
+	return len(s.salaries), nil
+}
+
+func newCatService(t *testing.T, cat domain.Cat) (*CatService, *catStore) {
+	store := &catStore{txDB: newTxDB(t), cat: cat}
+
+	return &CatService{
+		saver:      store,
+		provider:   store,
+		processor:  store,
+		validBreed: func(string) bool { return true },
+	}, store
+}
+
+func TestUpdateCatKeepsCurrency(t *testing.T) {
+	s, store := newCatService(t, domain.Cat{ID: 1, Name: "Tom", Breed: "Siamese", Salary: 1000, Currency: "EUR"})
+
+	err := s.UpdateCat(context.Background(), 1, &domain.CatRequest{Name: "Tom", Breed: "Siamese", Salary: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, "EUR", store.cat.Currency)
+	assert.Empty(t, store.salaries, "salary history recorded without a change")
+
+	err = s.UpdateCat(context.Background(), 1, &domain.CatRequest{Name: "Tom", Breed: "Siamese", Salary: 1000, Currency: "usd"})
+	assert.NoError(t, err)
+	assert.Equal(t, "USD", store.cat.Currency)
+	if assert.Len(t, store.salaries, 1) {
+		assert.Equal(t, int64(100000), store.salaries[0].Amount)
+		assert.Equal(t, "USD", store.salaries[0].Currency)
+	}
+
+	commits, rollbacks := store.ended()
+	assert.Equal(t, 2, commits)
+	assert.Zero(t, rollbacks)
+}
+
+func TestSaveSalaryLeavesCat(t *testing.T) {
+	cat := domain.Cat{ID: 1, Name: "Tom", Breed: "Siamese", Salary: 1000, Currency: "USD"}
+	s, store := newCatService(t, cat)
+
+	id, err := s.SaveSalary(context.Background(), 1, &domain.SalaryRequest{
+		Amount:        150050,
+		Currency:      "eur",
+		EffectiveFrom: time.Now().AddDate(0, 1, 0),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, id)
+	assert.Equal(t, cat, store.cat, "cat updated before the salary is effective")
+
+	if assert.Len(t, store.salaries, 1) {
+		assert.Equal(t, int64(150050), store.salaries[0].Amount)
+		assert.Equal(t, "EUR", store.salaries[0].Currency)
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+type ChatSaver interface {
+	SaveMessage(ctx context.Context, message *domain.Message) error
+}
+
+type ChatProvider interface {
+	UserByID(ctx context.Context, id int) (*domain.User, error)
+	MissionByID(ctx context.Context, id int) (*domain.Mission, error)
+	Cat(ctx context.Context, id int) (*domain.Cat, error)
+	Messages(ctx context.Context, missionID int, before int64, limit int) ([]*domain.Message, error)
+}
+
+type ChatService struct {
+	saver    ChatSaver
+	provider ChatProvider
+}
+
+// JoinChat checks the user may take part in the chat of the mission and returns the user.
+// The channel of a mission is open to admins and to the handler of the assigned cat.
+func (s *ChatService) JoinChat(ctx context.Context, userID, missionID int) (*domain.User, error) {
+	const op = "service.JoinChat"
+
+	user, err := s.provider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrForbidden)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	mission, err := s.provider.MissionByID(ctx, missionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.Role == domain.RoleAdmin {
+		return user, nil
+	}
+
+	if mission.CatID == 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	cat, err := s.provider.Cat(ctx, mission.CatID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if cat.HandlerID != user.ID {
+		return nil, fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	return user, nil
+}
+
+// SaveMessage persists a message the user posted to the chat of the mission.
+// The caller is expected to have checked access with JoinChat.
+func (s *ChatService) SaveMessage(ctx context.Context, user *domain.User, missionID int, body string) (*domain.Message, error) {
+	const op = "service.SaveMessage"
+
+	message := &domain.Message{
+		MissionID: missionID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Body:      body,
+	}
+
+	if err := s.saver.SaveMessage(ctx, message); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return message, nil
+}
+
+// Messages returns a page of the chat history of the mission, newest first.
+func (s *ChatService) Messages(ctx context.Context, userID, missionID int, before int64, limit int) ([]*domain.Message, error) {
+	const op = "service.Messages"
+
+	if _, err := s.JoinChat(ctx, userID, missionID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	messages, err := s.provider.Messages(ctx, missionID, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return messages, nil
+}
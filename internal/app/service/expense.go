@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/money"
+)
+
+// SaveExpense logs an expense against the mission and reports whether
+// the mission went over its budget.
+func (s *MissionService) SaveExpense(ctx context.Context, missionID int, er *domain.ExpenseRequest) (*domain.ExpenseResponse, error) {
+	const op = "service.SaveExpense"
+
+	mission, err := s.provider.MissionByID(ctx, missionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	expense := &domain.Expense{
+		MissionID:   missionID,
+		Category:    er.Category,
+		Amount:      er.Amount,
+		Currency:    money.Normalize(er.Currency),
+		ReceiptNote: er.ReceiptNote,
+	}
+
+	if expense.Currency != mission.Currency {
+		return nil, fmt.Errorf("%s: %w", op, ErrCurrencyMismatch)
+	}
+
+	id, err := s.saver.SaveExpense(ctx, expense)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	financials, err := s.financials(ctx, mission)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &domain.ExpenseResponse{
+		ID:         id,
+		Remaining:  financials.Remaining,
+		OverBudget: financials.OverBudget,
+	}, nil
+}
+
+// UpdateBudget sets the budget and the completion bonus of the mission. The
+// amounts must be in the mission currency, ErrCurrencyMismatch otherwise.
+func (s *MissionService) UpdateBudget(ctx context.Context, missionID int, br *domain.BudgetRequest) error {
+	const op = "service.UpdateBudget"
+
+	mission, err := s.provider.MissionByID(ctx, missionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if money.Normalize(br.Currency) != mission.Currency {
+		return fmt.Errorf("%s: %w", op, ErrCurrencyMismatch)
+	}
+
+	if err := s.processor.UpdateMissionBudget(ctx, missionID, br.Budget, br.Bonus); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *MissionService) Financials(ctx context.Context, missionID int) (*domain.MissionFinancials, error) {
+	const op = "service.Financials"
+
+	mission, err := s.provider.MissionByID(ctx, missionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	financials, err := s.financials(ctx, mission)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return financials, nil
+}
+
+func (s *MissionService) financials(ctx context.Context, mission *domain.Mission) (*domain.MissionFinancials, error) {
+	expenses, err := s.provider.Expenses(ctx, mission.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	bonuses, err := s.provider.MissionBonuses(ctx, mission.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &domain.MissionFinancials{
+		MissionID:  mission.ID,
+		Currency:   mission.Currency,
+		Budget:     mission.Budget,
+		Bonus:      mission.Bonus,
+		BonusPaid:  len(bonuses) > 0,
+		ByCategory: make([]domain.CategoryExpense, 0),
+		Expenses:   expenses,
+	}
+
+	categories := make(map[string]int64)
+	for _, e := range expenses {
+		f.Spent += e.Amount
+		categories[e.Category] += e.Amount
+	}
+
+	for category, amount := range categories {
+		f.ByCategory = append(f.ByCategory, domain.CategoryExpense{Category: category, Amount: amount})
+	}
+
+	sort.Slice(f.ByCategory, func(i, j int) bool {
+		return f.ByCategory[i].Category < f.ByCategory[j].Category
+	})
+
+	f.Remaining = f.Budget - f.Spent
+	f.OverBudget = f.Budget > 0 && f.Spent > f.Budget
+
+	return f, nil
+}
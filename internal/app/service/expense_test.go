@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveExpenseOverBudget(t *testing.T) {
+	s, store := newMissionService(t,
+		&domain.Mission{ID: 1, Budget: 10000, Currency: "USD"},
+		&domain.Mission{ID: 2, Currency: "USD"},
+	)
+
+	resp, err := s.SaveExpense(context.Background(), 1, &domain.ExpenseRequest{Category: "travel", Amount: 6000, Currency: "usd"})
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.ExpenseResponse{ID: 1, Remaining: 4000}, resp)
+
+	resp, err = s.SaveExpense(context.Background(), 1, &domain.ExpenseRequest{Category: "travel", Amount: 4000, Currency: "USD"})
+	assert.NoError(t, err)
+	assert.False(t, resp.OverBudget, "spending the whole budget is over budget")
+
+	resp, err = s.SaveExpense(context.Background(), 1, &domain.ExpenseRequest{Category: "gear", Amount: 1, Currency: "USD"})
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.ExpenseResponse{ID: 3, Remaining: -1, OverBudget: true}, resp)
+
+	_, err = s.SaveExpense(context.Background(), 1, &domain.ExpenseRequest{Category: "gear", Amount: 1, Currency: "EUR"})
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	assert.Len(t, store.expenses, 3)
+
+	f, err := s.Financials(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10001), f.Spent)
+	assert.True(t, f.OverBudget)
+	assert.Equal(t, []domain.CategoryExpense{{Category: "gear", Amount: 1}, {Category: "travel", Amount: 10000}}, f.ByCategory)
+
+	// Missions without a budget are never over it.
+	resp, err = s.SaveExpense(context.Background(), 2, &domain.ExpenseRequest{Category: "travel", Amount: 6000, Currency: "USD"})
+	assert.NoError(t, err)
+	assert.False(t, resp.OverBudget)
+}
+
+func TestUpdateBudgetCurrency(t *testing.T) {
+	s, store := newMissionService(t, &domain.Mission{ID: 1, Currency: "JPY"})
+
+	err := s.UpdateBudget(context.Background(), 1, &domain.BudgetRequest{Budget: 500000, Bonus: 50000, Currency: "USD"})
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	assert.Zero(t, store.missions[1].Budget)
+
+	err = s.UpdateBudget(context.Background(), 1, &domain.BudgetRequest{Budget: 500000, Bonus: 50000, Currency: "jpy"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(500000), store.missions[1].Budget)
+	assert.Equal(t, int64(50000), store.missions[1].Bonus)
+
+	err = s.UpdateBudget(context.Background(), 9, &domain.BudgetRequest{Currency: "JPY"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
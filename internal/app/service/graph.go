@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// AddRelationship links the target to another one on behalf of the user.
+func (s *TargetService) AddRelationship(ctx context.Context, userID, id int, rr *domain.RelationshipRequest) (*domain.Relationship, error) {
+	const op = "service.AddRelationship"
+
+	if rr.ToID == id {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidRelationship)
+	}
+
+	confidence := rr.Confidence
+	if confidence == 0 {
+		confidence = 1
+	}
+
+	r := &domain.Relationship{
+		FromID:     id,
+		ToID:       rr.ToID,
+		Type:       rr.Type,
+		Confidence: confidence,
+		Source:     rr.Source,
+		UserID:     userID,
+	}
+
+	if err := s.saver.SaveRelationship(ctx, r); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			return nil, fmt.Errorf("%s: %w", op, ErrAlreadyExists)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return r, nil
+}
+
+// DeleteRelationship deletes a relationship the target is either end of.
+func (s *TargetService) DeleteRelationship(ctx context.Context, id, relationshipID int) error {
+	const op = "service.DeleteRelationship"
+
+	if err := s.processor.DeleteRelationship(ctx, id, relationshipID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Graph returns the targets at most depth relationships away from the target
+// and the relationships between them.
+func (s *TargetService) Graph(ctx context.Context, id, depth int) (*domain.TargetGraph, error) {
+	const op = "service.Graph"
+
+	graph, err := s.provider.TargetGraph(ctx, id, depth)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return graph, nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/dedupe"
+)
+
+// dedupeLockKey is the advisory lock key held while looking for duplicate
+// targets, so only one replica does it at a time.
+const dedupeLockKey int64 = 0x5350594341540002
+
+// DetectDuplicates looks for targets of the same country with names or aliases
+// at least threshold similar and makes them the pending merge candidates. It
+// returns the number of candidates found.
+//
+// The work is guarded by a Postgres advisory lock, when another replica holds
+// it the call returns immediately without looking.
+func (s *TargetService) DetectDuplicates(ctx context.Context, threshold float64) (int, error) {
+	const op = "service.DetectDuplicates"
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	locked, err := s.processor.TryAdvisoryLock(ctx, tx, dedupeLockKey)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !locked {
+		tx.Rollback()
+		return 0, nil
+	}
+
+	targets, err := s.provider.Targets(ctx)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	aliases, err := s.provider.AllTargetAliases(ctx)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	records := make([]dedupe.Record, 0, len(targets))
+	for _, t := range targets {
+		records = append(records, dedupe.Record{ID: t.ID, Name: t.Name, Country: t.Country, Aliases: aliases[t.ID]})
+	}
+
+	matches := dedupe.Find(records, threshold)
+	candidates := make([]*domain.MergeCandidate, 0, len(matches))
+	for _, m := range matches {
+		candidates = append(candidates, &domain.MergeCandidate{
+			Target:    domain.Target{ID: m.TargetID},
+			Duplicate: domain.Target{ID: m.DuplicateID},
+			Score:     m.Score,
+			Reasons:   m.Reasons,
+		})
+	}
+
+	if err := s.saver.ReplaceMergeCandidates(ctx, tx, candidates); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return len(candidates), nil
+}
+
+// MergeCandidates returns a page of the pending merge candidates, the most similar first.
+func (s *TargetService) MergeCandidates(ctx context.Context, filter *domain.MergeCandidateFilter) ([]*domain.MergeCandidate, error) {
+	const op = "service.MergeCandidates"
+
+	candidates, err := s.provider.MergeCandidates(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return candidates, nil
+}
+
+// DismissMergeCandidate keeps the pair of targets from being suggested again.
+func (s *TargetService) DismissMergeCandidate(ctx context.Context, md *domain.MergeDismissal) error {
+	const op = "service.DismissMergeCandidate"
+
+	err := s.processor.DismissMergeCandidate(ctx, min(md.TargetID, md.DuplicateID), max(md.TargetID, md.DuplicateID))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MergeTargets merges the duplicates into the target on behalf of the user and
+// returns the dossier of the target. Missions, notes, photos and relationships
+// of the duplicates move to the target and their names become its aliases, see
+// MergeTarget of the storage. The merge is recorded in the audit log.
+func (s *TargetService) MergeTargets(ctx context.Context, userID int, mr *domain.TargetMergeRequest) (*domain.Dossier, error) {
+	const op = "service.MergeTargets"
+
+	target, err := s.provider.TargetByID(ctx, mr.TargetID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	seen := make(map[int]bool, len(mr.DuplicateIDs))
+	merged := make([]map[string]any, 0, len(mr.DuplicateIDs))
+	ids := make([]int, 0, len(mr.DuplicateIDs))
+	for _, id := range mr.DuplicateIDs {
+		if id == target.ID {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidMerge)
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		duplicate, err := s.provider.TargetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+			}
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		ids = append(ids, id)
+		merged = append(merged, map[string]any{"id": duplicate.ID, "name": duplicate.Name, "country": duplicate.Country})
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, id := range ids {
+		if err := s.processor.MergeTarget(ctx, tx, target.ID, id); err != nil {
+			tx.Rollback()
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+			}
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	entry := &domain.AuditEntry{
+		UserID:   userID,
+		Action:   "target.merged",
+		Entity:   "target",
+		EntityID: target.ID,
+		Details: map[string]any{
+			"name":          target.Name,
+			"country":       target.Country,
+			"duplicate_ids": ids,
+			"duplicates":    merged,
+		},
+	}
+
+	if err := s.saver.SaveAuditEntry(ctx, tx, entry); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	dossier, err := s.Dossier(ctx, target.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return dossier, nil
+}
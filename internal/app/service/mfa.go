@@ -0,0 +1,347 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/totp"
+)
+
+// totpSkew is how many time steps before and after the current one a code is
+// accepted for, to allow for the clock of the phone drifting.
+const totpSkew = 1
+
+// qrCodeSize is the width and height in pixels of the enrollment QR code.
+const qrCodeSize = 256
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EnrollTOTP generates a new TOTP secret for the user. The secret is not used
+// for login until it is confirmed with ActivateTOTP.
+func (s *AuthService) EnrollTOTP(ctx context.Context, cfg config.Auth, userID int) (*domain.TOTPEnrollment, error) {
+	const op = "service.EnrollTOTP"
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrMFAEnabled)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.saver.SaveTOTPSecret(ctx, userID, secret); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &domain.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(cfg.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+// TOTPQRCode renders the pending enrollment of the user as a PNG QR code.
+// Once TOTP is activated the secret is not shown anymore.
+func (s *AuthService) TOTPQRCode(ctx context.Context, cfg config.Auth, userID int) ([]byte, error) {
+	const op = "service.TOTPQRCode"
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.TOTPSecret == "" || user.TOTPEnabledAt != nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+
+	png, err := totp.QRCode(totp.URI(cfg.MFAIssuer, user.Email, user.TOTPSecret), qrCodeSize)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return png, nil
+}
+
+// ActivateTOTP confirms the pending enrollment with a code from the authenticator
+// and returns the recovery codes. They are shown this once only.
+func (s *AuthService) ActivateTOTP(ctx context.Context, userID int, code string) (*domain.RecoveryCodes, error) {
+	const op = "service.ActivateTOTP"
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrMFAEnabled)
+	}
+
+	if user.TOTPSecret == "" {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidMFACode)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.processor.EnableTOTP(ctx, tx, userID, step); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.processor.ReplaceRecoveryCodes(ctx, tx, userID, hashes); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &domain.RecoveryCodes{Codes: codes}, nil
+}
+
+// DisableTOTP turns TOTP off for the user, the code or a recovery code is asked
+// for so a stolen access token alone cannot remove the second factor.
+func (s *AuthService) DisableTOTP(ctx context.Context, userID int, code string) error {
+	const op = "service.DisableTOTP"
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.checkSecondFactor(ctx, user, code); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.processor.DisableTOTP(ctx, tx, userID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user, the old ones stop working.
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID int, code string) (*domain.RecoveryCodes, error) {
+	const op = "service.RegenerateRecoveryCodes"
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.checkSecondFactor(ctx, user, code); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.processor.ReplaceRecoveryCodes(ctx, tx, userID, hashes); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &domain.RecoveryCodes{Codes: codes}, nil
+}
+
+// LoginMFA answers the challenge of Login with a TOTP code or a recovery code
+// and returns an access token.
+// Wrong codes count as failed logins of the account, like wrong passwords.
+func (s *AuthService) LoginMFA(ctx context.Context, cfg config.Auth, req *domain.MFALoginRequest, client domain.Client) (string, error) {
+	const op = "service.LoginMFA"
+
+	userID, sessionVersion, err := s.keys.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
+	}
+
+	user, err := s.provider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The password was reset since the challenge was issued.
+	if user.SessionVersion != sessionVersion {
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if err := s.checkGuard(ctx, user.Email, client.IP); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.checkSecondFactor(ctx, user, req.Code); err != nil {
+		if !errors.Is(err, ErrInvalidMFACode) {
+			// Only wrong codes count.
+			if err := s.guard.Release(ctx, user.Email, client.IP); err != nil {
+				return "", fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.guard.Release(ctx, user.Email, client.IP); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.guard.Succeed(ctx, user.Email); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := s.newSession(ctx, cfg, user, client)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// MFAPolicies returns whether MFA is required, per role.
+func (s *AuthService) MFAPolicies(ctx context.Context) ([]domain.MFAPolicy, error) {
+	const op = "service.MFAPolicies"
+
+	policies, err := s.provider.MFAPolicies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return policies, nil
+}
+
+// SetMFAPolicy requires or stops requiring MFA for a role, only admins may change it.
+// Users of the role who have not enabled TOTP are then held to the MFA routes.
+func (s *AuthService) SetMFAPolicy(ctx context.Context, userID int, policy *domain.MFAPolicy) error {
+	const op = "service.SetMFAPolicy"
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.Role != domain.RoleAdmin {
+		return fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	if err := s.saver.SaveMFAPolicy(ctx, policy); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *AuthService) user(ctx context.Context, userID int) (*domain.User, error) {
+	user, err := s.provider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// checkSecondFactor accepts a TOTP code, each time step once, or an unused recovery code.
+func (s *AuthService) checkSecondFactor(ctx context.Context, user *domain.User, code string) error {
+	if user.TOTPEnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), totpSkew)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+
+		if err := s.processor.UseTOTPStep(ctx, user.ID, step); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return ErrInvalidMFACode
+			}
+			return err
+		}
+
+		return nil
+	}
+
+	if err := s.processor.UseRecoveryCode(ctx, user.ID, hashToken(normalizeRecoveryCode(code))); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+
+	return nil
+}
+
+// newRecoveryCodes returns recovery codes like "k3j5-9x2m" and their hashes.
+func newRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, domain.RecoveryCodeCount)
+	hashes := make([][]byte, domain.RecoveryCodeCount)
+
+	for i := range codes {
+		secret := make([]byte, 5)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(recoveryEncoding.EncodeToString(secret))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode lets recovery codes be typed in any case, with or without the dash.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
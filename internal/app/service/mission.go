@@ -5,33 +5,57 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/markraiter/spycat/internal/app/events"
 	"github.com/markraiter/spycat/internal/app/storage"
 	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/geojson"
+	"github.com/markraiter/spycat/internal/lib/money"
 )
 
 type MissionSaver interface {
 	SaveMission(ctx context.Context, tx *sql.Tx, mission *domain.Mission) (int, error)
 	SaveTarget(ctx context.Context, tx *sql.Tx, target *domain.Target) error
+	LinkTarget(ctx context.Context, tx *sql.Tx, missionID, targetID int) error
+	SaveExpense(ctx context.Context, expense *domain.Expense) (int, error)
+	SaveBonus(ctx context.Context, tx *sql.Tx, bonus *domain.Bonus) (int, error)
+	AuditSaver
+	EventSaver
 }
 
 type MissionProvider interface {
-	Missions(ctx context.Context) ([]*domain.Mission, error)
+	Missions(ctx context.Context, filter domain.MissionFilter) ([]*domain.Mission, error)
 	MissionByID(ctx context.Context, id int) (*domain.Mission, error)
-	Targets(ctx context.Context) ([]*domain.Target, error)
+	MissionTargets(ctx context.Context) ([]*domain.Target, error)
+	Expenses(ctx context.Context, missionID int) ([]*domain.Expense, error)
+	MissionBonuses(ctx context.Context, missionID int) ([]*domain.Bonus, error)
+	CatSkills(ctx context.Context, catID int) ([]domain.CatSkill, error)
+	MissionSkills(ctx context.Context, missionID int) ([]domain.RequiredSkill, error)
+	MissionTargetSkills(ctx context.Context, missionID int) ([]domain.RequiredSkill, error)
+	CandidateProvider
 }
 
 type MissionProcessor interface {
 	BeginTx(ctx context.Context) (*sql.Tx, error)
-	AssignMissionToCat(ctx context.Context, catID, missionID int) error
-	CompleteMission(ctx context.Context, id int) error
-	DeleteMission(ctx context.Context, id int) error
+	AssignMissionToCat(ctx context.Context, tx *sql.Tx, catID, missionID int) error
+	CompleteMission(ctx context.Context, tx *sql.Tx, id int) (*domain.Mission, error)
+	UpdateMissionBudget(ctx context.Context, id int, budget, bonus int64) error
+	UpdateMissionSchedule(ctx context.Context, tx *sql.Tx, mission *domain.Mission) error
+	MarkOverdueMissions(ctx context.Context, tx *sql.Tx) ([]*domain.Mission, error)
+	TryAdvisoryLock(ctx context.Context, tx *sql.Tx, key int64) (bool, error)
+	DeleteMission(ctx context.Context, tx *sql.Tx, id int) error
 }
 
+// overdueLockKey is the advisory lock key held while marking overdue missions,
+// so only one replica does it at a time.
+const overdueLockKey int64 = 0x5350594341540001
+
 type MissionService struct {
 	saver     MissionSaver
 	provider  MissionProvider
 	processor MissionProcessor
+	publisher events.Publisher
 }
 
 func (s *MissionService) SaveMission(ctx context.Context, mr *domain.MissionRequest) (int, error) {
@@ -42,12 +66,32 @@ func (s *MissionService) SaveMission(ctx context.Context, mr *domain.MissionRequ
 		Targets:   mr.Targets,
 		Notes:     mr.Notes,
 		Completed: mr.Completed,
+		Budget:    mr.Budget,
+		Bonus:     mr.Bonus,
+		Currency:  money.Normalize(mr.Currency),
+		Priority:  mr.Priority,
+		StartsAt:  mr.StartsAt,
+		DueAt:     mr.DueAt,
+	}
+
+	if mission.Priority == 0 {
+		mission.Priority = domain.PriorityDefault
 	}
 
 	if len(mission.Targets) > 3 {
 		return 0, fmt.Errorf("%s: %w", op, ErrTooManyTargets)
 	}
 
+	if !validSchedule(mission.StartsAt, mission.DueAt) {
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidSchedule)
+	}
+
+	for _, target := range mission.Targets {
+		if !validLocation(&target) {
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidLocation)
+		}
+	}
+
 	tx, err := s.processor.BeginTx(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
@@ -59,12 +103,38 @@ func (s *MissionService) SaveMission(ctx context.Context, mr *domain.MissionRequ
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	linked := make(map[int]bool, len(mission.Targets))
 	for _, target := range mission.Targets {
-		target.MissionID = missionID
-		if err := s.saver.SaveTarget(ctx, tx, &target); err != nil {
+		// A target with an ID is a registered one, the others are registered now.
+		if target.ID == 0 {
+			if err := s.saver.SaveTarget(ctx, tx, &target); err != nil {
+				tx.Rollback()
+				return 0, fmt.Errorf("%s: %w", op, err)
+			}
+		} else if linked[target.ID] {
+			continue
+		}
+
+		if err := s.saver.LinkTarget(ctx, tx, missionID, target.ID); err != nil {
 			tx.Rollback()
+			if errors.Is(err, storage.ErrNotFound) {
+				return 0, fmt.Errorf("%s: %w", op, ErrNotFound)
+			}
 			return 0, fmt.Errorf("%s: %w", op, err)
 		}
+		linked[target.ID] = true
+	}
+
+	event := &domain.Event{
+		Type:      domain.EventMissionCreated,
+		MissionID: missionID,
+		CatID:     mission.CatID,
+		Payload:   map[string]any{"priority": mission.Priority, "targets": len(mission.Targets)},
+	}
+
+	if err := s.saver.SaveEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
 	err = tx.Commit()
@@ -72,10 +142,12 @@ func (s *MissionService) SaveMission(ctx context.Context, mr *domain.MissionRequ
 		return 0, err
 	}
 
+	s.publisher.Publish(ctx, event)
+
 	return missionID, nil
 }
 
-func (s *MissionService) Missions(ctx context.Context) ([]*domain.Mission, error) {
+func (s *MissionService) Missions(ctx context.Context, filter domain.MissionFilter) ([]*domain.Mission, error) {
 	const op = "service.Missions"
 
 	tx, err := s.processor.BeginTx(ctx)
@@ -83,13 +155,13 @@ func (s *MissionService) Missions(ctx context.Context) ([]*domain.Mission, error
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	missions, err := s.provider.Missions(ctx)
+	missions, err := s.provider.Missions(ctx, filter)
 	if err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	targets, err := s.provider.Targets(ctx)
+	targets, err := s.provider.MissionTargets(ctx)
 	if err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -101,6 +173,9 @@ func (s *MissionService) Missions(ctx context.Context) ([]*domain.Mission, error
 				m.Targets = append(m.Targets, *t)
 			}
 		}
+		if filter.From != nil {
+			setDistances(m.Targets, *filter.From)
+		}
 	}
 
 	err = tx.Commit()
@@ -129,7 +204,7 @@ func (s *MissionService) MissionByID(ctx context.Context, id int) (*domain.Missi
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	targets, err := s.provider.Targets(ctx)
+	targets, err := s.provider.MissionTargets(ctx)
 	if err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -141,6 +216,12 @@ func (s *MissionService) MissionByID(ctx context.Context, id int) (*domain.Missi
 		}
 	}
 
+	mission.RequiredSkills, err = s.requiredSkills(ctx, mission.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -149,44 +230,335 @@ func (s *MissionService) MissionByID(ctx context.Context, id int) (*domain.Missi
 	return mission, nil
 }
 
-func (s *MissionService) AssignMissionToCat(ctx context.Context, catID, missionID int) error {
+// MissionFrom returns the mission like MissionByID, the located targets carry
+// their distance from the point.
+func (s *MissionService) MissionFrom(ctx context.Context, id int, from domain.GeoPoint) (*domain.Mission, error) {
+	const op = "service.MissionFrom"
+
+	mission, err := s.MissionByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	setDistances(mission.Targets, from)
+
+	return mission, nil
+}
+
+// MissionGeoJSON returns the layers of the targets of the mission as GeoJSON,
+// both when none is given.
+func (s *MissionService) MissionGeoJSON(ctx context.Context, id int, layers ...string) (*domain.FeatureCollection, error) {
+	const op = "service.MissionGeoJSON"
+
+	mission, err := s.MissionByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	targets := make([]*domain.Target, len(mission.Targets))
+	for i := range mission.Targets {
+		targets[i] = &mission.Targets[i]
+	}
+
+	return geojson.Collection(targets, layers...), nil
+}
+
+// AssignMissionToCat assigns the mission to the cat on behalf of the user.
+//
+// When the cat does not meet the skills required by the mission or by its
+// targets not completed yet the assignment is refused with ErrMissingSkills
+// unless override is set. Every assignment is recorded in the audit log
+// together with the override flag and the skill gaps.
+func (s *MissionService) AssignMissionToCat(ctx context.Context, userID, catID, missionID int, override bool) ([]domain.SkillGap, error) {
 	const op = "service.AssignMissionToCat"
 
-	err := s.processor.AssignMissionToCat(ctx, catID, missionID)
+	required, err := s.requiredSkills(ctx, missionID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	skills, err := s.provider.CatSkills(ctx, catID)
 	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	gaps := domain.SkillGaps(required, skills)
+	if len(gaps) > 0 && !override {
+		return gaps, fmt.Errorf("%s: %w", op, ErrMissingSkills)
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.processor.AssignMissionToCat(ctx, tx, catID, missionID)
+	if err != nil {
+		tx.Rollback()
 		if errors.Is(err, storage.ErrNotFound) {
-			return fmt.Errorf("%s: %w", op, ErrNotFound)
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
 		}
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return nil
+	entry := &domain.AuditEntry{
+		UserID:   userID,
+		Action:   "mission.assigned",
+		Entity:   "mission",
+		EntityID: missionID,
+		Details: map[string]any{
+			"cat_id":         catID,
+			"override":       override,
+			"missing_skills": gaps,
+		},
+	}
+
+	if err := s.saver.SaveAuditEntry(ctx, tx, entry); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := &domain.Event{
+		Type:      domain.EventMissionAssigned,
+		MissionID: missionID,
+		CatID:     catID,
+		Payload:   map[string]any{"override": override},
+	}
+
+	if err := s.saver.SaveEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.publisher.Publish(ctx, event)
+
+	return gaps, nil
 }
 
+// CompleteMission marks the mission completed and pays the mission bonus
+// to the assigned cat. Completing an already completed mission pays nothing,
+// emits no event and fails with ErrMissionCompleted.
 func (s *MissionService) CompleteMission(ctx context.Context, id int) error {
 	const op = "service.CompleteMission"
 
-	err := s.processor.CompleteMission(ctx, id)
+	tx, err := s.processor.BeginTx(ctx)
 	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	mission, err := s.processor.CompleteMission(ctx, tx, id)
+	if err != nil {
+		tx.Rollback()
 		if errors.Is(err, storage.ErrNotFound) {
 			return fmt.Errorf("%s: %w", op, ErrNotFound)
 		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if mission == nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, ErrMissionCompleted)
+	}
+
+	if mission.Bonus > 0 && mission.CatID != 0 {
+		bonus := &domain.Bonus{
+			CatID:     mission.CatID,
+			MissionID: mission.ID,
+			Amount:    mission.Bonus,
+			Currency:  mission.Currency,
+			Reason:    fmt.Sprintf("mission %d completed", mission.ID),
+		}
+
+		if _, err := s.saver.SaveBonus(ctx, tx, bonus); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	event := &domain.Event{
+		Type:      domain.EventMissionCompleted,
+		MissionID: mission.ID,
+		CatID:     mission.CatID,
+	}
+
+	if err := s.saver.SaveEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.publisher.Publish(ctx, event)
+
 	return nil
 }
 
 func (s *MissionService) DeleteMission(ctx context.Context, id int) error {
 	const op = "service.DeleteMission"
 
-	err := s.processor.DeleteMission(ctx, id)
+	mission, err := s.provider.MissionByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.processor.DeleteMission(ctx, tx, id); err != nil {
+		tx.Rollback()
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := &domain.Event{Type: domain.EventMissionDeleted, MissionID: id, CatID: mission.CatID}
+	if err := s.saver.SaveEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.publisher.Publish(ctx, event)
+
+	return nil
+}
+
+// UpdateSchedule changes priority and dates of the mission and records
+// a mission.rescheduled event.
+func (s *MissionService) UpdateSchedule(ctx context.Context, id int, sr *domain.ScheduleRequest) error {
+	const op = "service.UpdateSchedule"
+
+	if !validSchedule(sr.StartsAt, sr.DueAt) {
+		return fmt.Errorf("%s: %w", op, ErrInvalidSchedule)
+	}
+
+	mission := &domain.Mission{
+		ID:       id,
+		Priority: sr.Priority,
+		StartsAt: sr.StartsAt,
+		DueAt:    sr.DueAt,
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
 	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.processor.UpdateMissionSchedule(ctx, tx, mission); err != nil {
+		tx.Rollback()
 		if errors.Is(err, storage.ErrNotFound) {
 			return fmt.Errorf("%s: %w", op, ErrNotFound)
 		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	event := &domain.Event{
+		Type:      domain.EventMissionRescheduled,
+		MissionID: id,
+		CatID:     mission.CatID,
+		Payload:   map[string]any{"priority": sr.Priority, "starts_at": sr.StartsAt, "due_at": sr.DueAt},
+	}
+
+	if err := s.saver.SaveEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.publisher.Publish(ctx, event)
+
 	return nil
 }
+
+// MarkOverdue flags missions past their due date and records a mission.overdue
+// event for each of them. It returns the number of missions marked.
+//
+// The work is guarded by a Postgres advisory lock, when another replica holds
+// it the call returns immediately without marking anything.
+func (s *MissionService) MarkOverdue(ctx context.Context) (int, error) {
+	const op = "service.MarkOverdue"
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	locked, err := s.processor.TryAdvisoryLock(ctx, tx, overdueLockKey)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !locked {
+		tx.Rollback()
+		return 0, nil
+	}
+
+	missions, err := s.processor.MarkOverdueMissions(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	overdue := make([]*domain.Event, 0, len(missions))
+	for _, m := range missions {
+		event := &domain.Event{
+			Type:      domain.EventMissionOverdue,
+			MissionID: m.ID,
+			CatID:     m.CatID,
+			Payload:   map[string]any{"due_at": m.DueAt, "priority": m.Priority},
+		}
+
+		if err := s.saver.SaveEvent(ctx, tx, event); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		overdue = append(overdue, event)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.publisher.Publish(ctx, overdue...)
+
+	return len(missions), nil
+}
+
+// requiredSkills returns the skills required by the mission and by its targets
+// not completed in it.
+func (s *MissionService) requiredSkills(ctx context.Context, missionID int) ([]domain.RequiredSkill, error) {
+	required, err := s.provider.MissionSkills(ctx, missionID)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := s.provider.MissionTargetSkills(ctx, missionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.MergeRequiredSkills(required, targets), nil
+}
+
+func validSchedule(startsAt, dueAt *time.Time) bool {
+	return startsAt == nil || dueAt == nil || dueAt.After(*startsAt)
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// missionStore fakes the storage of missions, the events it saves and publishes
+// are recorded. Missions not in missions are not found.
+type missionStore struct {
+	MissionSaver
+	MissionProvider
+	MissionProcessor
+	db *txDB
+
+	missions map[int]*domain.Mission
+	// lockHeld tells that another replica holds the advisory lock.
+	lockHeld bool
+
+	// Skills required by missions and by their targets, and those of cats, by ID.
+	missionSkills map[int][]domain.RequiredSkill
+	targetSkills  map[int][]domain.RequiredSkill
+	catSkills     map[int][]domain.CatSkill
+
+	expenses  []*domain.Expense
+	bonuses   []*domain.Bonus
+	audit     []*domain.AuditEntry
+	events    []*domain.Event
+	published []*domain.Event
+}
+
+func newMissionService(t *testing.T, missions ...*domain.Mission) (*MissionService, *missionStore) {
+	store := &missionStore{db: newTxDB(t), missions: make(map[int]*domain.Mission)}
+	for _, m := range missions {
+		store.missions[m.ID] = m
+	}
+
+	return &MissionService{
+		saver:     store,
+		provider:  store,
+		processor: store,
+		publisher: store,
+	}, store
+}
+
+func (s *missionStore) BeginTx(ctx context.Context) (*sql.Tx, error) {
+	return s.db.BeginTx(ctx)
+}
+
+func (s *missionStore) SaveEvent(ctx context.Context, tx *sql.Tx, event *domain.Event) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *missionStore) Publish(ctx context.Context, events ...*domain.Event) {
+	s.published = append(s.published, events...)
+}
+
+func (s *missionStore) MissionByID(ctx context.Context, id int) (*domain.Mission, error) {
+	m, ok := s.missions[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	mission := *m
+	return &mission, nil
+}
+
+func (s *missionStore) SaveExpense(ctx context.Context, expense *domain.Expense) (int, error) {
+	s.expenses = append(s.expenses, expense)
+	return len(s.expenses), nil
+}
+
+func (s *missionStore) Expenses(ctx context.Context, missionID int) ([]*domain.Expense, error) {
+	var expenses []*domain.Expense
+	for _, e := range s.expenses {
+		if e.MissionID == missionID {
+			expenses = append(expenses, e)
+		}
+	}
+
+	return expenses, nil
+}
+
+func (s *missionStore) SaveBonus(ctx context.Context, tx *sql.Tx, bonus *domain.Bonus) (int, error) {
+	s.bonuses = append(s.bonuses, bonus)
+	return len(s.bonuses), nil
+}
+
+func (s *missionStore) MissionBonuses(ctx context.Context, missionID int) ([]*domain.Bonus, error) {
+	var bonuses []*domain.Bonus
+	for _, b := range s.bonuses {
+		if b.MissionID == missionID {
+			bonuses = append(bonuses, b)
+		}
+	}
+
+	return bonuses, nil
+}
+
+func (s *missionStore) UpdateMissionBudget(ctx context.Context, id int, budget, bonus int64) error {
+	m, ok := s.missions[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	m.Budget, m.Bonus = budget, bonus
+
+	return nil
+}
+
+func (s *missionStore) CompleteMission(ctx context.Context, tx *sql.Tx, id int) (*domain.Mission, error) {
+	m, ok := s.missions[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	if m.Completed {
+		return nil, nil
+	}
+
+	m.Completed = true
+	mission := *m
+
+	return &mission, nil
+}
+
+func (s *missionStore) MissionSkills(ctx context.Context, missionID int) ([]domain.RequiredSkill, error) {
+	return s.missionSkills[missionID], nil
+}
+
+func (s *missionStore) MissionTargetSkills(ctx context.Context, missionID int) ([]domain.RequiredSkill, error) {
+	return s.targetSkills[missionID], nil
+}
+
+func (s *missionStore) CatSkills(ctx context.Context, catID int) ([]domain.CatSkill, error) {
+	return s.catSkills[catID], nil
+}
+
+func (s *missionStore) AssignMissionToCat(ctx context.Context, tx *sql.Tx, catID, missionID int) error {
+	m, ok := s.missions[missionID]
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	m.CatID = catID
+
+	return nil
+}
+
+func (s *missionStore) SaveAuditEntry(ctx context.Context, tx *sql.Tx, entry *domain.AuditEntry) error {
+	s.audit = append(s.audit, entry)
+	return nil
+}
+
+func (s *missionStore) UpdateMissionSchedule(ctx context.Context, tx *sql.Tx, mission *domain.Mission) error {
+	m, ok := s.missions[mission.ID]
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	m.Priority, m.StartsAt, m.DueAt, m.Overdue = mission.Priority, mission.StartsAt, mission.DueAt, false
+	mission.CatID = m.CatID
+
+	return nil
+}
+
+func (s *missionStore) TryAdvisoryLock(ctx context.Context, tx *sql.Tx, key int64) (bool, error) {
+	return !s.lockHeld, nil
+}
+
+func (s *missionStore) MarkOverdueMissions(ctx context.Context, tx *sql.Tx) ([]*domain.Mission, error) {
+	var marked []*domain.Mission
+	for _, m := range s.missions {
+		if !m.Completed && !m.Overdue && m.DueAt != nil && m.DueAt.Before(time.Now()) {
+			m.Overdue = true
+			marked = append(marked, m)
+		}
+	}
+
+	return marked, nil
+}
+
+func TestAssignMissionToCatSkills(t *testing.T) {
+	s, store := newMissionService(t, &domain.Mission{ID: 1})
+
+	store.missionSkills = map[int][]domain.RequiredSkill{
+		1: {{SkillID: 1, Name: "infiltration", MinLevel: 2}, {SkillID: 2, Name: "languages", MinLevel: 3}},
+	}
+	// A target of the mission calls for more infiltration and for hacking.
+	store.targetSkills = map[int][]domain.RequiredSkill{
+		1: {{SkillID: 1, Name: "infiltration", MinLevel: 4}, {SkillID: 3, Name: "hacking", MinLevel: 1}},
+	}
+	store.catSkills = map[int][]domain.CatSkill{
+		7: {{SkillID: 1, Name: "infiltration", Level: 3}, {SkillID: 2, Name: "languages", Level: 5}},
+	}
+
+	want := []domain.SkillGap{
+		{SkillID: 3, Name: "hacking", Required: 1, Actual: 0},
+		{SkillID: 1, Name: "infiltration", Required: 4, Actual: 3},
+	}
+
+	gaps, err := s.AssignMissionToCat(context.Background(), 5, 7, 1, false)
+	assert.ErrorIs(t, err, ErrMissingSkills)
+	assert.Equal(t, want, gaps)
+	assert.Zero(t, store.missions[1].CatID, "mission assigned without override")
+	assert.Empty(t, store.audit)
+	assert.Empty(t, store.events)
+
+	gaps, err = s.AssignMissionToCat(context.Background(), 5, 7, 1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, want, gaps)
+	assert.Equal(t, 7, store.missions[1].CatID)
+
+	if assert.Len(t, store.audit, 1) {
+		assert.Equal(t, &domain.AuditEntry{
+			UserID:   5,
+			Action:   "mission.assigned",
+			Entity:   "mission",
+			EntityID: 1,
+			Details:  map[string]any{"cat_id": 7, "override": true, "missing_skills": want},
+		}, store.audit[0])
+	}
+
+	if assert.Len(t, store.published, 1) {
+		assert.Equal(t, domain.EventMissionAssigned, store.published[0].Type)
+		assert.Equal(t, map[string]any{"override": true}, store.published[0].Payload)
+	}
+
+	// A cat with every skill needs no override, and it is recorded so.
+	store.catSkills[8] = []domain.CatSkill{{SkillID: 1, Level: 4}, {SkillID: 2, Level: 3}, {SkillID: 3, Level: 1}}
+	gaps, err = s.AssignMissionToCat(context.Background(), 5, 8, 1, false)
+	assert.NoError(t, err)
+	assert.Empty(t, gaps)
+	if assert.Len(t, store.audit, 2) {
+		assert.Equal(t, false, store.audit[1].Details["override"])
+	}
+}
+
+func TestCompleteMissionPaysOnce(t *testing.T) {
+	s, store := newMissionService(t,
+		&domain.Mission{ID: 1, CatID: 2, Bonus: 50000, Currency: "EUR"},
+		&domain.Mission{ID: 2, Bonus: 50000, Currency: "EUR"},
+	)
+
+	assert.NoError(t, s.CompleteMission(context.Background(), 1))
+	assert.ErrorIs(t, s.CompleteMission(context.Background(), 1), ErrMissionCompleted)
+
+	if assert.Len(t, store.bonuses, 1) {
+		assert.Equal(t, &domain.Bonus{
+			CatID:     2,
+			MissionID: 1,
+			Amount:    50000,
+			Currency:  "EUR",
+			Reason:    "mission 1 completed",
+		}, store.bonuses[0])
+	}
+
+	// Without a cat there is nobody to pay.
+	assert.NoError(t, s.CompleteMission(context.Background(), 2))
+	assert.Len(t, store.bonuses, 1)
+
+	assert.ErrorIs(t, s.CompleteMission(context.Background(), 9), ErrNotFound)
+
+	if assert.Len(t, store.events, 2) {
+		assert.Equal(t, domain.EventMissionCompleted, store.events[0].Type)
+		assert.Equal(t, 1, store.events[0].MissionID)
+		assert.Equal(t, 2, store.events[1].MissionID)
+	}
+	assert.Equal(t, store.events, store.published)
+
+	f, err := s.Financials(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.True(t, f.BonusPaid)
+}
+
+func TestValidSchedule(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	assert.True(t, validSchedule(nil, nil))
+	assert.True(t, validSchedule(&now, nil))
+	assert.True(t, validSchedule(nil, &now))
+	assert.True(t, validSchedule(&now, &later))
+	assert.False(t, validSchedule(&later, &now), "due before the start")
+	assert.False(t, validSchedule(&now, &now), "due at the start")
+}
+
+func TestUpdateScheduleRecordsEvent(t *testing.T) {
+	due := time.Now().Add(-time.Hour)
+	s, store := newMissionService(t, &domain.Mission{ID: 1, CatID: 2, DueAt: &due, Overdue: true})
+
+	newDue := time.Now().Add(24 * time.Hour)
+	err := s.UpdateSchedule(context.Background(), 1, &domain.ScheduleRequest{Priority: 3, DueAt: &newDue})
+	assert.NoError(t, err)
+	assert.False(t, store.missions[1].Overdue)
+
+	if assert.Len(t, store.events, 1) {
+		assert.Equal(t, domain.EventMissionRescheduled, store.events[0].Type)
+		assert.Equal(t, 1, store.events[0].MissionID)
+		assert.Equal(t, 2, store.events[0].CatID)
+	}
+	assert.Equal(t, store.events, store.published)
+
+	err = s.UpdateSchedule(context.Background(), 9, &domain.ScheduleRequest{})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	err = s.UpdateSchedule(context.Background(), 1, &domain.ScheduleRequest{StartsAt: &newDue, DueAt: &due})
+	assert.ErrorIs(t, err, ErrInvalidSchedule)
+
+	assert.Len(t, store.events, 1, "event recorded for a failed update")
+	commits, rollbacks := store.db.ended()
+	assert.Equal(t, 1, commits)
+	assert.Equal(t, 1, rollbacks)
+}
+
+func TestMarkOverdue(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	s, store := newMissionService(t,
+		&domain.Mission{ID: 1, CatID: 2, DueAt: &past},
+		&domain.Mission{ID: 2, DueAt: &future},
+		&domain.Mission{ID: 3, DueAt: &past, Completed: true},
+	)
+
+	store.lockHeld = true
+	n, err := s.MarkOverdue(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, n, "missions marked without the lock")
+	assert.False(t, store.missions[1].Overdue)
+	assert.Empty(t, store.events)
+
+	store.lockHeld = false
+	n, err = s.MarkOverdue(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, store.missions[1].Overdue)
+
+	if assert.Len(t, store.events, 1) {
+		assert.Equal(t, domain.EventMissionOverdue, store.events[0].Type)
+		assert.Equal(t, 1, store.events[0].MissionID)
+		assert.Equal(t, 2, store.events[0].CatID)
+	}
+	assert.Equal(t, store.events, store.published)
+
+	n, _ = s.MarkOverdue(context.Background())
+	assert.Zero(t, n, "overdue mission marked again")
+	assert.Len(t, store.events, 1)
+
+	commits, rollbacks := store.db.ended()
+	assert.Equal(t, 2, commits)
+	assert.Equal(t, 1, rollbacks)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+type NotificationSaver interface {
+	SaveNotificationPreferences(ctx context.Context, userID int, preferences []domain.NotificationPreference) error
+}
+
+type NotificationProvider interface {
+	NotificationPreferences(ctx context.Context, userID int) ([]domain.NotificationPreference, error)
+	NotificationRecipients(ctx context.Context, eventType domain.EventType, catID int) ([]domain.Recipient, error)
+}
+
+type NotificationService struct {
+	saver    NotificationSaver
+	provider NotificationProvider
+}
+
+// NotificationPreferences returns the preference of the user for every notifiable
+// event type, notifications are on unless the user turned them off.
+func (s *NotificationService) NotificationPreferences(ctx context.Context, userID int) ([]domain.NotificationPreference, error) {
+	const op = "service.NotificationPreferences"
+
+	stored, err := s.provider.NotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	email := make(map[domain.EventType]bool, len(stored))
+	for _, p := range stored {
+		email[p.EventType] = p.Email
+	}
+
+	preferences := make([]domain.NotificationPreference, 0, len(domain.NotifiableEvents))
+	for _, t := range domain.NotifiableEvents {
+		on, ok := email[t]
+		preferences = append(preferences, domain.NotificationPreference{EventType: t, Email: on || !ok})
+	}
+
+	return preferences, nil
+}
+
+func (s *NotificationService) UpdateNotificationPreferences(ctx context.Context, userID int, nr *domain.NotificationPreferencesRequest) ([]domain.NotificationPreference, error) {
+	const op = "service.UpdateNotificationPreferences"
+
+	if err := s.saver.SaveNotificationPreferences(ctx, userID, nr.Preferences); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	preferences, err := s.NotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return preferences, nil
+}
+
+// NotificationRecipients returns the users to email about an event of the cat.
+func (s *NotificationService) NotificationRecipients(ctx context.Context, eventType domain.EventType, catID int) ([]domain.Recipient, error) {
+	const op = "service.NotificationRecipients"
+
+	recipients, err := s.provider.NotificationRecipients(ctx, eventType, catID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return recipients, nil
+}
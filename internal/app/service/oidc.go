@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/oidc"
+)
+
+// maxUsernameLength is the longest username, as for users who register.
+const maxUsernameLength = 50
+
+// StartOIDC begins a login with the identity provider: it returns the URL of the
+// provider to send the browser to and the signed flow, for the callback to check.
+func (s *AuthService) StartOIDC(ctx context.Context, cfg config.OIDC) (*domain.OIDCRedirect, error) {
+	const op = "service.StartOIDC"
+
+	if s.idp == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrOIDCDisabled)
+	}
+
+	var flow domain.OIDCFlow
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		random, err := randomString(32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		*value = random
+	}
+
+	url, err := s.idp.AuthCodeURL(ctx, &flow)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	flowToken, err := s.keys.NewOIDCFlowToken(&flow, cfg.StateTTL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &domain.OIDCRedirect{URL: url, FlowToken: flowToken}, nil
+}
+
+// LoginOIDC completes a login with the identity provider. The user the ID token
+// was issued for is signed in from the client, after being linked by email to
+// the existing user or provisioned. Users with TOTP enabled get a challenge like with Login.
+//
+// The groups of the user set its role, when cfg maps groups to roles: users in
+// none of the groups mapped are refused with ErrForbidden.
+func (s *AuthService) LoginOIDC(ctx context.Context, cfg config.Auth, oc config.OIDC, flowToken string, cb *domain.OIDCCallback, client domain.Client) (*domain.LoginResult, error) {
+	const op = "service.LoginOIDC"
+
+	if s.idp == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrOIDCDisabled)
+	}
+
+	flow, err := s.keys.ParseOIDCFlowToken(flowToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
+	}
+
+	// The callback must come back to the browser the login started in.
+	if subtle.ConstantTimeCompare([]byte(flow.State), []byte(cb.State)) != 1 {
+		return nil, fmt.Errorf("%s: %w: state mismatch", op, ErrInvalidToken)
+	}
+
+	identity, err := s.idp.Identify(ctx, cb.Code, flow)
+	if err != nil {
+		if errors.Is(err, oidc.ErrRejected) {
+			return nil, fmt.Errorf("%s: %w: %w", op, ErrInvalidCredentials, err)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	role, ok := oidcRole(oc, identity.Groups)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w: no group of %s maps to a role", op, ErrForbidden, identity.Subject)
+	}
+
+	user, err := s.oidcUser(ctx, identity, role)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := s.loginResult(ctx, cfg, user, client)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// oidcUser returns the user linked to the identity. An identity signing in the
+// first time is linked to the user with its email, or a new user is created.
+// A role other than "" replaces the one of the user.
+func (s *AuthService) oidcUser(ctx context.Context, identity *domain.OIDCIdentity, role string) (*domain.User, error) {
+	user, err := s.provider.UserByIdentity(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		if role != "" && role != user.Role {
+			if err := s.setRole(ctx, user, role); err != nil {
+				return nil, err
+			}
+		}
+		return user, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	// The email decides who the identity is, it must be one the provider checked.
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	user, err = s.provider.User(ctx, identity.Email)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		user = &domain.User{
+			Username: oidcUsername(identity),
+			Email:    identity.Email,
+			Role:     domain.RoleHandler,
+		}
+		if role != "" {
+			user.Role = role
+		}
+
+		if _, err := s.processor.SaveOIDCUser(ctx, tx, user); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	} else if role != "" && role != user.Role {
+		if err := s.processor.SetRole(ctx, tx, user.ID, role); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		user.Role = role
+	}
+
+	if err := s.processor.LinkIdentity(ctx, tx, user.ID, identity.Issuer, identity.Subject); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *AuthService) setRole(ctx context.Context, user *domain.User, role string) error {
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.processor.SetRole(ctx, tx, user.ID, role); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	user.Role = role
+
+	return nil
+}
+
+// oidcRole maps the groups of the user to a role: admin for members of
+// cfg.AdminGroups, handler for members of cfg.HandlerGroups or for everyone
+// when it is empty. It returns false when the user gets no role. When no group
+// is mapped at all, the role is "", roles are then managed in spycat.
+func oidcRole(cfg config.OIDC, groups []string) (string, bool) {
+	if len(cfg.AdminGroups) == 0 && len(cfg.HandlerGroups) == 0 {
+		return "", true
+	}
+
+	member := func(of []string) bool {
+		return slices.ContainsFunc(groups, func(group string) bool { return slices.Contains(of, group) })
+	}
+
+	switch {
+	case member(cfg.AdminGroups):
+		return domain.RoleAdmin, true
+	case len(cfg.HandlerGroups) == 0 || member(cfg.HandlerGroups):
+		return domain.RoleHandler, true
+	}
+
+	return "", false
+}
+
+// oidcUsername is the username of a provisioned user, the local part of the
+// email when the provider tells no name.
+func oidcUsername(identity *domain.OIDCIdentity) string {
+	username := identity.Username
+	if username == "" {
+		username, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	if runes := []rune(username); len(runes) > maxUsernameLength {
+		username = string(runes[:maxUsernameLength])
+	}
+
+	return username
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/payroll"
+)
+
+type PayrollProvider interface {
+	Cats(ctx context.Context) ([]*domain.Cat, error)
+	SalaryHistory(ctx context.Context, to time.Time) ([]*domain.Salary, error)
+	Bonuses(ctx context.Context, from, to time.Time) ([]*domain.Bonus, error)
+}
+
+type PayrollService struct {
+	provider PayrollProvider
+}
+
+// Payroll computes the pay of every cat with an effective salary or a bonus in the period,
+// deleted cats included up to the day they were deleted.
+// The period is a calendar month in "YYYY-MM" format.
+func (s *PayrollService) Payroll(ctx context.Context, period string) (*domain.Payroll, error) {
+	const op = "service.Payroll"
+
+	from, to, err := payroll.Period(period)
+	if err != nil {
+		if errors.Is(err, payroll.ErrInvalidPeriod) {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidPeriod)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	cats, err := s.provider.Cats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	salaries, err := s.provider.SalaryHistory(ctx, to)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	bonuses, err := s.provider.Bonuses(ctx, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	names := make(map[int]string, len(cats))
+	for _, cat := range cats {
+		names[cat.ID] = cat.Name
+	}
+
+	// A deleted cat has no ID anymore, it is told apart by its name.
+	type payee struct {
+		catID int
+		name  string
+	}
+
+	payeeOf := func(catID int, name string) payee {
+		if catID != 0 {
+			return payee{catID: catID, name: names[catID]}
+		}
+		return payee{name: name}
+	}
+
+	history := make(map[payee][]domain.Salary)
+	deleted := make(map[payee]time.Time)
+	for _, sal := range salaries {
+		p := payeeOf(sal.CatID, sal.CatName)
+		history[p] = append(history[p], *sal)
+		if sal.CatDeletedAt != nil {
+			deleted[p] = *sal.CatDeletedAt
+		}
+	}
+
+	type key struct {
+		payee    payee
+		currency string
+	}
+
+	entries := make(map[key]*domain.PayrollEntry)
+	entry := func(p payee, currency string) *domain.PayrollEntry {
+		k := key{payee: p, currency: currency}
+		if e, ok := entries[k]; ok {
+			return e
+		}
+
+		e := &domain.PayrollEntry{CatID: p.catID, CatName: p.name, Currency: currency}
+		entries[k] = e
+
+		return e
+	}
+
+	for p, h := range history {
+		last := to
+		if at, ok := deleted[p]; ok {
+			last = at
+		}
+
+		for _, line := range payroll.ProrateUntil(h, from, to, last) {
+			e := entry(p, line.Currency)
+			e.Days += line.Days
+			e.Base += line.Amount
+		}
+	}
+
+	for _, b := range bonuses {
+		entry(payeeOf(b.CatID, b.CatName), b.Currency).Bonus += b.Amount
+	}
+
+	result := &domain.Payroll{
+		Period:  period,
+		From:    from,
+		To:      to,
+		Entries: make([]domain.PayrollEntry, 0, len(entries)),
+		Totals:  make([]domain.PayrollTotal, 0),
+	}
+
+	totals := make(map[string]int64)
+	for _, e := range entries {
+		e.Total = e.Base + e.Bonus
+		totals[e.Currency] += e.Total
+		result.Entries = append(result.Entries, *e)
+	}
+
+	sort.Slice(result.Entries, func(i, j int) bool {
+		if result.Entries[i].CatName != result.Entries[j].CatName {
+			return result.Entries[i].CatName < result.Entries[j].CatName
+		}
+		return result.Entries[i].Currency < result.Entries[j].Currency
+	})
+
+	for currency, total := range totals {
+		result.Totals = append(result.Totals, domain.PayrollTotal{Currency: currency, Total: total})
+	}
+
+	sort.Slice(result.Totals, func(i, j int) bool {
+		return result.Totals[i].Currency < result.Totals[j].Currency
+	})
+
+	return result, nil
+}
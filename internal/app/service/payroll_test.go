@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+type payrollStore struct {
+	cats     []*domain.Cat
+	salaries []*domain.Salary
+	bonuses  []*domain.Bonus
+}
+
+func (s *payrollStore) Cats(ctx context.Context) ([]*domain.Cat, error) {
+	return s.cats, nil
+}
+
+func (s *payrollStore) SalaryHistory(ctx context.Context, to time.Time) ([]*domain.Salary, error) {
+	return s.salaries, nil
+}
+
+func (s *payrollStore) Bonuses(ctx context.Context, from, to time.Time) ([]*domain.Bonus, error) {
+	return s.bonuses, nil
+}
+
+func TestPayrollKeepsDeletedCats(t *testing.T) {
+	january := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	deletedAt := time.Date(2026, time.October, 10, 0, 0, 0, 0, time.UTC)
+
+	s := &PayrollService{provider: &payrollStore{
+		cats: []*domain.Cat{{ID: 1, Name: "Tom"}},
+		salaries: []*domain.Salary{
+			{CatID: 1, Amount: 310000, Currency: "USD", EffectiveFrom: january},
+			{CatName: "Felix", Amount: 310000, Currency: "USD", EffectiveFrom: january, CatDeletedAt: &deletedAt},
+		},
+		bonuses: []*domain.Bonus{
+			{CatID: 1, Amount: 5000, Currency: "USD"},
+			{CatName: "Felix", Amount: 7000, Currency: "USD"},
+		},
+	}}
+
+	p, err := s.Payroll(context.Background(), "2026-10")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []domain.PayrollEntry{
+		{CatName: "Felix", Currency: "USD", Days: 10, Base: 100000, Bonus: 7000, Total: 107000},
+		{CatID: 1, CatName: "Tom", Currency: "USD", Days: 31, Base: 310000, Bonus: 5000, Total: 315000},
+	}, p.Entries)
+	assert.Equal(t, []domain.PayrollTotal{{Currency: "USD", Total: 422000}}, p.Totals)
+}
@@ -2,20 +2,80 @@ package service
 
 import (
 	"errors"
+	"sync"
+	"time"
+
+	"github.com/markraiter/spycat/internal/app/events"
+	"github.com/markraiter/spycat/internal/lib/breed"
+	"github.com/markraiter/spycat/internal/lib/jwt"
 )
 
 var (
-	ErrAlreadyExists      = errors.New("already exists")
-	ErrNotFound           = errors.New("not found")
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrCatBreedNotFound   = errors.New("cat breed not found")
-	ErrTooManyTargets     = errors.New("too many targets")
-	ErrMissionCompleted   = errors.New("this mission completed")
+	ErrAlreadyExists         = errors.New("already exists")
+	ErrNotFound              = errors.New("not found")
+	ErrInvalidCredentials    = errors.New("invalid credentials")
+	ErrCatBreedNotFound      = errors.New("cat breed not found")
+	ErrTooManyTargets        = errors.New("too many targets")
+	ErrMissionCompleted      = errors.New("this mission completed")
+	ErrTargetCompleted       = errors.New("this target completed")
+	ErrInvalidPeriod         = errors.New("invalid period, expected YYYY-MM")
+	ErrCurrencyMismatch      = errors.New("currency does not match mission currency")
+	ErrMissingSkills         = errors.New("cat lacks skills required by the mission")
+	ErrInvalidSchedule       = errors.New("due date must be after start date")
+	ErrHandlerNotFound       = errors.New("handler not found")
+	ErrForbidden             = errors.New("forbidden")
+	ErrEmailNotVerified      = errors.New("email is not verified")
+	ErrInvalidToken          = errors.New("invalid or expired token")
+	ErrVerificationNotSent   = errors.New("verification email was not sent")
+	ErrInvalidMFACode        = errors.New("invalid authentication code")
+	ErrMFAEnabled            = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled         = errors.New("two-factor authentication is not enabled")
+	ErrTooManyAttempts       = errors.New("too many failed login attempts, try again later")
+	ErrInvalidExpiry         = errors.New("expiry must be in the future")
+	ErrOIDCDisabled          = errors.New("login with the identity provider is not configured")
+	ErrAccountDisabled       = errors.New("account is disabled")
+	ErrPasswordResetRequired = errors.New("password reset required, follow the link emailed to you")
+	ErrLastAdmin             = errors.New("the last admin cannot be removed")
+	ErrOwnAccount            = errors.New("admins cannot disable their own account")
+	ErrWeakPassword          = errors.New("password does not meet the policy")
+	ErrReauthRequired        = errors.New("sign in again to confirm the change")
+	ErrInvalidWebhookURL     = errors.New("webhook URL must be a public http or https address")
+	ErrInvalidMerge          = errors.New("a target cannot be merged into itself")
+	ErrInvalidRelationship   = errors.New("a target cannot be related to itself")
+	ErrInvalidLocation       = errors.New("target location needs a latitude within -90 and 90 and a longitude within -180 and 180")
 )
 
+// TooManyAttemptsError holds back a login for RetryAfter, it matches ErrTooManyAttempts.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+func (e *TooManyAttemptsError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// WeakPasswordError refuses a new password, Reason tells which rules of the
+// policy it breaks. It matches ErrWeakPassword.
+type WeakPasswordError struct {
+	Reason string
+}
+
+func (e *WeakPasswordError) Error() string {
+	return e.Reason
+}
+
+func (e *WeakPasswordError) Unwrap() error {
+	return ErrWeakPassword
+}
+
 type AuthStorage interface {
 	UserSaver
 	UserProvider
+	UserProcessor
 }
 
 type CatStorage interface {
@@ -32,14 +92,58 @@ type MissionStorage interface {
 
 type TargetStorage interface {
 	TargetSaver
+	TargetProvider
 	TargetProcessor
 }
 
+type PayrollStorage interface {
+	PayrollProvider
+}
+
+type SkillStorage interface {
+	SkillSaver
+	SkillProvider
+	SkillProcessor
+}
+
+type AuditStorage interface {
+	AuditProvider
+}
+
+type ChatStorage interface {
+	ChatSaver
+	ChatProvider
+}
+
+type NotificationStorage interface {
+	NotificationSaver
+	NotificationProvider
+}
+
+type APIKeyStorage interface {
+	APIKeySaver
+	APIKeyProvider
+	APIKeyProcessor
+}
+
+type WebhookStorage interface {
+	WebhookSaver
+	WebhookProvider
+	WebhookProcessor
+}
+
 type Service struct {
 	AuthService
 	CatService
 	MissionService
 	TargetService
+	PayrollService
+	SkillService
+	AuditService
+	WebhookService
+	ChatService
+	NotificationService
+	APIKeyService
 }
 
 func New(
@@ -47,26 +151,86 @@ func New(
 	c CatStorage,
 	m MissionStorage,
 	t TargetStorage,
-
+	p PayrollStorage,
+	s SkillStorage,
+	au AuditStorage,
+	w WebhookStorage,
+	ch ChatStorage,
+	n NotificationStorage,
+	k APIKeyStorage,
+	bus events.Publisher,
+	mailer AccountMailer,
+	guard LoginGuard,
+	idp IdentityProvider,
+	keys *jwt.KeySet,
+	passwords PasswordPolicy,
+	hasher PasswordHasher,
+	urls URLGuard,
 ) *Service {
 	return &Service{
 		AuthService: AuthService{
-			saver:    a,
-			provider: a,
+			saver:     a,
+			provider:  a,
+			processor: a,
+			mailer:    mailer,
+			guard:     guard,
+			idp:       idp,
+			keys:      keys,
+			passwords: passwords,
+			hasher:    hasher,
+			dummyHash: sync.OnceValue(func() string {
+				hash, _ := hasher.Hash("not a password")
+				return hash
+			}),
 		},
 		CatService: CatService{
-			saver:     c,
-			provider:  c,
-			processor: c,
+			saver:      c,
+			provider:   c,
+			processor:  c,
+			publisher:  bus,
+			validBreed: breed.ValidateCatBreed,
 		},
 		MissionService: MissionService{
 			saver:     m,
 			provider:  m,
 			processor: m,
+			publisher: bus,
 		},
 		TargetService: TargetService{
 			saver:     t,
+			provider:  t,
 			processor: t,
+			publisher: bus,
+		},
+		PayrollService: PayrollService{
+			provider: p,
+		},
+		SkillService: SkillService{
+			saver:     s,
+			provider:  s,
+			processor: s,
+		},
+		AuditService: AuditService{
+			provider: au,
+		},
+		WebhookService: WebhookService{
+			saver:     w,
+			provider:  w,
+			processor: w,
+			guard:     urls,
+		},
+		ChatService: ChatService{
+			saver:    ch,
+			provider: ch,
+		},
+		NotificationService: NotificationService{
+			saver:    n,
+			provider: n,
+		},
+		APIKeyService: APIKeyService{
+			saver:     k,
+			provider:  k,
+			processor: k,
 		},
 	}
 }
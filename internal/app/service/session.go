@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// maxUserAgentLength caps the user agent kept with a session.
+const maxUserAgentLength = 512
+
+// Sessions returns the sessions of the user in use, currentID marks the one of the request.
+func (s *AuthService) Sessions(ctx context.Context, userID int, currentID int64) ([]*domain.Session, error) {
+	const op = "service.Sessions"
+
+	sessions, err := s.provider.Sessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == currentID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs the session of the user out, its access tokens stop working at once.
+func (s *AuthService) RevokeSession(ctx context.Context, userID int, id int64) error {
+	const op = "service.RevokeSession"
+
+	if err := s.processor.RevokeSession(ctx, userID, id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeSessions signs the user out everywhere, the session of the request
+// included, and returns how many sessions were revoked.
+func (s *AuthService) RevokeSessions(ctx context.Context, userID int) (int, error) {
+	const op = "service.RevokeSessions"
+
+	n, err := s.processor.RevokeSessions(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// newSession records a session of the user from the client, lasting as long
+// as the access token it returns.
+func (s *AuthService) newSession(ctx context.Context, cfg config.Auth, user *domain.User, client domain.Client) (string, error) {
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+
+	session := &domain.Session{
+		UserID:    user.ID,
+		UserAgent: userAgent,
+		IP:        client.IP,
+		ExpiresAt: time.Now().Add(cfg.AccessTTL),
+	}
+
+	if _, err := s.saver.SaveSession(ctx, session); err != nil {
+		return "", err
+	}
+
+	return s.keys.NewToken(user, session.ID, cfg.AccessTTL)
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+type SkillSaver interface {
+	SaveSkill(ctx context.Context, skill *domain.Skill) (int, error)
+}
+
+type SkillProvider interface {
+	Skills(ctx context.Context) ([]*domain.Skill, error)
+	CatSkills(ctx context.Context, catID int) ([]domain.CatSkill, error)
+	MissionSkills(ctx context.Context, missionID int) ([]domain.RequiredSkill, error)
+	TargetSkills(ctx context.Context, targetID int) ([]domain.RequiredSkill, error)
+}
+
+type SkillProcessor interface {
+	SetCatSkill(ctx context.Context, catID, skillID, level int) error
+	DeleteCatSkill(ctx context.Context, catID, skillID int) error
+	SetMissionSkill(ctx context.Context, missionID, skillID, minLevel int) error
+	DeleteMissionSkill(ctx context.Context, missionID, skillID int) error
+	SetTargetSkill(ctx context.Context, targetID, skillID, minLevel int) error
+	DeleteTargetSkill(ctx context.Context, targetID, skillID int) error
+}
+
+type SkillService struct {
+	saver     SkillSaver
+	provider  SkillProvider
+	processor SkillProcessor
+}
+
+func (s *SkillService) SaveSkill(ctx context.Context, sr *domain.SkillRequest) (int, error) {
+	const op = "service.SaveSkill"
+
+	skill := &domain.Skill{
+		Name:        sr.Name,
+		Description: sr.Description,
+	}
+
+	id, err := s.saver.SaveSkill(ctx, skill)
+	if err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			return 0, fmt.Errorf("%s: %w", op, ErrAlreadyExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *SkillService) Skills(ctx context.Context) ([]*domain.Skill, error) {
+	const op = "service.Skills"
+
+	skills, err := s.provider.Skills(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return skills, nil
+}
+
+func (s *SkillService) SetCatSkill(ctx context.Context, catID, skillID, level int) error {
+	const op = "service.SetCatSkill"
+
+	if err := s.processor.SetCatSkill(ctx, catID, skillID, level); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *SkillService) DeleteCatSkill(ctx context.Context, catID, skillID int) error {
+	const op = "service.DeleteCatSkill"
+
+	if err := s.processor.DeleteCatSkill(ctx, catID, skillID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *SkillService) SetMissionSkill(ctx context.Context, missionID, skillID, minLevel int) error {
+	const op = "service.SetMissionSkill"
+
+	if err := s.processor.SetMissionSkill(ctx, missionID, skillID, minLevel); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *SkillService) DeleteMissionSkill(ctx context.Context, missionID, skillID int) error {
+	const op = "service.DeleteMissionSkill"
+
+	if err := s.processor.DeleteMissionSkill(ctx, missionID, skillID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetTargetSkill requires the skill of the cat of every mission the target is part of.
+func (s *SkillService) SetTargetSkill(ctx context.Context, targetID, skillID, minLevel int) error {
+	const op = "service.SetTargetSkill"
+
+	if err := s.processor.SetTargetSkill(ctx, targetID, skillID, minLevel); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *SkillService) DeleteTargetSkill(ctx context.Context, targetID, skillID int) error {
+	const op = "service.DeleteTargetSkill"
+
+	if err := s.processor.DeleteTargetSkill(ctx, targetID, skillID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
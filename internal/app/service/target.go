@@ -6,34 +6,332 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/markraiter/spycat/internal/app/events"
 	"github.com/markraiter/spycat/internal/app/storage"
 	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/geo"
+	"github.com/markraiter/spycat/internal/lib/geojson"
 )
 
 type TargetSaver interface {
 	SaveTarget(ctx context.Context, tx *sql.Tx, target *domain.Target) error
+	SaveTargetAlias(ctx context.Context, tx *sql.Tx, targetID int, alias string) error
+	SaveTargetPhoto(ctx context.Context, photo *domain.TargetPhoto) error
+	SaveTargetNote(ctx context.Context, tx *sql.Tx, note *domain.TargetNote) error
+	ReplaceMergeCandidates(ctx context.Context, tx *sql.Tx, candidates []*domain.MergeCandidate) error
+	SaveRelationship(ctx context.Context, r *domain.Relationship) error
+	AuditSaver
+	EventSaver
+}
+
+type TargetProvider interface {
+	Targets(ctx context.Context) ([]*domain.Target, error)
+	MissionTargets(ctx context.Context) ([]*domain.Target, error)
+	TargetsNear(ctx context.Context, point domain.GeoPoint, radiusKm float64) ([]*domain.Target, error)
+	TargetByID(ctx context.Context, id int) (*domain.Target, error)
+	TargetMissions(ctx context.Context, targetID int) ([]*domain.TargetMission, error)
+	TargetAliases(ctx context.Context, targetID int) ([]string, error)
+	TargetPhotos(ctx context.Context, targetID int) ([]*domain.TargetPhoto, error)
+	TargetNotes(ctx context.Context, targetID int) ([]*domain.TargetNote, error)
+	AllTargetAliases(ctx context.Context) (map[int][]string, error)
+	MergeCandidates(ctx context.Context, filter *domain.MergeCandidateFilter) ([]*domain.MergeCandidate, error)
+	TargetGraph(ctx context.Context, targetID, depth int) (*domain.TargetGraph, error)
+	TargetSkills(ctx context.Context, targetID int) ([]domain.RequiredSkill, error)
 }
 
 type TargetProcessor interface {
-	TargetCompleted(ctx context.Context, id int) error
+	BeginTx(ctx context.Context) (*sql.Tx, error)
+	TargetCompleted(ctx context.Context, tx *sql.Tx, missionID, targetID int) (bool, error)
 	AddTargetToMission(ctx context.Context, missionID, targetID int) error
+	RemoveTargetFromMission(ctx context.Context, missionID, targetID int) error
+	DeleteTargetAlias(ctx context.Context, targetID int, alias string) error
+	TryAdvisoryLock(ctx context.Context, tx *sql.Tx, key int64) (bool, error)
+	DismissMergeCandidate(ctx context.Context, targetID, duplicateID int) error
+	MergeTarget(ctx context.Context, tx *sql.Tx, targetID, duplicateID int) error
+	DeleteRelationship(ctx context.Context, targetID, id int) error
 }
 
 type TargetService struct {
 	saver     TargetSaver
+	provider  TargetProvider
 	processor TargetProcessor
+	publisher events.Publisher
+}
+
+// SaveTarget registers a target outside of any mission, its notes start the
+// notes history of the target.
+func (s *TargetService) SaveTarget(ctx context.Context, tr *domain.TargetRequest) (int, error) {
+	const op = "service.SaveTarget"
+
+	target := &domain.Target{
+		Name:      tr.Name,
+		Country:   tr.Country,
+		City:      tr.City,
+		Latitude:  tr.Latitude,
+		Longitude: tr.Longitude,
+		Notes:     tr.Notes,
+	}
+
+	if !validLocation(target) {
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidLocation)
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.saver.SaveTarget(ctx, tx, target); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, alias := range tr.Aliases {
+		err := s.saver.SaveTargetAlias(ctx, tx, target.ID, alias)
+		if err != nil && !errors.Is(err, storage.ErrAlreadyExists) {
+			tx.Rollback()
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return target.ID, nil
 }
 
-func (s *TargetService) CompleteTarget(ctx context.Context, id int) error {
-	const op = "service.TargetCompleted"
+// Dossier returns the target with its aliases, photos, notes history, every
+// mission it is part of and the skills it calls for.
+func (s *TargetService) Dossier(ctx context.Context, id int) (*domain.Dossier, error) {
+	const op = "service.Dossier"
 
-	if err := s.processor.TargetCompleted(ctx, id); err != nil {
+	target, err := s.provider.TargetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	dossier := &domain.Dossier{Target: *target}
+
+	if dossier.Aliases, err = s.provider.TargetAliases(ctx, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if dossier.Photos, err = s.provider.TargetPhotos(ctx, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if dossier.History, err = s.provider.TargetNotes(ctx, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if dossier.Missions, err = s.provider.TargetMissions(ctx, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if dossier.RequiredSkills, err = s.provider.TargetSkills(ctx, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return dossier, nil
+}
+
+// AddAlias adds an alias to the target, adding one it has already changes nothing.
+func (s *TargetService) AddAlias(ctx context.Context, id int, alias string) error {
+	const op = "service.AddAlias"
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.saver.SaveTargetAlias(ctx, tx, id, alias); err != nil {
+		tx.Rollback()
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			return nil
+		}
 		if errors.Is(err, storage.ErrNotFound) {
 			return fmt.Errorf("%s: %w", op, ErrNotFound)
 		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *TargetService) DeleteAlias(ctx context.Context, id int, alias string) error {
+	const op = "service.DeleteAlias"
+
+	if err := s.processor.DeleteTargetAlias(ctx, id, alias); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AddPhoto adds a photo to the target on behalf of the user.
+func (s *TargetService) AddPhoto(ctx context.Context, userID, id int, pr *domain.TargetPhotoRequest) (*domain.TargetPhoto, error) {
+	const op = "service.AddPhoto"
+
+	photo := &domain.TargetPhoto{TargetID: id, UserID: userID, URL: pr.URL, Caption: pr.Caption}
+	if err := s.saver.SaveTargetPhoto(ctx, photo); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return photo, nil
+}
+
+// AddNote writes a note on the target on behalf of the user, it becomes the
+// latest note of the target and the earlier ones stay in its history.
+func (s *TargetService) AddNote(ctx context.Context, userID, id int, nr *domain.TargetNoteRequest) (*domain.TargetNote, error) {
+	const op = "service.AddNote"
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	note := &domain.TargetNote{TargetID: id, UserID: userID, Body: nr.Body}
+	if err := s.saver.SaveTargetNote(ctx, tx, note); err != nil {
+		tx.Rollback()
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return note, nil
+}
+
+// Targets returns every target, or with near set the located targets nearest
+// to it first, those within radiusKm of it when radiusKm is above zero.
+func (s *TargetService) Targets(ctx context.Context, near *domain.GeoPoint, radiusKm float64) ([]*domain.Target, error) {
+	const op = "service.Targets"
+
+	var (
+		targets []*domain.Target
+		err     error
+	)
+	if near != nil {
+		targets, err = s.provider.TargetsNear(ctx, *near, radiusKm)
+	} else {
+		targets, err = s.provider.Targets(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return targets, nil
+}
+
+// TargetsGeoJSON returns the layers of the targets of the missions as GeoJSON,
+// both when none is given, a target is laid out once for each of its missions.
+// With completed set only the targets of that state in the mission are laid out.
+func (s *TargetService) TargetsGeoJSON(ctx context.Context, completed *bool, layers ...string) (*domain.FeatureCollection, error) {
+	const op = "service.TargetsGeoJSON"
+
+	targets, err := s.provider.MissionTargets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if completed != nil {
+		matching := targets[:0]
+		for _, t := range targets {
+			if t.Completed == *completed {
+				matching = append(matching, t)
+			}
+		}
+		targets = matching
+	}
+
+	return geojson.Collection(targets, layers...), nil
+}
+
+// CompleteTarget completes the target in the mission, or in every mission it
+// is part of when missionID is zero. A target.completed event is recorded for
+// each mission the target was not completed in yet.
+func (s *TargetService) CompleteTarget(ctx context.Context, id, missionID int) error {
+	const op = "service.CompleteTarget"
+
+	target, err := s.provider.TargetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	missions, err := s.provider.TargetMissions(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	links := missions[:0]
+	for _, m := range missions {
+		if missionID == 0 || m.MissionID == missionID {
+			links = append(links, m)
+		}
+	}
+
+	if len(links) == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var completed []*domain.Event
+	for _, m := range links {
+		flipped, err := s.processor.TargetCompleted(ctx, tx, m.MissionID, id)
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, storage.ErrNotFound) {
+				return fmt.Errorf("%s: %w", op, ErrNotFound)
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if !flipped {
+			continue
+		}
+
+		event := &domain.Event{
+			Type:      domain.EventTargetCompleted,
+			MissionID: m.MissionID,
+			CatID:     m.CatID,
+			TargetID:  target.ID,
+			Payload:   map[string]any{"name": target.Name, "country": target.Country},
+		}
+
+		if err := s.saver.SaveEvent(ctx, tx, event); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		completed = append(completed, event)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.publisher.Publish(ctx, completed...)
+
 	return nil
 }
 
@@ -52,3 +350,43 @@ func (s *TargetService) AddTargetToMission(ctx context.Context, missionID, targe
 
 	return nil
 }
+
+// RemoveTargetFromMission unlinks the target from the mission, unless the mission
+// or the target in it is completed. The target stays in the registry.
+func (s *TargetService) RemoveTargetFromMission(ctx context.Context, missionID, targetID int) error {
+	const op = "service.RemoveTargetFromMission"
+
+	if err := s.processor.RemoveTargetFromMission(ctx, missionID, targetID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		if errors.Is(err, storage.ErrMissionCompleted) {
+			return fmt.Errorf("%s: %w", op, ErrMissionCompleted)
+		}
+		if errors.Is(err, storage.ErrTargetCompleted) {
+			return fmt.Errorf("%s: %w", op, ErrTargetCompleted)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// validLocation tells whether the target has both coordinates within range or none.
+func validLocation(t *domain.Target) bool {
+	if t.Latitude == nil || t.Longitude == nil {
+		return t.Latitude == nil && t.Longitude == nil
+	}
+
+	return geo.Valid(*t.Latitude, *t.Longitude)
+}
+
+// setDistances sets the distance of the located targets from the point.
+func setDistances(targets []domain.Target, from domain.GeoPoint) {
+	for i := range targets {
+		if t := &targets[i]; t.Located() {
+			distance := geo.Distance(from.Latitude, from.Longitude, *t.Latitude, *t.Longitude)
+			t.DistanceKm = &distance
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"testing"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// targetStore fakes the registry of targets and their links to missions.
+type targetStore struct {
+	TargetSaver
+	TargetProvider
+	TargetProcessor
+	db *txDB
+
+	targets map[int]*domain.Target
+	// missions tells whether a mission is completed, by ID.
+	missions map[int]bool
+	// links are the missions of every target, by target ID.
+	links map[int][]*domain.TargetMission
+	// flipped counts the calls of TargetCompleted that completed a target.
+	flipped int
+
+	events    []*domain.Event
+	published []*domain.Event
+}
+
+func newTargetService(t *testing.T) (*TargetService, *targetStore) {
+	store := &targetStore{
+		db:       newTxDB(t),
+		targets:  make(map[int]*domain.Target),
+		missions: make(map[int]bool),
+		links:    make(map[int][]*domain.TargetMission),
+	}
+
+	return &TargetService{
+		saver:     store,
+		provider:  store,
+		processor: store,
+		publisher: store,
+	}, store
+}
+
+func (s *targetStore) BeginTx(ctx context.Context) (*sql.Tx, error) {
+	return s.db.BeginTx(ctx)
+}
+
+func (s *targetStore) SaveEvent(ctx context.Context, tx *sql.Tx, event *domain.Event) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *targetStore) Publish(ctx context.Context, events ...*domain.Event) {
+	s.published = append(s.published, events...)
+}
+
+func (s *targetStore) TargetByID(ctx context.Context, id int) (*domain.Target, error) {
+	t, ok := s.targets[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	target := *t
+	return &target, nil
+}
+
+func (s *targetStore) TargetMissions(ctx context.Context, targetID int) ([]*domain.TargetMission, error) {
+	missions := make([]*domain.TargetMission, 0, len(s.links[targetID]))
+	for _, m := range s.links[targetID] {
+		mission := *m
+		missions = append(missions, &mission)
+	}
+
+	// Newest first.
+	sort.SliceStable(missions, func(i, j int) bool { return missions[i].MissionID > missions[j].MissionID })
+
+	return missions, nil
+}
+
+func (s *targetStore) TargetAliases(ctx context.Context, targetID int) ([]string, error) {
+	return nil, nil
+}
+
+func (s *targetStore) TargetPhotos(ctx context.Context, targetID int) ([]*domain.TargetPhoto, error) {
+	return nil, nil
+}
+
+func (s *targetStore) TargetNotes(ctx context.Context, targetID int) ([]*domain.TargetNote, error) {
+	return nil, nil
+}
+
+func (s *targetStore) TargetSkills(ctx context.Context, targetID int) ([]domain.RequiredSkill, error) {
+	return nil, nil
+}
+
+func (s *targetStore) link(missionID, targetID int) *domain.TargetMission {
+	for _, m := range s.links[targetID] {
+		if m.MissionID == missionID {
+			return m
+		}
+	}
+
+	return nil
+}
+
+func (s *targetStore) AddTargetToMission(ctx context.Context, missionID, targetID int) error {
+	completed, ok := s.missions[missionID]
+	if _, found := s.targets[targetID]; !ok || !found {
+		return storage.ErrNotFound
+	}
+
+	if completed {
+		return storage.ErrMissionCompleted
+	}
+
+	if s.link(missionID, targetID) == nil {
+		s.links[targetID] = append(s.links[targetID], &domain.TargetMission{MissionID: missionID})
+	}
+
+	return nil
+}
+
+func (s *targetStore) RemoveTargetFromMission(ctx context.Context, missionID, targetID int) error {
+	completed, ok := s.missions[missionID]
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	if completed {
+		return storage.ErrMissionCompleted
+	}
+
+	m := s.link(missionID, targetID)
+	if m == nil {
+		return storage.ErrNotFound
+	}
+
+	if m.Completed {
+		return storage.ErrTargetCompleted
+	}
+
+	links := s.links[targetID][:0]
+	for _, l := range s.links[targetID] {
+		if l != m {
+			links = append(links, l)
+		}
+	}
+	s.links[targetID] = links
+
+	return nil
+}
+
+func (s *targetStore) TargetCompleted(ctx context.Context, tx *sql.Tx, missionID, targetID int) (bool, error) {
+	m := s.link(missionID, targetID)
+	if m == nil {
+		return false, storage.ErrNotFound
+	}
+
+	if m.Completed {
+		return false, nil
+	}
+
+	m.Completed = true
+	s.flipped++
+
+	return true, nil
+}
+
+func TestLinkTargetToMissions(t *testing.T) {
+	ctx := context.Background()
+	s, store := newTargetService(t)
+
+	store.targets[1] = &domain.Target{ID: 1, Name: "John Doe", Country: "USA"}
+	store.missions[10] = false
+	store.missions[11] = false
+	store.missions[12] = true
+
+	assert.NoError(t, s.AddTargetToMission(ctx, 10, 1))
+	assert.NoError(t, s.AddTargetToMission(ctx, 11, 1))
+	assert.NoError(t, s.AddTargetToMission(ctx, 11, 1), "linking twice failed")
+	assert.ErrorIs(t, s.AddTargetToMission(ctx, 12, 1), ErrMissionCompleted)
+	assert.ErrorIs(t, s.AddTargetToMission(ctx, 13, 1), ErrNotFound)
+	assert.ErrorIs(t, s.AddTargetToMission(ctx, 10, 2), ErrNotFound)
+
+	dossier, err := s.Dossier(ctx, 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "John Doe", dossier.Name)
+		assert.Equal(t, []*domain.TargetMission{{MissionID: 11}, {MissionID: 10}}, dossier.Missions)
+	}
+
+	assert.NoError(t, s.CompleteTarget(ctx, 1, 10))
+	assert.ErrorIs(t, s.RemoveTargetFromMission(ctx, 10, 1), ErrTargetCompleted)
+	assert.NoError(t, s.RemoveTargetFromMission(ctx, 11, 1))
+	assert.ErrorIs(t, s.RemoveTargetFromMission(ctx, 11, 1), ErrNotFound)
+
+	store.missions[10] = true
+	assert.ErrorIs(t, s.RemoveTargetFromMission(ctx, 10, 1), ErrMissionCompleted)
+
+	// The missions the target was part of before stay in the dossier.
+	dossier, err = s.Dossier(ctx, 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []*domain.TargetMission{{MissionID: 10, Completed: true}}, dossier.Missions)
+	}
+}
+
+func TestCompleteTargetPerMission(t *testing.T) {
+	ctx := context.Background()
+	s, store := newTargetService(t)
+
+	store.targets[1] = &domain.Target{ID: 1, Name: "John Doe", Country: "USA"}
+	store.links[1] = []*domain.TargetMission{{MissionID: 10, CatID: 3}, {MissionID: 11, CatID: 4}}
+
+	assert.NoError(t, s.CompleteTarget(ctx, 1, 10))
+	assert.True(t, store.link(10, 1).Completed)
+	assert.False(t, store.link(11, 1).Completed, "target completed in another mission")
+
+	if assert.Len(t, store.events, 1) {
+		assert.Equal(t, &domain.Event{
+			Type:      domain.EventTargetCompleted,
+			MissionID: 10,
+			CatID:     3,
+			TargetID:  1,
+			Payload:   map[string]any{"name": "John Doe", "country": "USA"},
+		}, store.events[0])
+	}
+
+	// Completing it again records nothing, completing it everywhere records
+	// the mission it was not completed in only.
+	assert.NoError(t, s.CompleteTarget(ctx, 1, 10))
+	assert.NoError(t, s.CompleteTarget(ctx, 1, 0))
+
+	if assert.Len(t, store.events, 2) {
+		assert.Equal(t, 11, store.events[1].MissionID)
+		assert.Equal(t, 4, store.events[1].CatID)
+	}
+	assert.Equal(t, store.events, store.published)
+	assert.Equal(t, 2, store.flipped)
+
+	assert.ErrorIs(t, s.CompleteTarget(ctx, 1, 12), ErrNotFound, "target completed in a mission it is not part of")
+	assert.ErrorIs(t, s.CompleteTarget(ctx, 2, 0), ErrNotFound)
+}
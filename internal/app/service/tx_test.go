@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// txDriver hands out transactions that do nothing, for fakes of the storage
+// that take a *sql.Tx and ignore it. It counts how the transactions ended.
+type txDriver struct {
+	mu        sync.Mutex
+	commits   int
+	rollbacks int
+}
+
+func (d *txDriver) Open(string) (driver.Conn, error) { return &txConn{d: d}, nil }
+
+type txConn struct{ d *txDriver }
+
+func (c *txConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("txDriver: no queries") }
+func (c *txConn) Close() error                        { return nil }
+func (c *txConn) Begin() (driver.Tx, error)           { return c, nil }
+
+func (c *txConn) Commit() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.commits++
+	return nil
+}
+
+func (c *txConn) Rollback() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.rollbacks++
+	return nil
+}
+
+type txConnector struct{ d *txDriver }
+
+func (c txConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c txConnector) Driver() driver.Driver                        { return c.d }
+
+// txDB begins transactions on a txDriver.
+type txDB struct {
+	db     *sql.DB
+	driver *txDriver
+}
+
+func newTxDB(t *testing.T) *txDB {
+	d := &txDriver{}
+	db := sql.OpenDB(txConnector{d: d})
+	t.Cleanup(func() { db.Close() })
+
+	return &txDB{db: db, driver: d}
+}
+
+func (db *txDB) BeginTx(ctx context.Context) (*sql.Tx, error) {
+	return db.db.BeginTx(ctx, nil)
+}
+
+func (db *txDB) ended() (commits, rollbacks int) {
+	db.driver.mu.Lock()
+	defer db.driver.mu.Unlock()
+
+	return db.driver.commits, db.driver.rollbacks
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// Profile returns the account of the user.
+func (s *AuthService) Profile(ctx context.Context, userID int) (*domain.Profile, error) {
+	const op = "service.Profile"
+
+	profile, err := s.provider.Profile(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return profile, nil
+}
+
+// UpdateProfile changes the username and the email of the user. A new email
+// is confirmed as told by confirmUser and verified again, a verification link
+// is emailed to it. The profile is changed even when the email cannot be sent,
+// the error then wraps ErrVerificationNotSent.
+func (s *AuthService) UpdateProfile(ctx context.Context, cfg config.Auth, userID int, sessionID int64, pu *domain.ProfileUpdate) (*domain.Profile, error) {
+	const op = "service.UpdateProfile"
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if pu.Username != nil {
+		user.Username = *pu.Username
+	}
+
+	emailChanged := pu.Email != nil && *pu.Email != user.Email
+	if emailChanged {
+		if err := s.confirmUser(ctx, cfg, user, sessionID, pu.Password); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		user.Email = *pu.Email
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.processor.UpdateProfile(ctx, tx, user); err != nil {
+		tx.Rollback()
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			return nil, fmt.Errorf("%s: %w", op, ErrAlreadyExists)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	profile, err := s.Profile(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if emailChanged {
+		if err := s.sendVerification(ctx, cfg, user); err != nil {
+			return profile, fmt.Errorf("%s: %w: %w", op, ErrVerificationNotSent, err)
+		}
+	}
+
+	return profile, nil
+}
+
+// ChangePassword sets a new password after checking the current one and signs
+// the user out of every session but the one of the request.
+func (s *AuthService) ChangePassword(ctx context.Context, userID int, sessionID int64, pr *domain.PasswordChangeRequest) error {
+	const op = "service.ChangePassword"
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.verifyPassword(user, pr.CurrentPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.checkPassword(pr.NewPassword, user.Username, user.Email); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := s.hasher.Hash(pr.NewPassword)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.processor.ChangePassword(ctx, tx, userID, passHash, sessionID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteAccount deletes the user once confirmed as told by confirmUser. Chat
+// messages and audit entries are kept without their author and the cats the
+// user handled are left without a handler, everything else of the user is
+// deleted. The last admin cannot delete the account, ErrLastAdmin is returned.
+func (s *AuthService) DeleteAccount(ctx context.Context, cfg config.Auth, userID int, sessionID int64, password string) error {
+	const op = "service.DeleteAccount"
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.confirmUser(ctx, cfg, user, sessionID, password); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.Role == domain.RoleAdmin {
+		admins, err := s.processor.ActiveAdmins(ctx, tx)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if admins <= 1 {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", op, ErrLastAdmin)
+		}
+	}
+
+	if err := s.processor.DeleteUser(ctx, tx, userID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Users returns a page of the users matching the filter, only admins may list them.
+func (s *AuthService) Users(ctx context.Context, userID int, filter *domain.UserFilter) ([]*domain.Profile, error) {
+	const op = "service.Users"
+
+	if err := s.requireAdmin(ctx, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	profiles, err := s.provider.Users(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return profiles, nil
+}
+
+// DisableUser keeps the user from signing in and signs the user out everywhere,
+// API keys of the user stop working too. Only admins may, not on themselves.
+func (s *AuthService) DisableUser(ctx context.Context, userID, id int) error {
+	const op = "service.DisableUser"
+
+	if id == userID {
+		return fmt.Errorf("%s: %w", op, ErrOwnAccount)
+	}
+
+	if err := s.setDisabled(ctx, userID, id, true); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// EnableUser lets a disabled user sign in again, only admins may.
+func (s *AuthService) EnableUser(ctx context.Context, userID, id int) error {
+	const op = "service.EnableUser"
+
+	if err := s.setDisabled(ctx, userID, id, false); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *AuthService) setDisabled(ctx context.Context, userID, id int, disabled bool) error {
+	if err := s.requireAdmin(ctx, userID); err != nil {
+		return err
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.processor.SetDisabled(ctx, tx, id, disabled); err != nil {
+		tx.Rollback()
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ForcePasswordReset refuses the password of the user until it is reset, signs
+// the user out everywhere and emails a reset link. Only admins may.
+func (s *AuthService) ForcePasswordReset(ctx context.Context, cfg config.Auth, userID, id int) error {
+	const op = "service.ForcePasswordReset"
+
+	if err := s.requireAdmin(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.user(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.processor.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.processor.RequirePasswordReset(ctx, tx, id); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.sendPasswordReset(ctx, cfg, user); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// requireAdmin returns ErrForbidden unless the user is an admin.
+func (s *AuthService) requireAdmin(ctx context.Context, userID int) error {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Role != domain.RoleAdmin {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// confirmUser checks that the user asks for an account change. Users with a
+// password give it. Users signing in with the identity provider only have none,
+// the session of the request must have started within cfg.ReauthWindow instead,
+// or ErrReauthRequired is returned and they sign in again.
+func (s *AuthService) confirmUser(ctx context.Context, cfg config.Auth, user *domain.User, sessionID int64, password string) error {
+	if user.Password != "" {
+		return s.verifyPassword(user, password)
+	}
+
+	if sessionID == 0 {
+		return ErrReauthRequired
+	}
+
+	sessions, err := s.provider.Sessions(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == sessionID && time.Since(session.CreatedAt) < cfg.ReauthWindow {
+			return nil
+		}
+	}
+
+	return ErrReauthRequired
+}
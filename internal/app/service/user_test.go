@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+type sessionProvider struct {
+	UserProvider
+	sessions []*domain.Session
+}
+
+func (p *sessionProvider) Sessions(ctx context.Context, userID int) ([]*domain.Session, error) {
+	return p.sessions, nil
+}
+
+type plainHasher struct {
+	PasswordHasher
+}
+
+func (plainHasher) Verify(hash, password string) (bool, error) {
+	return hash != "" && hash == password, nil
+}
+
+func TestConfirmUser(t *testing.T) {
+	cfg := config.Auth{ReauthWindow: 5 * time.Minute}
+	now := time.Now()
+
+	s := &AuthService{
+		provider: &sessionProvider{sessions: []*domain.Session{
+			{ID: 1, UserID: 7, CreatedAt: now.Add(-time.Hour)},
+			{ID: 2, UserID: 7, CreatedAt: now.Add(-time.Minute)},
+		}},
+		hasher: plainHasher{},
+	}
+
+	withPassword := &domain.User{ID: 7, Password: "secret"}
+	withoutPassword := &domain.User{ID: 7}
+
+	tests := []struct {
+		name      string
+		user      *domain.User
+		sessionID int64
+		password  string
+		want      error
+	}{
+		{name: "right password", user: withPassword, sessionID: 1, password: "secret"},
+		{name: "wrong password", user: withPassword, sessionID: 2, password: "guess", want: ErrInvalidCredentials},
+		{name: "no password, recent login", user: withoutPassword, sessionID: 2},
+		{name: "no password, old login", user: withoutPassword, sessionID: 1, want: ErrReauthRequired},
+		{name: "no password, any password given", user: withoutPassword, sessionID: 1, password: "secret", want: ErrReauthRequired},
+		{name: "no password, API key", user: withoutPassword, want: ErrReauthRequired},
+		{name: "no password, unknown session", user: withoutPassword, sessionID: 3, want: ErrReauthRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.confirmUser(context.Background(), cfg, tt.user, tt.sessionID, tt.password)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
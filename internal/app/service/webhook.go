@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// EventSaver records domain events. Saving an event also queues it
+// for delivery to the webhooks subscribed to its type.
+type EventSaver interface {
+	SaveEvent(ctx context.Context, tx *sql.Tx, event *domain.Event) error
+}
+
+type WebhookSaver interface {
+	SaveWebhook(ctx context.Context, webhook *domain.Webhook) (int, error)
+}
+
+type WebhookProvider interface {
+	UserByID(ctx context.Context, id int) (*domain.User, error)
+	Webhooks(ctx context.Context) ([]*domain.Webhook, error)
+	Webhook(ctx context.Context, id int) (*domain.Webhook, error)
+	WebhookDeliveries(ctx context.Context, webhookID int) ([]*domain.WebhookDelivery, error)
+}
+
+type WebhookProcessor interface {
+	UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error
+	DeleteWebhook(ctx context.Context, id int) error
+	Redeliver(ctx context.Context, deliveryID int64) (int64, error)
+}
+
+// URLGuard checks that a URL given by a user does not reach the network of the server.
+type URLGuard interface {
+	CheckURL(ctx context.Context, rawURL string) error
+}
+
+// WebhookService manages the webhooks. Webhooks receive every event they are
+// subscribed to, whoever it concerns, so only admins may manage them.
+type WebhookService struct {
+	saver     WebhookSaver
+	provider  WebhookProvider
+	processor WebhookProcessor
+	guard     URLGuard
+}
+
+func (s *WebhookService) SaveWebhook(ctx context.Context, userID int, wr *domain.WebhookRequest) (int, error) {
+	const op = "service.SaveWebhook"
+
+	if err := s.requireAdmin(ctx, userID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.guard.CheckURL(ctx, wr.URL); err != nil {
+		return 0, fmt.Errorf("%s: %w: %w", op, ErrInvalidWebhookURL, err)
+	}
+
+	id, err := s.saver.SaveWebhook(ctx, webhookOf(wr))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *WebhookService) Webhooks(ctx context.Context, userID int) ([]*domain.Webhook, error) {
+	const op = "service.Webhooks"
+
+	if err := s.requireAdmin(ctx, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	webhooks, err := s.provider.Webhooks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhooks, nil
+}
+
+func (s *WebhookService) Webhook(ctx context.Context, userID, id int) (*domain.Webhook, error) {
+	const op = "service.Webhook"
+
+	if err := s.requireAdmin(ctx, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	webhook, err := s.provider.Webhook(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhook, nil
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, userID, id int, wr *domain.WebhookRequest) error {
+	const op = "service.UpdateWebhook"
+
+	if err := s.requireAdmin(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.guard.CheckURL(ctx, wr.URL); err != nil {
+		return fmt.Errorf("%s: %w: %w", op, ErrInvalidWebhookURL, err)
+	}
+
+	webhook := webhookOf(wr)
+	webhook.ID = id
+
+	if err := s.processor.UpdateWebhook(ctx, webhook); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, userID, id int) error {
+	const op = "service.DeleteWebhook"
+
+	if err := s.requireAdmin(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.processor.DeleteWebhook(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *WebhookService) WebhookDeliveries(ctx context.Context, userID, webhookID int) ([]*domain.WebhookDelivery, error) {
+	const op = "service.WebhookDeliveries"
+
+	if err := s.requireAdmin(ctx, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.provider.Webhook(ctx, webhookID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := s.provider.WebhookDeliveries(ctx, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver queues the event of the delivery to be sent again and returns the ID of the new delivery.
+func (s *WebhookService) Redeliver(ctx context.Context, userID int, deliveryID int64) (int64, error) {
+	const op = "service.Redeliver"
+
+	if err := s.requireAdmin(ctx, userID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := s.processor.Redeliver(ctx, deliveryID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return 0, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// requireAdmin returns ErrForbidden unless the user is an admin.
+func (s *WebhookService) requireAdmin(ctx context.Context, userID int) error {
+	user, err := s.provider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrForbidden
+		}
+		return err
+	}
+
+	if user.Role != domain.RoleAdmin {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+func webhookOf(wr *domain.WebhookRequest) *domain.Webhook {
+	webhook := &domain.Webhook{
+		URL:    wr.URL,
+		Secret: wr.Secret,
+		Active: wr.Active == nil || *wr.Active,
+	}
+
+	for _, t := range wr.EventTypes {
+		webhook.EventTypes = append(webhook.EventTypes, domain.EventType(t))
+	}
+
+	return webhook
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+const apiKeyColumns = "k.id, k.user_id, k.name, k.prefix, k.scopes, k.expires_at, k.last_used_at, k.created_at, k.revoked_at"
+
+func (s *Storage) SaveAPIKey(ctx context.Context, key *domain.APIKey) (int64, error) {
+	const op = "storage.SaveAPIKey"
+
+	query := `INSERT INTO api_keys (user_id, name, prefix, secret_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+
+	err := s.PostgresDB.QueryRowContext(ctx, query, key.UserID, key.Name, key.Prefix, key.SecretHash, pq.Array(key.Scopes), key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAlreadyExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key.ID, nil
+}
+
+// APIKeys returns the API keys of the user, the newest first.
+func (s *Storage) APIKeys(ctx context.Context, userID int) ([]*domain.APIKey, error) {
+	const op = "storage.APIKeys"
+
+	query := "SELECT " + apiKeyColumns + " FROM api_keys k WHERE k.user_id = $1 ORDER BY k.id DESC"
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	keys := make([]*domain.APIKey, 0)
+	for rows.Next() {
+		key := &domain.APIKey{}
+		if err := scanAPIKey(rows, key); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// APIKeyByPrefix returns the API key with the prefix, with its secret hash and the username and email of its user.
+func (s *Storage) APIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	const op = "storage.APIKeyByPrefix"
+
+	query := "SELECT " + apiKeyColumns + `, k.secret_hash, u.username, u.email
+		FROM api_keys k JOIN users u ON u.id = k.user_id
+		WHERE k.prefix = $1`
+
+	key := &domain.APIKey{}
+	err := scanAPIKey(s.PostgresDB.QueryRowContext(ctx, query, prefix), key, &key.SecretHash, &key.Username, &key.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// TouchAPIKey records the key was used, at most once a minute to spare the writes.
+func (s *Storage) TouchAPIKey(ctx context.Context, id int64) error {
+	const op = "storage.TouchAPIKey"
+
+	query := `UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+
+	if _, err := s.PostgresDB.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeAPIKey revokes the API key of the user, storage.ErrNotFound if there is no such key in use.
+func (s *Storage) RevokeAPIKey(ctx context.Context, userID int, id int64) error {
+	const op = "storage.RevokeAPIKey"
+
+	query := "UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL"
+
+	result, err := s.PostgresDB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func scanAPIKey(row scanner, key *domain.APIKey, extra ...any) error {
+	dest := []any{
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		pq.Array(&key.Scopes),
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.CreatedAt,
+		&key.RevokedAt,
+	}
+
+	return row.Scan(append(dest, extra...)...)
+}
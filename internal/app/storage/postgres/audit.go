@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+func (s *Storage) SaveAuditEntry(ctx context.Context, tx *sql.Tx, entry *domain.AuditEntry) error {
+	const op = "storage.SaveAuditEntry"
+
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `INSERT INTO audit_log (user_id, action, entity, entity_id, details)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5) RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, entry.UserID, entry.Action, entry.Entity, entry.EntityID, details).
+		Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) AuditEntries(ctx context.Context, entity string, entityID int) ([]*domain.AuditEntry, error) {
+	const op = "storage.AuditEntries"
+
+	query := `SELECT id, COALESCE(user_id, 0), action, entity, entity_id, COALESCE(details, '{}'), created_at
+		FROM audit_log WHERE entity = $1 AND entity_id = $2 ORDER BY created_at DESC, id DESC`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, entity, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	entries := make([]*domain.AuditEntry, 0)
+	for rows.Next() {
+		e := &domain.AuditEntry{}
+
+		var details []byte
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Action, &e.Entity, &e.EntityID, &details, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := json.Unmarshal(details, &e.Details); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
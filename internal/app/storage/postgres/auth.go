@@ -32,7 +32,7 @@ func (s *Storage) SaveUser(ctx context.Context, user *domain.User) (int, error)
 func (s *Storage) User(ctx context.Context, email string) (*domain.User, error) {
 	const op = "storage.UserByEmail"
 
-	query, err := s.PostgresDB.Prepare("SELECT id, username, password, email FROM users WHERE email = $1")
+	query, err := s.PostgresDB.Prepare("SELECT id, username, COALESCE(password, ''), email, role, email_verified_at, session_version, COALESCE(totp_secret, ''), totp_enabled_at, disabled_at, password_reset_required FROM users WHERE email = $1")
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -40,7 +40,8 @@ func (s *Storage) User(ctx context.Context, email string) (*domain.User, error)
 	row := query.QueryRowContext(ctx, email)
 
 	user := &domain.User{}
-	err = row.Scan(&user.ID, &user.Username, &user.Password, &user.Email)
+	err = row.Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Role, &user.EmailVerifiedAt, &user.SessionVersion,
+		&user.TOTPSecret, &user.TOTPEnabledAt, &user.DisabledAt, &user.PasswordResetRequired)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -52,3 +53,143 @@ func (s *Storage) User(ctx context.Context, email string) (*domain.User, error)
 
 	return user, nil
 }
+
+func (s *Storage) UserByID(ctx context.Context, id int) (*domain.User, error) {
+	const op = "storage.UserByID"
+
+	query := `SELECT id, username, COALESCE(password, ''), email, role, email_verified_at, session_version, COALESCE(totp_secret, ''), totp_enabled_at,
+			disabled_at, password_reset_required
+		FROM users WHERE id = $1`
+
+	user := &domain.User{}
+	err := s.PostgresDB.QueryRowContext(ctx, query, id).
+		Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Role, &user.EmailVerifiedAt, &user.SessionVersion, &user.TOTPSecret, &user.TOTPEnabledAt,
+			&user.DisabledAt, &user.PasswordResetRequired)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// VerifyEmail marks the email of the user as verified, unless it already is.
+func (s *Storage) VerifyEmail(ctx context.Context, userID int) error {
+	const op = "storage.VerifyEmail"
+
+	query := "UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1"
+
+	result, err := s.PostgresDB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) SessionState(ctx context.Context, userID int, sessionID int64) (*domain.SessionState, error) {
+	const op = "storage.SessionState"
+
+	query := `SELECT u.session_version, u.totp_enabled_at IS NULL AND COALESCE(p.required, false), u.disabled_at IS NOT NULL,
+			EXISTS (SELECT 1 FROM sessions s
+				WHERE s.id = $2 AND s.user_id = u.id AND s.revoked_at IS NULL AND s.expires_at > NOW())
+		FROM users u LEFT JOIN mfa_policies p ON p.role = u.role
+		WHERE u.id = $1`
+
+	state := &domain.SessionState{}
+	err := s.PostgresDB.QueryRowContext(ctx, query, userID, sessionID).Scan(&state.Version, &state.MFAEnrollmentRequired, &state.Disabled, &state.SessionActive)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return state, nil
+}
+
+func (s *Storage) SaveResetToken(ctx context.Context, token *domain.ResetToken) error {
+	const op = "storage.SaveResetToken"
+
+	query := "INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING id"
+
+	if err := s.PostgresDB.QueryRowContext(ctx, query, token.UserID, token.Hash, token.ExpiresAt).Scan(&token.ID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UpdatePasswordHash replaces the old hash of the password of the user, unless
+// the password changed meanwhile.
+func (s *Storage) UpdatePasswordHash(ctx context.Context, userID int, old, hash string) error {
+	const op = "storage.UpdatePasswordHash"
+
+	query := "UPDATE users SET password = $1 WHERE id = $2 AND password = $3"
+
+	if _, err := s.PostgresDB.ExecContext(ctx, query, hash, userID, old); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ConsumeResetToken marks the unused, unexpired reset token with the hash as used
+// and returns the ID of its user.
+func (s *Storage) ConsumeResetToken(ctx context.Context, tx *sql.Tx, hash []byte) (int, error) {
+	const op = "storage.ConsumeResetToken"
+
+	query := `UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`
+
+	var userID int
+	if err := tx.QueryRowContext(ctx, query, hash).Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
+
+// ResetPassword sets the password of the user and revokes every session and
+// every other reset token of the user. Resetting through an emailed token also
+// proves the email address, so it is marked verified.
+func (s *Storage) ResetPassword(ctx context.Context, tx *sql.Tx, userID int, password string) error {
+	const op = "storage.ResetPassword"
+
+	query := `UPDATE users SET password = $1, session_version = session_version + 1, password_reset_required = false,
+		email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $2`
+
+	if _, err := tx.ExecContext(ctx, query, password, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = "UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL"
+
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL"
+
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// CatStats returns the mission history of the cats that had a mission.
+func (s *Storage) CatStats(ctx context.Context) ([]domain.CatStats, error) {
+	const op = "storage.CatStats"
+
+	query := `SELECT cat_id,
+			COUNT(*) FILTER (WHERE NOT completed AND NOT overdue),
+			COUNT(*) FILTER (WHERE completed),
+			COUNT(*) FILTER (WHERE completed OR overdue)
+		FROM missions WHERE cat_id IS NOT NULL GROUP BY cat_id`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	stats := make([]domain.CatStats, 0)
+	for rows.Next() {
+		var st domain.CatStats
+		if err := rows.Scan(&st.CatID, &st.ActiveMissions, &st.CompletedMissions, &st.FinishedMissions); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		stats = append(stats, st)
+	}
+
+	return stats, nil
+}
+
+// AllCatSkills returns skills of all cats keyed by cat ID.
+func (s *Storage) AllCatSkills(ctx context.Context) (map[int][]domain.CatSkill, error) {
+	const op = "storage.AllCatSkills"
+
+	query := `SELECT cs.cat_id, s.id, s.name, cs.level FROM cat_skills cs
+		JOIN skills s ON s.id = cs.skill_id ORDER BY cs.cat_id, s.name`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	skills := make(map[int][]domain.CatSkill)
+	for rows.Next() {
+		var catID int
+		var skill domain.CatSkill
+		if err := rows.Scan(&catID, &skill.SkillID, &skill.Name, &skill.Level); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		skills[catID] = append(skills[catID], skill)
+	}
+
+	return skills, nil
+}
@@ -9,13 +9,15 @@ import (
 	"github.com/lib/pq"
 	"github.com/markraiter/spycat/internal/app/storage"
 	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/money"
 )
 
-func (s *Storage) SaveCat(ctx context.Context, cat *domain.Cat) (int, error) {
+func (s *Storage) SaveCat(ctx context.Context, tx *sql.Tx, cat *domain.Cat) (int, error) {
 	const op = "storage.SaveCat"
 
-	query := "INSERT INTO cats (name, breed, years_of_experience, salary) VALUES ($1, $2, $3, $4) RETURNING id"
-	err := s.PostgresDB.QueryRow(query, cat.Name, cat.Breed, cat.YearsOfExperience, cat.Salary).Scan(&cat.ID)
+	query := `INSERT INTO cats (name, breed, years_of_experience, salary, currency, handler_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0)) RETURNING id`
+	err := tx.QueryRowContext(ctx, query, cat.Name, cat.Breed, cat.YearsOfExperience, cat.Salary, cat.Currency, cat.HandlerID).Scan(&cat.ID)
 	if err != nil {
 		var pgErr *pq.Error
 
@@ -29,19 +31,61 @@ func (s *Storage) SaveCat(ctx context.Context, cat *domain.Cat) (int, error) {
 	return cat.ID, nil
 }
 
+// catColumns read the salary of the cat from its salary history as of today,
+// the salary column only holds the one it was created or last updated with.
+const catColumns = `c.id, c.name, c.breed, c.years_of_experience, c.salary, c.currency, COALESCE(c.handler_id, 0),
+	h.amount, h.currency
+	FROM cats c LEFT JOIN LATERAL (
+		SELECT amount, currency FROM salary_history
+		WHERE cat_id = c.id AND effective_from <= (NOW() AT TIME ZONE 'UTC')::DATE
+		ORDER BY effective_from DESC LIMIT 1
+	) h ON true`
+
+func scanCat(row interface{ Scan(...any) error }) (*domain.Cat, error) {
+	cat := &domain.Cat{}
+
+	var amount sql.NullInt64
+	var currency sql.NullString
+	err := row.Scan(&cat.ID, &cat.Name, &cat.Breed, &cat.YearsOfExperience, &cat.Salary, &cat.Currency, &cat.HandlerID,
+		&amount, &currency)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount.Valid {
+		cat.SalaryAmount = amount.Int64
+		cat.Currency = currency.String
+		cat.Salary = int(money.ToMajor(amount.Int64, currency.String))
+	} else {
+		cat.SalaryAmount = money.ToMinor(int64(cat.Salary), cat.Currency)
+	}
+
+	return cat, nil
+}
+
 func (s *Storage) Cat(ctx context.Context, id int) (*domain.Cat, error) {
 	const op = "storage.Cat"
 
-	query, err := s.PostgresDB.Prepare("SELECT id, name, breed, years_of_experience, salary FROM cats WHERE id = $1")
+	query := "SELECT " + catColumns + " WHERE c.id = $1"
+
+	cat, err := scanCat(s.PostgresDB.QueryRowContext(ctx, query, id))
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	row := query.QueryRowContext(ctx, id)
+	return cat, nil
+}
 
-	cat := &domain.Cat{}
-	err = row.Scan(&cat.ID, &cat.Name, &cat.Breed, &cat.YearsOfExperience, &cat.Salary)
+// CatForUpdate returns the cat and locks it until the end of the transaction.
+func (s *Storage) CatForUpdate(ctx context.Context, tx *sql.Tx, id int) (*domain.Cat, error) {
+	const op = "storage.CatForUpdate"
+
+	query := "SELECT " + catColumns + " WHERE c.id = $1 FOR UPDATE OF c"
 
+	cat, err := scanCat(tx.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
@@ -55,12 +99,9 @@ func (s *Storage) Cat(ctx context.Context, id int) (*domain.Cat, error) {
 func (s *Storage) Cats(ctx context.Context) ([]*domain.Cat, error) {
 	const op = "storage.Cats"
 
-	query, err := s.PostgresDB.Prepare("SELECT id, name, breed, years_of_experience, salary FROM cats ORDER BY created_at DESC")
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
+	query := "SELECT " + catColumns + " ORDER BY c.created_at DESC"
 
-	rows, err := query.QueryContext(ctx)
+	rows, err := s.PostgresDB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -68,8 +109,7 @@ func (s *Storage) Cats(ctx context.Context) ([]*domain.Cat, error) {
 
 	cats := make([]*domain.Cat, 0)
 	for rows.Next() {
-		cat := &domain.Cat{}
-		err = rows.Scan(&cat.ID, &cat.Name, &cat.Breed, &cat.YearsOfExperience, &cat.Salary)
+		cat, err := scanCat(rows)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
@@ -80,11 +120,12 @@ func (s *Storage) Cats(ctx context.Context) ([]*domain.Cat, error) {
 	return cats, nil
 }
 
-func (s *Storage) UpdateCat(ctx context.Context, cat *domain.Cat) error {
+func (s *Storage) UpdateCat(ctx context.Context, tx *sql.Tx, cat *domain.Cat) error {
 	const op = "storage.UpdateCat"
 
-	query := "UPDATE cats SET name = $1, breed = $2, years_of_experience = $3, salary = $4 WHERE id = $5"
-	result, err := s.PostgresDB.Exec(query, cat.Name, cat.Breed, cat.YearsOfExperience, cat.Salary, cat.ID)
+	query := `UPDATE cats SET name = $1, breed = $2, years_of_experience = $3, salary = $4, currency = $5, handler_id = NULLIF($6, 0)
+		WHERE id = $7`
+	result, err := tx.ExecContext(ctx, query, cat.Name, cat.Breed, cat.YearsOfExperience, cat.Salary, cat.Currency, cat.HandlerID, cat.ID)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -101,11 +142,24 @@ func (s *Storage) UpdateCat(ctx context.Context, cat *domain.Cat) error {
 	return nil
 }
 
-func (s *Storage) DeleteCat(ctx context.Context, id int) error {
+// DeleteCat deletes the cat. Its salary history and bonuses are kept with the
+// name of the cat, the salary stops today.
+func (s *Storage) DeleteCat(ctx context.Context, tx *sql.Tx, id int) error {
 	const op = "storage.DeleteCat"
 
+	keep := `UPDATE salary_history h SET cat_name = c.name, cat_deleted_at = (NOW() AT TIME ZONE 'UTC')::DATE
+		FROM cats c WHERE c.id = $1 AND h.cat_id = c.id`
+	if _, err := tx.ExecContext(ctx, keep, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	keep = "UPDATE bonuses b SET cat_name = c.name FROM cats c WHERE c.id = $1 AND b.cat_id = c.id"
+	if _, err := tx.ExecContext(ctx, keep, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	query := "DELETE FROM cats WHERE id = $1"
-	result, err := s.PostgresDB.Exec(query, id)
+	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
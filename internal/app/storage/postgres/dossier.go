@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// SaveTargetAlias adds an alias to the target. It returns storage.ErrNotFound
+// when the target does not exist and storage.ErrAlreadyExists when the target
+// has the alias already.
+func (s *Storage) SaveTargetAlias(ctx context.Context, tx *sql.Tx, targetID int, alias string) error {
+	const op = "storage.SaveTargetAlias"
+
+	query := "INSERT INTO target_aliases (target_id, alias) VALUES ($1, $2)"
+	if _, err := tx.ExecContext(ctx, query, targetID, alias); err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrAlreadyExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteTargetAlias(ctx context.Context, targetID int, alias string) error {
+	const op = "storage.DeleteTargetAlias"
+
+	result, err := s.PostgresDB.ExecContext(ctx, "DELETE FROM target_aliases WHERE target_id = $1 AND alias = $2", targetID, alias)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// TargetAliases returns the aliases of the target in alphabetical order.
+func (s *Storage) TargetAliases(ctx context.Context, targetID int) ([]string, error) {
+	const op = "storage.TargetAliases"
+
+	rows, err := s.PostgresDB.QueryContext(ctx, "SELECT alias FROM target_aliases WHERE target_id = $1 ORDER BY alias", targetID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	aliases := make([]string, 0)
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		aliases = append(aliases, alias)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return aliases, nil
+}
+
+// SaveTargetPhoto adds the photo to its target, it returns storage.ErrNotFound
+// when the target does not exist.
+func (s *Storage) SaveTargetPhoto(ctx context.Context, photo *domain.TargetPhoto) error {
+	const op = "storage.SaveTargetPhoto"
+
+	query := `INSERT INTO target_photos (target_id, url, caption, user_id)
+		VALUES ($1, $2, $3, NULLIF($4, 0)) RETURNING id, created_at`
+
+	err := s.PostgresDB.QueryRowContext(ctx, query, photo.TargetID, photo.URL, photo.Caption, photo.UserID).
+		Scan(&photo.ID, &photo.CreatedAt)
+	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TargetPhotos returns the photos of the target, newest first.
+func (s *Storage) TargetPhotos(ctx context.Context, targetID int) ([]*domain.TargetPhoto, error) {
+	const op = "storage.TargetPhotos"
+
+	query := `SELECT id, target_id, COALESCE(user_id, 0), url, caption, created_at
+		FROM target_photos WHERE target_id = $1 ORDER BY id DESC`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	photos := make([]*domain.TargetPhoto, 0)
+	for rows.Next() {
+		p := &domain.TargetPhoto{}
+		if err := rows.Scan(&p.ID, &p.TargetID, &p.UserID, &p.URL, &p.Caption, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		photos = append(photos, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return photos, nil
+}
+
+// SaveTargetNote adds the note to the history of its target and makes it the
+// latest note of the target. It returns storage.ErrNotFound when the target
+// does not exist.
+func (s *Storage) SaveTargetNote(ctx context.Context, tx *sql.Tx, note *domain.TargetNote) error {
+	const op = "storage.SaveTargetNote"
+
+	result, err := tx.ExecContext(ctx, "UPDATE targets SET notes = $2, updated_at = NOW() WHERE id = $1", note.TargetID, note.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	query := `INSERT INTO target_notes (target_id, user_id, body)
+		VALUES ($1, NULLIF($2, 0), $3) RETURNING id, created_at`
+
+	if err := tx.QueryRowContext(ctx, query, note.TargetID, note.UserID, note.Body).Scan(&note.ID, &note.CreatedAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TargetNotes returns every note written on the target, newest first.
+func (s *Storage) TargetNotes(ctx context.Context, targetID int) ([]*domain.TargetNote, error) {
+	const op = "storage.TargetNotes"
+
+	query := `SELECT n.id, n.target_id, COALESCE(n.user_id, 0), COALESCE(u.username, ''), n.body, n.created_at
+		FROM target_notes n LEFT JOIN users u ON u.id = n.user_id
+		WHERE n.target_id = $1
+		ORDER BY n.id DESC`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	notes := make([]*domain.TargetNote, 0)
+	for rows.Next() {
+		n := &domain.TargetNote{}
+		if err := rows.Scan(&n.ID, &n.TargetID, &n.UserID, &n.Username, &n.Body, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		notes = append(notes, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return notes, nil
+}
@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+func (s *Storage) SaveEvent(ctx context.Context, tx *sql.Tx, event *domain.Event) error {
+	const op = "storage.SaveEvent"
+
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `INSERT INTO domain_events (type, mission_id, cat_id, target_id, payload)
+		VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, 0), $5) RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, event.Type, event.MissionID, event.CatID, event.TargetID, payload).
+		Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Queue the event for every webhook subscribed to it in the same transaction,
+	// so an event is never recorded without its deliveries or the other way round.
+	query = `INSERT INTO webhook_deliveries (subscription_id, event_id)
+		SELECT id, $1 FROM webhook_subscriptions WHERE active AND $2 = ANY(event_types)`
+
+	if _, err := tx.ExecContext(ctx, query, event.ID, event.Type); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+const eventColumns = "id, type, COALESCE(mission_id, 0), COALESCE(cat_id, 0), COALESCE(target_id, 0), COALESCE(payload, '{}'), created_at"
+
+func (s *Storage) Event(ctx context.Context, id int64) (*domain.Event, error) {
+	const op = "storage.Event"
+
+	query := "SELECT " + eventColumns + " FROM domain_events WHERE id = $1"
+
+	event, err := scanEvent(s.PostgresDB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return event, nil
+}
+
+// EventsAfter returns up to limit events with ID greater than id, oldest first.
+func (s *Storage) EventsAfter(ctx context.Context, id int64, limit int) ([]*domain.Event, error) {
+	const op = "storage.EventsAfter"
+
+	query := "SELECT " + eventColumns + " FROM domain_events WHERE id > $1 ORDER BY id LIMIT $2"
+
+	events, err := s.events(ctx, query, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// RecentEvents returns the last limit events, oldest first.
+func (s *Storage) RecentEvents(ctx context.Context, limit int) ([]*domain.Event, error) {
+	const op = "storage.RecentEvents"
+
+	query := "SELECT * FROM (SELECT " + eventColumns + " FROM domain_events ORDER BY id DESC LIMIT $1) e ORDER BY id"
+
+	events, err := s.events(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) events(ctx context.Context, query string, args ...any) ([]*domain.Event, error) {
+	rows, err := s.PostgresDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*domain.Event, 0)
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func scanEvent(row scanner) (*domain.Event, error) {
+	event := &domain.Event{}
+
+	var payload []byte
+	err := row.Scan(&event.ID, &event.Type, &event.MissionID, &event.CatID, &event.TargetID, &payload, &event.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(payload, &event.Payload); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
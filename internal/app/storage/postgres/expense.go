@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+func (s *Storage) SaveExpense(ctx context.Context, expense *domain.Expense) (int, error) {
+	const op = "storage.SaveExpense"
+
+	query := `INSERT INTO mission_expenses (mission_id, category, amount, currency, receipt_note)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+
+	err := s.PostgresDB.QueryRowContext(ctx, query,
+		expense.MissionID,
+		expense.Category,
+		expense.Amount,
+		expense.Currency,
+		expense.ReceiptNote,
+	).Scan(&expense.ID, &expense.CreatedAt)
+	if err != nil {
+		var pgErr *pq.Error
+
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return expense.ID, nil
+}
+
+func (s *Storage) Expenses(ctx context.Context, missionID int) ([]*domain.Expense, error) {
+	const op = "storage.Expenses"
+
+	query := `SELECT id, mission_id, category, amount, currency, COALESCE(receipt_note, ''), created_at
+		FROM mission_expenses WHERE mission_id = $1 ORDER BY created_at`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, missionID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	expenses := make([]*domain.Expense, 0)
+	for rows.Next() {
+		e := &domain.Expense{}
+		err = rows.Scan(&e.ID, &e.MissionID, &e.Category, &e.Amount, &e.Currency, &e.ReceiptNote, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		expenses = append(expenses, e)
+	}
+
+	return expenses, nil
+}
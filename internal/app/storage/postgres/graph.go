@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// SaveRelationship links the targets of the relationship. It returns
+// storage.ErrNotFound when either target does not exist and
+// storage.ErrAlreadyExists when they are linked with the type already.
+func (s *Storage) SaveRelationship(ctx context.Context, r *domain.Relationship) error {
+	const op = "storage.SaveRelationship"
+
+	query := `INSERT INTO target_relationships (from_id, to_id, type, confidence, source, user_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0)) RETURNING id, created_at`
+
+	err := s.PostgresDB.QueryRowContext(ctx, query, r.FromID, r.ToID, r.Type, r.Confidence, r.Source, r.UserID).
+		Scan(&r.ID, &r.CreatedAt)
+	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrAlreadyExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteRelationship deletes the relationship of the target, the target being
+// either end of it.
+func (s *Storage) DeleteRelationship(ctx context.Context, targetID, id int) error {
+	const op = "storage.DeleteRelationship"
+
+	query := "DELETE FROM target_relationships WHERE id = $1 AND (from_id = $2 OR to_id = $2)"
+
+	result, err := s.PostgresDB.ExecContext(ctx, query, id, targetID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// TargetGraph returns the targets at most depth relationships away from the
+// target, following relationships in either direction, nearest first, and
+// every relationship between them. It returns storage.ErrNotFound when the
+// target does not exist.
+func (s *Storage) TargetGraph(ctx context.Context, targetID, depth int) (*domain.TargetGraph, error) {
+	const op = "storage.TargetGraph"
+
+	query := `WITH RECURSIVE reach (id, depth) AS (
+			SELECT $1::INT, 0
+			UNION
+			SELECT CASE WHEN r.from_id = reach.id THEN r.to_id ELSE r.from_id END, reach.depth + 1
+			FROM reach
+			JOIN target_relationships r ON r.from_id = reach.id OR r.to_id = reach.id
+			WHERE reach.depth < $2
+		)
+		SELECT t.id, t.name, t.country, t.city, MIN(reach.depth) AS depth
+		FROM reach JOIN targets t ON t.id = reach.id
+		GROUP BY t.id
+		ORDER BY depth, t.id`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, targetID, depth)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	graph := &domain.TargetGraph{TargetID: targetID, Nodes: make([]*domain.GraphNode, 0), Edges: make([]*domain.Relationship, 0)}
+	ids := make([]int64, 0)
+	for rows.Next() {
+		n := &domain.GraphNode{}
+		if err := rows.Scan(&n.ID, &n.Name, &n.Country, &n.City, &n.Depth); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		graph.Nodes = append(graph.Nodes, n)
+		ids = append(ids, int64(n.ID))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(graph.Nodes) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	query = `SELECT id, from_id, to_id, type, confidence, source, COALESCE(user_id, 0), created_at
+		FROM target_relationships
+		WHERE from_id = ANY($1) AND to_id = ANY($1)
+		ORDER BY id`
+
+	rows, err = s.PostgresDB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := &domain.Relationship{}
+		if err := rows.Scan(&r.ID, &r.FromID, &r.ToID, &r.Type, &r.Confidence, &r.Source, &r.UserID, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		graph.Edges = append(graph.Edges, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return graph, nil
+}
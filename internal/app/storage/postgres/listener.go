@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/config"
+)
+
+// EventsChannel is the notification channel a trigger on domain_events
+// notifies with the ID of every new event.
+const EventsChannel = "domain_events"
+
+// ChatChannel is the notification channel mission chat frames are fanned out through.
+const ChatChannel = "mission_chat"
+
+// NewListener opens a dedicated connection listening for notifications on the channel.
+// The listener reconnects on its own, a nil notification is sent after every reconnect.
+func NewListener(cfg config.Postgres, channel string) (*pq.Listener, error) {
+	listener := pq.NewListener(dataSource(cfg, cfg.Database), time.Second, time.Minute, nil)
+
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// Notify sends a notification with the payload to everyone listening on the channel.
+func (s *Storage) Notify(ctx context.Context, channel, payload string) error {
+	const op = "storage.Notify"
+
+	if _, err := s.PostgresDB.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, payload); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
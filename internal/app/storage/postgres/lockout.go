@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// UpdateLoginAttempts calls update with the failed logins of the key and saves
+// the record unless update returns false. The record is locked meanwhile, so
+// concurrent logins of the key wait for each other.
+func (s *Storage) UpdateLoginAttempts(ctx context.Context, key string, update func(attempts *domain.LoginAttempts) bool) error {
+	const op = "storage.UpdateLoginAttempts"
+
+	tx, err := s.PostgresDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// A key without failures gets an empty record, there is a row to lock then.
+	query := `INSERT INTO login_attempts (key, failures, last_failure_at) VALUES ($1, 0, 'epoch')
+		ON CONFLICT (key) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, key); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = "SELECT failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1 FOR UPDATE"
+
+	attempts := &domain.LoginAttempts{Key: key}
+	err = tx.QueryRowContext(ctx, query, key).Scan(&attempts.Failures, &attempts.LastFailure, &attempts.LockedUntil)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !update(attempts) {
+		tx.Rollback()
+		return nil
+	}
+
+	query = "UPDATE login_attempts SET failures = $1, last_failure_at = $2, locked_until = $3 WHERE key = $4"
+	if _, err := tx.ExecContext(ctx, query, attempts.Failures, attempts.LastFailure, attempts.LockedUntil, key); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteLoginAttempts(ctx context.Context, key string) error {
+	const op = "storage.DeleteLoginAttempts"
+
+	if _, err := s.PostgresDB.ExecContext(ctx, "DELETE FROM login_attempts WHERE key = $1", key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PurgeLoginAttempts deletes the records whose last failure is older than the
+// window and that are not locked anymore.
+func (s *Storage) PurgeLoginAttempts(ctx context.Context, now time.Time, window time.Duration) (int, error) {
+	const op = "storage.PurgeLoginAttempts"
+
+	query := `DELETE FROM login_attempts
+		WHERE last_failure_at < $1 - make_interval(secs => $2)
+			AND (locked_until IS NULL OR locked_until < $1)`
+
+	res, err := s.PostgresDB.ExecContext(ctx, query, now, window.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(n), nil
+}
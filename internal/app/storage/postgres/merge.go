@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// AllTargetAliases returns the aliases of every target by target ID.
+func (s *Storage) AllTargetAliases(ctx context.Context) (map[int][]string, error) {
+	const op = "storage.AllTargetAliases"
+
+	rows, err := s.PostgresDB.QueryContext(ctx, "SELECT target_id, alias FROM target_aliases ORDER BY target_id, alias")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	aliases := make(map[int][]string)
+	for rows.Next() {
+		var (
+			targetID int
+			alias    string
+		)
+		if err := rows.Scan(&targetID, &alias); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		aliases[targetID] = append(aliases[targetID], alias)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return aliases, nil
+}
+
+// ReplaceMergeCandidates replaces the pending merge candidates with the given
+// ones. Pairs dismissed before are kept dismissed.
+func (s *Storage) ReplaceMergeCandidates(ctx context.Context, tx *sql.Tx, candidates []*domain.MergeCandidate) error {
+	const op = "storage.ReplaceMergeCandidates"
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM target_merge_candidates WHERE dismissed_at IS NULL"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `INSERT INTO target_merge_candidates (target_id, duplicate_id, score, reasons)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (target_id, duplicate_id) DO NOTHING`
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	for _, c := range candidates {
+		if _, err := stmt.ExecContext(ctx, c.Target.ID, c.Duplicate.ID, c.Score, pq.Array(c.Reasons)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// MergeCandidates returns a page of the pending merge candidates, the most
+// similar first.
+func (s *Storage) MergeCandidates(ctx context.Context, filter *domain.MergeCandidateFilter) ([]*domain.MergeCandidate, error) {
+	const op = "storage.MergeCandidates"
+
+	query := `SELECT t.id, t.name, t.country, t.city, d.id, d.name, d.country, d.city, c.score, c.reasons, c.detected_at
+		FROM target_merge_candidates c
+		JOIN targets t ON t.id = c.target_id
+		JOIN targets d ON d.id = c.duplicate_id
+		WHERE c.dismissed_at IS NULL
+		ORDER BY c.score DESC, c.target_id, c.duplicate_id
+		LIMIT $1 OFFSET $2`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	candidates := make([]*domain.MergeCandidate, 0)
+	for rows.Next() {
+		c := &domain.MergeCandidate{}
+		err := rows.Scan(&c.Target.ID, &c.Target.Name, &c.Target.Country, &c.Target.City,
+			&c.Duplicate.ID, &c.Duplicate.Name, &c.Duplicate.Country, &c.Duplicate.City,
+			&c.Score, pq.Array(&c.Reasons), &c.DetectedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return candidates, nil
+}
+
+// DismissMergeCandidate keeps the pair from being suggested again, targetID
+// being the lower ID of the pair.
+func (s *Storage) DismissMergeCandidate(ctx context.Context, targetID, duplicateID int) error {
+	const op = "storage.DismissMergeCandidate"
+
+	query := `UPDATE target_merge_candidates SET dismissed_at = COALESCE(dismissed_at, NOW())
+		WHERE target_id = $1 AND duplicate_id = $2`
+
+	result, err := s.PostgresDB.ExecContext(ctx, query, targetID, duplicateID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// MergeTarget moves everything of the duplicate to the target and deletes the
+// duplicate. The missions of the duplicate are linked to the target, a target
+// completed in a mission under either record stays completed. Notes and photos
+// move over, the name and the aliases of the duplicate become aliases of the
+// target and the location, the city and the notes of the duplicate fill those
+// the target lacks. Relationships of the duplicate move to the target, those
+// between the two are dropped, required skills keep the higher level. It returns
+// storage.ErrNotFound when either does not exist.
+func (s *Storage) MergeTarget(ctx context.Context, tx *sql.Tx, targetID, duplicateID int) error {
+	const op = "storage.MergeTarget"
+
+	var locked int
+	query := "SELECT COUNT(*) FROM (SELECT id FROM targets WHERE id IN ($1, $2) ORDER BY id FOR UPDATE) t"
+	if err := tx.QueryRowContext(ctx, query, targetID, duplicateID).Scan(&locked); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if locked != 2 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	queries := []string{
+		`INSERT INTO mission_targets (mission_id, target_id, completed, completed_at, added_at)
+			SELECT mission_id, $1, completed, completed_at, added_at FROM mission_targets WHERE target_id = $2
+			ON CONFLICT (mission_id, target_id) DO UPDATE SET
+				completed = mission_targets.completed OR EXCLUDED.completed,
+				completed_at = LEAST(mission_targets.completed_at, EXCLUDED.completed_at),
+				added_at = LEAST(mission_targets.added_at, EXCLUDED.added_at)`,
+		`UPDATE target_notes SET target_id = $1 WHERE target_id = $2`,
+		`UPDATE target_photos SET target_id = $1 WHERE target_id = $2`,
+		`INSERT INTO target_aliases (target_id, alias)
+			SELECT $1, alias FROM target_aliases WHERE target_id = $2
+			UNION SELECT $1, name FROM targets WHERE id = $2
+			EXCEPT SELECT $1, name FROM targets WHERE id = $1
+			ON CONFLICT DO NOTHING`,
+		`UPDATE targets t SET
+				city = CASE WHEN t.city = '' THEN d.city ELSE t.city END,
+				latitude = CASE WHEN t.latitude IS NULL THEN d.latitude ELSE t.latitude END,
+				longitude = CASE WHEN t.latitude IS NULL THEN d.longitude ELSE t.longitude END,
+				notes = CASE WHEN t.notes = '' THEN d.notes ELSE t.notes END,
+				updated_at = NOW()
+			FROM targets d WHERE t.id = $1 AND d.id = $2`,
+		`INSERT INTO target_relationships (from_id, to_id, type, confidence, source, user_id, created_at)
+			SELECT CASE WHEN from_id = $2 THEN $1 ELSE from_id END, CASE WHEN to_id = $2 THEN $1 ELSE to_id END,
+				type, confidence, source, user_id, created_at
+			FROM target_relationships WHERE (from_id = $2 OR to_id = $2) AND from_id <> $1 AND to_id <> $1
+			ON CONFLICT (from_id, to_id, type) DO UPDATE SET
+				confidence = GREATEST(target_relationships.confidence, EXCLUDED.confidence)`,
+		`INSERT INTO target_skills (target_id, skill_id, min_level)
+			SELECT $1, skill_id, min_level FROM target_skills WHERE target_id = $2
+			ON CONFLICT (target_id, skill_id) DO UPDATE SET
+				min_level = GREATEST(target_skills.min_level, EXCLUDED.min_level)`,
+		`DELETE FROM targets WHERE id = $2`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, targetID, duplicateID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+func (s *Storage) SaveMessage(ctx context.Context, message *domain.Message) error {
+	const op = "storage.SaveMessage"
+
+	query := `INSERT INTO mission_messages (mission_id, user_id, body)
+		VALUES ($1, NULLIF($2, 0), $3) RETURNING id, created_at`
+
+	err := s.PostgresDB.QueryRowContext(ctx, query, message.MissionID, message.UserID, message.Body).
+		Scan(&message.ID, &message.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Messages returns up to limit messages of the mission posted before the message
+// with the given ID, newest first. A zero before starts from the latest message.
+func (s *Storage) Messages(ctx context.Context, missionID int, before int64, limit int) ([]*domain.Message, error) {
+	const op = "storage.Messages"
+
+	query := `SELECT m.id, m.mission_id, COALESCE(m.user_id, 0), COALESCE(u.username, ''), m.body, m.created_at
+		FROM mission_messages m LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.mission_id = $1 AND ($2 = 0 OR m.id < $2)
+		ORDER BY m.id DESC LIMIT $3`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, missionID, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	messages := make([]*domain.Message, 0)
+	for rows.Next() {
+		m := &domain.Message{}
+		if err := rows.Scan(&m.ID, &m.MissionID, &m.UserID, &m.Username, &m.Body, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// SaveTOTPSecret stores a new secret for the user, pending activation.
+func (s *Storage) SaveTOTPSecret(ctx context.Context, userID int, secret string) error {
+	const op = "storage.SaveTOTPSecret"
+
+	query := "UPDATE users SET totp_secret = $1, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = $2"
+
+	if _, err := s.PostgresDB.ExecContext(ctx, query, secret, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// EnableTOTP activates the pending secret, step is the time step of the code it was confirmed with.
+func (s *Storage) EnableTOTP(ctx context.Context, tx *sql.Tx, userID int, step int64) error {
+	const op = "storage.EnableTOTP"
+
+	query := "UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $1 WHERE id = $2 AND totp_secret IS NOT NULL"
+
+	if _, err := tx.ExecContext(ctx, query, step, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DisableTOTP removes the secret and the recovery codes of the user.
+func (s *Storage) DisableTOTP(ctx context.Context, tx *sql.Tx, userID int) error {
+	const op = "storage.DisableTOTP"
+
+	query := "UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = $1"
+
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the time step a code was accepted for. It returns
+// storage.ErrNotFound when the step, or a later one, was used already.
+func (s *Storage) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	const op = "storage.UseTOTPStep"
+
+	query := "UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1"
+
+	result, err := s.PostgresDB.ExecContext(ctx, query, step, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes drops the recovery codes of the user and stores the new ones.
+func (s *Storage) ReplaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, hashes [][]byte) error {
+	const op = "storage.ReplaceRecoveryCodes"
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, hash := range hashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks the unused recovery code with the hash as used.
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int, hash []byte) error {
+	const op = "storage.UseRecoveryCode"
+
+	query := `UPDATE mfa_recovery_codes SET used_at = NOW()
+		WHERE id = (
+			SELECT id FROM mfa_recovery_codes
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1 FOR UPDATE
+		)`
+
+	result, err := s.PostgresDB.ExecContext(ctx, query, userID, hash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// MFAPolicies returns the policy of every role, roles without a row do not require MFA.
+func (s *Storage) MFAPolicies(ctx context.Context) ([]domain.MFAPolicy, error) {
+	const op = "storage.MFAPolicies"
+
+	query := `SELECT r.role, COALESCE(p.required, false)
+		FROM (VALUES ('admin'), ('handler')) AS r (role)
+		LEFT JOIN mfa_policies p ON p.role = r.role
+		ORDER BY r.role`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var policies []domain.MFAPolicy
+	for rows.Next() {
+		var p domain.MFAPolicy
+		if err := rows.Scan(&p.Role, &p.Required); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		policies = append(policies, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return policies, nil
+}
+
+func (s *Storage) SaveMFAPolicy(ctx context.Context, policy *domain.MFAPolicy) error {
+	const op = "storage.SaveMFAPolicy"
+
+	query := `INSERT INTO mfa_policies (role, required) VALUES ($1, $2)
+		ON CONFLICT (role) DO UPDATE SET required = EXCLUDED.required`
+
+	if _, err := s.PostgresDB.ExecContext(ctx, query, policy.Role, policy.Required); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS bonuses;
+DROP TABLE IF EXISTS salary_history;
+ALTER TABLE cats DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,31 @@
+ALTER TABLE cats ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+CREATE TABLE IF NOT EXISTS salary_history (
+    id             SERIAL PRIMARY KEY,
+    cat_id         INT NOT NULL REFERENCES cats(id) ON DELETE CASCADE,
+    amount         BIGINT NOT NULL CHECK (amount >= 0),
+    currency       CHAR(3) NOT NULL,
+    effective_from DATE NOT NULL,
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (cat_id, effective_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_salary_history_cat ON salary_history (cat_id, effective_from);
+
+INSERT INTO salary_history (cat_id, amount, currency, effective_from)
+SELECT id, salary::BIGINT * 100, 'USD', created_at::DATE
+FROM cats
+WHERE salary IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS bonuses (
+    id         SERIAL PRIMARY KEY,
+    cat_id     INT NOT NULL REFERENCES cats(id) ON DELETE CASCADE,
+    mission_id INT REFERENCES missions(id) ON DELETE SET NULL,
+    amount     BIGINT NOT NULL CHECK (amount >= 0),
+    currency   CHAR(3) NOT NULL,
+    reason     TEXT,
+    awarded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_bonuses_awarded_at ON bonuses (awarded_at);
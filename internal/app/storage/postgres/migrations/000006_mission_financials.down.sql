@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS mission_expenses;
+ALTER TABLE missions DROP COLUMN IF EXISTS currency;
+ALTER TABLE missions DROP COLUMN IF EXISTS bonus;
+ALTER TABLE missions DROP COLUMN IF EXISTS budget;
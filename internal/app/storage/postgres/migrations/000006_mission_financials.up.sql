@@ -0,0 +1,15 @@
+ALTER TABLE missions ADD COLUMN IF NOT EXISTS budget BIGINT NOT NULL DEFAULT 0 CHECK (budget >= 0);
+ALTER TABLE missions ADD COLUMN IF NOT EXISTS bonus BIGINT NOT NULL DEFAULT 0 CHECK (bonus >= 0);
+ALTER TABLE missions ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+CREATE TABLE IF NOT EXISTS mission_expenses (
+    id           SERIAL PRIMARY KEY,
+    mission_id   INT NOT NULL REFERENCES missions(id) ON DELETE CASCADE,
+    category     VARCHAR(50) NOT NULL,
+    amount       BIGINT NOT NULL CHECK (amount > 0),
+    currency     CHAR(3) NOT NULL,
+    receipt_note TEXT,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_mission_expenses_mission ON mission_expenses (mission_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS mission_skills;
+DROP TABLE IF EXISTS cat_skills;
+DROP TABLE IF EXISTS skills;
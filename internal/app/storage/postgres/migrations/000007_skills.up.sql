@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS skills (
+    id          SERIAL PRIMARY KEY,
+    name        VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO skills (name, description) VALUES
+    ('infiltration', 'Getting into guarded places unnoticed'),
+    ('surveillance', 'Watching targets without being detected'),
+    ('languages', 'Speaking the language of the target country'),
+    ('disguise', 'Blending in with the locals'),
+    ('lockpicking', 'Opening doors that should stay closed'),
+    ('hacking', 'Getting into computer systems')
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS cat_skills (
+    cat_id   INT NOT NULL REFERENCES cats(id) ON DELETE CASCADE,
+    skill_id INT NOT NULL REFERENCES skills(id) ON DELETE CASCADE,
+    level    SMALLINT NOT NULL CHECK (level BETWEEN 1 AND 5),
+    PRIMARY KEY (cat_id, skill_id)
+);
+
+CREATE TABLE IF NOT EXISTS mission_skills (
+    mission_id INT NOT NULL REFERENCES missions(id) ON DELETE CASCADE,
+    skill_id   INT NOT NULL REFERENCES skills(id) ON DELETE CASCADE,
+    min_level  SMALLINT NOT NULL CHECK (min_level BETWEEN 1 AND 5),
+    PRIMARY KEY (mission_id, skill_id)
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    INT REFERENCES users(id) ON DELETE SET NULL,
+    action     VARCHAR(100) NOT NULL,
+    entity     VARCHAR(50) NOT NULL,
+    entity_id  INT NOT NULL,
+    details    JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log (entity, entity_id);
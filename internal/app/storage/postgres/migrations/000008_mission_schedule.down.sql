@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS domain_events;
+DROP INDEX IF EXISTS idx_missions_due_at;
+ALTER TABLE missions DROP COLUMN IF EXISTS overdue;
+ALTER TABLE missions DROP COLUMN IF EXISTS due_at;
+ALTER TABLE missions DROP COLUMN IF EXISTS starts_at;
+ALTER TABLE missions DROP COLUMN IF EXISTS priority;
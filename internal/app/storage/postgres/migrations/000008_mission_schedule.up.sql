@@ -0,0 +1,16 @@
+ALTER TABLE missions ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 3 CHECK (priority BETWEEN 1 AND 5);
+ALTER TABLE missions ADD COLUMN IF NOT EXISTS starts_at TIMESTAMPTZ;
+ALTER TABLE missions ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ;
+ALTER TABLE missions ADD COLUMN IF NOT EXISTS overdue BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_missions_due_at ON missions (due_at) WHERE NOT completed AND NOT overdue;
+
+CREATE TABLE IF NOT EXISTS domain_events (
+    id         BIGSERIAL PRIMARY KEY,
+    type       VARCHAR(100) NOT NULL,
+    mission_id INT,
+    cat_id     INT,
+    target_id  INT,
+    payload    JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS domain_events_notify ON domain_events;
+DROP FUNCTION IF EXISTS notify_domain_event();
@@ -0,0 +1,10 @@
+CREATE OR REPLACE FUNCTION notify_domain_event() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('domain_events', NEW.id::TEXT);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS domain_events_notify ON domain_events;
+CREATE TRIGGER domain_events_notify AFTER INSERT ON domain_events
+    FOR EACH ROW EXECUTE FUNCTION notify_domain_event();
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS mission_messages;
+ALTER TABLE cats DROP COLUMN IF EXISTS handler_id;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'handler' CHECK (role IN ('admin', 'handler'));
+ALTER TABLE cats ADD COLUMN IF NOT EXISTS handler_id INT REFERENCES users (id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS mission_messages (
+    id         BIGSERIAL PRIMARY KEY,
+    mission_id INT NOT NULL REFERENCES missions (id) ON DELETE CASCADE,
+    user_id    INT REFERENCES users (id) ON DELETE SET NULL,
+    body       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mission_messages_mission ON mission_messages (mission_id, id);
@@ -0,0 +1,7 @@
+-- A missing row means the notification is on, users opt out per event type.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id    INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    email      BOOLEAN NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (user_id, event_type)
+);
@@ -0,0 +1,19 @@
+-- totp_secret is set on enrollment, the factor is only in use once totp_enabled_at is set.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMPTZ;
+-- The last time step a code was accepted for, older and equal steps are refused.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id        BIGSERIAL PRIMARY KEY,
+    user_id   INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL,
+    used_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user ON mfa_recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS mfa_policies (
+    role     VARCHAR(20) PRIMARY KEY CHECK (role IN ('admin', 'handler')),
+    required BOOLEAN NOT NULL DEFAULT FALSE
+);
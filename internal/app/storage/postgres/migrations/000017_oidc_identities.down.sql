@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS user_identities;
+-- An empty password matches no bcrypt hash, these users cannot sign in until they reset it.
+UPDATE users SET password = '' WHERE password IS NULL;
+ALTER TABLE users ALTER COLUMN password SET NOT NULL;
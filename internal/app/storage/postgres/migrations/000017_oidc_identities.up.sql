@@ -0,0 +1,13 @@
+-- Users provisioned by the identity provider sign in there and have no password.
+ALTER TABLE users ALTER COLUMN password DROP NOT NULL;
+
+-- The subject of the ID tokens of an issuer, linked to the user it signs in as.
+CREATE TABLE IF NOT EXISTS user_identities (
+    issuer     VARCHAR(255) NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    user_id    INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities (user_id);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS targets_latitude_idx;
+
+ALTER TABLE targets DROP CONSTRAINT IF EXISTS targets_location_check;
+ALTER TABLE targets DROP CONSTRAINT IF EXISTS targets_longitude_check;
+ALTER TABLE targets DROP CONSTRAINT IF EXISTS targets_latitude_check;
+
+ALTER TABLE targets DROP COLUMN IF EXISTS longitude;
+ALTER TABLE targets DROP COLUMN IF EXISTS latitude;
+ALTER TABLE targets DROP COLUMN IF EXISTS city;
@@ -0,0 +1,11 @@
+ALTER TABLE targets ADD COLUMN IF NOT EXISTS city VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE targets ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE targets ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+ALTER TABLE targets ADD CONSTRAINT targets_latitude_check CHECK (latitude BETWEEN -90 AND 90);
+ALTER TABLE targets ADD CONSTRAINT targets_longitude_check CHECK (longitude BETWEEN -180 AND 180);
+-- A target is located by both coordinates or not at all.
+ALTER TABLE targets ADD CONSTRAINT targets_location_check CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+-- Proximity searches narrow the targets down to a band of latitudes first.
+CREATE INDEX IF NOT EXISTS targets_latitude_idx ON targets (latitude) WHERE latitude IS NOT NULL;
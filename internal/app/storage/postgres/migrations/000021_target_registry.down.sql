@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS target_notes;
+DROP TABLE IF EXISTS target_photos;
+DROP TABLE IF EXISTS target_aliases;
+
+ALTER TABLE targets ALTER COLUMN notes DROP NOT NULL;
+ALTER TABLE targets ADD COLUMN IF NOT EXISTS mission_id INT REFERENCES missions (id) ON DELETE CASCADE;
+ALTER TABLE targets ADD COLUMN IF NOT EXISTS completed BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- A target linked to several missions keeps the first of them, targets of no
+-- mission cannot be kept.
+UPDATE targets t SET mission_id = mt.mission_id, completed = mt.completed
+FROM (
+    SELECT DISTINCT ON (target_id) target_id, mission_id, completed
+    FROM mission_targets ORDER BY target_id, added_at, mission_id
+) mt
+WHERE mt.target_id = t.id;
+
+DELETE FROM targets WHERE mission_id IS NULL;
+ALTER TABLE targets ALTER COLUMN mission_id SET NOT NULL;
+
+DROP TABLE IF EXISTS mission_targets;
@@ -0,0 +1,56 @@
+-- Targets are a registry of their own, missions link to them and complete them
+-- each on their own.
+CREATE TABLE IF NOT EXISTS mission_targets (
+    mission_id   INT NOT NULL REFERENCES missions (id) ON DELETE CASCADE,
+    target_id    INT NOT NULL REFERENCES targets (id) ON DELETE CASCADE,
+    completed    BOOLEAN NOT NULL DEFAULT FALSE,
+    completed_at TIMESTAMPTZ,
+    added_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (mission_id, target_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mission_targets_target ON mission_targets (target_id);
+
+INSERT INTO mission_targets (mission_id, target_id, completed, completed_at, added_at)
+SELECT mission_id, id, completed, CASE WHEN completed THEN updated_at END, COALESCE(created_at, NOW())
+FROM targets
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE targets DROP COLUMN IF EXISTS mission_id;
+ALTER TABLE targets DROP COLUMN IF EXISTS completed;
+UPDATE targets SET notes = '' WHERE notes IS NULL;
+ALTER TABLE targets ALTER COLUMN notes SET DEFAULT '';
+ALTER TABLE targets ALTER COLUMN notes SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS target_aliases (
+    target_id INT NOT NULL REFERENCES targets (id) ON DELETE CASCADE,
+    alias     VARCHAR(255) NOT NULL,
+    PRIMARY KEY (target_id, alias)
+);
+
+CREATE INDEX IF NOT EXISTS idx_target_aliases_alias ON target_aliases (LOWER(alias));
+
+CREATE TABLE IF NOT EXISTS target_photos (
+    id         SERIAL PRIMARY KEY,
+    target_id  INT NOT NULL REFERENCES targets (id) ON DELETE CASCADE,
+    url        TEXT NOT NULL,
+    caption    TEXT NOT NULL DEFAULT '',
+    user_id    INT REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_target_photos_target ON target_photos (target_id, id);
+
+-- Every note ever written on a target, targets.notes holds the latest.
+CREATE TABLE IF NOT EXISTS target_notes (
+    id         BIGSERIAL PRIMARY KEY,
+    target_id  INT NOT NULL REFERENCES targets (id) ON DELETE CASCADE,
+    user_id    INT REFERENCES users (id) ON DELETE SET NULL,
+    body       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_target_notes_target ON target_notes (target_id, id);
+
+INSERT INTO target_notes (target_id, body, created_at)
+SELECT id, notes, COALESCE(created_at, NOW()) FROM targets WHERE notes <> '';
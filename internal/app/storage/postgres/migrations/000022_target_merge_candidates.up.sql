@@ -0,0 +1,14 @@
+-- Pairs of targets the duplicate detection job found likely to be the same,
+-- the target being the lower ID. Dismissed pairs are not suggested again.
+CREATE TABLE IF NOT EXISTS target_merge_candidates (
+    target_id    INT NOT NULL REFERENCES targets (id) ON DELETE CASCADE,
+    duplicate_id INT NOT NULL REFERENCES targets (id) ON DELETE CASCADE,
+    score        DOUBLE PRECISION NOT NULL,
+    reasons      TEXT[] NOT NULL DEFAULT '{}',
+    detected_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    dismissed_at TIMESTAMPTZ,
+    PRIMARY KEY (target_id, duplicate_id),
+    CHECK (target_id < duplicate_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_target_merge_candidates_duplicate ON target_merge_candidates (duplicate_id);
@@ -0,0 +1,15 @@
+-- Directed links between targets, read as "to is the <type> of from".
+CREATE TABLE IF NOT EXISTS target_relationships (
+    id         SERIAL PRIMARY KEY,
+    from_id    INT NOT NULL REFERENCES targets (id) ON DELETE CASCADE,
+    to_id      INT NOT NULL REFERENCES targets (id) ON DELETE CASCADE,
+    type       VARCHAR(20) NOT NULL CHECK (type IN ('associate', 'employer', 'employee', 'family', 'handler', 'informant')),
+    confidence DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (confidence > 0 AND confidence <= 1),
+    source     TEXT NOT NULL DEFAULT '',
+    user_id    INT REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (from_id, to_id, type),
+    CHECK (from_id <> to_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_target_relationships_to ON target_relationships (to_id);
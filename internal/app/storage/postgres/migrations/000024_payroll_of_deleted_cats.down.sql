@@ -0,0 +1,16 @@
+-- The payroll records of deleted cats are lost.
+DELETE FROM bonuses WHERE cat_id IS NULL;
+DELETE FROM salary_history WHERE cat_id IS NULL;
+
+ALTER TABLE bonuses
+    DROP CONSTRAINT IF EXISTS bonuses_cat_id_fkey,
+    ADD CONSTRAINT bonuses_cat_id_fkey FOREIGN KEY (cat_id) REFERENCES cats (id) ON DELETE CASCADE,
+    DROP COLUMN IF EXISTS cat_name,
+    ALTER COLUMN cat_id SET NOT NULL;
+
+ALTER TABLE salary_history
+    DROP CONSTRAINT IF EXISTS salary_history_cat_id_fkey,
+    ADD CONSTRAINT salary_history_cat_id_fkey FOREIGN KEY (cat_id) REFERENCES cats (id) ON DELETE CASCADE,
+    DROP COLUMN IF EXISTS cat_deleted_at,
+    DROP COLUMN IF EXISTS cat_name,
+    ALTER COLUMN cat_id SET NOT NULL;
@@ -0,0 +1,14 @@
+-- Payroll records outlive the cats. A deleted cat is known by the name it had,
+-- its salary stops on the day it was deleted.
+ALTER TABLE salary_history
+    ALTER COLUMN cat_id DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS cat_name VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS cat_deleted_at DATE,
+    DROP CONSTRAINT IF EXISTS salary_history_cat_id_fkey,
+    ADD CONSTRAINT salary_history_cat_id_fkey FOREIGN KEY (cat_id) REFERENCES cats (id) ON DELETE SET NULL;
+
+ALTER TABLE bonuses
+    ALTER COLUMN cat_id DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS cat_name VARCHAR(255),
+    DROP CONSTRAINT IF EXISTS bonuses_cat_id_fkey,
+    ADD CONSTRAINT bonuses_cat_id_fkey FOREIGN KEY (cat_id) REFERENCES cats (id) ON DELETE SET NULL;
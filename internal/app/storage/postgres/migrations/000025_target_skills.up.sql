@@ -0,0 +1,7 @@
+-- Skills a target calls for, required of the cat of every mission the target is part of.
+CREATE TABLE IF NOT EXISTS target_skills (
+    target_id INT NOT NULL REFERENCES targets (id) ON DELETE CASCADE,
+    skill_id  INT NOT NULL REFERENCES skills (id) ON DELETE CASCADE,
+    min_level SMALLINT NOT NULL CHECK (min_level BETWEEN 1 AND 5),
+    PRIMARY KEY (target_id, skill_id)
+);
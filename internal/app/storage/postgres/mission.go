@@ -5,18 +5,63 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/markraiter/spycat/internal/app/storage"
 	"github.com/markraiter/spycat/internal/domain"
 )
 
+const missionColumns = "id, COALESCE(cat_id, 0), COALESCE(notes, ''), completed, budget, bonus, currency, priority, starts_at, due_at, overdue"
+
+// missionSortColumns maps sort keys accepted by Missions to columns.
+var missionSortColumns = map[string]string{
+	"created_at": "created_at",
+	"priority":   "priority",
+	"starts_at":  "starts_at",
+	"due_at":     "due_at",
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMission(row scanner) (*domain.Mission, error) {
+	m := &domain.Mission{}
+
+	var startsAt, dueAt sql.NullTime
+	err := row.Scan(&m.ID, &m.CatID, &m.Notes, &m.Completed, &m.Budget, &m.Bonus, &m.Currency, &m.Priority, &startsAt, &dueAt, &m.Overdue)
+	if err != nil {
+		return nil, err
+	}
+
+	if startsAt.Valid {
+		m.StartsAt = &startsAt.Time
+	}
+	if dueAt.Valid {
+		m.DueAt = &dueAt.Time
+	}
+
+	return m, nil
+}
+
 func (s *Storage) SaveMission(ctx context.Context, tx *sql.Tx, mission *domain.Mission) (int, error) {
 	const op = "storage.SaveMission"
 
-	query := "INSERT INTO missions (cat_id, notes, completed) VALUES ($1, $2, $3) RETURNING id"
+	query := `INSERT INTO missions (cat_id, notes, completed, budget, bonus, currency, priority, starts_at, due_at)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
 
 	var missionID int
-	err := tx.QueryRowContext(ctx, query, mission.CatID, mission.Notes, mission.Completed).Scan(&missionID)
+	err := tx.QueryRowContext(ctx, query,
+		mission.CatID,
+		mission.Notes,
+		mission.Completed,
+		mission.Budget,
+		mission.Bonus,
+		mission.Currency,
+		mission.Priority,
+		mission.StartsAt,
+		mission.DueAt,
+	).Scan(&missionID)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
@@ -24,15 +69,54 @@ func (s *Storage) SaveMission(ctx context.Context, tx *sql.Tx, mission *domain.M
 	return missionID, nil
 }
 
-func (s *Storage) Missions(ctx context.Context) ([]*domain.Mission, error) {
+func (s *Storage) Missions(ctx context.Context, filter domain.MissionFilter) ([]*domain.Mission, error) {
 	const op = "storage.Missions"
 
-	query, err := s.PostgresDB.Prepare("SELECT id, cat_id, notes, completed FROM missions ORDER BY created_at DESC")
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	var (
+		where []string
+		args  []any
+	)
+
+	cond := func(format string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.Priority != 0 {
+		cond("priority = $%d", filter.Priority)
+	}
+	if filter.Completed != nil {
+		cond("completed = $%d", *filter.Completed)
+	}
+	if filter.Overdue != nil {
+		cond("overdue = $%d", *filter.Overdue)
+	}
+	if filter.DueBefore != nil {
+		cond("due_at < $%d", *filter.DueBefore)
+	}
+	if filter.DueAfter != nil {
+		cond("due_at >= $%d", *filter.DueAfter)
+	}
+
+	query := "SELECT " + missionColumns + " FROM missions"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	column, ok := missionSortColumns[filter.Sort]
+	if !ok {
+		column = "created_at"
 	}
 
-	rows, err := query.QueryContext(ctx)
+	// Keep the historical newest first order unless asked otherwise.
+	direction := "DESC"
+	if filter.Sort != "" && !filter.Desc {
+		direction = "ASC"
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s %s NULLS LAST, id", column, direction)
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -40,8 +124,7 @@ func (s *Storage) Missions(ctx context.Context) ([]*domain.Mission, error) {
 
 	missions := make([]*domain.Mission, 0)
 	for rows.Next() {
-		m := &domain.Mission{}
-		err = rows.Scan(&m.ID, &m.CatID, &m.Notes, &m.Completed)
+		m, err := scanMission(rows)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
@@ -55,11 +138,10 @@ func (s *Storage) Missions(ctx context.Context) ([]*domain.Mission, error) {
 func (s *Storage) MissionByID(ctx context.Context, id int) (*domain.Mission, error) {
 	const op = "storage.MissionByID"
 
-	query := "SELECT id, cat_id, notes, completed FROM missions WHERE id = $1"
+	query := "SELECT " + missionColumns + " FROM missions WHERE id = $1"
 	row := s.PostgresDB.QueryRowContext(ctx, query, id)
 
-	m := &domain.Mission{}
-	err := row.Scan(&m.ID, &m.CatID, &m.Notes, &m.Completed)
+	m, err := scanMission(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
@@ -70,111 +152,151 @@ func (s *Storage) MissionByID(ctx context.Context, id int) (*domain.Mission, err
 	return m, nil
 }
 
-func (s *Storage) AssignMissionToCat(ctx context.Context, catID, missionID int) error {
-	const op = "storage.AssignMissionToCat"
+// UpdateMissionSchedule changes priority and dates of the mission and clears
+// the overdue flag, so the scheduler re-evaluates the new due date. It sets
+// the cat of the mission.
+func (s *Storage) UpdateMissionSchedule(ctx context.Context, tx *sql.Tx, mission *domain.Mission) error {
+	const op = "storage.UpdateMissionSchedule"
 
-	tx, err := s.PostgresDB.BeginTx(ctx, nil)
+	query := `UPDATE missions SET priority = $1, starts_at = $2, due_at = $3, overdue = false WHERE id = $4
+		RETURNING COALESCE(cat_id, 0)`
+	err := tx.QueryRowContext(ctx, query, mission.Priority, mission.StartsAt, mission.DueAt, mission.ID).Scan(&mission.CatID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = s.Cat(ctx, catID)
+	return nil
+}
+
+// MarkOverdueMissions flags not completed missions past their due date
+// and returns the missions that became overdue.
+func (s *Storage) MarkOverdueMissions(ctx context.Context, tx *sql.Tx) ([]*domain.Mission, error) {
+	const op = "storage.MarkOverdueMissions"
+
+	query := `UPDATE missions SET overdue = true
+		WHERE NOT completed AND NOT overdue AND due_at < NOW()
+		RETURNING ` + missionColumns
+
+	rows, err := tx.QueryContext(ctx, query)
 	if err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
-			tx.Rollback()
-			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	missions := make([]*domain.Mission, 0)
+	for rows.Next() {
+		m, err := scanMission(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		tx.Rollback()
-		return fmt.Errorf("%s: %w", op, err)
+
+		missions = append(missions, m)
 	}
 
-	_, err = s.MissionByID(ctx, missionID)
+	return missions, nil
+}
+
+func (s *Storage) AssignMissionToCat(ctx context.Context, tx *sql.Tx, catID, missionID int) error {
+	const op = "storage.AssignMissionToCat"
+
+	_, err := s.Cat(ctx, catID)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
-			tx.Rollback()
 			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
 		}
-		tx.Rollback()
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	query := "UPDATE missions SET cat_id = $1 WHERE id = $2"
-	_, err = tx.ExecContext(ctx, query, catID, missionID)
+	result, err := tx.ExecContext(ctx, query, catID, missionID)
 	if err != nil {
-		tx.Rollback()
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = tx.Commit()
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
 	return nil
 }
 
-func (s *Storage) CompleteMission(ctx context.Context, id int) error {
+// CompleteMission marks the mission completed and returns its cat, bonus and
+// currency. It returns nil when the mission was completed already, so
+// concurrent calls see the mission completed by one of them only, and
+// storage.ErrNotFound when it does not exist.
+func (s *Storage) CompleteMission(ctx context.Context, tx *sql.Tx, id int) (*domain.Mission, error) {
 	const op = "storage.CompleteMission"
 
-	tx, err := s.PostgresDB.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
+	mission := &domain.Mission{ID: id, Completed: true}
+	query := `UPDATE missions SET completed = true WHERE id = $1 AND NOT completed
+		RETURNING COALESCE(cat_id, 0), bonus, currency`
 
-	_, err = s.MissionByID(ctx, id)
-	if err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
-			tx.Rollback()
-			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
-		}
-		tx.Rollback()
-		return fmt.Errorf("%s: %w", op, err)
+	err := tx.QueryRowContext(ctx, query, id).Scan(&mission.CatID, &mission.Bonus, &mission.Currency)
+	if err == nil {
+		return mission, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	query := "UPDATE missions SET completed = true WHERE id = $1"
-	_, err = tx.ExecContext(ctx, query, id)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("%s: %w", op, err)
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM missions WHERE id = $1)", id).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+	if !exists {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
 	}
 
-	return nil
+	return nil, nil
 }
 
-func (s *Storage) DeleteMission(ctx context.Context, id int) error {
-	const op = "storage.DeleteMission"
+func (s *Storage) UpdateMissionBudget(ctx context.Context, id int, budget, bonus int64) error {
+	const op = "storage.UpdateMissionBudget"
 
-	tx, err := s.PostgresDB.BeginTx(ctx, nil)
+	query := "UPDATE missions SET budget = $1, bonus = $2 WHERE id = $3"
+	result, err := s.PostgresDB.ExecContext(ctx, query, budget, bonus, id)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = s.MissionByID(ctx, id)
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
-			tx.Rollback()
-			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
-		}
-		tx.Rollback()
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteMission(ctx context.Context, tx *sql.Tx, id int) error {
+	const op = "storage.DeleteMission"
+
 	query := "DELETE FROM missions WHERE id = $1"
-	_, err = tx.ExecContext(ctx, query, id)
+	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
-		tx.Rollback()
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = tx.Commit()
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
 	return nil
 }
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// NotificationPreferences returns the preferences the user has set, event types
+// without a row are left out.
+func (s *Storage) NotificationPreferences(ctx context.Context, userID int) ([]domain.NotificationPreference, error) {
+	const op = "storage.NotificationPreferences"
+
+	query := "SELECT event_type, email FROM notification_preferences WHERE user_id = $1 ORDER BY event_type"
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	preferences := []domain.NotificationPreference{}
+	for rows.Next() {
+		var p domain.NotificationPreference
+		if err := rows.Scan(&p.EventType, &p.Email); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		preferences = append(preferences, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return preferences, nil
+}
+
+func (s *Storage) SaveNotificationPreferences(ctx context.Context, userID int, preferences []domain.NotificationPreference) error {
+	const op = "storage.SaveNotificationPreferences"
+
+	tx, err := s.PostgresDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `INSERT INTO notification_preferences (user_id, event_type, email) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, event_type) DO UPDATE SET email = EXCLUDED.email`
+
+	for _, p := range preferences {
+		if _, err := tx.ExecContext(ctx, query, userID, p.EventType, p.Email); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// NotificationRecipients returns the users to email about an event of the cat:
+// the admins and the handler of the cat, unless they opted out of the event type.
+func (s *Storage) NotificationRecipients(ctx context.Context, eventType domain.EventType, catID int) ([]domain.Recipient, error) {
+	const op = "storage.NotificationRecipients"
+
+	query := `SELECT u.id, u.username, u.email FROM users u
+		WHERE (u.role = 'admin' OR u.id = (SELECT handler_id FROM cats WHERE id = $2))
+		AND NOT EXISTS (
+			SELECT 1 FROM notification_preferences p
+			WHERE p.user_id = u.id AND p.event_type = $1 AND NOT p.email
+		)
+		ORDER BY u.id`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, eventType, catID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var recipients []domain.Recipient
+	for rows.Next() {
+		var r domain.Recipient
+		if err := rows.Scan(&r.UserID, &r.Username, &r.Email); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		recipients = append(recipients, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return recipients, nil
+}
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// UserByIdentity returns the user the subject of the issuer is linked to.
+func (s *Storage) UserByIdentity(ctx context.Context, issuer, subject string) (*domain.User, error) {
+	const op = "storage.UserByIdentity"
+
+	query := `SELECT u.id, u.username, u.email, u.role, u.email_verified_at, u.session_version, COALESCE(u.totp_secret, ''), u.totp_enabled_at,
+			u.disabled_at, u.password_reset_required
+		FROM user_identities i JOIN users u ON u.id = i.user_id
+		WHERE i.issuer = $1 AND i.subject = $2`
+
+	user := &domain.User{}
+	err := s.PostgresDB.QueryRowContext(ctx, query, issuer, subject).
+		Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.EmailVerifiedAt, &user.SessionVersion, &user.TOTPSecret, &user.TOTPEnabledAt,
+			&user.DisabledAt, &user.PasswordResetRequired)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// SaveOIDCUser creates a user without a password, with the email verified by the identity provider.
+func (s *Storage) SaveOIDCUser(ctx context.Context, tx *sql.Tx, user *domain.User) (int, error) {
+	const op = "storage.SaveOIDCUser"
+
+	query := `INSERT INTO users (username, email, role, email_verified_at) VALUES ($1, $2, $3, NOW())
+		RETURNING id, email_verified_at`
+
+	if err := tx.QueryRowContext(ctx, query, user.Username, user.Email, user.Role).Scan(&user.ID, &user.EmailVerifiedAt); err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAlreadyExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user.ID, nil
+}
+
+// LinkIdentity links the subject of the issuer to the user.
+func (s *Storage) LinkIdentity(ctx context.Context, tx *sql.Tx, userID int, issuer, subject string) error {
+	const op = "storage.LinkIdentity"
+
+	query := "INSERT INTO user_identities (issuer, subject, user_id) VALUES ($1, $2, $3)"
+
+	if _, err := tx.ExecContext(ctx, query, issuer, subject, userID); err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrAlreadyExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetRole changes the role of the user.
+func (s *Storage) SetRole(ctx context.Context, tx *sql.Tx, userID int, role string) error {
+	const op = "storage.SetRole"
+
+	result, err := tx.ExecContext(ctx, "UPDATE users SET role = $1 WHERE id = $2", role, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
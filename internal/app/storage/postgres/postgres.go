@@ -11,18 +11,13 @@ import (
 
 type Storage struct {
 	PostgresDB *sql.DB
+	// postgis tells whether the PostGIS extension is installed, distances are
+	// measured with it when it is.
+	postgis bool
 }
 
 func New(cfg config.Postgres) *Storage {
-	initialEntryString := fmt.Sprintf("host=%s port=%s user=%s dbname=postgres password=%s sslmode=%s",
-		cfg.Host,
-		cfg.Port,
-		cfg.User,
-		cfg.Password,
-		cfg.SSLMode,
-	)
-
-	initialDB, err := sql.Open(cfg.Driver, initialEntryString)
+	initialDB, err := sql.Open(cfg.Driver, dataSource(cfg, "postgres"))
 	if err != nil {
 		panic(err)
 	}
@@ -33,16 +28,7 @@ func New(cfg config.Postgres) *Storage {
 		panic(err)
 	}
 
-	entryString := fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=%s",
-		cfg.Host,
-		cfg.Port,
-		cfg.User,
-		cfg.Database,
-		cfg.Password,
-		cfg.SSLMode,
-	)
-
-	db, err := sql.Open(cfg.Driver, entryString)
+	db, err := sql.Open(cfg.Driver, dataSource(cfg, cfg.Database))
 	if err != nil {
 		panic(err)
 	}
@@ -52,7 +38,23 @@ func New(cfg config.Postgres) *Storage {
 		return nil
 	}
 
-	return &Storage{PostgresDB: db}
+	// Without the extension, or when it cannot be told, distances are measured
+	// in plain SQL.
+	var postgis bool
+	_ = db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'postgis')").Scan(&postgis)
+
+	return &Storage{PostgresDB: db, postgis: postgis}
+}
+
+func dataSource(cfg config.Postgres, database string) string {
+	return fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=%s",
+		cfg.Host,
+		cfg.Port,
+		cfg.User,
+		database,
+		cfg.Password,
+		cfg.SSLMode,
+	)
 }
 
 func (s *Storage) BeginTx(ctx context.Context) (*sql.Tx, error) {
@@ -63,6 +65,17 @@ func (s *Storage) BeginTx(ctx context.Context) (*sql.Tx, error) {
 	return tx, nil
 }
 
+// TryAdvisoryLock takes a transaction level advisory lock if it is free.
+// The lock is released when the transaction ends.
+func (s *Storage) TryAdvisoryLock(ctx context.Context, tx *sql.Tx, key int64) (bool, error) {
+	var locked bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", key).Scan(&locked); err != nil {
+		return false, err
+	}
+
+	return locked, nil
+}
+
 func (s *Storage) Close() {
 	s.PostgresDB.Close()
 }
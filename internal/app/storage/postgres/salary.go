@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+func (s *Storage) SaveSalary(ctx context.Context, tx *sql.Tx, salary *domain.Salary) (int, error) {
+	const op = "storage.SaveSalary"
+
+	query := `INSERT INTO salary_history (cat_id, amount, currency, effective_from) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (cat_id, effective_from) DO UPDATE SET amount = EXCLUDED.amount, currency = EXCLUDED.currency
+		RETURNING id`
+
+	err := tx.QueryRowContext(ctx, query, salary.CatID, salary.Amount, salary.Currency, salary.EffectiveFrom).Scan(&salary.ID)
+	if err != nil {
+		var pgErr *pq.Error
+
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return salary.ID, nil
+}
+
+func (s *Storage) Salaries(ctx context.Context, catID int) ([]*domain.Salary, error) {
+	const op = "storage.Salaries"
+
+	query := "SELECT id, cat_id, amount, currency, effective_from FROM salary_history WHERE cat_id = $1 ORDER BY effective_from"
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, catID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	salaries := make([]*domain.Salary, 0)
+	for rows.Next() {
+		sal := &domain.Salary{}
+		if err := rows.Scan(&sal.ID, &sal.CatID, &sal.Amount, &sal.Currency, &sal.EffectiveFrom); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		salaries = append(salaries, sal)
+	}
+
+	return salaries, nil
+}
+
+// SalaryHistory returns salary records of all cats, deleted ones included, effective
+// on or before the given date, ordered by cat and effective date.
+func (s *Storage) SalaryHistory(ctx context.Context, to time.Time) ([]*domain.Salary, error) {
+	const op = "storage.SalaryHistory"
+
+	query := `SELECT id, COALESCE(cat_id, 0), amount, currency, effective_from, COALESCE(cat_name, ''), cat_deleted_at
+		FROM salary_history WHERE effective_from <= $1 ORDER BY cat_id, cat_name, effective_from`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, to)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	salaries := make([]*domain.Salary, 0)
+	for rows.Next() {
+		sal := &domain.Salary{}
+		if err := rows.Scan(&sal.ID, &sal.CatID, &sal.Amount, &sal.Currency, &sal.EffectiveFrom, &sal.CatName, &sal.CatDeletedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		salaries = append(salaries, sal)
+	}
+
+	return salaries, nil
+}
+
+// Bonuses returns bonuses awarded in the half-open interval [from, to).
+func (s *Storage) Bonuses(ctx context.Context, from, to time.Time) ([]*domain.Bonus, error) {
+	const op = "storage.Bonuses"
+
+	query := `SELECT id, COALESCE(cat_id, 0), COALESCE(mission_id, 0), amount, currency, COALESCE(reason, ''), awarded_at,
+			COALESCE(cat_name, '')
+		FROM bonuses WHERE awarded_at >= $1 AND awarded_at < $2 ORDER BY awarded_at`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	bonuses := make([]*domain.Bonus, 0)
+	for rows.Next() {
+		b := &domain.Bonus{}
+		if err := rows.Scan(&b.ID, &b.CatID, &b.MissionID, &b.Amount, &b.Currency, &b.Reason, &b.AwardedAt, &b.CatName); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		bonuses = append(bonuses, b)
+	}
+
+	return bonuses, nil
+}
+
+func (s *Storage) SaveBonus(ctx context.Context, tx *sql.Tx, bonus *domain.Bonus) (int, error) {
+	const op = "storage.SaveBonus"
+
+	query := `INSERT INTO bonuses (cat_id, mission_id, amount, currency, reason)
+		VALUES ($1, NULLIF($2, 0), $3, $4, $5) RETURNING id, awarded_at`
+
+	err := tx.QueryRowContext(ctx, query,
+		bonus.CatID,
+		bonus.MissionID,
+		bonus.Amount,
+		bonus.Currency,
+		bonus.Reason,
+	).Scan(&bonus.ID, &bonus.AwardedAt)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return bonus.ID, nil
+}
+
+func (s *Storage) MissionBonuses(ctx context.Context, missionID int) ([]*domain.Bonus, error) {
+	const op = "storage.MissionBonuses"
+
+	query := `SELECT id, COALESCE(cat_id, 0), COALESCE(mission_id, 0), amount, currency, COALESCE(reason, ''), awarded_at
+		FROM bonuses WHERE mission_id = $1 ORDER BY awarded_at`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, missionID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	bonuses := make([]*domain.Bonus, 0)
+	for rows.Next() {
+		b := &domain.Bonus{}
+		if err := rows.Scan(&b.ID, &b.CatID, &b.MissionID, &b.Amount, &b.Currency, &b.Reason, &b.AwardedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		bonuses = append(bonuses, b)
+	}
+
+	return bonuses, nil
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+func (s *Storage) SaveSession(ctx context.Context, session *domain.Session) (int64, error) {
+	const op = "storage.SaveSession"
+
+	query := `INSERT INTO sessions (user_id, user_agent, ip, expires_at) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, last_seen_at`
+
+	err := s.PostgresDB.QueryRowContext(ctx, query, session.UserID, session.UserAgent, session.IP, session.ExpiresAt).
+		Scan(&session.ID, &session.CreatedAt, &session.LastSeenAt)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session.ID, nil
+}
+
+// Sessions returns the sessions of the user neither revoked nor expired, the last seen first.
+func (s *Storage) Sessions(ctx context.Context, userID int) ([]*domain.Session, error) {
+	const op = "storage.Sessions"
+
+	query := `SELECT id, user_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_seen_at DESC, id DESC`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	sessions := make([]*domain.Session, 0)
+	for rows.Next() {
+		session := &domain.Session{}
+		err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP,
+			&session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.RevokedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// TouchSession records the session was used, at most once a minute to spare the writes.
+func (s *Storage) TouchSession(ctx context.Context, id int64) error {
+	const op = "storage.TouchSession"
+
+	query := "UPDATE sessions SET last_seen_at = NOW() WHERE id = $1 AND last_seen_at < NOW() - INTERVAL '1 minute'"
+
+	if _, err := s.PostgresDB.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeSession revokes the session of the user, storage.ErrNotFound if there is no such session in use.
+func (s *Storage) RevokeSession(ctx context.Context, userID int, id int64) error {
+	const op = "storage.RevokeSession"
+
+	query := "UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()"
+
+	result, err := s.PostgresDB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// RevokeSessions revokes every session of the user and returns how many there were.
+func (s *Storage) RevokeSessions(ctx context.Context, userID int) (int, error) {
+	const op = "storage.RevokeSessions"
+
+	query := "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()"
+
+	result, err := s.PostgresDB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(rowsAffected), nil
+}
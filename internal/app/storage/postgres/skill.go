@@ -0,0 +1,263 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+func (s *Storage) SaveSkill(ctx context.Context, skill *domain.Skill) (int, error) {
+	const op = "storage.SaveSkill"
+
+	query := "INSERT INTO skills (name, description) VALUES ($1, $2) RETURNING id"
+	err := s.PostgresDB.QueryRowContext(ctx, query, skill.Name, skill.Description).Scan(&skill.ID)
+	if err != nil {
+		var pgErr *pq.Error
+
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAlreadyExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return skill.ID, nil
+}
+
+func (s *Storage) Skills(ctx context.Context) ([]*domain.Skill, error) {
+	const op = "storage.Skills"
+
+	rows, err := s.PostgresDB.QueryContext(ctx, "SELECT id, name, COALESCE(description, '') FROM skills ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	skills := make([]*domain.Skill, 0)
+	for rows.Next() {
+		skill := &domain.Skill{}
+		if err := rows.Scan(&skill.ID, &skill.Name, &skill.Description); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		skills = append(skills, skill)
+	}
+
+	return skills, nil
+}
+
+func (s *Storage) SetCatSkill(ctx context.Context, catID, skillID, level int) error {
+	const op = "storage.SetCatSkill"
+
+	query := `INSERT INTO cat_skills (cat_id, skill_id, level) VALUES ($1, $2, $3)
+		ON CONFLICT (cat_id, skill_id) DO UPDATE SET level = EXCLUDED.level`
+
+	if _, err := s.PostgresDB.ExecContext(ctx, query, catID, skillID, level); err != nil {
+		var pgErr *pq.Error
+
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteCatSkill(ctx context.Context, catID, skillID int) error {
+	const op = "storage.DeleteCatSkill"
+
+	result, err := s.PostgresDB.ExecContext(ctx, "DELETE FROM cat_skills WHERE cat_id = $1 AND skill_id = $2", catID, skillID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) CatSkills(ctx context.Context, catID int) ([]domain.CatSkill, error) {
+	const op = "storage.CatSkills"
+
+	query := `SELECT s.id, s.name, cs.level FROM cat_skills cs
+		JOIN skills s ON s.id = cs.skill_id
+		WHERE cs.cat_id = $1 ORDER BY s.name`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, catID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	skills := make([]domain.CatSkill, 0)
+	for rows.Next() {
+		var skill domain.CatSkill
+		if err := rows.Scan(&skill.SkillID, &skill.Name, &skill.Level); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		skills = append(skills, skill)
+	}
+
+	return skills, nil
+}
+
+func (s *Storage) SetMissionSkill(ctx context.Context, missionID, skillID, minLevel int) error {
+	const op = "storage.SetMissionSkill"
+
+	query := `INSERT INTO mission_skills (mission_id, skill_id, min_level) VALUES ($1, $2, $3)
+		ON CONFLICT (mission_id, skill_id) DO UPDATE SET min_level = EXCLUDED.min_level`
+
+	if _, err := s.PostgresDB.ExecContext(ctx, query, missionID, skillID, minLevel); err != nil {
+		var pgErr *pq.Error
+
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteMissionSkill(ctx context.Context, missionID, skillID int) error {
+	const op = "storage.DeleteMissionSkill"
+
+	result, err := s.PostgresDB.ExecContext(ctx, "DELETE FROM mission_skills WHERE mission_id = $1 AND skill_id = $2", missionID, skillID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) MissionSkills(ctx context.Context, missionID int) ([]domain.RequiredSkill, error) {
+	const op = "storage.MissionSkills"
+
+	query := `SELECT s.id, s.name, ms.min_level FROM mission_skills ms
+		JOIN skills s ON s.id = ms.skill_id
+		WHERE ms.mission_id = $1 ORDER BY s.name`
+
+	skills, err := s.requiredSkills(ctx, query, missionID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return skills, nil
+}
+
+func (s *Storage) SetTargetSkill(ctx context.Context, targetID, skillID, minLevel int) error {
+	const op = "storage.SetTargetSkill"
+
+	query := `INSERT INTO target_skills (target_id, skill_id, min_level) VALUES ($1, $2, $3)
+		ON CONFLICT (target_id, skill_id) DO UPDATE SET min_level = EXCLUDED.min_level`
+
+	if _, err := s.PostgresDB.ExecContext(ctx, query, targetID, skillID, minLevel); err != nil {
+		var pgErr *pq.Error
+
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteTargetSkill(ctx context.Context, targetID, skillID int) error {
+	const op = "storage.DeleteTargetSkill"
+
+	result, err := s.PostgresDB.ExecContext(ctx, "DELETE FROM target_skills WHERE target_id = $1 AND skill_id = $2", targetID, skillID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) TargetSkills(ctx context.Context, targetID int) ([]domain.RequiredSkill, error) {
+	const op = "storage.TargetSkills"
+
+	query := `SELECT s.id, s.name, ts.min_level FROM target_skills ts
+		JOIN skills s ON s.id = ts.skill_id
+		WHERE ts.target_id = $1 ORDER BY s.name`
+
+	skills, err := s.requiredSkills(ctx, query, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return skills, nil
+}
+
+// MissionTargetSkills returns the skills the targets of the mission not completed
+// in it call for, the highest level when several targets call for a skill.
+func (s *Storage) MissionTargetSkills(ctx context.Context, missionID int) ([]domain.RequiredSkill, error) {
+	const op = "storage.MissionTargetSkills"
+
+	query := `SELECT s.id, s.name, MAX(ts.min_level) FROM mission_targets mt
+		JOIN target_skills ts ON ts.target_id = mt.target_id
+		JOIN skills s ON s.id = ts.skill_id
+		WHERE mt.mission_id = $1 AND NOT mt.completed
+		GROUP BY s.id, s.name ORDER BY s.name`
+
+	skills, err := s.requiredSkills(ctx, query, missionID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return skills, nil
+}
+
+func (s *Storage) requiredSkills(ctx context.Context, query string, id int) ([]domain.RequiredSkill, error) {
+	rows, err := s.PostgresDB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skills := make([]domain.RequiredSkill, 0)
+	for rows.Next() {
+		var skill domain.RequiredSkill
+		if err := rows.Scan(&skill.SkillID, &skill.Name, &skill.MinLevel); err != nil {
+			return nil, err
+		}
+
+		skills = append(skills, skill)
+	}
+
+	return skills, rows.Err()
+}
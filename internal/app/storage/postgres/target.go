@@ -6,31 +6,99 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/lib/pq"
 	"github.com/markraiter/spycat/internal/app/storage"
 	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/geo"
 )
 
+const targetColumns = "t.id, t.name, t.country, t.city, t.latitude, t.longitude, t.notes"
+
+func scanTarget(row interface{ Scan(...any) error }, t *domain.Target, extra ...any) error {
+	return row.Scan(append([]any{&t.ID, &t.Name, &t.Country, &t.City, &t.Latitude, &t.Longitude, &t.Notes}, extra...)...)
+}
+
+// SaveTarget registers the target and sets its ID.
 func (s *Storage) SaveTarget(ctx context.Context, tx *sql.Tx, target *domain.Target) error {
 	const op = "storage.SaveTarget"
-	query := "INSERT INTO targets (mission_id, name, country, notes, completed) VALUES ($1, $2, $3, $4, $5)"
 
-	_, err := tx.ExecContext(ctx, query, target.MissionID, target.Name, target.Country, target.Notes, target.Completed)
+	query := `INSERT INTO targets (name, country, city, latitude, longitude, notes)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+
+	err := tx.QueryRowContext(ctx, query, target.Name, target.Country, target.City,
+		target.Latitude, target.Longitude, target.Notes).Scan(&target.ID)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if target.Notes != "" {
+		query = "INSERT INTO target_notes (target_id, body) VALUES ($1, $2)"
+		if _, err := tx.ExecContext(ctx, query, target.ID, target.Notes); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// LinkTarget makes the registered target part of the mission. It returns
+// storage.ErrNotFound when either does not exist and storage.ErrAlreadyExists
+// when the target is part of the mission already.
+func (s *Storage) LinkTarget(ctx context.Context, tx *sql.Tx, missionID, targetID int) error {
+	const op = "storage.LinkTarget"
+
+	query := "INSERT INTO mission_targets (mission_id, target_id) VALUES ($1, $2)"
+	if _, err := tx.ExecContext(ctx, query, missionID, targetID); err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrAlreadyExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
+// Targets returns the registered targets, newest first.
 func (s *Storage) Targets(ctx context.Context) ([]*domain.Target, error) {
 	const op = "storage.Targets"
 
-	query, err := s.PostgresDB.Prepare("SELECT id, mission_id, name, country, notes, completed FROM targets ORDER BY created_at DESC")
+	rows, err := s.PostgresDB.QueryContext(ctx, "SELECT "+targetColumns+" FROM targets t ORDER BY t.created_at DESC, t.id DESC")
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
+
+	targets := make([]*domain.Target, 0)
+	for rows.Next() {
+		t := &domain.Target{}
+		if err := scanTarget(rows, t); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		targets = append(targets, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
-	rows, err := query.QueryContext(ctx)
+	return targets, nil
+}
+
+// MissionTargets returns the targets of every mission, once for each mission a
+// target is part of, with the mission ID and the completion in the mission set.
+func (s *Storage) MissionTargets(ctx context.Context) ([]*domain.Target, error) {
+	const op = "storage.MissionTargets"
+
+	query := `SELECT ` + targetColumns + `, mt.mission_id, mt.completed
+		FROM mission_targets mt JOIN targets t ON t.id = mt.target_id
+		ORDER BY mt.added_at DESC, t.id DESC`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -39,46 +107,115 @@ func (s *Storage) Targets(ctx context.Context) ([]*domain.Target, error) {
 	targets := make([]*domain.Target, 0)
 	for rows.Next() {
 		t := &domain.Target{}
-		err = rows.Scan(&t.ID, &t.MissionID, &t.Name, &t.Country, &t.Notes, &t.Completed)
-		if err != nil {
+		if err := scanTarget(rows, t, &t.MissionID, &t.Completed); err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 
 		targets = append(targets, t)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return targets, nil
 }
 
-func (s *Storage) TargetCompleted(ctx context.Context, id int) error {
+// TargetsNear returns the located targets nearest to the point first, those
+// within radiusKm of it when radiusKm is above zero. Distances are measured on
+// the WGS 84 spheroid with PostGIS when the extension is installed and with the
+// haversine formula otherwise.
+func (s *Storage) TargetsNear(ctx context.Context, point domain.GeoPoint, radiusKm float64) ([]*domain.Target, error) {
+	const op = "storage.TargetsNear"
+
+	var distance string
+	if s.postgis {
+		distance = `ST_Distance(ST_MakePoint(longitude, latitude)::geography,
+			ST_MakePoint($2::float8, $1::float8)::geography) / 1000`
+	} else {
+		distance = fmt.Sprintf(`2 * %[1]g * ASIN(LEAST(1, SQRT(
+			POWER(SIN(RADIANS(latitude - $1::float8) / 2), 2) +
+			COS(RADIANS($1::float8)) * COS(RADIANS(latitude)) * POWER(SIN(RADIANS(longitude - $2::float8) / 2), 2))))`,
+			geo.EarthRadiusKm)
+	}
+
+	// No target further in latitude than the radius is within it, the band
+	// lets the latitude index narrow the targets down before measuring them.
+	query := `SELECT * FROM (
+			SELECT ` + targetColumns + `, ` + distance + ` AS distance FROM targets t
+			WHERE latitude IS NOT NULL
+				AND ($3::float8 <= 0 OR latitude BETWEEN $1::float8 - $4::float8 AND $1::float8 + $4::float8)
+		) n
+		WHERE $3::float8 <= 0 OR distance <= $3::float8
+		ORDER BY distance, id`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, point.Latitude, point.Longitude, radiusKm, radiusKm/geo.KmPerDegree)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	targets := make([]*domain.Target, 0)
+	for rows.Next() {
+		t := &domain.Target{}
+		if err := scanTarget(rows, t, &t.DistanceKm); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		targets = append(targets, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return targets, nil
+}
+
+// TargetCompleted completes the target in the mission and tells whether it
+// was not completed in it yet, so concurrent calls see the target completed
+// by one of them only. It returns storage.ErrNotFound when the target is not
+// part of the mission.
+func (s *Storage) TargetCompleted(ctx context.Context, tx *sql.Tx, missionID, targetID int) (bool, error) {
 	const op = "storage.TargetCompleted"
 
-	query := "UPDATE targets SET completed = true WHERE id = $1"
-	result, err := s.PostgresDB.ExecContext(ctx, query, id)
+	query := `UPDATE mission_targets SET completed = true, completed_at = NOW()
+		WHERE mission_id = $1 AND target_id = $2 AND NOT completed`
+	result, err := tx.ExecContext(ctx, query, missionID, targetID)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	if rowsAffected > 0 {
+		return true, nil
 	}
 
-	return nil
+	var exists bool
+	query = "SELECT EXISTS (SELECT 1 FROM mission_targets WHERE mission_id = $1 AND target_id = $2)"
+	if err := tx.QueryRowContext(ctx, query, missionID, targetID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !exists {
+		return false, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return false, nil
 }
 
 func (s *Storage) TargetByID(ctx context.Context, id int) (*domain.Target, error) {
 	const op = "storage.TargetByID"
 
-	query := "SELECT id, mission_id, name, country, notes, completed FROM targets WHERE id = $1"
+	query := "SELECT " + targetColumns + " FROM targets t WHERE t.id = $1"
 	row := s.PostgresDB.QueryRowContext(ctx, query, id)
 
 	t := &domain.Target{}
-	err := row.Scan(&t.ID, &t.MissionID, &t.Name, &t.Country, &t.Notes, &t.Completed)
+	err := scanTarget(row, t)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
@@ -89,6 +226,40 @@ func (s *Storage) TargetByID(ctx context.Context, id int) (*domain.Target, error
 	return t, nil
 }
 
+// TargetMissions returns the missions the target is part of, newest first.
+func (s *Storage) TargetMissions(ctx context.Context, targetID int) ([]*domain.TargetMission, error) {
+	const op = "storage.TargetMissions"
+
+	query := `SELECT mt.mission_id, COALESCE(m.cat_id, 0), mt.completed, mt.completed_at, m.completed, mt.added_at
+		FROM mission_targets mt JOIN missions m ON m.id = mt.mission_id
+		WHERE mt.target_id = $1
+		ORDER BY mt.added_at DESC, mt.mission_id DESC`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	missions := make([]*domain.TargetMission, 0)
+	for rows.Next() {
+		m := &domain.TargetMission{}
+		if err := rows.Scan(&m.MissionID, &m.CatID, &m.Completed, &m.CompletedAt, &m.MissionCompleted, &m.AddedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		missions = append(missions, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return missions, nil
+}
+
+// AddTargetToMission makes the target part of the mission as well, adding a
+// target the mission has already changes nothing.
 func (s *Storage) AddTargetToMission(ctx context.Context, missionID, targetID int) error {
 	const op = "storage.AddTargetToMission"
 
@@ -122,7 +293,7 @@ func (s *Storage) AddTargetToMission(ctx context.Context, missionID, targetID in
 		return fmt.Errorf("%s: %w", op, storage.ErrMissionCompleted)
 	}
 
-	query := "UPDATE targets SET mission_id = $1 WHERE id = $2"
+	query := "INSERT INTO mission_targets (mission_id, target_id) VALUES ($1, $2) ON CONFLICT DO NOTHING"
 	_, err = tx.ExecContext(ctx, query, missionID, targetID)
 	if err != nil {
 		tx.Rollback()
@@ -136,3 +307,61 @@ func (s *Storage) AddTargetToMission(ctx context.Context, missionID, targetID in
 
 	return nil
 }
+
+// RemoveTargetFromMission unlinks the target from the mission, the target stays
+// in the registry and part of its other missions. It returns
+// storage.ErrMissionCompleted for a completed mission, storage.ErrTargetCompleted
+// for a target completed in the mission and storage.ErrNotFound when the target
+// is not part of the mission.
+func (s *Storage) RemoveTargetFromMission(ctx context.Context, missionID, targetID int) error {
+	const op = "storage.RemoveTargetFromMission"
+
+	tx, err := s.PostgresDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The mission row is locked, so it is not completed meanwhile.
+	var missionCompleted bool
+	err = tx.QueryRowContext(ctx, "SELECT completed FROM missions WHERE id = $1 FOR UPDATE", missionID).Scan(&missionCompleted)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if missionCompleted {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, storage.ErrMissionCompleted)
+	}
+
+	var targetCompleted bool
+	query := "SELECT completed FROM mission_targets WHERE mission_id = $1 AND target_id = $2 FOR UPDATE"
+	err = tx.QueryRowContext(ctx, query, missionID, targetID).Scan(&targetCompleted)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if targetCompleted {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, storage.ErrTargetCompleted)
+	}
+
+	query = "DELETE FROM mission_targets WHERE mission_id = $1 AND target_id = $2"
+	if _, err := tx.ExecContext(ctx, query, missionID, targetID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+const profileColumns = `id, username, email, role, email_verified_at IS NOT NULL, totp_enabled_at IS NOT NULL,
+	COALESCE(password, '') <> '', password_reset_required, COALESCE(created_at, NOW()), disabled_at`
+
+func scanProfile(row interface{ Scan(...any) error }) (*domain.Profile, error) {
+	p := &domain.Profile{}
+	err := row.Scan(&p.ID, &p.Username, &p.Email, &p.Role, &p.EmailVerified, &p.MFAEnabled,
+		&p.HasPassword, &p.PasswordResetRequired, &p.CreatedAt, &p.DisabledAt)
+
+	return p, err
+}
+
+func (s *Storage) Profile(ctx context.Context, userID int) (*domain.Profile, error) {
+	const op = "storage.Profile"
+
+	query := "SELECT " + profileColumns + " FROM users WHERE id = $1"
+
+	profile, err := scanProfile(s.PostgresDB.QueryRowContext(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return profile, nil
+}
+
+// Users returns a page of the users whose username or email contains the query, by ID.
+func (s *Storage) Users(ctx context.Context, filter *domain.UserFilter) ([]*domain.Profile, error) {
+	const op = "storage.Users"
+
+	query := "SELECT " + profileColumns + ` FROM users
+		WHERE $1 = '' OR username ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%'
+		ORDER BY id LIMIT $2 OFFSET $3`
+
+	// The query is matched literally.
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(filter.Query)
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, pattern, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	profiles := make([]*domain.Profile, 0)
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return profiles, nil
+}
+
+// UpdateProfile sets the username and the email of the user. A new email is
+// not verified and the reset tokens sent to the former one stop working.
+func (s *Storage) UpdateProfile(ctx context.Context, tx *sql.Tx, user *domain.User) error {
+	const op = "storage.UpdateProfile"
+
+	query := `UPDATE users u SET username = $1, email = $2,
+			email_verified_at = CASE WHEN old.email = $2 THEN u.email_verified_at END
+		FROM (SELECT email FROM users WHERE id = $3) old
+		WHERE u.id = $3
+		RETURNING old.email <> u.email`
+
+	var emailChanged bool
+	if err := tx.QueryRowContext(ctx, query, user.Username, user.Email, user.ID).Scan(&emailChanged); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrAlreadyExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !emailChanged {
+		return nil
+	}
+
+	query = "UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL"
+
+	if _, err := tx.ExecContext(ctx, query, user.ID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ChangePassword sets the password of the user and revokes every session of
+// the user but keepSessionID, and every reset token.
+func (s *Storage) ChangePassword(ctx context.Context, tx *sql.Tx, userID int, password string, keepSessionID int64) error {
+	const op = "storage.ChangePassword"
+
+	query := "UPDATE users SET password = $1, password_reset_required = false WHERE id = $2"
+
+	if _, err := tx.ExecContext(ctx, query, password, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = "UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL"
+
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL"
+
+	if _, err := tx.ExecContext(ctx, query, userID, keepSessionID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteUser deletes the user with its sessions, API keys, identities and
+// preferences. Chat messages and audit entries are kept without their author
+// and the cats the user handled are left without a handler.
+func (s *Storage) DeleteUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	const op = "storage.DeleteUser"
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// ActiveAdmins counts the admins who are not disabled, locking them until tx ends
+// for two admins not to remove each other at once.
+func (s *Storage) ActiveAdmins(ctx context.Context, tx *sql.Tx) (int, error) {
+	const op = "storage.ActiveAdmins"
+
+	query := "SELECT id FROM users WHERE role = $1 AND disabled_at IS NULL FOR UPDATE"
+
+	rows, err := tx.QueryContext(ctx, query, domain.RoleAdmin)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		n++
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// SetDisabled disables or enables the user. Disabling signs the user out everywhere.
+func (s *Storage) SetDisabled(ctx context.Context, tx *sql.Tx, userID int, disabled bool) error {
+	const op = "storage.SetDisabled"
+
+	query := "UPDATE users SET disabled_at = NULL WHERE id = $1"
+	if disabled {
+		query = `UPDATE users SET disabled_at = COALESCE(disabled_at, NOW()), session_version = session_version + 1
+			WHERE id = $1`
+	}
+
+	result, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	if !disabled {
+		return nil
+	}
+
+	query = "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL"
+
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RequirePasswordReset refuses the password of the user until it is reset and
+// signs the user out everywhere.
+func (s *Storage) RequirePasswordReset(ctx context.Context, tx *sql.Tx, userID int) error {
+	const op = "storage.RequirePasswordReset"
+
+	query := "UPDATE users SET password_reset_required = true, session_version = session_version + 1 WHERE id = $1"
+
+	result, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	query = "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL"
+
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
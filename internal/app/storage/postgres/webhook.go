@@ -0,0 +1,279 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/app/storage"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+const deliveryColumns = `d.id, d.subscription_id, d.event_id, e.type, d.status, d.attempts, d.next_attempt_at,
+	COALESCE(d.response_code, 0), COALESCE(d.last_error, ''), d.delivered_at, d.created_at`
+
+func (s *Storage) SaveWebhook(ctx context.Context, webhook *domain.Webhook) (int, error) {
+	const op = "storage.SaveWebhook"
+
+	query := `INSERT INTO webhook_subscriptions (url, event_types, secret, active)
+		VALUES ($1, $2, $3, $4) RETURNING id`
+
+	var id int
+	err := s.PostgresDB.QueryRowContext(ctx, query, webhook.URL, pq.Array(eventTypes(webhook.EventTypes)), webhook.Secret, webhook.Active).
+		Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) Webhooks(ctx context.Context) ([]*domain.Webhook, error) {
+	const op = "storage.Webhooks"
+
+	query := "SELECT id, url, event_types, secret, active, created_at FROM webhook_subscriptions ORDER BY id"
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	webhooks := make([]*domain.Webhook, 0)
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, nil
+}
+
+func (s *Storage) Webhook(ctx context.Context, id int) (*domain.Webhook, error) {
+	const op = "storage.Webhook"
+
+	query := "SELECT id, url, event_types, secret, active, created_at FROM webhook_subscriptions WHERE id = $1"
+
+	w, err := scanWebhook(s.PostgresDB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return w, nil
+}
+
+func (s *Storage) UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	const op = "storage.UpdateWebhook"
+
+	query := "UPDATE webhook_subscriptions SET url = $1, event_types = $2, secret = $3, active = $4 WHERE id = $5"
+
+	result, err := s.PostgresDB.ExecContext(ctx, query,
+		webhook.URL, pq.Array(eventTypes(webhook.EventTypes)), webhook.Secret, webhook.Active, webhook.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteWebhook(ctx context.Context, id int) error {
+	const op = "storage.DeleteWebhook"
+
+	result, err := s.PostgresDB.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) WebhookDeliveries(ctx context.Context, webhookID int) ([]*domain.WebhookDelivery, error) {
+	const op = "storage.WebhookDeliveries"
+
+	query := `SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries d JOIN domain_events e ON e.id = d.event_id
+		WHERE d.subscription_id = $1 ORDER BY d.created_at DESC, d.id DESC`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*domain.WebhookDelivery, 0)
+	for rows.Next() {
+		d := &domain.WebhookDelivery{}
+		if err := scanDelivery(rows, d); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver queues a new delivery of the same event to the same webhook,
+// the original delivery is kept in the log untouched.
+func (s *Storage) Redeliver(ctx context.Context, deliveryID int64) (int64, error) {
+	const op = "storage.Redeliver"
+
+	query := `INSERT INTO webhook_deliveries (subscription_id, event_id)
+		SELECT subscription_id, event_id FROM webhook_deliveries WHERE id = $1 RETURNING id`
+
+	var id int64
+	if err := s.PostgresDB.QueryRowContext(ctx, query, deliveryID).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// ClaimDeliveries takes up to limit due deliveries of active webhooks out of the outbox.
+// Claimed deliveries are leased by moving their next attempt forward, so other
+// replicas skip them until the lease runs out.
+func (s *Storage) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxDelivery, error) {
+	const op = "storage.ClaimDeliveries"
+
+	query := `WITH claimed AS (
+			UPDATE webhook_deliveries SET next_attempt_at = NOW() + make_interval(secs => $2)
+			WHERE id IN (
+				SELECT d.id FROM webhook_deliveries d
+				JOIN webhook_subscriptions s ON s.id = d.subscription_id
+				WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND s.active
+				ORDER BY d.next_attempt_at, d.id LIMIT $1
+				FOR UPDATE OF d SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT ` + deliveryColumns + `, s.url, s.secret,
+			COALESCE(e.mission_id, 0), COALESCE(e.cat_id, 0), COALESCE(e.target_id, 0), COALESCE(e.payload, '{}'), e.created_at
+		FROM claimed d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		JOIN domain_events e ON e.id = d.event_id
+		ORDER BY d.id`
+
+	rows, err := s.PostgresDB.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*domain.OutboxDelivery, 0)
+	for rows.Next() {
+		d := &domain.OutboxDelivery{}
+
+		var payload []byte
+		err := scanDelivery(rows, &d.WebhookDelivery,
+			&d.URL, &d.Secret, &d.Event.MissionID, &d.Event.CatID, &d.Event.TargetID, &payload, &d.Event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := json.Unmarshal(payload, &d.Event.Payload); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		d.Event.ID = d.EventID
+		d.Event.Type = d.EventType
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+func (s *Storage) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	const op = "storage.UpdateDelivery"
+
+	query := `UPDATE webhook_deliveries SET status = $1, attempts = $2, next_attempt_at = $3,
+		response_code = NULLIF($4, 0), last_error = NULLIF($5, ''), delivered_at = $6 WHERE id = $7`
+
+	_, err := s.PostgresDB.ExecContext(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.ResponseCode,
+		delivery.LastError,
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func scanWebhook(row scanner) (*domain.Webhook, error) {
+	w := &domain.Webhook{}
+
+	var types []string
+	if err := row.Scan(&w.ID, &w.URL, pq.Array(&types), &w.Secret, &w.Active, &w.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	for _, t := range types {
+		w.EventTypes = append(w.EventTypes, domain.EventType(t))
+	}
+
+	return w, nil
+}
+
+func scanDelivery(row scanner, d *domain.WebhookDelivery, extra ...any) error {
+	dest := []any{
+		&d.ID,
+		&d.WebhookID,
+		&d.EventID,
+		&d.EventType,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.ResponseCode,
+		&d.LastError,
+		&d.DeliveredAt,
+		&d.CreatedAt,
+	}
+
+	return row.Scan(append(dest, extra...)...)
+}
+
+func eventTypes(types []domain.EventType) []string {
+	s := make([]string, 0, len(types))
+	for _, t := range types {
+		s = append(s, string(t))
+	}
+
+	return s
+}
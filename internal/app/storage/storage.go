@@ -6,4 +6,5 @@ var (
 	ErrAlreadyExists    = errors.New("already exists")
 	ErrNotFound         = errors.New("not found")
 	ErrMissionCompleted = errors.New("this mission completed")
+	ErrTargetCompleted  = errors.New("this target completed")
 )
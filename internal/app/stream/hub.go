@@ -0,0 +1,131 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind
+// before it is dropped.
+const subscriberBuffer = 64
+
+// Hub fans domain events out to live subscribers and keeps the most recent
+// of them in a bounded buffer, so reconnecting clients can resume.
+type Hub struct {
+	mu     sync.Mutex
+	size   int
+	buffer []*domain.Event
+	seen   map[int64]struct{}
+	subs   map[chan *domain.Event]struct{}
+	closed bool
+}
+
+func NewHub(size int) *Hub {
+	return &Hub{
+		size: size,
+		seen: make(map[int64]struct{}, size),
+		subs: make(map[chan *domain.Event]struct{}),
+	}
+}
+
+// Publish buffers the events and sends them to every subscriber. Events already
+// in the buffer are skipped. A subscriber that is too slow to keep up is dropped,
+// its channel is closed so the client reconnects and resumes from the buffer.
+func (h *Hub) Publish(events ...*domain.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	for _, e := range events {
+		if _, ok := h.seen[e.ID]; ok {
+			continue
+		}
+
+		h.buffer = append(h.buffer, e)
+		h.seen[e.ID] = struct{}{}
+
+		if len(h.buffer) > h.size {
+			delete(h.seen, h.buffer[0].ID)
+			h.buffer = append(h.buffer[:0], h.buffer[1:]...)
+		}
+
+		for ch := range h.subs {
+			select {
+			case ch <- e:
+			default:
+				delete(h.subs, ch)
+				close(ch)
+			}
+		}
+	}
+}
+
+// Subscribe returns the buffered events that follow the event lastID in the
+// order they were published, and a channel of events published from now on.
+// Events may be committed out of ID order, so the position of lastID in the
+// buffer is what counts; when it is no longer buffered, every buffered event
+// with a greater ID is returned. A zero lastID returns no buffered events.
+//
+// The channel is closed when the hub closes or the subscriber falls behind.
+// Call cancel once done with the subscription.
+func (h *Hub) Subscribe(lastID int64) (missed []*domain.Event, events <-chan *domain.Event, cancel func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan *domain.Event, subscriberBuffer)
+	if h.closed {
+		close(ch)
+		return nil, ch, func() {}
+	}
+
+	h.subs[ch] = struct{}{}
+
+	if lastID != 0 {
+		missed = h.after(lastID)
+	}
+
+	cancel = func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+
+	return missed, ch, cancel
+}
+
+// Close ends every subscription.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+func (h *Hub) after(lastID int64) []*domain.Event {
+	for i, e := range h.buffer {
+		if e.ID == lastID {
+			return append([]*domain.Event(nil), h.buffer[i+1:]...)
+		}
+	}
+
+	missed := make([]*domain.Event, 0)
+	for _, e := range h.buffer {
+		if e.ID > lastID {
+			missed = append(missed, e)
+		}
+	}
+
+	return missed
+}
@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func events(ids ...int64) []*domain.Event {
+	events := make([]*domain.Event, 0, len(ids))
+	for _, id := range ids {
+		events = append(events, &domain.Event{ID: id, Type: domain.EventMissionAssigned})
+	}
+
+	return events
+}
+
+func ids(events []*domain.Event) []int64 {
+	ids := make([]int64, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+
+	return ids
+}
+
+func TestHubResume(t *testing.T) {
+	hub := NewHub(4)
+	// Event 3 commits after event 4.
+	hub.Publish(events(1, 2, 4, 3, 5)...)
+
+	tests := []struct {
+		name   string
+		lastID int64
+		want   []int64
+	}{
+		{name: "fresh client", lastID: 0, want: []int64{}},
+		{name: "buffered position", lastID: 4, want: []int64{3, 5}},
+		{name: "up to date", lastID: 5, want: []int64{}},
+		{name: "evicted from buffer", lastID: 1, want: []int64{2, 4, 3, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missed, _, cancel := hub.Subscribe(tt.lastID)
+			defer cancel()
+
+			assert.Equal(t, tt.want, ids(missed))
+		})
+	}
+}
+
+func TestHubPublish(t *testing.T) {
+	hub := NewHub(10)
+
+	_, ch, cancel := hub.Subscribe(0)
+	defer cancel()
+
+	hub.Publish(events(1, 2)...)
+	hub.Publish(events(2, 3)...)
+
+	got := []int64{(<-ch).ID, (<-ch).ID, (<-ch).ID}
+	assert.Equal(t, []int64{1, 2, 3}, got)
+	assert.Empty(t, ch, "duplicate event delivered")
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewHub(subscriberBuffer * 2)
+
+	_, ch, cancel := hub.Subscribe(0)
+	defer cancel()
+
+	for id := int64(1); id <= subscriberBuffer+1; id++ {
+		hub.Publish(events(id)...)
+	}
+
+	n := 0
+	for range ch {
+		n++
+	}
+	assert.Equal(t, subscriberBuffer, n)
+}
+
+func TestHubClose(t *testing.T) {
+	hub := NewHub(10)
+
+	_, ch, cancel := hub.Subscribe(0)
+	defer cancel()
+
+	hub.Close()
+
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	_, ch, _ = hub.Subscribe(0)
+	_, ok = <-ch
+	assert.False(t, ok)
+}
@@ -0,0 +1,138 @@
+package stream
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+type EventProvider interface {
+	Event(ctx context.Context, id int64) (*domain.Event, error)
+	EventsAfter(ctx context.Context, id int64, limit int) ([]*domain.Event, error)
+	RecentEvents(ctx context.Context, limit int) ([]*domain.Event, error)
+}
+
+type Listener interface {
+	NotificationChannel() <-chan *pq.Notification
+}
+
+// catchUpWindow is how far below the last relayed event IDs a catch up reads
+// again. IDs are taken when events are recorded but events show up when they
+// commit, so an event may commit after one with a greater ID was relayed.
+const catchUpWindow = 100
+
+// Relay publishes events recorded by any replica into the hub.
+// Postgres notifies every listening replica with the ID of each new event.
+type Relay struct {
+	log      *slog.Logger
+	hub      *Hub
+	provider EventProvider
+	listener Listener
+	size     int
+	last     int64
+	// relayed are the IDs relayed within catchUpWindow of last.
+	relayed map[int64]struct{}
+}
+
+func NewRelay(log *slog.Logger, hub *Hub, provider EventProvider, listener Listener, size int) *Relay {
+	return &Relay{
+		log:      log,
+		hub:      hub,
+		provider: provider,
+		listener: listener,
+		size:     size,
+		relayed:  make(map[int64]struct{}),
+	}
+}
+
+// Run blocks until ctx is cancelled, then closes the hub.
+func (r *Relay) Run(ctx context.Context) {
+	const op = "stream.Run"
+	log := r.log.With(slog.String("operation", op))
+
+	defer r.hub.Close()
+
+	events, err := r.provider.RecentEvents(ctx, r.size)
+	if err != nil {
+		log.Error("error while loading recent events", sl.Err(err))
+	}
+	r.publish(events...)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-r.listener.NotificationChannel():
+			if err := r.handle(ctx, n); err != nil && ctx.Err() == nil {
+				log.Error("error while relaying events", sl.Err(err))
+			}
+		}
+	}
+}
+
+func (r *Relay) handle(ctx context.Context, n *pq.Notification) error {
+	// Notifications sent while the connection was down are lost,
+	// catch up on everything recorded since the last relayed event.
+	if n == nil {
+		return r.catchUp(ctx)
+	}
+
+	id, err := strconv.ParseInt(n.Extra, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	event, err := r.provider.Event(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	r.publish(event)
+	return nil
+}
+
+// catchUp relays the events recorded since catchUpWindow below the last relayed
+// one, page by page until a short page. Those relayed already are skipped.
+func (r *Relay) catchUp(ctx context.Context) error {
+	after := max(r.last-catchUpWindow, 0)
+
+	for {
+		events, err := r.provider.EventsAfter(ctx, after, r.size)
+		if err != nil {
+			return err
+		}
+
+		r.publish(events...)
+
+		if len(events) == 0 || len(events) < r.size {
+			return nil
+		}
+
+		after = events[len(events)-1].ID
+	}
+}
+
+func (r *Relay) publish(events ...*domain.Event) {
+	fresh := make([]*domain.Event, 0, len(events))
+	for _, e := range events {
+		if _, ok := r.relayed[e.ID]; ok {
+			continue
+		}
+
+		r.relayed[e.ID] = struct{}{}
+		r.last = max(r.last, e.ID)
+		fresh = append(fresh, e)
+	}
+
+	for id := range r.relayed {
+		if id < r.last-catchUpWindow {
+			delete(r.relayed, id)
+		}
+	}
+
+	r.hub.Publish(fresh...)
+}
@@ -0,0 +1,83 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// provider serves the committed events, in the order they were committed.
+type provider struct {
+	events []*domain.Event
+	pages  int
+}
+
+func (p *provider) Event(_ context.Context, id int64) (*domain.Event, error) {
+	for _, e := range p.events {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (p *provider) EventsAfter(_ context.Context, id int64, limit int) ([]*domain.Event, error) {
+	p.pages++
+
+	var after []*domain.Event
+	for _, e := range p.events {
+		if e.ID > id {
+			after = append(after, e)
+		}
+	}
+
+	sort.Slice(after, func(i, j int) bool { return after[i].ID < after[j].ID })
+
+	return after[:min(limit, len(after))], nil
+}
+
+func (p *provider) RecentEvents(context.Context, int) ([]*domain.Event, error) {
+	return nil, nil
+}
+
+func notification(id int64) *pq.Notification {
+	return &pq.Notification{Extra: strconv.FormatInt(id, 10)}
+}
+
+func TestRelayCatchUp(t *testing.T) {
+	ctx := context.Background()
+	p := &provider{}
+	hub := NewHub(100)
+	r := NewRelay(slog.New(slog.NewTextHandler(io.Discard, nil)), hub, p, nil, 2)
+
+	_, ch, cancel := hub.Subscribe(0)
+	defer cancel()
+
+	// Events 1, 2 and 4 are relayed as they commit.
+	p.events = events(1, 2, 4)
+	for _, id := range []int64{1, 2, 4} {
+		assert.NoError(t, r.handle(ctx, notification(id)))
+	}
+
+	// While the connection is down event 3 commits after event 4, and more
+	// events than fit a page follow.
+	p.events = append(p.events, events(3, 5, 6, 7)...)
+	assert.NoError(t, r.handle(ctx, nil))
+
+	var relayed []*domain.Event
+	for len(ch) > 0 {
+		relayed = append(relayed, <-ch)
+	}
+
+	assert.Equal(t, []int64{1, 2, 4, 3, 5, 6, 7}, ids(relayed), "events lost or relayed twice")
+	assert.Equal(t, 4, p.pages, "catch up stopped before a short page")
+	assert.Equal(t, int64(7), r.last)
+}
@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/egress"
+	"github.com/markraiter/spycat/internal/lib/sl"
+)
+
+const (
+	HeaderEvent     = "X-Spycat-Event"
+	HeaderDelivery  = "X-Spycat-Delivery"
+	HeaderTimestamp = "X-Spycat-Timestamp"
+	HeaderSignature = "X-Spycat-Signature"
+)
+
+type Store interface {
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+}
+
+// Dispatcher delivers queued domain events to webhook subscribers.
+//
+// Failed deliveries are retried with exponential backoff until the maximum
+// number of attempts is reached. Deliveries to non-public addresses fail,
+// unless cfg.AllowPrivate is set. Deliveries are claimed with a lease, so
+// dispatchers on several replicas never send the same delivery concurrently.
+type Dispatcher struct {
+	log    *slog.Logger
+	cfg    config.Webhook
+	store  Store
+	client *http.Client
+	wake   chan struct{}
+}
+
+func New(log *slog.Logger, cfg config.Webhook, store Store) *Dispatcher {
+	return &Dispatcher{
+		log:    log,
+		cfg:    cfg,
+		store:  store,
+		client: egress.New(cfg.AllowPrivate).Client(cfg.Timeout),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Wake makes Run dispatch right away instead of waiting for the next poll.
+// It is meant to be subscribed to the event bus, so fresh deliveries go out
+// without the poll delay.
+func (d *Dispatcher) Wake(_ context.Context, _ *domain.Event) error {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Run blocks until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	const op = "webhook.Run"
+	log := d.log.With(slog.String("operation", op))
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+
+		if _, err := d.Dispatch(ctx); err != nil && ctx.Err() == nil {
+			log.Error("error while dispatching webhooks", sl.Err(err))
+		}
+	}
+}
+
+// Dispatch sends one batch of due deliveries and returns its size.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	const op = "webhook.Dispatch"
+
+	// The lease outlives the slowest possible attempt of the batch.
+	deliveries, err := d.store.ClaimDeliveries(ctx, d.cfg.BatchSize, 2*d.cfg.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery *domain.OutboxDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *domain.OutboxDelivery) {
+	const op = "webhook.deliver"
+	log := d.log.With(slog.String("operation", op), slog.Int64("delivery_id", delivery.ID))
+
+	code, err := d.send(ctx, delivery)
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.ResponseCode = code
+
+	switch {
+	case err == nil:
+		delivery.Status = domain.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case delivery.Attempts >= d.cfg.MaxAttempts:
+		log.Warn("webhook delivery failed, giving up", sl.Err(err))
+		delivery.Status = domain.DeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		log.Warn("webhook delivery failed, will retry", sl.Err(err))
+		delivery.NextAttemptAt = now.Add(Backoff(delivery.Attempts, d.cfg.BackoffBase, d.cfg.BackoffMax))
+		delivery.LastError = err.Error()
+	}
+
+	// The delivery is being finished even if the dispatcher is shutting down,
+	// otherwise a sent event would be sent again once the lease runs out.
+	if err := d.store.UpdateDelivery(context.WithoutCancel(ctx), &delivery.WebhookDelivery); err != nil {
+		log.Error("error while updating webhook delivery", sl.Err(err))
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *domain.OutboxDelivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "spycat-webhooks")
+	req.Header.Set(HeaderEvent, string(delivery.Event.Type))
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // nolint: errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value of the request body sent at timestamp.
+// Receivers recompute HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook
+// secret and compare it with the header.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before the next attempt after the given number
+// of failed attempts: base doubled for every attempt past the first, capped at max.
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+
+	return min(delay, max)
+}
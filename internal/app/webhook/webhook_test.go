@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/egress"
+	"github.com/stretchr/testify/assert"
+)
+
+// memStore is an in-memory outbox handing out every pending delivery once.
+type memStore struct {
+	mu         sync.Mutex
+	deliveries []*domain.OutboxDelivery
+	updated    map[int64]domain.WebhookDelivery
+}
+
+func (s *memStore) ClaimDeliveries(_ context.Context, limit int, _ time.Duration) ([]*domain.OutboxDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := min(limit, len(s.deliveries))
+	claimed := s.deliveries[:n]
+	s.deliveries = s.deliveries[n:]
+
+	return claimed, nil
+}
+
+func (s *memStore) UpdateDelivery(_ context.Context, d *domain.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updated[d.ID] = *d
+
+	return nil
+}
+
+func newDispatcher(store Store) *Dispatcher {
+	cfg := config.Webhook{
+		BatchSize:   10,
+		Timeout:     time.Second,
+		MaxAttempts: 3,
+		BackoffBase: time.Minute,
+		BackoffMax:  time.Hour,
+		// The test servers listen on loopback.
+		AllowPrivate: true,
+	}
+
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store)
+}
+
+func delivery(id int64, url string, attempts int) *domain.OutboxDelivery {
+	return &domain.OutboxDelivery{
+		WebhookDelivery: domain.WebhookDelivery{
+			ID:        id,
+			EventID:   7,
+			EventType: domain.EventMissionAssigned,
+			Status:    domain.DeliveryPending,
+			Attempts:  attempts,
+		},
+		URL:    url,
+		Secret: "a-long-shared-secret",
+		Event: domain.Event{
+			ID:        7,
+			Type:      domain.EventMissionAssigned,
+			MissionID: 1,
+			CatID:     2,
+		},
+	}
+}
+
+func TestDispatchSignsAndDelivers(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	var body []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		received <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	store := &memStore{
+		deliveries: []*domain.OutboxDelivery{delivery(1, srv.URL, 0)},
+		updated:    map[int64]domain.WebhookDelivery{},
+	}
+
+	n, err := newDispatcher(store).Dispatch(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1, n)
+
+	r := <-received
+	assert.Equal(t, "mission.assigned", r.Header.Get(HeaderEvent))
+	assert.Equal(t, "1", r.Header.Get(HeaderDelivery))
+
+	timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, Sign("a-long-shared-secret", timestamp, body), r.Header.Get(HeaderSignature))
+
+	var event domain.Event
+	assert.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, 1, event.MissionID)
+	assert.Equal(t, 2, event.CatID)
+
+	d := store.updated[1]
+	assert.Equal(t, domain.DeliveryDelivered, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, http.StatusNoContent, d.ResponseCode)
+	assert.NotNil(t, d.DeliveredAt)
+}
+
+func TestDispatchRetriesWithBackoff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	store := &memStore{
+		deliveries: []*domain.OutboxDelivery{delivery(1, srv.URL, 0), delivery(2, srv.URL, 2)},
+		updated:    map[int64]domain.WebhookDelivery{},
+	}
+
+	before := time.Now()
+	_, err := newDispatcher(store).Dispatch(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	retried := store.updated[1]
+	assert.Equal(t, domain.DeliveryPending, retried.Status)
+	assert.Equal(t, 1, retried.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, retried.ResponseCode)
+	assert.NotEmpty(t, retried.LastError)
+	assert.WithinDuration(t, before.Add(time.Minute), retried.NextAttemptAt, 5*time.Second)
+
+	failed := store.updated[2]
+	assert.Equal(t, domain.DeliveryFailed, failed.Status)
+	assert.Equal(t, 3, failed.Attempts)
+	assert.Nil(t, failed.DeliveredAt)
+}
+
+func TestDispatchRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	store := &memStore{
+		deliveries: []*domain.OutboxDelivery{delivery(1, srv.URL, 0)},
+		updated:    map[int64]domain.WebhookDelivery{},
+	}
+
+	d := newDispatcher(store)
+	d.client = egress.New(false).Client(time.Second)
+
+	_, err := d.Dispatch(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.False(t, called)
+
+	refused := store.updated[1]
+	assert.Equal(t, domain.DeliveryPending, refused.Status)
+	assert.Contains(t, refused.LastError, egress.ErrForbiddenDestination.Error())
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := 30*time.Second, 10*time.Minute
+
+	assert.Equal(t, 30*time.Second, Backoff(1, base, max))
+	assert.Equal(t, time.Minute, Backoff(2, base, max))
+	assert.Equal(t, 8*time.Minute, Backoff(5, base, max))
+	assert.Equal(t, max, Backoff(6, base, max))
+	assert.Equal(t, max, Backoff(100, base, max))
+}
@@ -11,6 +11,15 @@ type Config struct {
 	Server
 	Postgres
 	Auth
+	Scheduler
+	Webhook
+	Stream
+	Events
+	Notify
+	Lockout
+	OIDC
+	Password
+	PasswordHash
 }
 
 type Postgres struct {
@@ -28,11 +37,137 @@ type Server struct {
 	ReadTimeout  time.Duration `env:"READ_TIMEOUT" env-default:"5s"`
 	WriteTimeout time.Duration `env:"WRITE_TIMEOUT" env-default:"5s"`
 	IdleTimeout  time.Duration `env:"IDLE_TIMEOUT" env-default:"120s"`
+	// ProxyHeader, e.g. X-Forwarded-For, is read for the client IP address when
+	// the request comes from one of the TrustedProxies.
+	ProxyHeader    string   `env:"PROXY_HEADER"`
+	TrustedProxies []string `env:"TRUSTED_PROXIES" env-separator:","`
 }
 
 type Auth struct {
-	SigningKey string        `env:"SIGNING_KEY" env-required:"true"`
-	AccessTTL  time.Duration `env:"ACCESS_TTL" env-default:"1h"`
+	// SigningKey is the HS256 secret tokens are signed with when KeysDir is not set.
+	SigningKey string `env:"SIGNING_KEY"`
+	// KeysDir holds the RSA and Ed25519 keys, SigningKeyID is the kid of the one tokens are signed with.
+	KeysDir      string        `env:"JWT_KEYS_DIR"`
+	SigningKeyID string        `env:"JWT_SIGNING_KID"`
+	Issuer       string        `env:"JWT_ISSUER" env-default:"spycat"`
+	Audience     string        `env:"JWT_AUDIENCE" env-default:"spycat-api"`
+	AccessTTL    time.Duration `env:"ACCESS_TTL" env-default:"1h"`
+	// RequireVerifiedEmail blocks login until the user verified the email address.
+	RequireVerifiedEmail bool          `env:"REQUIRE_VERIFIED_EMAIL" env-default:"true"`
+	VerificationTTL      time.Duration `env:"VERIFICATION_TTL" env-default:"48h"`
+	PasswordResetTTL     time.Duration `env:"PASSWORD_RESET_TTL" env-default:"1h"`
+	MFATokenTTL          time.Duration `env:"MFA_TOKEN_TTL" env-default:"5m"`
+	MFAIssuer            string        `env:"MFA_ISSUER" env-default:"SpyCat"`
+	// ReauthWindow is how recent the login of a user without a password must be
+	// to change the email or delete the account.
+	ReauthWindow time.Duration `env:"REAUTH_WINDOW" env-default:"5m"`
+	// AdminEmails get the admin role when they sign in with a verified email,
+	// that is how the first admin is made.
+	AdminEmails []string `env:"ADMIN_EMAILS" env-separator:","`
+}
+
+// Scheduler holds the intervals of the background jobs, an interval that is
+// not positive disables the job.
+type Scheduler struct {
+	OverdueInterval time.Duration `env:"OVERDUE_CHECK_INTERVAL" env-default:"1m"`
+	DedupeInterval  time.Duration `env:"TARGET_DEDUPE_INTERVAL" env-default:"1h"`
+	// DedupeThreshold is the lowest similarity, from 0 to 1, of the names of
+	// two targets suggested for a merge.
+	DedupeThreshold float64 `env:"TARGET_DEDUPE_THRESHOLD" env-default:"0.92"`
+	// LoginPurgeInterval is how often the failed logins that neither count
+	// nor lock anymore are deleted.
+	LoginPurgeInterval time.Duration `env:"LOGIN_ATTEMPTS_PURGE_INTERVAL" env-default:"1h"`
+}
+
+type Webhook struct {
+	PollInterval time.Duration `env:"WEBHOOK_POLL_INTERVAL" env-default:"5s"`
+	BatchSize    int           `env:"WEBHOOK_BATCH_SIZE" env-default:"20"`
+	Timeout      time.Duration `env:"WEBHOOK_TIMEOUT" env-default:"10s"`
+	MaxAttempts  int           `env:"WEBHOOK_MAX_ATTEMPTS" env-default:"8"`
+	BackoffBase  time.Duration `env:"WEBHOOK_BACKOFF_BASE" env-default:"30s"`
+	BackoffMax   time.Duration `env:"WEBHOOK_BACKOFF_MAX" env-default:"6h"`
+	// AllowPrivate lets webhooks reach loopback and private addresses, for development only.
+	AllowPrivate bool `env:"WEBHOOK_ALLOW_PRIVATE" env-default:"false"`
+}
+
+type Stream struct {
+	BufferSize int           `env:"STREAM_BUFFER_SIZE" env-default:"1000"`
+	Heartbeat  time.Duration `env:"STREAM_HEARTBEAT" env-default:"15s"`
+}
+
+type Events struct {
+	QueueSize int `env:"EVENTS_QUEUE_SIZE" env-default:"256"`
+}
+
+type Notify struct {
+	SMTPHost     string        `env:"SMTP_HOST" env-default:"localhost"`
+	SMTPPort     string        `env:"SMTP_PORT" env-default:"1025"`
+	SMTPUsername string        `env:"SMTP_USERNAME"`
+	SMTPPassword string        `env:"SMTP_PASSWORD"`
+	SMTPSecurity string        `env:"SMTP_SECURITY" env-default:"plain"` // plain or starttls
+	SMTPTimeout  time.Duration `env:"SMTP_TIMEOUT" env-default:"10s"`
+	From         string        `env:"NOTIFY_FROM" env-default:"SpyCat <noreply@spycat.local>"`
+	// AppURL is the public URL links in emails point to.
+	AppURL string `env:"APP_URL" env-default:"http://localhost:8000"`
+	// DevDir, when set, makes emails be written to the directory as .eml files instead of sent.
+	DevDir string `env:"NOTIFY_DEV_DIR"`
+}
+
+type Lockout struct {
+	Store            string        `env:"LOCKOUT_STORE" env-default:"postgres"` // memory or postgres
+	Window           time.Duration `env:"LOCKOUT_WINDOW" env-default:"15m"`
+	AccountThreshold int           `env:"LOCKOUT_ACCOUNT_THRESHOLD" env-default:"5"`
+	IPThreshold      int           `env:"LOCKOUT_IP_THRESHOLD" env-default:"50"`
+	Duration         time.Duration `env:"LOCKOUT_DURATION" env-default:"15m"`
+	DelayBase        time.Duration `env:"LOCKOUT_DELAY_BASE" env-default:"1s"`
+	DelayMax         time.Duration `env:"LOCKOUT_DELAY_MAX" env-default:"30s"`
+}
+
+// OIDC delegates login to an OpenID Connect provider, it is off while Issuer is empty.
+type OIDC struct {
+	// Issuer is the issuer URL of the provider, its metadata is read from
+	// <Issuer>/.well-known/openid-configuration.
+	Issuer       string   `env:"OIDC_ISSUER"`
+	ClientID     string   `env:"OIDC_CLIENT_ID"`
+	ClientSecret string   `env:"OIDC_CLIENT_SECRET"`
+	RedirectURL  string   `env:"OIDC_REDIRECT_URL" env-default:"http://localhost:8000/api/v1/auth/oidc/callback"`
+	Scopes       []string `env:"OIDC_SCOPES" env-separator:"," env-default:"openid,email,profile"`
+	// GroupsClaim is the ID token claim listing the groups of the user.
+	GroupsClaim string `env:"OIDC_GROUPS_CLAIM" env-default:"groups"`
+	// Members of AdminGroups get the admin role, of HandlerGroups the handler one,
+	// others are refused. When HandlerGroups is empty, everyone else gets the
+	// handler role. When both are empty, roles are not taken from the provider.
+	AdminGroups   []string      `env:"OIDC_ADMIN_GROUPS" env-separator:","`
+	HandlerGroups []string      `env:"OIDC_HANDLER_GROUPS" env-separator:","`
+	StateTTL      time.Duration `env:"OIDC_STATE_TTL" env-default:"10m"`
+	Timeout       time.Duration `env:"OIDC_TIMEOUT" env-default:"10s"`
+}
+
+// Password is the policy of new passwords, by default the one of NIST SP 800-63B:
+// a length, no composition rules and no password known to be breached.
+type Password struct {
+	MinLength      int  `env:"PASSWORD_MIN_LENGTH" env-default:"8"`
+	MaxLength      int  `env:"PASSWORD_MAX_LENGTH" env-default:"64"`
+	RequireUpper   bool `env:"PASSWORD_REQUIRE_UPPER" env-default:"false"`
+	RequireLower   bool `env:"PASSWORD_REQUIRE_LOWER" env-default:"false"`
+	RequireNumber  bool `env:"PASSWORD_REQUIRE_NUMBER" env-default:"false"`
+	RequireSpecial bool `env:"PASSWORD_REQUIRE_SPECIAL" env-default:"false"`
+	// BreachCheck refuses the passwords listed in BreachedFile, or in the list
+	// bundled when it is empty. The file has lines "PREFIX:SUFFIX[:COUNT]" of
+	// SHA-1 hashes, as the Have I Been Pwned range API returns them.
+	BreachCheck  bool   `env:"PASSWORD_BREACH_CHECK" env-default:"true"`
+	BreachedFile string `env:"PASSWORD_BREACHED_FILE"`
+}
+
+// PasswordHash is how new passwords are hashed, with bcrypt or argon2id.
+// Hashes made otherwise are replaced at the next login of their user.
+type PasswordHash struct {
+	Algorithm  string `env:"PASSWORD_HASH" env-default:"bcrypt"` // bcrypt or argon2id
+	BcryptCost int    `env:"BCRYPT_COST" env-default:"10"`
+	// Argon2Memory is in KiB, the defaults are the ones OWASP recommends.
+	Argon2Memory  uint32 `env:"ARGON2_MEMORY" env-default:"19456"`
+	Argon2Time    uint32 `env:"ARGON2_TIME" env-default:"2"`
+	Argon2Threads uint8  `env:"ARGON2_THREADS" env-default:"1"`
 }
 
 func MustLoad() *Config {
@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+// API key scopes. A request with an API key needs ScopeRead to GET and
+// ScopeWrite for any other method.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// APIKeyPrefix starts every API key, "sc_<prefix>_<secret>".
+const APIKeyPrefix = "sc_"
+
+// APIKey lets scripts authenticate as its user, with the Authorization header "ApiKey <key>".
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int        `json:"user_id" example:"1"`
+	Name       string     `json:"name" example:"nightly report"`
+	Prefix     string     `json:"prefix" example:"k3j59x2m"`
+	Scopes     []string   `json:"scopes" example:"read"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	SecretHash []byte     `json:"-"`
+	// Username and Email are of the user, filled in when the key authenticates a request.
+	Username string `json:"-"`
+	Email    string `json:"-"`
+}
+
+// HasScope tells whether the key was granted the scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type APIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100" example:"nightly report"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=read write" example:"read"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2027-01-01T00:00:00Z"`
+}
+
+// NewAPIKey is a created API key with its secret, which is only ever shown then.
+type NewAPIKey struct {
+	APIKey
+	Key string `json:"key" example:"sc_k3j59x2m_c2VjcmV0LWtleS1ieXRlcy1ub3QtcmVhbA"`
+}
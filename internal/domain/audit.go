@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+type AuditEntry struct {
+	ID        int            `json:"id"`
+	UserID    int            `json:"user_id" example:"1"`
+	Action    string         `json:"action" example:"mission.assigned"`
+	Entity    string         `json:"entity" example:"mission"`
+	EntityID  int            `json:"entity_id" example:"1"`
+	Details   map[string]any `json:"details"`
+	CreatedAt time.Time      `json:"created_at"`
+}
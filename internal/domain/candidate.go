@@ -0,0 +1,49 @@
+package domain
+
+// Candidate is a cat ranked for a mission.
+// Eligible cats meet all skills required by the mission.
+type Candidate struct {
+	CatID          int            `json:"cat_id" example:"1"`
+	CatName        string         `json:"cat_name" example:"Tom"`
+	Eligible       bool           `json:"eligible" example:"true"`
+	ActiveMissions int            `json:"active_missions" example:"0"`
+	MissingSkills  []SkillGap     `json:"missing_skills,omitempty"`
+	Score          CandidateScore `json:"score"`
+}
+
+// CandidateScore is the breakdown of a candidate score.
+// Every component is normalized to [0, 1], Total is their weighted sum.
+type CandidateScore struct {
+	Experience  float64 `json:"experience" example:"0.5"`
+	Skills      float64 `json:"skills" example:"1"`
+	Workload    float64 `json:"workload" example:"1"`
+	Cost        float64 `json:"cost" example:"0.7"`
+	SuccessRate float64 `json:"success_rate" example:"0.8"`
+	Total       float64 `json:"total" example:"0.82"`
+}
+
+// CatStats is the mission history of a cat. Finished missions are the
+// completed ones and those that went overdue, active missions are the others,
+// still in progress and neither a success nor a failure yet.
+type CatStats struct {
+	CatID             int
+	ActiveMissions    int
+	CompletedMissions int
+	FinishedMissions  int
+}
+
+// AssignmentProposal is a suggested assignment of unassigned missions to cats.
+// It is not applied until the missions are assigned explicitly.
+type AssignmentProposal struct {
+	Assignments []ProposedAssignment `json:"assignments"`
+	Unassigned  []int                `json:"unassigned"`
+	TotalScore  float64              `json:"total_score" example:"2.4"`
+}
+
+type ProposedAssignment struct {
+	MissionID int     `json:"mission_id" example:"1"`
+	CatID     int     `json:"cat_id" example:"1"`
+	CatName   string  `json:"cat_name" example:"Tom"`
+	Eligible  bool    `json:"eligible" example:"true"`
+	Score     float64 `json:"score" example:"0.82"`
+}
@@ -1,11 +1,17 @@
 package domain
 
+// Cat is a spy cat. Its salary is the one of its salary history effective
+// today, SalaryAmount in minor units and Salary in whole major units.
 type Cat struct {
-	ID                int    `json:"id"`
-	Name              string `json:"name" validate:"required" example:"Tom"`
-	YearsOfExperience int    `json:"years_of_experience" validate:"omitempty" example:"5"`
-	Breed             string `json:"breed" validate:"required" example:"Siamese"`
-	Salary            int    `json:"salary" validate:"omitempty" example:"1000"`
+	ID                int        `json:"id"`
+	Name              string     `json:"name" validate:"required" example:"Tom"`
+	YearsOfExperience int        `json:"years_of_experience" validate:"omitempty" example:"5"`
+	Breed             string     `json:"breed" validate:"required" example:"Siamese"`
+	Salary            int        `json:"salary" validate:"omitempty" example:"1000"`
+	SalaryAmount      int64      `json:"salary_amount" example:"100000"`
+	Currency          string     `json:"currency" validate:"omitempty,len=3" example:"USD"`
+	HandlerID         int        `json:"handler_id,omitempty" example:"1"`
+	Skills            []CatSkill `json:"skills,omitempty"`
 }
 
 type CatRequest struct {
@@ -13,4 +19,6 @@ type CatRequest struct {
 	YearsOfExperience int    `json:"years_of_experience" validate:"omitempty" example:"5"`
 	Breed             string `json:"breed" validate:"required" example:"Siamese"`
 	Salary            int    `json:"salary" validate:"omitempty" example:"1000"`
+	Currency          string `json:"currency" validate:"omitempty,len=3" example:"USD"`
+	HandlerID         int    `json:"handler_id,omitempty" validate:"omitempty,min=1" example:"1"`
 }
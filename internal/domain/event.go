@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+type EventType string
+
+const (
+	EventCatCreated         EventType = "cat.created"
+	EventCatDeleted         EventType = "cat.deleted"
+	EventMissionCreated     EventType = "mission.created"
+	EventMissionAssigned    EventType = "mission.assigned"
+	EventMissionCompleted   EventType = "mission.completed"
+	EventMissionOverdue     EventType = "mission.overdue"
+	EventMissionRescheduled EventType = "mission.rescheduled"
+	EventMissionDeleted     EventType = "mission.deleted"
+	EventTargetCompleted    EventType = "target.completed"
+)
+
+// Event is a domain event recorded after a state change.
+type Event struct {
+	ID        int64          `json:"id"`
+	Type      EventType      `json:"type" example:"mission.overdue"`
+	MissionID int            `json:"mission_id,omitempty" example:"1"`
+	CatID     int            `json:"cat_id,omitempty" example:"1"`
+	TargetID  int            `json:"target_id,omitempty" example:"1"`
+	Payload   map[string]any `json:"payload,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// BusMetrics are the counters of the in-process event bus since start.
+type BusMetrics struct {
+	Published   int64               `json:"published" example:"120"`
+	Subscribers []SubscriberMetrics `json:"subscribers"`
+}
+
+type SubscriberMetrics struct {
+	Name      string `json:"name" example:"webhook.wake"`
+	Async     bool   `json:"async" example:"true"`
+	Delivered int64  `json:"delivered" example:"118"`
+	Failed    int64  `json:"failed" example:"1"`
+	Panics    int64  `json:"panics" example:"0"`
+	Dropped   int64  `json:"dropped" example:"1"`
+	Queued    int    `json:"queued" example:"0"`
+}
@@ -0,0 +1,54 @@
+package domain
+
+import "time"
+
+// Expense is a cost logged against a mission, stored in minor units of the currency.
+type Expense struct {
+	ID          int       `json:"id"`
+	MissionID   int       `json:"mission_id" example:"1"`
+	Category    string    `json:"category" example:"travel"`
+	Amount      int64     `json:"amount" example:"12050"`
+	Currency    string    `json:"currency" example:"USD"`
+	ReceiptNote string    `json:"receipt_note" example:"Train ticket Paris - Berlin"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ExpenseRequest struct {
+	Category    string `json:"category" validate:"required,max=50" example:"travel"`
+	Amount      int64  `json:"amount" validate:"required,min=1" example:"12050"`
+	Currency    string `json:"currency" validate:"required,len=3" example:"USD"`
+	ReceiptNote string `json:"receipt_note" validate:"omitempty" example:"Train ticket Paris - Berlin"`
+}
+
+type ExpenseResponse struct {
+	ID         int   `json:"id" example:"1"`
+	Remaining  int64 `json:"remaining" example:"487950"`
+	OverBudget bool  `json:"over_budget" example:"false"`
+}
+
+// BudgetRequest amounts are in minor units of Currency, which has to be the mission currency.
+type BudgetRequest struct {
+	Budget   int64  `json:"budget" validate:"min=0" example:"500000"`
+	Bonus    int64  `json:"bonus" validate:"min=0" example:"50000"`
+	Currency string `json:"currency" validate:"required,len=3" example:"USD"`
+}
+
+// MissionFinancials summarizes the budget of a mission, amounts are in minor units.
+// A mission with zero budget is considered unbudgeted and is never over budget.
+type MissionFinancials struct {
+	MissionID  int               `json:"mission_id" example:"1"`
+	Currency   string            `json:"currency" example:"USD"`
+	Budget     int64             `json:"budget" example:"500000"`
+	Spent      int64             `json:"spent" example:"12050"`
+	Remaining  int64             `json:"remaining" example:"487950"`
+	OverBudget bool              `json:"over_budget" example:"false"`
+	Bonus      int64             `json:"bonus" example:"50000"`
+	BonusPaid  bool              `json:"bonus_paid" example:"false"`
+	ByCategory []CategoryExpense `json:"by_category"`
+	Expenses   []*Expense        `json:"expenses"`
+}
+
+type CategoryExpense struct {
+	Category string `json:"category" example:"travel"`
+	Amount   int64  `json:"amount" example:"12050"`
+}
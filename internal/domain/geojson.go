@@ -0,0 +1,22 @@
+package domain
+
+// FeatureCollection is a GeoJSON feature collection of RFC 7946.
+type FeatureCollection struct {
+	Type     string     `json:"type" example:"FeatureCollection"`
+	Features []*Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature, Geometry is null when the location is unknown.
+type Feature struct {
+	Type       string         `json:"type" example:"Feature"`
+	ID         string         `json:"id,omitempty" example:"target-1"`
+	Geometry   *Geometry      `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// Geometry is a GeoJSON point, its coordinates are the longitude and the
+// latitude in this order.
+type Geometry struct {
+	Type        string     `json:"type" example:"Point"`
+	Coordinates [2]float64 `json:"coordinates" swaggertype:"array,number" example:"-74.006,40.7128"`
+}
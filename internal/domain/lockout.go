@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// LoginAttempts is the record of failed logins of an account or an IP address.
+type LoginAttempts struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
+	// LockedUntil is set while logins are refused outright.
+	LockedUntil *time.Time
+}
+
+// UnlockRequest names the account, the IP address or both to clear the failed logins of.
+type UnlockRequest struct {
+	Email string `json:"email" validate:"required_without=IP,omitempty,email" example:"email@example.com"`
+	IP    string `json:"ip" validate:"required_without=Email,omitempty,ip" example:"203.0.113.7"`
+}
@@ -0,0 +1,56 @@
+package domain
+
+import "time"
+
+// Message is a chat message posted to the channel of a mission.
+type Message struct {
+	ID        int64     `json:"id"`
+	MissionID int       `json:"mission_id" example:"1"`
+	UserID    int       `json:"user_id,omitempty" example:"1"`
+	Username  string    `json:"username,omitempty" example:"username"`
+	Body      string    `json:"body" example:"Target left the building"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MessageMaxLength is the longest message body in characters.
+const MessageMaxLength = 1000
+
+type FrameType string
+
+const (
+	FrameMessage  FrameType = "message"
+	FrameTyping   FrameType = "typing"
+	FramePresence FrameType = "presence"
+	FrameError    FrameType = "error"
+)
+
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"
+	PresenceOffline PresenceStatus = "offline"
+)
+
+// ChatUser is a user connected to the channel of a mission.
+type ChatUser struct {
+	UserID   int    `json:"user_id" example:"1"`
+	Username string `json:"username" example:"username"`
+}
+
+// ChatFrame is a JSON frame sent over the mission chat socket.
+//
+// Clients send "message" frames with a body and "typing" frames. The server sends
+// "message" frames with the persisted message, "typing" frames, "presence" frames
+// with a status when somebody joins or leaves or with the list of connected users
+// right after connecting, and "error" frames.
+type ChatFrame struct {
+	Type      FrameType      `json:"type" example:"message"`
+	MissionID int            `json:"mission_id,omitempty" example:"1"`
+	UserID    int            `json:"user_id,omitempty" example:"1"`
+	Username  string         `json:"username,omitempty" example:"username"`
+	Body      string         `json:"body,omitempty" example:"Target left the building"`
+	Status    PresenceStatus `json:"status,omitempty" example:"online"`
+	Users     []ChatUser     `json:"users,omitempty"`
+	Message   *Message       `json:"message,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
@@ -0,0 +1,53 @@
+package domain
+
+// RecoveryCodeCount is how many recovery codes a user gets, each works once.
+const RecoveryCodeCount = 10
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	URI    string `json:"uri" example:"otpauth://totp/SpyCat:email@example.com?secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP&issuer=SpyCat"`
+}
+
+// CodeRequest carries a TOTP code or a recovery code.
+type CodeRequest struct {
+	Code string `json:"code" validate:"required,min=6,max=32" example:"123456"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"codes" example:"k3j5-9x2m"`
+}
+
+// MFAChallenge is returned by login when a second factor is needed.
+type MFAChallenge struct {
+	MFAToken  string `json:"mfa_token" example:"eyJhbGciOiJIUzI1NiIs..."`
+	ExpiresIn int    `json:"expires_in" example:"300"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required" example:"eyJhbGciOiJIUzI1NiIs..."`
+	Code     string `json:"code" validate:"required,min=6,max=32" example:"123456"`
+}
+
+// LoginResult holds either an access token or, when a second factor is needed, a challenge.
+type LoginResult struct {
+	Token     string
+	Challenge *MFAChallenge
+}
+
+// MFAPolicy tells whether users of the role must use a second factor.
+type MFAPolicy struct {
+	Role     string `json:"role" validate:"required,oneof=admin handler" example:"admin"`
+	Required bool   `json:"required" example:"true"`
+}
+
+// SessionState is what authenticating a request needs to know about the user.
+type SessionState struct {
+	Version int
+	// MFAEnrollmentRequired is set when the role of the user requires MFA
+	// and the user has not enabled it yet.
+	MFAEnrollmentRequired bool
+	// SessionActive is set when the session asked about is neither revoked nor expired.
+	SessionActive bool
+	// Disabled is set while an admin keeps the user from signing in.
+	Disabled bool
+}
@@ -1,16 +1,59 @@
 package domain
 
+import "time"
+
+// Mission priorities, a higher value is more urgent.
+const (
+	PriorityLowest  = 1
+	PriorityDefault = 3
+	PriorityHighest = 5
+)
+
 type Mission struct {
-	ID        int      `json:"id"`
-	CatID     int      `json:"cat_id" validate:"required" example:"1"`
-	Targets   []Target `json:"targets" validate:"dive,required"`
-	Notes     string   `json:"notes" validate:"omitempty" example:"Lorem ipsum"`
-	Completed bool     `json:"completed" validate:"omitempty" example:"false"`
+	ID             int             `json:"id"`
+	CatID          int             `json:"cat_id" validate:"required" example:"1"`
+	Targets        []Target        `json:"targets" validate:"dive,required"`
+	Notes          string          `json:"notes" validate:"omitempty" example:"Lorem ipsum"`
+	Completed      bool            `json:"completed" validate:"omitempty" example:"false"`
+	Budget         int64           `json:"budget" validate:"min=0" example:"500000"`
+	Bonus          int64           `json:"bonus" validate:"min=0" example:"50000"`
+	Currency       string          `json:"currency" validate:"omitempty,len=3" example:"USD"`
+	Priority       int             `json:"priority" validate:"omitempty,min=1,max=5" example:"3"`
+	StartsAt       *time.Time      `json:"starts_at" example:"2026-10-01T00:00:00Z"`
+	DueAt          *time.Time      `json:"due_at" example:"2026-10-31T00:00:00Z"`
+	Overdue        bool            `json:"overdue" example:"false"`
+	RequiredSkills []RequiredSkill `json:"required_skills,omitempty"`
 }
 
 type MissionRequest struct {
-	CatID     int      `json:"cat_id" validate:"required" example:"1"`
-	Targets   []Target `json:"targets" validate:"dive,required"`
-	Notes     string   `json:"notes" validate:"omitempty" example:"Lorem ipsum"`
-	Completed bool     `json:"completed" validate:"omitempty" example:"false"`
+	CatID     int        `json:"cat_id" validate:"omitempty" example:"1"`
+	Targets   []Target   `json:"targets" validate:"dive,required"`
+	Notes     string     `json:"notes" validate:"omitempty" example:"Lorem ipsum"`
+	Completed bool       `json:"completed" validate:"omitempty" example:"false"`
+	Budget    int64      `json:"budget" validate:"min=0" example:"500000"`
+	Bonus     int64      `json:"bonus" validate:"min=0" example:"50000"`
+	Currency  string     `json:"currency" validate:"omitempty,len=3" example:"USD"`
+	Priority  int        `json:"priority" validate:"omitempty,min=1,max=5" example:"3"`
+	StartsAt  *time.Time `json:"starts_at" example:"2026-10-01T00:00:00Z"`
+	DueAt     *time.Time `json:"due_at" example:"2026-10-31T00:00:00Z"`
+}
+
+type ScheduleRequest struct {
+	Priority int        `json:"priority" validate:"required,min=1,max=5" example:"3"`
+	StartsAt *time.Time `json:"starts_at" example:"2026-10-01T00:00:00Z"`
+	DueAt    *time.Time `json:"due_at" example:"2026-10-31T00:00:00Z"`
+}
+
+// MissionFilter narrows and orders the list of missions.
+// Zero values do not filter.
+type MissionFilter struct {
+	Priority  int
+	Completed *bool
+	Overdue   *bool
+	DueBefore *time.Time
+	DueAfter  *time.Time
+	Sort      string
+	Desc      bool
+	// From sets the distance of the located targets of the missions from it.
+	From *GeoPoint
 }
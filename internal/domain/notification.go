@@ -0,0 +1,25 @@
+package domain
+
+// NotifiableEvents are the event types users are emailed about.
+var NotifiableEvents = []EventType{
+	EventMissionAssigned,
+	EventMissionCompleted,
+	EventMissionOverdue,
+}
+
+// NotificationPreference tells whether the user is emailed about an event type.
+type NotificationPreference struct {
+	EventType EventType `json:"event_type" validate:"required,oneof=mission.assigned mission.completed mission.overdue" example:"mission.assigned"`
+	Email     bool      `json:"email" example:"true"`
+}
+
+type NotificationPreferencesRequest struct {
+	Preferences []NotificationPreference `json:"preferences" validate:"required,min=1,dive"`
+}
+
+// Recipient is a user an event notification is sent to.
+type Recipient struct {
+	UserID   int
+	Username string
+	Email    string
+}
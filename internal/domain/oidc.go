@@ -0,0 +1,36 @@
+package domain
+
+// OIDCFlow is what the callback of a login with the identity provider checks
+// against, it is kept in a cookie between the redirect and the callback.
+type OIDCFlow struct {
+	// State ties the callback to the browser the login started in.
+	State string `json:"state"`
+	// Nonce ties the ID token to the login.
+	Nonce string `json:"nonce"`
+	// Verifier is the PKCE code verifier, the provider was given its challenge.
+	Verifier string `json:"verifier"`
+}
+
+// OIDCRedirect sends the browser to the identity provider.
+type OIDCRedirect struct {
+	URL string
+	// FlowToken is the signed OIDCFlow, for the cookie.
+	FlowToken string
+}
+
+// OIDCCallback is the query of the redirect back from the identity provider.
+type OIDCCallback struct {
+	Code  string `query:"code" validate:"required"`
+	State string `query:"state" validate:"required"`
+}
+
+// OIDCIdentity is the user an ID token was issued for.
+type OIDCIdentity struct {
+	Issuer  string
+	Subject string
+	Email   string
+	// EmailVerified is true only when the provider says the email is verified.
+	EmailVerified bool
+	Username      string
+	Groups        []string
+}
@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+// Payroll is the pay computed for every cat paid in a calendar month.
+// All amounts are in minor units of the entry currency.
+type Payroll struct {
+	Period  string         `json:"period" example:"2026-10"`
+	From    time.Time      `json:"from"`
+	To      time.Time      `json:"to"`
+	Entries []PayrollEntry `json:"entries"`
+	Totals  []PayrollTotal `json:"totals"`
+}
+
+// PayrollEntry is the pay of a cat in one currency, CatID is 0 for a deleted cat.
+type PayrollEntry struct {
+	CatID    int    `json:"cat_id" example:"1"`
+	CatName  string `json:"cat_name" example:"Tom"`
+	Currency string `json:"currency" example:"USD"`
+	Days     int    `json:"days" example:"31"`
+	Base     int64  `json:"base" example:"100000"`
+	Bonus    int64  `json:"bonus" example:"50000"`
+	Total    int64  `json:"total" example:"150000"`
+}
+
+type PayrollTotal struct {
+	Currency string `json:"currency" example:"USD"`
+	Total    int64  `json:"total" example:"150000"`
+}
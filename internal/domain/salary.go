@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// Salary is a monthly salary of a cat effective from the given date.
+// Amount is stored in minor units of the currency (e.g. cents).
+type Salary struct {
+	ID            int       `json:"id"`
+	CatID         int       `json:"cat_id" example:"1"`
+	Amount        int64     `json:"amount" example:"100000"`
+	Currency      string    `json:"currency" example:"USD"`
+	EffectiveFrom time.Time `json:"effective_from" example:"2026-10-01T00:00:00Z"`
+	// CatName and CatDeletedAt are kept once the cat is deleted, CatID is then 0.
+	CatName      string     `json:"-"`
+	CatDeletedAt *time.Time `json:"-"`
+}
+
+type SalaryRequest struct {
+	Amount        int64     `json:"amount" validate:"min=0" example:"100000"`
+	Currency      string    `json:"currency" validate:"required,len=3" example:"USD"`
+	EffectiveFrom time.Time `json:"effective_from" validate:"required" example:"2026-10-01T00:00:00Z"`
+}
+
+// Bonus is a one-off payment to a cat, stored in minor units of the currency.
+type Bonus struct {
+	ID        int       `json:"id"`
+	CatID     int       `json:"cat_id" example:"1"`
+	MissionID int       `json:"mission_id,omitempty" example:"1"`
+	Amount    int64     `json:"amount" example:"50000"`
+	Currency  string    `json:"currency" example:"USD"`
+	Reason    string    `json:"reason" example:"mission completed"`
+	AwardedAt time.Time `json:"awarded_at"`
+	// CatName is kept once the cat is deleted, CatID is then 0.
+	CatName string `json:"-"`
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// Client is where a login comes from.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// Session is a login of a user, each access token belongs to one.
+type Session struct {
+	ID         int64      `json:"id" example:"12"`
+	UserID     int        `json:"-"`
+	UserAgent  string     `json:"user_agent" example:"Mozilla/5.0 (X11; Linux x86_64) Firefox/131.0"`
+	IP         string     `json:"ip" example:"203.0.113.7"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+	// Current is set on the session of the request.
+	Current bool `json:"current"`
+}
@@ -0,0 +1,90 @@
+package domain
+
+import "sort"
+
+type Skill struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name" example:"infiltration"`
+	Description string `json:"description" example:"Getting into guarded places unnoticed"`
+}
+
+type SkillRequest struct {
+	Name        string `json:"name" validate:"required,max=100" example:"infiltration"`
+	Description string `json:"description" validate:"omitempty" example:"Getting into guarded places unnoticed"`
+}
+
+// CatSkill is a proficiency of a cat in a skill on a scale from 1 to 5.
+type CatSkill struct {
+	SkillID int    `json:"skill_id" example:"1"`
+	Name    string `json:"name" example:"infiltration"`
+	Level   int    `json:"level" example:"3"`
+}
+
+// RequiredSkill is a minimal proficiency in a skill a cat needs for a mission,
+// required by the mission itself or by one of its targets.
+type RequiredSkill struct {
+	SkillID  int    `json:"skill_id" example:"1"`
+	Name     string `json:"name" example:"infiltration"`
+	MinLevel int    `json:"min_level" example:"3"`
+}
+
+type SkillLevelRequest struct {
+	Level int `json:"level" validate:"required,min=1,max=5" example:"3"`
+}
+
+// SkillGap describes a required skill the cat lacks or is not proficient enough in.
+type SkillGap struct {
+	SkillID  int    `json:"skill_id" example:"1"`
+	Name     string `json:"name" example:"infiltration"`
+	Required int    `json:"required" example:"3"`
+	Actual   int    `json:"actual" example:"1"`
+}
+
+// SkillGaps returns required skills the cat does not meet.
+func SkillGaps(required []RequiredSkill, skills []CatSkill) []SkillGap {
+	levels := make(map[int]int, len(skills))
+	for _, s := range skills {
+		levels[s.SkillID] = s.Level
+	}
+
+	var gaps []SkillGap
+	for _, r := range required {
+		if levels[r.SkillID] < r.MinLevel {
+			gaps = append(gaps, SkillGap{
+				SkillID:  r.SkillID,
+				Name:     r.Name,
+				Required: r.MinLevel,
+				Actual:   levels[r.SkillID],
+			})
+		}
+	}
+
+	return gaps
+}
+
+// MergeRequiredSkills joins the requirements, a skill required more than once
+// needs the highest of the levels. The result is ordered by skill name.
+func MergeRequiredSkills(requirements ...[]RequiredSkill) []RequiredSkill {
+	levels := make(map[int]RequiredSkill)
+	for _, required := range requirements {
+		for _, r := range required {
+			if l, ok := levels[r.SkillID]; !ok || r.MinLevel > l.MinLevel {
+				levels[r.SkillID] = r
+			}
+		}
+	}
+
+	merged := make([]RequiredSkill, 0, len(levels))
+	for _, r := range levels {
+		merged = append(merged, r)
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Name != merged[j].Name {
+			return merged[i].Name < merged[j].Name
+		}
+		return merged[i].SkillID < merged[j].SkillID
+	})
+
+	return merged
+}
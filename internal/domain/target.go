@@ -1,10 +1,167 @@
 package domain
 
+import "time"
+
+// Target is a target of the registry. MissionID and Completed are those of the
+// target in a mission, set when the target is read through the mission.
 type Target struct {
-	ID        int    `json:"id"`
-	MissionID int    `json:"mission_id" validate:"required" example:"1"`
-	Name      string `json:"name" validate:"required" example:"John Doe"`
-	Country   string `json:"country" validate:"required" example:"USA"`
-	Notes     string `json:"notes" validate:"omitempty" example:"Lorem ipsum"`
-	Completed bool   `json:"completed" validate:"omitepmty" example:"false"`
+	ID        int      `json:"id"`
+	MissionID int      `json:"mission_id,omitempty" example:"1"`
+	Name      string   `json:"name" validate:"required" example:"John Doe"`
+	Country   string   `json:"country" validate:"required" example:"USA"`
+	City      string   `json:"city,omitempty" example:"New York"`
+	Latitude  *float64 `json:"latitude,omitempty" example:"40.7128"`
+	Longitude *float64 `json:"longitude,omitempty" example:"-74.006"`
+	Notes     string   `json:"notes" validate:"omitempty" example:"Lorem ipsum"`
+	Completed bool     `json:"completed" validate:"omitempty" example:"false"`
+	// DistanceKm is the distance of the target from the point asked for,
+	// set for located targets only.
+	DistanceKm *float64 `json:"distance_km,omitempty" example:"12.3"`
+}
+
+// Located tells whether both coordinates of the target are known.
+func (t *Target) Located() bool {
+	return t.Latitude != nil && t.Longitude != nil
+}
+
+// GeoPoint is a point on the Earth in decimal degrees.
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// TargetRequest registers a target outside of any mission.
+type TargetRequest struct {
+	Name      string   `json:"name" validate:"required,max=255" example:"John Doe"`
+	Country   string   `json:"country" validate:"required,max=255" example:"USA"`
+	City      string   `json:"city" validate:"max=255" example:"New York"`
+	Latitude  *float64 `json:"latitude" example:"40.7128"`
+	Longitude *float64 `json:"longitude" example:"-74.006"`
+	Notes     string   `json:"notes" example:"Lorem ipsum"`
+	Aliases   []string `json:"aliases" validate:"dive,required,max=255" example:"Johnny"`
+}
+
+// Dossier is everything known about a target.
+type Dossier struct {
+	Target
+	Aliases []string       `json:"aliases"`
+	Photos  []*TargetPhoto `json:"photos"`
+	// History is every note written on the target, newest first.
+	History []*TargetNote `json:"notes_history"`
+	// Missions are every mission the target is part of, newest first.
+	Missions []*TargetMission `json:"missions"`
+	// RequiredSkills are required of the cat of every mission the target is part of.
+	RequiredSkills []RequiredSkill `json:"required_skills"`
+}
+
+// TargetMission is a mission a target is part of.
+type TargetMission struct {
+	MissionID int `json:"mission_id" example:"1"`
+	CatID     int `json:"cat_id,omitempty" example:"1"`
+	// Completed tells whether the target is completed in the mission.
+	Completed        bool       `json:"completed" example:"false"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+	MissionCompleted bool       `json:"mission_completed" example:"false"`
+	AddedAt          time.Time  `json:"added_at"`
+}
+
+// TargetNote is a note written on a target.
+type TargetNote struct {
+	ID        int64     `json:"id"`
+	TargetID  int       `json:"target_id" example:"1"`
+	UserID    int       `json:"user_id,omitempty" example:"1"`
+	Username  string    `json:"username,omitempty" example:"username"`
+	Body      string    `json:"body" example:"Seen at the airport"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TargetPhoto is a photo of a target, kept as a link.
+type TargetPhoto struct {
+	ID        int       `json:"id"`
+	TargetID  int       `json:"target_id" example:"1"`
+	UserID    int       `json:"user_id,omitempty" example:"1"`
+	URL       string    `json:"url" example:"https://example.com/photos/1.jpg"`
+	Caption   string    `json:"caption,omitempty" example:"Leaving the embassy"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TargetNoteRequest struct {
+	Body string `json:"body" validate:"required,max=10000" example:"Seen at the airport"`
+}
+
+type TargetPhotoRequest struct {
+	URL     string `json:"url" validate:"required,url,max=2048" example:"https://example.com/photos/1.jpg"`
+	Caption string `json:"caption" validate:"max=255" example:"Leaving the embassy"`
+}
+
+type TargetAliasRequest struct {
+	Alias string `json:"alias" validate:"required,max=255" example:"Johnny"`
+}
+
+// MergeCandidate is a pair of targets likely to be the same, Target being the
+// one registered first.
+type MergeCandidate struct {
+	Target     Target    `json:"target"`
+	Duplicate  Target    `json:"duplicate"`
+	Score      float64   `json:"score" example:"0.96"`
+	Reasons    []string  `json:"reasons" example:"similar name"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// MergeCandidateFilter pages through the merge candidates.
+type MergeCandidateFilter struct {
+	Limit  int `query:"limit"`
+	Offset int `query:"offset"`
+}
+
+// TargetMergeRequest merges the duplicates into the target.
+type TargetMergeRequest struct {
+	TargetID     int   `json:"target_id" validate:"required" example:"1"`
+	DuplicateIDs []int `json:"duplicate_ids" validate:"required,min=1,dive,required" example:"2"`
+}
+
+// MergeDismissal tells that two targets are not the same.
+type MergeDismissal struct {
+	TargetID    int `json:"target_id" validate:"required" example:"1"`
+	DuplicateID int `json:"duplicate_id" validate:"required" example:"2"`
+}
+
+// Relationship is a directed link between two targets, read as To being the
+// Type of From: the employer of a target is linked from the target.
+type Relationship struct {
+	ID     int    `json:"id"`
+	FromID int    `json:"from_id" example:"1"`
+	ToID   int    `json:"to_id" example:"2"`
+	Type   string `json:"type" example:"employer"`
+	// Confidence is how sure the link is, from 0 to 1.
+	Confidence float64   `json:"confidence" example:"0.8"`
+	Source     string    `json:"source,omitempty" example:"Intercepted call of 2024-03-01"`
+	UserID     int       `json:"user_id,omitempty" example:"1"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RelationshipRequest links a target to another one.
+type RelationshipRequest struct {
+	ToID int    `json:"to_id" validate:"required" example:"2"`
+	Type string `json:"type" validate:"required,oneof=associate employer employee family handler informant" example:"employer"`
+	// Confidence is 1 when left out.
+	Confidence float64 `json:"confidence" validate:"omitempty,gt=0,lte=1" example:"0.8"`
+	Source     string  `json:"source" validate:"max=1000" example:"Intercepted call of 2024-03-01"`
+}
+
+// TargetGraph is the part of the relationship graph around a target.
+type TargetGraph struct {
+	TargetID int             `json:"target_id" example:"1"`
+	Nodes    []*GraphNode    `json:"nodes"`
+	Edges    []*Relationship `json:"edges"`
+}
+
+// GraphNode is a target of a graph, Depth being the number of relationships
+// between it and the target the graph is around, in either direction.
+type GraphNode struct {
+	ID      int    `json:"id" example:"2"`
+	Name    string `json:"name" example:"John Doe"`
+	Country string `json:"country" example:"USA"`
+	City    string `json:"city,omitempty" example:"New York"`
+	Depth   int    `json:"depth" example:"1"`
 }
@@ -1,98 +1,95 @@
 package domain
 
-import (
-	"strings"
+import "time"
 
-	"github.com/go-playground/validator"
+const (
+	RoleAdmin   = "admin"
+	RoleHandler = "handler"
 )
 
 type User struct {
 	ID       int    `json:"id"`
+	Role     string `json:"role" example:"handler"`
 	Username string `json:"username" validate:"min=3,max=50" example:"username"`
-	Password string `json:"password" validate:"min=8,max=50,number,upper,lower,special" example:"Password12345!"`
+	Password string `json:"password" example:"Password12345!"`
 	Email    string `json:"email" validate:"email" example:"email@example.com"`
+	// EmailVerifiedAt is nil until the user follows the verification email.
+	EmailVerifiedAt *time.Time `json:"-"`
+	// SessionVersion is bumped to revoke every access token of the user.
+	SessionVersion int        `json:"-"`
+	TOTPSecret     string     `json:"-"`
+	TOTPEnabledAt  *time.Time `json:"-"`
+	// DisabledAt is set while an admin keeps the user from signing in.
+	DisabledAt *time.Time `json:"-"`
+	// PasswordResetRequired is set by an admin, the password is then refused until it is reset.
+	PasswordResetRequired bool `json:"-"`
 }
 
-type UserRequest struct {
-	Username string `json:"username" validate:"required,min=3,max=50" example:"username"`
-	Password string `json:"password" validate:"required,min=8,max=50,number,upper,lower,special" example:"Password12345!"`
-	Email    string `json:"email" validate:"required,email" example:"email@example.com"`
+// Profile is the account of a user, as shown to the user and to admins.
+type Profile struct {
+	ID                    int        `json:"id" example:"1"`
+	Username              string     `json:"username" example:"username"`
+	Email                 string     `json:"email" example:"email@example.com"`
+	Role                  string     `json:"role" example:"handler"`
+	EmailVerified         bool       `json:"email_verified"`
+	MFAEnabled            bool       `json:"mfa_enabled"`
+	HasPassword           bool       `json:"has_password"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	CreatedAt             time.Time  `json:"created_at"`
+	DisabledAt            *time.Time `json:"disabled_at,omitempty"`
 }
 
-type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email" example:"email@example.com"`
-	Password string `json:"password" validate:"required,min=8,max=50,number,upper,lower,special" example:"Password12345!"`
+// ProfileUpdate changes the fields given. Changing the email requires the
+// current password, or a recent login for users without one, and the new
+// address to be verified again.
+type ProfileUpdate struct {
+	Username *string `json:"username" validate:"omitempty,min=3,max=50" example:"username"`
+	Email    *string `json:"email" validate:"omitempty,email" example:"email@example.com"`
+	Password string  `json:"password" example:"Password12345!"`
 }
 
-// ValidateContainsNumber checks if password contains at least one number
-//
-// Example:
-//
-//	ValidateContainsNumber("password12345") // true
-//	ValidateContainsNumber("password") // false
-func ValidateContainsNumber(fl validator.FieldLevel) bool {
-	password := fl.Field().String()
-
-	for _, char := range password {
-		if char >= '0' && char <= '9' {
-			return true
-		}
-	}
-
-	return false
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required" example:"Password12345!"`
+	NewPassword     string `json:"new_password" validate:"required" example:"Password54321!"`
 }
 
-// ValidateContainsUpper checks if password contains at least one uppercase letter
-//
-// Example:
-//
-//	ValidateContainsUpper("Password") // true
-//	ValidateContainsUpper("password") // false
-func ValidateContainsUpper(fl validator.FieldLevel) bool {
-	password := fl.Field().String()
-
-	for _, char := range password {
-		if char >= 'A' && char <= 'Z' {
-			return true
-		}
-	}
-
-	return false
+// AccountDeletionRequest confirms the deletion with the password, users without
+// one leave it out and must have signed in recently.
+type AccountDeletionRequest struct {
+	Password string `json:"password" example:"Password12345!"`
 }
 
-// ValidateContainsLower checks if password contains at least one lowercase letter
-//
-// Example:
-//
-//	ValidateContainsLower("password") // true
-//	ValidateContainsLower("PASSWORD") // false
-func ValidateContainsLower(fl validator.FieldLevel) bool {
-	password := fl.Field().String()
+// UserFilter selects a page of users, Query matches the username or the email.
+type UserFilter struct {
+	Query  string `query:"q"`
+	Limit  int    `query:"limit"`
+	Offset int    `query:"offset"`
+}
 
-	for _, char := range password {
-		if char >= 'a' && char <= 'z' {
-			return true
-		}
-	}
+type UserRequest struct {
+	Username string `json:"username" validate:"required,min=3,max=50" example:"username"`
+	Password string `json:"password" validate:"required" example:"Password12345!"`
+	Email    string `json:"email" validate:"required,email" example:"email@example.com"`
+}
 
-	return false
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email" example:"email@example.com"`
+	Password string `json:"password" validate:"required" example:"Password12345!"`
 }
 
-// ValidateContainsSpecial checks if password contains at least one special character
-//
-// Example:
-//
-//	ValidateContainsSpecial("password!") // true
-//	ValidateContainsSpecial("password") // false
-func ValidateContainsSpecial(fl validator.FieldLevel) bool {
-	password := fl.Field().String()
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email" example:"email@example.com"`
+}
 
-	specialChars := "!@#$%^&*()-_=+[]{}|;:'\",.<>/?"
-	for _, char := range password {
-		if strings.ContainsRune(specialChars, char) {
-			return true
-		}
-	}
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required" example:"c2VjcmV0LXJlc2V0LXRva2Vu"`
+	Password string `json:"password" validate:"required" example:"Password12345!"`
+}
 
-	return false
+// ResetToken is a single-use password reset token, only its SHA-256 hash is stored.
+type ResetToken struct {
+	ID        int64
+	UserID    int
+	Hash      []byte
+	ExpiresAt time.Time
 }
@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// Webhook is a subscription of an external URL to domain events.
+type Webhook struct {
+	ID         int         `json:"id"`
+	URL        string      `json:"url" example:"https://ops.example.com/hooks/spycat"`
+	EventTypes []EventType `json:"event_types" example:"mission.assigned,mission.completed"`
+	Secret     string      `json:"-"`
+	Active     bool        `json:"active" example:"true"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+type WebhookRequest struct {
+	URL        string   `json:"url" validate:"required,url" example:"https://ops.example.com/hooks/spycat"`
+	EventTypes []string `json:"event_types" validate:"required,min=1,dive,oneof=cat.created cat.deleted mission.created mission.assigned mission.completed mission.overdue mission.rescheduled mission.deleted target.completed" example:"mission.assigned,mission.completed"`
+	Secret     string   `json:"secret" validate:"required,min=16,max=256" example:"a-long-shared-secret"`
+	Active     *bool    `json:"active,omitempty" example:"true"`
+}
+
+// WebhookDelivery is an attempt to deliver an event to a webhook.
+// Pending deliveries form the outbox the dispatcher works through.
+type WebhookDelivery struct {
+	ID            int64          `json:"id"`
+	WebhookID     int            `json:"webhook_id" example:"1"`
+	EventID       int64          `json:"event_id" example:"1"`
+	EventType     EventType      `json:"event_type" example:"mission.assigned"`
+	Status        DeliveryStatus `json:"status" example:"delivered"`
+	Attempts      int            `json:"attempts" example:"1"`
+	NextAttemptAt time.Time      `json:"next_attempt_at"`
+	ResponseCode  int            `json:"response_code,omitempty" example:"200"`
+	LastError     string         `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time     `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// OutboxDelivery is a pending delivery claimed by the dispatcher together
+// with everything needed to send it.
+type OutboxDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+	Event  Event
+}
@@ -0,0 +1,117 @@
+package assignment
+
+import "math"
+
+// Solve finds the assignment of rows to columns with the minimal total cost
+// using the Hungarian algorithm in O(n^2 * m).
+//
+// The cost matrix may be rectangular, but all rows must have the same length.
+// The result holds the assigned column for every row, or -1 when the row is
+// left unassigned because there are fewer columns than rows.
+func Solve(cost [][]float64) []int {
+	if len(cost) == 0 {
+		return []int{}
+	}
+
+	if len(cost) > len(cost[0]) {
+		return transposed(cost)
+	}
+
+	n, m := len(cost), len(cost[0])
+
+	// u, v are the potentials of rows and columns, p[j] is the row matched
+	// to column j and way[j] is the previous column on the augmenting path.
+	// Index 0 is a sentinel, rows and columns are 1-based.
+	u := make([]float64, n+1)
+	v := make([]float64, m+1)
+	p := make([]int, m+1)
+	way := make([]int, m+1)
+
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, m+1)
+		used := make([]bool, m+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+
+		for {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+
+			for j := 1; j <= m; j++ {
+				if used[j] {
+					continue
+				}
+
+				cur := cost[i0-1][j-1] - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+
+			for j := 0; j <= m; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	result := make([]int, n)
+	for i := range result {
+		result[i] = -1
+	}
+
+	for j := 1; j <= m; j++ {
+		if p[j] != 0 {
+			result[p[j]-1] = j - 1
+		}
+	}
+
+	return result
+}
+
+func transposed(cost [][]float64) []int {
+	t := make([][]float64, len(cost[0]))
+	for j := range t {
+		t[j] = make([]float64, len(cost))
+		for i := range cost {
+			t[j][i] = cost[i][j]
+		}
+	}
+
+	result := make([]int, len(cost))
+	for i := range result {
+		result[i] = -1
+	}
+
+	for j, i := range Solve(t) {
+		if i >= 0 {
+			result[i] = j
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,119 @@
+package assignment
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func total(cost [][]float64, result []int) float64 {
+	var sum float64
+	for i, j := range result {
+		if j >= 0 {
+			sum += cost[i][j]
+		}
+	}
+
+	return sum
+}
+
+// bruteForce returns the minimal total cost assigning min(rows, cols) pairs.
+func bruteForce(cost [][]float64) float64 {
+	best := math.Inf(1)
+	used := make([]bool, len(cost[0]))
+	need := len(cost)
+	if len(cost[0]) < need {
+		need = len(cost[0])
+	}
+
+	var walk func(i, assigned int, sum float64)
+	walk = func(i, assigned int, sum float64) {
+		if assigned == need {
+			best = math.Min(best, sum)
+			return
+		}
+		if i == len(cost) {
+			return
+		}
+		if len(cost)-i > need-assigned {
+			walk(i+1, assigned, sum)
+		}
+		for j := range cost[i] {
+			if !used[j] {
+				used[j] = true
+				walk(i+1, assigned+1, sum+cost[i][j])
+				used[j] = false
+			}
+		}
+	}
+	walk(0, 0, 0)
+
+	return best
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name string
+		cost [][]float64
+		want []int
+	}{
+		{
+			name: "empty",
+			cost: nil,
+			want: []int{},
+		},
+		{
+			name: "square",
+			cost: [][]float64{
+				{4, 1, 3},
+				{2, 0, 5},
+				{3, 2, 2},
+			},
+			want: []int{1, 0, 2},
+		},
+		{
+			name: "more columns",
+			cost: [][]float64{
+				{9, 1, 9, 9},
+				{9, 9, 9, 2},
+			},
+			want: []int{1, 3},
+		},
+		{
+			name: "more rows",
+			cost: [][]float64{
+				{5, 9},
+				{1, 9},
+				{9, 1},
+			},
+			want: []int{-1, 0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Solve(tt.cost))
+		})
+	}
+}
+
+func TestSolveMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for n := 1; n <= 5; n++ {
+		for m := 1; m <= 5; m++ {
+			cost := make([][]float64, n)
+			for i := range cost {
+				cost[i] = make([]float64, m)
+				for j := range cost[i] {
+					cost[i][j] = float64(r.Intn(100))
+				}
+			}
+
+			result := Solve(cost)
+			assert.InDelta(t, bruteForce(cost), total(cost, result), 1e-9, "n=%d m=%d", n, m)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Package dedupe finds targets that are likely the same person entered more
+// than once, by their names, aliases and countries.
+package dedupe
+
+import (
+	"bufio"
+	_ "embed"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Reasons a match is given for.
+const (
+	ReasonSameName    = "same name"
+	ReasonSimilarName = "similar name"
+	ReasonAlias       = "alias"
+)
+
+//go:embed countries.txt
+var countriesText string
+
+// countries maps the compacted names and codes of the countries to their alpha-2 code.
+var countries = readCountries(countriesText)
+
+// Record is a target to compare.
+type Record struct {
+	ID      int
+	Name    string
+	Country string
+	Aliases []string
+}
+
+// Match is a pair of records likely to be the same target, TargetID being the
+// lower of their IDs.
+type Match struct {
+	TargetID    int
+	DuplicateID int
+	Score       float64
+	Reasons     []string
+}
+
+// Find returns the pairs of records of the same country whose names or aliases
+// are at least threshold similar, the most similar first. The score of a pair
+// is that of its most similar names.
+func Find(records []Record, threshold float64) []Match {
+	type entry struct {
+		id    int
+		names []string // the normalized name first, then the aliases
+	}
+
+	byCountry := make(map[string][]entry)
+	for _, r := range records {
+		e := entry{id: r.ID}
+		for _, name := range append([]string{r.Name}, r.Aliases...) {
+			if name = NormalizeName(name); name != "" {
+				e.names = append(e.names, name)
+			}
+		}
+		if len(e.names) == 0 {
+			continue
+		}
+
+		country := NormalizeCountry(r.Country)
+		byCountry[country] = append(byCountry[country], e)
+	}
+
+	var matches []Match
+	for _, entries := range byCountry {
+		for i := range entries {
+			for j := i + 1; j < len(entries); j++ {
+				a, b := entries[i], entries[j]
+
+				var best float64
+				var alias bool
+				for x, nameA := range a.names {
+					for y, nameB := range b.names {
+						if score := Similarity(nameA, nameB); score > best {
+							best, alias = score, x > 0 || y > 0
+						}
+					}
+				}
+
+				if best < threshold {
+					continue
+				}
+
+				m := Match{TargetID: min(a.id, b.id), DuplicateID: max(a.id, b.id), Score: best}
+				switch {
+				case alias:
+					m.Reasons = append(m.Reasons, ReasonAlias)
+				case a.names[0] == b.names[0]:
+					m.Reasons = append(m.Reasons, ReasonSameName)
+				default:
+					m.Reasons = append(m.Reasons, ReasonSimilarName)
+				}
+
+				matches = append(matches, m)
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].TargetID != matches[j].TargetID {
+			return matches[i].TargetID < matches[j].TargetID
+		}
+		return matches[i].DuplicateID < matches[j].DuplicateID
+	})
+
+	return matches
+}
+
+// NormalizeName folds the case and the accents of the name, drops apostrophes
+// and dots and turns any other punctuation into single spaces, so that
+// "John  O'Brien-Doe" becomes "john obrien doe".
+func NormalizeName(name string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range norm.NFKD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r), r == '\'', r == '’', r == '.':
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			space = true
+		}
+	}
+
+	return b.String()
+}
+
+// NormalizeCountry returns the ISO 3166-1 alpha-2 code of the country, given
+// by its name or either code, and the compacted name of unknown countries.
+func NormalizeCountry(country string) string {
+	key := compact(country)
+	if code, ok := countries[key]; ok {
+		return code
+	}
+
+	return key
+}
+
+// Similarity returns the Jaro-Winkler similarity of two normalized names, from
+// 0 to 1, the higher of the names as written and with their words sorted, so
+// that "doe john" is as similar to "john doe" as it is to itself.
+func Similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+
+	return max(JaroWinkler(a, b), JaroWinkler(sortWords(a), sortWords(b)))
+}
+
+// JaroWinkler returns the Jaro-Winkler similarity of the strings, from 0 to 1.
+func JaroWinkler(a, b string) float64 {
+	s, t := []rune(a), []rune(b)
+	if len(s) == 0 || len(t) == 0 {
+		if len(s) == len(t) {
+			return 1
+		}
+		return 0
+	}
+
+	window := max(len(s), len(t))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	sMatched := make([]bool, len(s))
+	tMatched := make([]bool, len(t))
+
+	matches := 0
+	for i := range s {
+		for j := max(0, i-window); j < min(len(t), i+window+1); j++ {
+			if !tMatched[j] && s[i] == t[j] {
+				sMatched[i], tMatched[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, j := 0, 0
+	for i := range s {
+		if !sMatched[i] {
+			continue
+		}
+		for !tMatched[j] {
+			j++
+		}
+		if s[i] != t[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions/2))/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(s), len(t)) && s[prefix] == t[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+func sortWords(s string) string {
+	words := strings.Fields(s)
+	sort.Strings(words)
+
+	return strings.Join(words, " ")
+}
+
+func compact(s string) string {
+	return strings.ReplaceAll(NormalizeName(s), " ", "")
+}
+
+func readCountries(text string) map[string]string {
+	countries := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		names := strings.Split(line, ",")
+		code := strings.ToLower(names[0])
+		for _, name := range names {
+			countries[compact(name)] = code
+		}
+	}
+
+	return countries
+}
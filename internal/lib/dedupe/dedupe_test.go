@@ -0,0 +1,83 @@
+package dedupe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeName(t *testing.T) {
+	for in, want := range map[string]string{
+		"John Doe":          "john doe",
+		"  john   DOE ":     "john doe",
+		"John  O'Brien-Doe": "john obrien doe",
+		"J.R.R. Tolkien":    "jrr tolkien",
+		"José Martínez":     "jose martinez",
+		"Łukasz Żółć":       "łukasz zołc",
+		"ＪＯＨＮ ＤＯＥ":          "john doe",
+		"Doe, John":         "doe john",
+		"":                  "",
+		"---":               "",
+		"Agent 007":         "agent 007",
+		"Zoë Saldaña/Smith": "zoe saldana smith",
+	} {
+		assert.Equal(t, want, NormalizeName(in), in)
+	}
+}
+
+func TestNormalizeCountry(t *testing.T) {
+	for _, us := range []string{"USA", "US", "u.s.a.", "United States", "united states of america", " America "} {
+		assert.Equal(t, "us", NormalizeCountry(us), us)
+	}
+	assert.Equal(t, "gb", NormalizeCountry("UK"))
+	assert.Equal(t, "gb", NormalizeCountry("GBR"))
+	assert.Equal(t, "ci", NormalizeCountry("Côte d’Ivoire"))
+	assert.Equal(t, "atlantis", NormalizeCountry("Atlantis"))
+}
+
+func TestJaroWinkler(t *testing.T) {
+	assert.InDelta(t, 0.961, JaroWinkler("martha", "marhta"), 0.001)
+	assert.InDelta(t, 0.840, JaroWinkler("dwayne", "duane"), 0.001)
+	assert.InDelta(t, 0.813, JaroWinkler("dixon", "dicksonx"), 0.001)
+	assert.Equal(t, 1.0, JaroWinkler("", ""))
+	assert.Equal(t, 0.0, JaroWinkler("abc", ""))
+	assert.Equal(t, 0.0, JaroWinkler("abc", "xyz"))
+	assert.Equal(t, 1.0, JaroWinkler("same", "same"))
+}
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, Similarity("john doe", "john doe"))
+	assert.Equal(t, 1.0, Similarity("doe john", "john doe"))
+	assert.Greater(t, Similarity("jon doe", "john doe"), 0.9)
+	assert.Less(t, Similarity("jane roe", "john doe"), 0.8)
+}
+
+func TestFind(t *testing.T) {
+	records := []Record{
+		{ID: 1, Name: "John Doe", Country: "USA"},
+		{ID: 2, Name: "john  doe", Country: "US"},
+		{ID: 3, Name: "Jon Doe", Country: "United States"},
+		{ID: 4, Name: "John Doe", Country: "Canada"},
+		{ID: 5, Name: "Max Mustermann", Country: "Germany"},
+		{ID: 6, Name: "The Accountant", Country: "DEU", Aliases: []string{"Max Mustermann"}},
+		{ID: 7, Name: "Erika Mustermann", Country: "Germany"},
+		{ID: 8, Name: "...", Country: "USA"},
+	}
+
+	matches := Find(records, 0.9)
+	if !assert.Len(t, matches, 4) {
+		return
+	}
+
+	assert.Equal(t, Match{TargetID: 1, DuplicateID: 2, Score: 1, Reasons: []string{ReasonSameName}}, matches[0])
+	assert.Equal(t, Match{TargetID: 5, DuplicateID: 6, Score: 1, Reasons: []string{ReasonAlias}}, matches[1])
+
+	for _, m := range matches[2:] {
+		assert.Equal(t, 3, m.DuplicateID)
+		assert.Equal(t, []string{ReasonSimilarName}, m.Reasons)
+		assert.Less(t, m.Score, 1.0)
+	}
+
+	assert.Empty(t, Find(records, 1.01))
+	assert.Empty(t, Find(nil, 0.9))
+}
@@ -0,0 +1,133 @@
+// Package egress keeps requests the server makes to URLs given by users, such
+// as webhook deliveries, away from the network of the server itself.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenDestination is returned for URLs and connections reaching a
+// loopback, link-local, private or otherwise non-public address.
+var ErrForbiddenDestination = errors.New("destination is not a public address")
+
+// nonPublic are the ranges Public refuses on top of those netip tells about.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// Shared address space of carrier-grade NAT, RFC 6598.
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// NAT64, reaching IPv4 addresses through IPv6, RFC 6052.
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// Public tells whether the address is a public unicast one: not loopback,
+// link-local, private, multicast or unspecified.
+func Public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range nonPublic {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Guard checks the destinations of requests.
+type Guard struct {
+	// allowPrivate lets requests reach any address, for development.
+	allowPrivate bool
+	resolver     *net.Resolver
+}
+
+func New(allowPrivate bool) *Guard {
+	return &Guard{allowPrivate: allowPrivate, resolver: net.DefaultResolver}
+}
+
+// CheckURL returns ErrForbiddenDestination unless the URL is an http or https
+// one whose host resolves to public addresses only.
+func (g *Guard) CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrForbiddenDestination, u.Scheme)
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("%w: no host", ErrForbiddenDestination)
+	}
+
+	if g.allowPrivate {
+		return nil
+	}
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return checkAddr(ip)
+	}
+
+	addrs, err := g.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+
+	for _, ip := range addrs {
+		if err := checkAddr(ip); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Client returns an HTTP client refusing to connect to non-public addresses,
+// redirects included. The address is checked once resolved, right before
+// connecting, so a host resolving to another address later is caught too.
+func (g *Guard) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: g.control}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Through a proxy only the proxy address would be checked.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func (g *Guard) control(network, address string, _ syscall.RawConn) error {
+	if g.allowPrivate {
+		return nil
+	}
+
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+
+	return checkAddr(addrPort.Addr())
+}
+
+func checkAddr(ip netip.Addr) error {
+	if !Public(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenDestination, ip)
+	}
+
+	return nil
+}
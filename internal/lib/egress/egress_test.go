@@ -0,0 +1,79 @@
+package egress
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Public(netip.MustParseAddr(tt.ip)), tt.ip)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	g := New(false)
+	ctx := context.Background()
+
+	assert.NoError(t, g.CheckURL(ctx, "https://93.184.216.34/hooks"))
+
+	for _, u := range []string{
+		"http://127.0.0.1:8000/api",
+		"http://[::1]/",
+		"http://169.254.169.254/latest/meta-data/",
+		"https://10.0.0.5/hooks",
+		"http://localhost:8000/",
+		"ftp://93.184.216.34/",
+		"http:///path",
+	} {
+		assert.ErrorIs(t, g.CheckURL(ctx, u), ErrForbiddenDestination, u)
+	}
+
+	assert.NoError(t, New(true).CheckURL(ctx, "http://127.0.0.1:8000/api"), "private allowed")
+	assert.ErrorIs(t, New(true).CheckURL(ctx, "file:///etc/passwd"), ErrForbiddenDestination, "scheme still checked")
+}
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	_, err := New(false).Client(time.Second).Get(srv.URL)
+	assert.ErrorIs(t, err, ErrForbiddenDestination)
+
+	resp, err := New(true).Client(time.Second).Get(srv.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	}
+}
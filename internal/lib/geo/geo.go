@@ -0,0 +1,96 @@
+// Package geo measures distances between points on the Earth, taken as a sphere.
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EarthRadiusKm is the mean radius of the Earth.
+const EarthRadiusKm = 6371.0088
+
+// KmPerDegree is the length of a degree of latitude, no two points are closer
+// than their difference in latitude times it.
+const KmPerDegree = EarthRadiusKm * math.Pi / 180
+
+// Valid tells whether the latitude and the longitude are within their ranges.
+func Valid(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// ParsePoint parses a point written as "lat,lng" in decimal degrees.
+func ParsePoint(s string) (lat, lng float64, err error) {
+	latText, lngText, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid point %q, expected lat,lng", s)
+	}
+
+	lat, err = strconv.ParseFloat(strings.TrimSpace(latText), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid latitude %q", latText)
+	}
+
+	lng, err = strconv.ParseFloat(strings.TrimSpace(lngText), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid longitude %q", lngText)
+	}
+
+	if !Valid(lat, lng) {
+		return 0, 0, fmt.Errorf("point %q is out of range", s)
+	}
+
+	return lat, lng, nil
+}
+
+// Distance returns the great-circle distance in kilometres between two points
+// with the haversine formula.
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	phi1, phi2 := radians(lat1), radians(lat2)
+	dPhi, dLambda := radians(lat2-lat1), radians(lng2-lng1)
+
+	h := math.Pow(math.Sin(dPhi/2), 2) + math.Cos(phi1)*math.Cos(phi2)*math.Pow(math.Sin(dLambda/2), 2)
+
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// Centroid accumulates points to find their centroid on the sphere. Unlike the
+// mean of the coordinates it holds for points across the antimeridian.
+type Centroid struct {
+	x, y, z float64
+	n       int
+}
+
+// Add adds a point to the centroid.
+func (c *Centroid) Add(lat, lng float64) {
+	phi, lambda := radians(lat), radians(lng)
+
+	c.x += math.Cos(phi) * math.Cos(lambda)
+	c.y += math.Cos(phi) * math.Sin(lambda)
+	c.z += math.Sin(phi)
+	c.n++
+}
+
+// Point returns the centroid of the points added, ok is false without points
+// or when they balance out, as antipodes do.
+func (c *Centroid) Point() (lat, lng float64, ok bool) {
+	if c.n == 0 {
+		return 0, 0, false
+	}
+
+	x, y, z := c.x/float64(c.n), c.y/float64(c.n), c.z/float64(c.n)
+	if math.Sqrt(x*x+y*y+z*z) < 1e-9 {
+		return 0, 0, false
+	}
+
+	return degrees(math.Atan2(z, math.Hypot(x, y))), degrees(math.Atan2(y, x)), true
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
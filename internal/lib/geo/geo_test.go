@@ -0,0 +1,73 @@
+package geo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"same point", 50.4501, 30.5234, 50.4501, 30.5234, 0},
+		{"Kyiv to Lviv", 50.4501, 30.5234, 49.8397, 24.0297, 467.9},
+		{"London to Paris", 51.5074, -0.1278, 48.8566, 2.3522, 343.6},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, 111.2},
+		{"antipodes", 0, 0, 0, 180, 20015.1},
+		{"one degree of latitude", 10, 20, 11, 20, KmPerDegree},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, Distance(tt.lat1, tt.lng1, tt.lat2, tt.lng2), 0.5)
+			assert.InDelta(t, tt.want, Distance(tt.lat2, tt.lng2, tt.lat1, tt.lng1), 0.5)
+		})
+	}
+}
+
+func TestParsePoint(t *testing.T) {
+	lat, lng, err := ParsePoint("50.4501, 30.5234")
+	assert.NoError(t, err)
+	assert.Equal(t, 50.4501, lat)
+	assert.Equal(t, 30.5234, lng)
+
+	lat, lng, err = ParsePoint("-90,180")
+	assert.NoError(t, err)
+	assert.Equal(t, -90.0, lat)
+	assert.Equal(t, 180.0, lng)
+
+	for _, s := range []string{"", "50.45", "50.45;30.52", "north,30.52", "50.45,east", "91,0", "0,-180.5", "NaN,0"} {
+		_, _, err := ParsePoint(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestCentroid(t *testing.T) {
+	var c Centroid
+	_, _, ok := c.Point()
+	assert.False(t, ok, "no points")
+
+	c.Add(50.4501, 30.5234)
+	lat, lng, ok := c.Point()
+	assert.True(t, ok)
+	assert.InDelta(t, 50.4501, lat, 1e-9)
+	assert.InDelta(t, 30.5234, lng, 1e-9)
+
+	c = Centroid{}
+	c.Add(10, 179)
+	c.Add(-10, -179)
+	lat, lng, ok = c.Point()
+	assert.True(t, ok)
+	assert.InDelta(t, 0, lat, 1e-9)
+	assert.InDelta(t, 180, math.Abs(lng), 1e-9, "across the antimeridian")
+
+	c = Centroid{}
+	c.Add(0, 0)
+	c.Add(0, 180)
+	_, _, ok = c.Point()
+	assert.False(t, ok, "antipodes")
+}
@@ -0,0 +1,138 @@
+// Package geojson lays targets out as GeoJSON feature collections, RFC 7946,
+// for map tools.
+package geojson
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/markraiter/spycat/internal/lib/geo"
+)
+
+// Layers of a collection, every feature tells its layer in the "layer" property.
+const (
+	// LayerTargets has a point for each located target.
+	LayerTargets = "targets"
+	// LayerCountries has a feature for each country with the number of its
+	// targets, placed at the centroid of its located targets.
+	LayerCountries = "countries"
+)
+
+// ValidLayer tells whether the layer is known.
+func ValidLayer(layer string) bool {
+	return layer == LayerTargets || layer == LayerCountries
+}
+
+// Collection returns the layers of the targets, both when none is given.
+func Collection(targets []*domain.Target, layers ...string) *domain.FeatureCollection {
+	if len(layers) == 0 {
+		layers = []string{LayerTargets, LayerCountries}
+	}
+
+	fc := &domain.FeatureCollection{Type: "FeatureCollection", Features: make([]*domain.Feature, 0)}
+	for _, layer := range layers {
+		switch layer {
+		case LayerTargets:
+			fc.Features = append(fc.Features, targetFeatures(targets)...)
+		case LayerCountries:
+			fc.Features = append(fc.Features, countryFeatures(targets)...)
+		}
+	}
+
+	return fc
+}
+
+func targetFeatures(targets []*domain.Target) []*domain.Feature {
+	features := make([]*domain.Feature, 0, len(targets))
+	for _, t := range targets {
+		if !t.Located() {
+			continue
+		}
+
+		properties := map[string]any{
+			"layer":      LayerTargets,
+			"name":       t.Name,
+			"country":    t.Country,
+			"completed":  t.Completed,
+			"mission_id": t.MissionID,
+		}
+		if t.City != "" {
+			properties["city"] = t.City
+		}
+
+		features = append(features, &domain.Feature{
+			Type:       "Feature",
+			ID:         fmt.Sprintf("target-%d", t.ID),
+			Geometry:   point(*t.Latitude, *t.Longitude),
+			Properties: properties,
+		})
+	}
+
+	return features
+}
+
+type country struct {
+	name                        string
+	targets, completed, located int
+	centroid                    geo.Centroid
+}
+
+// countryFeatures counts the targets of each country, told apart regardless of
+// case and surrounding spaces, in the order of the country names.
+func countryFeatures(targets []*domain.Target) []*domain.Feature {
+	countries := make(map[string]*country)
+	for _, t := range targets {
+		key := strings.ToLower(strings.TrimSpace(t.Country))
+		c, ok := countries[key]
+		if !ok {
+			c = &country{name: strings.TrimSpace(t.Country)}
+			countries[key] = c
+		}
+
+		c.targets++
+		if t.Completed {
+			c.completed++
+		}
+		if t.Located() {
+			c.located++
+			c.centroid.Add(*t.Latitude, *t.Longitude)
+		}
+	}
+
+	keys := make([]string, 0, len(countries))
+	for key := range countries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	features := make([]*domain.Feature, 0, len(keys))
+	for _, key := range keys {
+		c := countries[key]
+
+		feature := &domain.Feature{
+			Type: "Feature",
+			ID:   "country-" + key,
+			Properties: map[string]any{
+				"layer":     LayerCountries,
+				"country":   c.name,
+				"targets":   c.targets,
+				"completed": c.completed,
+				"active":    c.targets - c.completed,
+				"located":   c.located,
+			},
+		}
+		if lat, lng, ok := c.centroid.Point(); ok {
+			feature.Geometry = point(lat, lng)
+		}
+
+		features = append(features, feature)
+	}
+
+	return features
+}
+
+func point(lat, lng float64) *domain.Geometry {
+	return &domain.Geometry{Type: "Point", Coordinates: [2]float64{lng, lat}}
+}
@@ -0,0 +1,68 @@
+package geojson
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func coordinate(v float64) *float64 {
+	return &v
+}
+
+var targets = []*domain.Target{
+	{ID: 1, MissionID: 1, Name: "Jane Roe", Country: "Ukraine", City: "Kyiv", Latitude: coordinate(50.4501), Longitude: coordinate(30.5234)},
+	{ID: 2, MissionID: 1, Name: "John Doe", Country: "ukraine ", Latitude: coordinate(49.8397), Longitude: coordinate(24.0297), Completed: true},
+	{ID: 3, MissionID: 2, Name: "Max Mustermann", Country: "Germany"},
+}
+
+func TestCollection(t *testing.T) {
+	fc := Collection(targets)
+	assert.Equal(t, "FeatureCollection", fc.Type)
+	if !assert.Len(t, fc.Features, 4) {
+		return
+	}
+
+	kyiv := fc.Features[0]
+	assert.Equal(t, "target-1", kyiv.ID)
+	assert.Equal(t, [2]float64{30.5234, 50.4501}, kyiv.Geometry.Coordinates, "longitude first")
+	assert.Equal(t, map[string]any{
+		"layer": LayerTargets, "name": "Jane Roe", "country": "Ukraine", "city": "Kyiv", "completed": false, "mission_id": 1,
+	}, kyiv.Properties)
+	assert.Equal(t, "target-2", fc.Features[1].ID)
+
+	germany := fc.Features[2]
+	assert.Equal(t, "country-germany", germany.ID)
+	assert.Nil(t, germany.Geometry, "no located targets")
+	assert.Equal(t, 1, germany.Properties["active"])
+	assert.Equal(t, 0, germany.Properties["located"])
+
+	ukraine := fc.Features[3]
+	assert.Equal(t, "country-ukraine", ukraine.ID)
+	assert.Equal(t, "Ukraine", ukraine.Properties["country"])
+	assert.Equal(t, 2, ukraine.Properties["targets"])
+	assert.Equal(t, 1, ukraine.Properties["completed"])
+	assert.Equal(t, 1, ukraine.Properties["active"])
+	if assert.NotNil(t, ukraine.Geometry) {
+		assert.InDelta(t, 27.28, ukraine.Geometry.Coordinates[0], 0.05)
+		assert.InDelta(t, 50.15, ukraine.Geometry.Coordinates[1], 0.05)
+	}
+}
+
+func TestCollectionLayers(t *testing.T) {
+	assert.Len(t, Collection(targets, LayerTargets).Features, 2)
+	assert.Len(t, Collection(targets, LayerCountries).Features, 2)
+	assert.True(t, ValidLayer(LayerCountries))
+	assert.False(t, ValidLayer("cities"))
+
+	raw, err := json.Marshal(Collection(nil))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"FeatureCollection","features":[]}`, string(raw))
+
+	raw, err = json.Marshal(Collection(targets[2:], LayerCountries))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"FeatureCollection","features":[{"type":"Feature","id":"country-germany","geometry":null,
+		"properties":{"layer":"countries","country":"Germany","targets":1,"completed":0,"active":1,"located":0}}]}`, string(raw))
+}
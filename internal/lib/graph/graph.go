@@ -0,0 +1,165 @@
+// Package graph writes relationship graphs of targets in the GraphML and DOT
+// formats for offline analysis tools such as Gephi, yEd and Graphviz.
+package graph
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// Formats the graph can be written in.
+const (
+	FormatGraphML = "graphml"
+	FormatDOT     = "dot"
+)
+
+const graphMLNamespace = "http://graphml.graphdrawing.org/xmlns"
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// graphMLKeys declares the attributes of the nodes and the edges.
+var graphMLKeys = []graphMLKey{
+	{ID: "name", For: "node", Name: "name", Type: "string"},
+	{ID: "country", For: "node", Name: "country", Type: "string"},
+	{ID: "city", For: "node", Name: "city", Type: "string"},
+	{ID: "depth", For: "node", Name: "depth", Type: "int"},
+	{ID: "type", For: "edge", Name: "type", Type: "string"},
+	{ID: "confidence", For: "edge", Name: "confidence", Type: "double"},
+	{ID: "source", For: "edge", Name: "source", Type: "string"},
+}
+
+// WriteGraphML writes the graph as a directed GraphML document.
+func WriteGraphML(w io.Writer, g *domain.TargetGraph) error {
+	doc := graphML{
+		XMLNS: graphMLNamespace,
+		Keys:  graphMLKeys,
+		Graph: graphMLGraph{
+			ID:          graphID(g),
+			EdgeDefault: "directed",
+			Nodes:       make([]graphMLNode, 0, len(g.Nodes)),
+			Edges:       make([]graphMLEdge, 0, len(g.Edges)),
+		},
+	}
+
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: nodeID(n.ID),
+			Data: []graphMLData{
+				{Key: "name", Value: n.Name},
+				{Key: "country", Value: n.Country},
+				{Key: "city", Value: n.City},
+				{Key: "depth", Value: strconv.Itoa(n.Depth)},
+			},
+		})
+	}
+
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     edgeID(e.ID),
+			Source: nodeID(e.FromID),
+			Target: nodeID(e.ToID),
+			Data: []graphMLData{
+				{Key: "type", Value: e.Type},
+				{Key: "confidence", Value: formatFloat(e.Confidence)},
+				{Key: "source", Value: e.Source},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteDOT writes the graph as a Graphviz digraph. Nodes are labelled with the
+// name and the country of the target, edges with the type of the relationship.
+func WriteDOT(w io.Writer, g *domain.TargetGraph) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "digraph %s {\n", quote(graphID(g)))
+	for _, n := range g.Nodes {
+		fmt.Fprintf(bw, "  %s [label=%s, country=%s, city=%s, depth=%d];\n",
+			nodeID(n.ID), quote(n.Name+"\n"+n.Country), quote(n.Country), quote(n.City), n.Depth)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "  %s -> %s [id=%s, label=%s, confidence=%s, source=%s];\n",
+			nodeID(e.FromID), nodeID(e.ToID), edgeID(e.ID), quote(e.Type), formatFloat(e.Confidence), quote(e.Source))
+	}
+	fmt.Fprint(bw, "}\n")
+
+	return bw.Flush()
+}
+
+func graphID(g *domain.TargetGraph) string {
+	return "target-" + strconv.Itoa(g.TargetID)
+}
+
+func nodeID(id int) string {
+	return "n" + strconv.Itoa(id)
+}
+
+func edgeID(id int) string {
+	return "e" + strconv.Itoa(id)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", `\n`)
+
+// quote makes s a DOT double-quoted string.
+func quote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
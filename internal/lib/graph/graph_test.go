@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var g = &domain.TargetGraph{
+	TargetID: 1,
+	Nodes: []*domain.GraphNode{
+		{ID: 1, Name: "John Doe", Country: "USA", City: "New York", Depth: 0},
+		{ID: 2, Name: `Acme "Global" Corp`, Country: "UK", Depth: 1},
+	},
+	Edges: []*domain.Relationship{
+		{ID: 7, FromID: 1, ToID: 2, Type: "employer", Confidence: 0.8, Source: "Tax records & payslips"},
+	},
+}
+
+func TestWriteGraphML(t *testing.T) {
+	var buf bytes.Buffer
+	if !assert.NoError(t, WriteGraphML(&buf, g)) {
+		return
+	}
+
+	var doc graphML
+	if !assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc), "well-formed") {
+		return
+	}
+
+	assert.Equal(t, graphMLNamespace, doc.XMLNS)
+	assert.Equal(t, "target-1", doc.Graph.ID)
+	assert.Equal(t, "directed", doc.Graph.EdgeDefault)
+	assert.Len(t, doc.Keys, len(graphMLKeys))
+
+	if assert.Len(t, doc.Graph.Nodes, 2) {
+		assert.Equal(t, "n2", doc.Graph.Nodes[1].ID)
+		assert.Contains(t, doc.Graph.Nodes[1].Data, graphMLData{Key: "name", Value: `Acme "Global" Corp`})
+		assert.Contains(t, doc.Graph.Nodes[1].Data, graphMLData{Key: "depth", Value: "1"})
+	}
+
+	if assert.Len(t, doc.Graph.Edges, 1) {
+		e := doc.Graph.Edges[0]
+		assert.Equal(t, "e7", e.ID)
+		assert.Equal(t, "n1", e.Source)
+		assert.Equal(t, "n2", e.Target)
+		assert.Contains(t, e.Data, graphMLData{Key: "confidence", Value: "0.8"})
+		assert.Contains(t, e.Data, graphMLData{Key: "source", Value: "Tax records & payslips"})
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if !assert.NoError(t, WriteDOT(&buf, g)) {
+		return
+	}
+
+	want := `digraph "target-1" {
+  n1 [label="John Doe\nUSA", country="USA", city="New York", depth=0];
+  n2 [label="Acme \"Global\" Corp\nUK", country="UK", city="", depth=1];
+  n1 -> n2 [id=e7, label="employer", confidence=0.8, source="Tax records & payslips"];
+}
+`
+	assert.Equal(t, want, buf.String())
+}
+
+func TestWriteEmpty(t *testing.T) {
+	empty := &domain.TargetGraph{TargetID: 3}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteDOT(&buf, empty))
+	assert.Equal(t, "digraph \"target-3\" {\n}\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, WriteGraphML(&buf, empty))
+	assert.Contains(t, buf.String(), `<graph id="target-3" edgedefault="directed"></graph>`)
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", `"plain"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\path`, `"C:\\path"`},
+		{"two\r\nlines", `"two\nlines"`},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, quote(tt.in), tt.in)
+	}
+}
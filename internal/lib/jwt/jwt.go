@@ -1,12 +1,14 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/markraiter/spycat/internal/config"
 	"github.com/markraiter/spycat/internal/domain"
 )
 
@@ -18,32 +20,150 @@ var (
 	ErrNotFoundInTokenClaims = errors.New("not found in token claims")
 )
 
+// Purpose tokens are never accepted as access tokens.
+const (
+	// PurposeEmailVerification marks tokens sent in verification emails.
+	PurposeEmailVerification = "email_verification"
+	// PurposeMFA marks tokens proving the password was checked, pending the second factor.
+	PurposeMFA = "mfa_pending"
+	// PurposeOIDC marks tokens carrying a login with the identity provider, from the redirect to the callback.
+	PurposeOIDC = "oidc_login"
+)
+
 type TokenClaims struct {
+	// ID is the jti claim, unique per token.
+	ID       string
 	UID      string
 	Username string
 	Email    string
 	Exp      int64
+	// SessionVersion is the session version of the user when the token was issued.
+	SessionVersion int
+	// SessionID is the session the token belongs to.
+	SessionID int64
+}
+
+type VerificationClaims struct {
+	UID   int
+	Email string
 }
 
-// NewToken generates new JWT token and returns signedString.
+// claims are the registered claims, sub being the user ID, and ours.
+type claims struct {
+	jwt.RegisteredClaims
+	Username       string `json:"username,omitempty"`
+	Email          string `json:"email,omitempty"`
+	SessionVersion int    `json:"sv,omitempty"`
+	SessionID      int64  `json:"sid,omitempty"`
+	Purpose        string `json:"purpose,omitempty"`
+	// OIDC is set on PurposeOIDC tokens only.
+	OIDC *domain.OIDCFlow `json:"oidc,omitempty"`
+}
+
+// NewToken generates new JWT token of the session and returns signedString.
 //
 // In case of error occurs it throws an error.
-func NewToken(cfg config.Auth, user *domain.User, duration time.Duration) (string, error) {
+func (ks *KeySet) NewToken(user *domain.User, sessionID int64, duration time.Duration) (string, error) {
 	const operation = "jwt.NewToken"
 
-	token := jwt.New(jwt.SigningMethodHS256)
+	c, err := ks.newClaims(user, duration)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", operation, err)
+	}
+
+	c.Username = user.Username
+	c.Email = user.Email
+	c.SessionVersion = user.SessionVersion
+	c.SessionID = sessionID
+
+	tokenString, err := ks.sign(c)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", operation, err)
+	}
+
+	return tokenString, nil
+}
+
+// NewVerificationToken generates a token proving the user owns the email address.
+// The token no longer verifies the user once the email changes.
+func (ks *KeySet) NewVerificationToken(user *domain.User, duration time.Duration) (string, error) {
+	const operation = "jwt.NewVerificationToken"
+
+	c, err := ks.newClaims(user, duration)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", operation, err)
+	}
+
+	c.Email = user.Email
+	c.Purpose = PurposeEmailVerification
+
+	tokenString, err := ks.sign(c)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", operation, err)
+	}
+
+	return tokenString, nil
+}
+
+// NewMFAToken generates a token proving the password of the user was checked,
+// it is exchanged for an access token with the second factor.
+func (ks *KeySet) NewMFAToken(user *domain.User, duration time.Duration) (string, error) {
+	const operation = "jwt.NewMFAToken"
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", ErrInvalidClaims
+	c, err := ks.newClaims(user, duration)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", operation, err)
+	}
+
+	c.SessionVersion = user.SessionVersion
+	c.Purpose = PurposeMFA
+
+	tokenString, err := ks.sign(c)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", operation, err)
 	}
 
-	claims["uid"] = user.ID
-	claims["username"] = user.Username
-	claims["email"] = user.Email
-	claims["exp"] = time.Now().Add(duration).Unix()
+	return tokenString, nil
+}
+
+// ParseMFAToken parses a token made by NewMFAToken and returns the user ID and session version.
+func (ks *KeySet) ParseMFAToken(tokenString string) (int, int, error) {
+	c, err := ks.parse(tokenString)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if c.Purpose != PurposeMFA {
+		return 0, 0, ErrInvalidToken
+	}
+
+	uid, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return 0, 0, ErrInvalidClaims
+	}
+
+	return uid, c.SessionVersion, nil
+}
+
+// NewOIDCFlowToken generates a token carrying the login flow with the identity
+// provider, it is kept in a cookie of the browser until the callback.
+func (ks *KeySet) NewOIDCFlowToken(flow *domain.OIDCFlow, duration time.Duration) (string, error) {
+	const operation = "jwt.NewOIDCFlowToken"
+
+	now := time.Now()
+	c := &claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   flow.State,
+			Issuer:    ks.issuer,
+			Audience:  jwt.ClaimStrings{ks.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+		},
+		Purpose: PurposeOIDC,
+		OIDC:    flow,
+	}
 
-	tokenString, err := token.SignedString([]byte(cfg.SigningKey))
+	tokenString, err := ks.sign(c)
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", operation, err)
 	}
@@ -51,83 +171,126 @@ func NewToken(cfg config.Auth, user *domain.User, duration time.Duration) (strin
 	return tokenString, nil
 }
 
+// ParseOIDCFlowToken parses a token made by NewOIDCFlowToken.
+func (ks *KeySet) ParseOIDCFlowToken(tokenString string) (*domain.OIDCFlow, error) {
+	c, err := ks.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Purpose != PurposeOIDC || c.OIDC == nil || c.OIDC.State != c.Subject {
+		return nil, ErrInvalidToken
+	}
+
+	return c.OIDC, nil
+}
+
+// ParseVerificationToken parses a token made by NewVerificationToken.
+func (ks *KeySet) ParseVerificationToken(tokenString string) (*VerificationClaims, error) {
+	c, err := ks.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Purpose != PurposeEmailVerification {
+		return nil, ErrInvalidToken
+	}
+
+	uid, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return nil, ErrInvalidClaims
+	}
+
+	if c.Email == "" {
+		return nil, ErrNotFoundInTokenClaims
+	}
+
+	return &VerificationClaims{UID: uid, Email: c.Email}, nil
+}
+
 // ParseToken parses the JWT token and returns the user ID.
 //
 // If the token is invalid, returns an error.
 // If the token is valid, returns the user ID.
-func ParseToken(tokenString, signingKey string) (*TokenClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidSigningMethod
-		}
-
-		return []byte(signingKey), nil
-	})
+func (ks *KeySet) ParseToken(tokenString string) (*TokenClaims, error) {
+	c, err := ks.parse(tokenString)
 	if err != nil {
-		if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, ErrTokenExpired
-		}
-
-		return nil, fmt.Errorf("accessToken throws an error during parsing: %w", err)
+		return nil, err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
+	// Purpose tokens, such as email verification ones, do not grant access.
+	if c.Purpose != "" {
 		return nil, ErrInvalidToken
 	}
 
-	var userID string
-	if uid, ok := claims["uid"]; ok {
-		switch v := uid.(type) {
-		case string:
-			userID = v
-		case float64:
-			userID = fmt.Sprintf("%.0f", v)
-		}
-	} else {
+	if c.Email == "" || c.Username == "" {
 		return nil, ErrNotFoundInTokenClaims
 	}
 
-	var exp int64
-	if expClaim, ok := claims["exp"]; ok {
-		switch v := expClaim.(type) {
-		case float64:
-			exp = int64(v)
-		case int64:
-			exp = v
-		default:
-			return nil, ErrNotFoundInTokenClaims
-		}
-	} else {
-		return nil, ErrNotFoundInTokenClaims
+	return &TokenClaims{
+		ID:             c.ID,
+		UID:            c.Subject,
+		Username:       c.Username,
+		Email:          c.Email,
+		Exp:            c.ExpiresAt.Unix(),
+		SessionVersion: c.SessionVersion,
+		SessionID:      c.SessionID,
+	}, nil
+}
+
+func (ks *KeySet) newClaims(user *domain.User, duration time.Duration) (*claims, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return nil, err
 	}
 
-	var email string
-	if emailClaim, ok := claims["email"]; ok {
-		switch v := emailClaim.(type) {
-		case string:
-			email = v
-		}
-	} else {
-		return nil, ErrNotFoundInTokenClaims
+	now := time.Now()
+
+	return &claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
+			Subject:   strconv.Itoa(user.ID),
+			Issuer:    ks.issuer,
+			Audience:  jwt.ClaimStrings{ks.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+		},
+	}, nil
+}
+
+func (ks *KeySet) sign(c *claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, c)
+	if ks.signing.ID != "" {
+		token.Header["kid"] = ks.signing.ID
 	}
 
-	var username string
-	if usernameClaim, ok := claims["username"]; ok {
-		switch v := usernameClaim.(type) {
-		case string:
-			username = v
+	return token.SignedString(ks.signing.sign)
+}
+
+func (ks *KeySet) parse(tokenString string) (*claims, error) {
+	c := &claims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, c, ks.keyFunc,
+		jwt.WithValidMethods([]string{
+			jwt.SigningMethodHS256.Alg(),
+			jwt.SigningMethodRS256.Alg(),
+			jwt.SigningMethodEdDSA.Alg(),
+		}),
+		jwt.WithIssuer(ks.issuer),
+		jwt.WithAudience(ks.audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
 		}
-	} else {
-		return nil, ErrNotFoundInTokenClaims
+
+		return nil, fmt.Errorf("token throws an error during parsing: %w", err)
 	}
 
-	tc := TokenClaims{
-		UID:      userID,
-		Username: username,
-		Email:    email,
-		Exp:      exp,
+	if !token.Valid || c.Subject == "" {
+		return nil, ErrInvalidToken
 	}
 
-	return &tc, nil
+	return c, nil
 }
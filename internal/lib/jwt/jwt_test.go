@@ -9,11 +9,22 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestNewToken(t *testing.T) {
-	cfg := config.Auth{
+func testKeySet(t *testing.T) *KeySet {
+	ks, err := NewKeySet(config.Auth{
 		SigningKey: "testKey",
+		Issuer:     "spycat",
+		Audience:   "spycat-api",
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
 
+	return ks
+}
+
+func TestNewToken(t *testing.T) {
+	ks := testKeySet(t)
+
 	user := domain.User{
 		ID:       111,
 		Username: "testUser",
@@ -21,15 +32,13 @@ func TestNewToken(t *testing.T) {
 	}
 	duration := time.Minute
 
-	token, err := NewToken(cfg, &user, duration)
+	token, err := ks.NewToken(&user, 1, duration)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 }
 
 func TestParseToken(t *testing.T) {
-	cfg := config.Auth{
-		SigningKey: "testKey",
-	}
+	ks := testKeySet(t)
 
 	user := domain.User{
 		ID:       111,
@@ -64,10 +73,10 @@ func TestParseToken(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			token, err := NewToken(cfg, tt.user, tt.duration)
+			token, err := ks.NewToken(tt.user, 1, tt.duration)
 			assert.NoError(t, err)
 
-			claims, err := ParseToken(token, cfg.SigningKey)
+			claims, err := ks.ParseToken(token)
 
 			if tt.wantErr != nil {
 				assert.Error(t, err)
@@ -80,7 +89,128 @@ func TestParseToken(t *testing.T) {
 				assert.Equal(t, tt.wantClaims.UID, claims.UID)
 				assert.Equal(t, tt.wantClaims.Username, claims.Username)
 				assert.Equal(t, tt.wantClaims.Email, claims.Email)
+				assert.Len(t, claims.ID, 32)
 			}
 		})
 	}
 }
+
+func TestParseTokenAudience(t *testing.T) {
+	ks := testKeySet(t)
+
+	other, err := NewKeySet(config.Auth{SigningKey: "testKey", Issuer: "spycat", Audience: "billing"})
+	assert.NoError(t, err)
+
+	token, err := other.NewToken(&domain.User{ID: 1, Username: "testUser", Email: "test@test.com"}, 1, time.Minute)
+	assert.NoError(t, err)
+
+	_, err = ks.ParseToken(token)
+	assert.Error(t, err, "token for another audience accepted")
+}
+
+func TestVerificationToken(t *testing.T) {
+	ks := testKeySet(t)
+
+	user := domain.User{
+		ID:       111,
+		Username: "testUser",
+		Email:    "test@test.com",
+	}
+
+	token, err := ks.NewVerificationToken(&user, time.Minute)
+	assert.NoError(t, err)
+
+	claims, err := ks.ParseVerificationToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, &VerificationClaims{UID: 111, Email: "test@test.com"}, claims)
+
+	_, err = ks.ParseToken(token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "verification token accepted as access token")
+
+	access, err := ks.NewToken(&user, 1, time.Minute)
+	assert.NoError(t, err)
+
+	_, err = ks.ParseVerificationToken(access)
+	assert.ErrorIs(t, err, ErrInvalidToken, "access token accepted as verification token")
+
+	other, err := NewKeySet(config.Auth{SigningKey: "otherKey", Issuer: "spycat", Audience: "spycat-api"})
+	assert.NoError(t, err)
+
+	_, err = other.ParseVerificationToken(token)
+	assert.Error(t, err)
+}
+
+func TestSessionVersion(t *testing.T) {
+	ks := testKeySet(t)
+
+	user := domain.User{
+		ID:             111,
+		Username:       "testUser",
+		Email:          "test@test.com",
+		SessionVersion: 3,
+	}
+
+	token, err := ks.NewToken(&user, 42, time.Minute)
+	assert.NoError(t, err)
+
+	claims, err := ks.ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, claims.SessionVersion)
+	assert.Equal(t, int64(42), claims.SessionID)
+}
+
+func TestMFAToken(t *testing.T) {
+	ks := testKeySet(t)
+
+	user := domain.User{
+		ID:             111,
+		Username:       "testUser",
+		Email:          "test@test.com",
+		SessionVersion: 2,
+	}
+
+	token, err := ks.NewMFAToken(&user, time.Minute)
+	assert.NoError(t, err)
+
+	uid, sv, err := ks.ParseMFAToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, 111, uid)
+	assert.Equal(t, 2, sv)
+
+	_, err = ks.ParseToken(token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "MFA token accepted as access token")
+
+	verification, err := ks.NewVerificationToken(&user, time.Minute)
+	assert.NoError(t, err)
+
+	_, _, err = ks.ParseMFAToken(verification)
+	assert.ErrorIs(t, err, ErrInvalidToken, "verification token accepted as MFA token")
+
+	expired, err := ks.NewMFAToken(&user, -time.Minute)
+	assert.NoError(t, err)
+
+	_, _, err = ks.ParseMFAToken(expired)
+	assert.Error(t, err)
+}
+
+func TestOIDCFlowToken(t *testing.T) {
+	ks := testKeySet(t)
+
+	flow := &domain.OIDCFlow{State: "state", Nonce: "nonce", Verifier: "verifier"}
+
+	token, err := ks.NewOIDCFlowToken(flow, time.Minute)
+	assert.NoError(t, err)
+
+	parsed, err := ks.ParseOIDCFlowToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, flow, parsed)
+
+	_, err = ks.ParseToken(token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "OIDC flow token accepted as access token")
+
+	access, err := ks.NewToken(&domain.User{ID: 1, Username: "testUser", Email: "test@test.com"}, 1, time.Minute)
+	assert.NoError(t, err)
+
+	_, err = ks.ParseOIDCFlowToken(access)
+	assert.ErrorIs(t, err, ErrInvalidToken, "access token accepted as OIDC flow token")
+}
@@ -0,0 +1,200 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// minRSABits is the smallest RSA key accepted.
+const minRSABits = 2048
+
+// Key is a key tokens are signed or verified with.
+type Key struct {
+	ID     string
+	method jwt.SigningMethod
+	// sign is nil for keys kept to verify tokens only.
+	sign   any
+	verify any
+}
+
+// KeySet signs tokens with one key and verifies them with any key of the set,
+// the kid header of a token names its key.
+//
+// The keys are read from the *.pem files of cfg.KeysDir, the file name without
+// the extension is the kid. A file holds an RSA or an Ed25519 private key, to
+// sign with RS256 or EdDSA, or only a public key, to verify with. cfg.SigningKeyID
+// picks the key to sign with. To rotate, add the new key, sign with it and keep
+// the old one, its public key is enough, until the tokens it signed expired.
+//
+// cfg.SigningKey, the HS256 secret of tokens without a kid, signs when there is
+// no keys directory and otherwise only verifies, so switching from it to
+// asymmetric keys keeps the tokens it signed valid until they expire. Tokens
+// without the sub, iss and aud claims, issued by releases before keysets, are
+// refused: upgrading from such a release signs everyone out once.
+type KeySet struct {
+	issuer   string
+	audience string
+	signing  *Key
+	keys     map[string]*Key
+	legacy   *Key
+}
+
+func NewKeySet(cfg config.Auth) (*KeySet, error) {
+	const op = "jwt.NewKeySet"
+
+	ks := &KeySet{
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		keys:     make(map[string]*Key),
+	}
+
+	if cfg.SigningKey != "" {
+		secret := []byte(cfg.SigningKey)
+		ks.legacy = &Key{method: jwt.SigningMethodHS256, sign: secret, verify: secret}
+	}
+
+	if cfg.KeysDir != "" {
+		paths, err := filepath.Glob(filepath.Join(cfg.KeysDir, "*.pem"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, path := range paths {
+			key, err := loadKey(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			ks.keys[key.ID] = key
+		}
+
+		if cfg.SigningKeyID == "" {
+			return nil, fmt.Errorf("%s: JWT_SIGNING_KID is required with JWT_KEYS_DIR", op)
+		}
+	}
+
+	switch {
+	case cfg.SigningKeyID != "":
+		key, ok := ks.keys[cfg.SigningKeyID]
+		if !ok || key.sign == nil {
+			return nil, fmt.Errorf("%s: no private key %q in %s", op, cfg.SigningKeyID, cfg.KeysDir)
+		}
+		ks.signing = key
+	case ks.legacy != nil:
+		ks.signing = ks.legacy
+	default:
+		return nil, fmt.Errorf("%s: set SIGNING_KEY or JWT_KEYS_DIR", op)
+	}
+
+	return ks, nil
+}
+
+// newKey returns a key with the kid for an RSA or Ed25519 private or public key.
+func newKey(kid string, key any) (*Key, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key %s is shorter than %d bits", kid, minRSABits)
+		}
+		return &Key{ID: kid, method: jwt.SigningMethodRS256, sign: k, verify: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: kid, method: jwt.SigningMethodRS256, verify: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: kid, method: jwt.SigningMethodEdDSA, sign: k, verify: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: kid, method: jwt.SigningMethodEdDSA, verify: k}, nil
+	}
+
+	return nil, fmt.Errorf("key %s: unsupported key type %T, expected RSA or Ed25519", kid, key)
+}
+
+func loadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return newKey(strings.TrimSuffix(filepath.Base(path), ".pem"), key)
+}
+
+// keyFunc returns the key to verify the token with, refusing a token signed
+// with another algorithm than its key is for.
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	key := ks.legacy
+	if kid, _ := token.Header["kid"].(string); kid != "" {
+		key = ks.keys[kid]
+	}
+
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, ErrInvalidSigningMethod
+	}
+
+	return key.verify, nil
+}
+
+// JWKS returns the public keys of the set, the HS256 secret is never published.
+func (ks *KeySet) JWKS() *domain.JWKS {
+	jwks := &domain.JWKS{Keys: []domain.JWK{}}
+
+	for _, key := range ks.keys {
+		jwk := domain.JWK{Kid: key.ID, Use: "sig", Alg: key.method.Alg()}
+
+		switch k := key.verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		default:
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+
+	return jwks
+}
@@ -0,0 +1,197 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var testUser = &domain.User{ID: 7, Username: "testUser", Email: "test@test.com"}
+
+func writeKey(t *testing.T, dir, kid, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writePrivateKey(t *testing.T, dir, kid string, key any) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, kid, "PRIVATE KEY", der)
+}
+
+func writePublicKey(t *testing.T, dir, kid string, key any) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, kid, "PUBLIC KEY", der)
+}
+
+func testConfig(dir, kid string) config.Auth {
+	return config.Auth{KeysDir: dir, SigningKeyID: kid, Issuer: "spycat", Audience: "spycat-api"}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePrivateKey(t, dir, "2026-09", rsaKey)
+	writePrivateKey(t, dir, "2026-10", edKey)
+
+	old, err := NewKeySet(testConfig(dir, "2026-09"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	oldToken, err := old.NewToken(testUser, 1, time.Minute)
+	assert.NoError(t, err)
+
+	// Rotate: sign with the Ed25519 key and keep only the public RSA key.
+	writePublicKey(t, dir, "2026-09", &rsaKey.PublicKey)
+
+	ks, err := NewKeySet(testConfig(dir, "2026-10"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	token, err := ks.NewToken(testUser, 1, time.Minute)
+	assert.NoError(t, err)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &claims{})
+	assert.NoError(t, err)
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+	assert.Equal(t, "2026-10", parsed.Header["kid"])
+
+	claims, err := ks.ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "7", claims.UID)
+
+	claims, err = ks.ParseToken(oldToken)
+	assert.NoError(t, err, "token of the retired key refused")
+	assert.Equal(t, "7", claims.UID)
+
+	_, err = NewKeySet(testConfig(dir, "2026-09"))
+	assert.Error(t, err, "public key accepted as signing key")
+
+	jwks := ks.JWKS()
+	if assert.Len(t, jwks.Keys, 2) {
+		assert.Equal(t, domain.JWK{Kty: "RSA", Kid: "2026-09", Use: "sig", Alg: "RS256", N: jwks.Keys[0].N, E: "AQAB"}, jwks.Keys[0])
+		assert.Equal(t, "OKP", jwks.Keys[1].Kty)
+		assert.Equal(t, "Ed25519", jwks.Keys[1].Crv)
+		assert.Len(t, jwks.Keys[1].X, 43)
+	}
+}
+
+func TestKeySetRefusesForeignTokens(t *testing.T) {
+	dir := t.TempDir()
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePrivateKey(t, dir, "k1", edKey)
+
+	ks, err := NewKeySet(testConfig(dir, "k1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c, err := ks.newClaims(testUser, time.Minute)
+	assert.NoError(t, err)
+	c.Username, c.Email = testUser.Username, testUser.Email
+
+	// An HS256 token claiming the kid of the Ed25519 key, signed with its public key.
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
+	forged.Header["kid"] = "k1"
+	token, err := forged.SignedString([]byte(edKey.Public().(ed25519.PublicKey)))
+	assert.NoError(t, err)
+
+	_, err = ks.ParseToken(token)
+	assert.Error(t, err, "algorithm confusion")
+
+	// No HS256 secret is configured, tokens without a kid have no key.
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
+	token, err = legacy.SignedString([]byte("testKey"))
+	assert.NoError(t, err)
+
+	_, err = ks.ParseToken(token)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// A key that is not in the set.
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	other := jwt.NewWithClaims(jwt.SigningMethodEdDSA, c)
+	other.Header["kid"] = "k2"
+	token, err = other.SignedString(otherKey)
+	assert.NoError(t, err)
+
+	_, err = ks.ParseToken(token)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestKeySetRefusesUIDTokens(t *testing.T) {
+	cfg := testConfig("", "")
+	cfg.SigningKey = "testKey"
+
+	ks, err := NewKeySet(cfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// A token of the releases before keysets, signed with the same secret.
+	old := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"uid":      testUser.ID,
+		"username": testUser.Username,
+		"email":    testUser.Email,
+		"exp":      time.Now().Add(time.Minute).Unix(),
+		"sv":       0,
+	})
+	token, err := old.SignedString([]byte("testKey"))
+	assert.NoError(t, err)
+
+	_, err = ks.ParseToken(token)
+	assert.Error(t, err, "no sub, iss and aud claims")
+}
+
+func TestNewKeySetConfig(t *testing.T) {
+	_, err := NewKeySet(config.Auth{})
+	assert.Error(t, err, "no key at all")
+
+	dir := t.TempDir()
+	_, err = NewKeySet(testConfig(dir, ""))
+	assert.Error(t, err, "keys directory without a signing kid")
+
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "weak", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(weak))
+	_, err = NewKeySet(testConfig(dir, "weak"))
+	assert.Error(t, err, "1024 bit RSA key accepted")
+
+	ks, err := NewKeySet(config.Auth{SigningKey: "testKey"})
+	assert.NoError(t, err)
+	assert.Empty(t, ks.JWKS().Keys, "HS256 secret published")
+}
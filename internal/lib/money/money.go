@@ -0,0 +1,84 @@
+package money
+
+import (
+	"fmt"
+	"strings"
+)
+
+const DefaultCurrency = "USD"
+
+// zeroDecimal lists ISO 4217 currencies that have no minor unit.
+var zeroDecimal = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "ISK": true,
+	"JPY": true, "KMF": true, "KRW": true, "PYG": true, "RWF": true,
+	"UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true,
+	"XPF": true,
+}
+
+// Normalize returns the upper-cased currency code, falling back to DefaultCurrency.
+func Normalize(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return DefaultCurrency
+	}
+
+	return currency
+}
+
+// Exponent returns the number of minor unit digits of the currency.
+func Exponent(currency string) int {
+	if zeroDecimal[Normalize(currency)] {
+		return 0
+	}
+
+	return 2
+}
+
+// ToMinor converts an amount in major units into minor units.
+//
+// Example:
+//
+//	ToMinor(1000, "USD") // 100000
+//	ToMinor(1000, "JPY") // 1000
+func ToMinor(amount int64, currency string) int64 {
+	for i := 0; i < Exponent(currency); i++ {
+		amount *= 10
+	}
+
+	return amount
+}
+
+// ToMajor converts an amount in minor units into whole major units, truncating the rest.
+func ToMajor(amount int64, currency string) int64 {
+	for i := 0; i < Exponent(currency); i++ {
+		amount /= 10
+	}
+
+	return amount
+}
+
+// Format renders an amount in minor units as a decimal string.
+//
+// Example:
+//
+//	Format(123456, "USD") // "1234.56"
+//	Format(-5, "EUR") // "-0.05"
+func Format(amount int64, currency string) string {
+	exp := Exponent(currency)
+	if exp == 0 {
+		return fmt.Sprintf("%d", amount)
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	div := int64(1)
+	for i := 0; i < exp; i++ {
+		div *= 10
+	}
+
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/div, exp, amount%div)
+}
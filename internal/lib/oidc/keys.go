@@ -0,0 +1,203 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	errUnknownKey = errors.New("unknown signing key")
+	errKeyAlg     = errors.New("signing algorithm does not match the key")
+)
+
+// minRSABits is the smallest RSA key accepted.
+const minRSABits = 2048
+
+// supportedAlgs are the algorithms ID tokens may be signed with, never "none" or HMAC.
+var supportedAlgs = []string{
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodES256.Alg(),
+	jwt.SigningMethodES384.Alg(),
+	jwt.SigningMethodEdDSA.Alg(),
+}
+
+// key is a public key of the provider and the algorithm it verifies.
+type key struct {
+	alg    string
+	public any
+}
+
+// jwk is a key of the JWKS of the provider, RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keyFunc returns the key of the provider the token names with its kid. An
+// unknown kid makes the JWKS be read again, at most every keysRefreshInterval.
+// A token without a kid is accepted when the provider has a single key.
+func (p *Provider) keyFunc(ctx context.Context, token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	k, err := p.key(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Method.Alg() != k.alg {
+		return nil, errKeyAlg
+	}
+
+	return k.public, nil
+}
+
+func (p *Provider) key(ctx context.Context, kid string) (*key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k := p.lookup(kid); k != nil {
+		return k, nil
+	}
+
+	if p.keys != nil && p.now().Sub(p.keysAt) < keysRefreshInterval {
+		return nil, errUnknownKey
+	}
+
+	if err := p.fetchKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	if k := p.lookup(kid); k != nil {
+		return k, nil
+	}
+
+	return nil, errUnknownKey
+}
+
+func (p *Provider) lookup(kid string) *key {
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k
+		}
+	}
+
+	return p.keys[kid]
+}
+
+// fetchKeys reads the JWKS of the provider, keys not used for signatures and
+// of unsupported types are skipped. p.mu must be held.
+func (p *Provider) fetchKeys(ctx context.Context) error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.metadata.JWKSURI, &set); err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]*key, len(set.Keys))
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+
+		k, err := parseJWK(j)
+		if err != nil {
+			continue
+		}
+
+		// A key declaring its algorithm is used for that one only.
+		if j.Alg != "" && j.Alg != k.alg {
+			continue
+		}
+
+		keys[j.Kid] = k
+	}
+
+	p.keys = keys
+	p.keysAt = p.now()
+
+	return nil
+}
+
+func parseJWK(j jwk) (*key, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeBigInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		if n.BitLen() < minRSABits || !e.IsInt64() {
+			return nil, errors.New("RSA key too short or exponent too large")
+		}
+		return &key{alg: jwt.SigningMethodRS256.Alg(), public: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var alg string
+		switch j.Crv {
+		case "P-256":
+			curve, alg = elliptic.P256(), jwt.SigningMethodES256.Alg()
+		case "P-384":
+			curve, alg = elliptic.P384(), jwt.SigningMethodES384.Alg()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := decodeBigInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return &key{alg: alg, public: &ecdsa.PublicKey{Curve: curve, X: x, Y: y}}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return &key{alg: jwt.SigningMethodEdDSA.Alg(), public: ed25519.PublicKey(x)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
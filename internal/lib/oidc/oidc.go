@@ -0,0 +1,312 @@
+// Package oidc is the relying party side of the OpenID Connect authorization
+// code flow with PKCE: it reads the metadata of the provider, builds the
+// authorization URL, exchanges the code and verifies the ID token against the
+// JWKS of the provider.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+// ErrRejected is returned when the provider refuses the code or the ID token is not valid.
+var ErrRejected = errors.New("rejected by the identity provider")
+
+const (
+	// maxResponseSize caps what is read of a response of the provider.
+	maxResponseSize = 1 << 20
+	// keysRefreshInterval is how often at most the JWKS is fetched again for an unknown kid.
+	keysRefreshInterval = time.Minute
+	// leeway is the clock skew accepted between the provider and us.
+	leeway = time.Minute
+)
+
+// metadata is the part of the provider configuration document we use.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider talks to one OpenID Connect provider. Its metadata is read on first
+// use and kept, its keys are read again when a token names an unknown kid, so
+// the provider can rotate them.
+type Provider struct {
+	cfg    config.OIDC
+	client *http.Client
+	now    func() time.Time
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     map[string]*key
+	keysAt   time.Time
+}
+
+func New(cfg config.OIDC) *Provider {
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		now:    time.Now,
+	}
+}
+
+// Challenge returns the S256 PKCE code challenge of the verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the URL of the provider to send the browser to for the login flow.
+func (p *Provider) AuthCodeURL(ctx context.Context, flow *domain.OIDCFlow) (string, error) {
+	const op = "oidc.AuthCodeURL"
+
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	u, err := url.Parse(md.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", flow.State)
+	q.Set("nonce", flow.Nonce)
+	q.Set("code_challenge", Challenge(flow.Verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Identify exchanges the code of the callback for an ID token and returns the
+// identity it was issued for, once the token is verified to be for this flow.
+func (p *Provider) Identify(ctx context.Context, code string, flow *domain.OIDCFlow) (*domain.OIDCIdentity, error) {
+	const op = "oidc.Identify"
+
+	rawIDToken, err := p.exchange(ctx, code, flow.Verifier)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	identity, err := p.Verify(ctx, rawIDToken, flow.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identity, nil
+}
+
+// exchange redeems the code at the token endpoint and returns the ID token.
+// The client authenticates with HTTP basic auth when it has a secret.
+func (p *Provider) exchange(ctx context.Context, code, verifier string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {p.cfg.ClientID},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("token endpoint returned %s: %w", resp.Status, err)
+	}
+
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		return "", fmt.Errorf("%w: %s %s", ErrRejected, body.Error, body.ErrorDescription)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	if body.IDToken == "" {
+		return "", fmt.Errorf("%w: no id_token in the token response, is the openid scope asked for?", ErrRejected)
+	}
+
+	return body.IDToken, nil
+}
+
+// Verify checks the signature, issuer, audience, expiry and nonce of the ID token.
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (*domain.OIDCIdentity, error) {
+	const op = "oidc.Verify"
+
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims,
+		func(token *jwt.Token) (any, error) { return p.keyFunc(ctx, token) },
+		jwt.WithValidMethods(supportedAlgs),
+		jwt.WithIssuer(md.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrRejected, err)
+	}
+
+	// With other audiences too, the token must have been issued to us.
+	if aud, _ := claims.GetAudience(); len(aud) > 1 && stringClaim(claims, "azp") != p.cfg.ClientID {
+		return nil, fmt.Errorf("%s: %w: azp is not the client ID", op, ErrRejected)
+	}
+
+	if stringClaim(claims, "nonce") != nonce {
+		return nil, fmt.Errorf("%s: %w: nonce mismatch", op, ErrRejected)
+	}
+
+	identity := &domain.OIDCIdentity{
+		Issuer:        md.Issuer,
+		Subject:       stringClaim(claims, "sub"),
+		Email:         stringClaim(claims, "email"),
+		EmailVerified: boolClaim(claims, "email_verified"),
+		Username:      stringClaim(claims, "preferred_username"),
+		Groups:        stringsClaim(claims, p.cfg.GroupsClaim),
+	}
+
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%s: %w: no sub claim", op, ErrRejected)
+	}
+
+	if identity.Username == "" {
+		identity.Username = stringClaim(claims, "name")
+	}
+
+	return identity, nil
+}
+
+// discover reads the provider configuration document, once it succeeded it is kept.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	issuer := strings.TrimSuffix(p.cfg.Issuer, "/")
+
+	var md metadata
+	if err := p.getJSON(ctx, issuer+"/.well-known/openid-configuration", &md); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+
+	// The issuer of the document must be the one configured, tokens are checked against it.
+	if strings.TrimSuffix(md.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", md.Issuer, p.cfg.Issuer)
+	}
+
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, errors.New("discovery: authorization_endpoint, token_endpoint and jwks_uri are required")
+	}
+
+	p.metadata = &md
+
+	return p.metadata, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+// boolClaim tells whether the claim is present and true, some providers send
+// booleans as strings.
+func boolClaim(claims jwt.MapClaims, name string) bool {
+	switch v := claims[name].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+
+	return false
+}
+
+// stringsClaim reads a claim holding a list of strings or a single string.
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
@@ -0,0 +1,381 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testClientID     = "spycat"
+	testClientSecret = "s3cret"
+	testRedirectURL  = "http://localhost:8000/api/v1/auth/oidc/callback"
+)
+
+// grant is an authorization code the mock IdP issued.
+type grant struct {
+	challenge string
+	nonce     string
+}
+
+// mockIdP is a minimal OpenID Connect provider: its /authorize logs the user in
+// straight away and redirects back with a code.
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu     sync.Mutex
+	kid    string
+	key    *rsa.PrivateKey
+	grants map[string]grant
+	// claims are added to, or override, the claims of the ID tokens.
+	claims jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	idp := &mockIdP{t: t, grants: make(map[string]grant), claims: jwt.MapClaims{}}
+	idp.rotate("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{ // nolint: errcheck
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *mockIdP) config() config.OIDC {
+	return config.OIDC{
+		Issuer:       idp.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		GroupsClaim:  "groups",
+		Timeout:      5 * time.Second,
+	}
+}
+
+// rotate makes the IdP sign with a new key, the old one is not published anymore.
+func (idp *mockIdP) rotate(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+
+	idp.mu.Lock()
+	idp.kid, idp.key = kid, key
+	idp.mu.Unlock()
+}
+
+func (idp *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]any{ // nolint: errcheck
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": idp.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (idp *mockIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != testClientID || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	code := randomText(idp.t)
+
+	idp.mu.Lock()
+	idp.grants[code] = grant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	idp.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	redirect.RawQuery = url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != testClientID || secret != testClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"}) // nolint: errcheck
+		return
+	}
+
+	idp.mu.Lock()
+	g, ok := idp.grants[r.FormValue("code")]
+	delete(idp.grants, r.FormValue("code"))
+	idp.mu.Unlock()
+
+	if !ok || Challenge(r.FormValue("code_verifier")) != g.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"}) // nolint: errcheck
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{ // nolint: errcheck
+		"access_token": "opaque",
+		"token_type":   "Bearer",
+		"id_token":     idp.idToken(g.nonce),
+	})
+}
+
+func (idp *mockIdP) idToken(nonce string) string {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"sub":            "agent-007",
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          nonce,
+		"email":          "Bond@Example.com",
+		"email_verified": true,
+		"name":           "James Bond",
+		"groups":         []string{"spycat-admins", "staff"},
+	}
+	for name, value := range idp.claims {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idp.kid
+
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+
+	return signed
+}
+
+// login runs the flow up to the callback and returns the code and state it gets.
+func login(t *testing.T, p *Provider, flow *domain.OIDCFlow) (string, string) {
+	authURL, err := p.AuthCodeURL(context.Background(), flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatalf("no redirect, status %s", resp.Status)
+	}
+
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func randomText(t *testing.T) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func testFlow(t *testing.T) *domain.OIDCFlow {
+	return &domain.OIDCFlow{State: randomText(t), Nonce: randomText(t), Verifier: randomText(t) + randomText(t)}
+}
+
+func TestIdentify(t *testing.T) {
+	idp := newMockIdP(t)
+	p := New(idp.config())
+
+	flow := testFlow(t)
+	code, state := login(t, p, flow)
+	assert.Equal(t, flow.State, state)
+
+	identity, err := p.Identify(context.Background(), code, flow)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.OIDCIdentity{
+		Issuer:        idp.server.URL,
+		Subject:       "agent-007",
+		Email:         "Bond@Example.com",
+		EmailVerified: true,
+		Username:      "James Bond",
+		Groups:        []string{"spycat-admins", "staff"},
+	}, identity)
+
+	_, err = p.Identify(context.Background(), code, flow)
+	assert.ErrorIs(t, err, ErrRejected, "code redeemed twice")
+}
+
+func TestIdentifyEmailVerified(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   bool
+	}{
+		{name: "verified", want: true},
+		{name: "verified as a string", claims: jwt.MapClaims{"email_verified": "true"}, want: true},
+		{name: "not verified", claims: jwt.MapClaims{"email_verified": false}},
+		{name: "not verified as a string", claims: jwt.MapClaims{"email_verified": "false"}},
+		{name: "no claim", claims: jwt.MapClaims{"email_verified": nil}},
+		{name: "not a boolean", claims: jwt.MapClaims{"email_verified": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			idp.claims = tt.claims
+			p := New(idp.config())
+
+			flow := testFlow(t)
+			code, _ := login(t, p, flow)
+
+			identity, err := p.Identify(context.Background(), code, flow)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, identity.EmailVerified)
+			}
+		})
+	}
+}
+
+func TestIdentifyPKCE(t *testing.T) {
+	idp := newMockIdP(t)
+	p := New(idp.config())
+
+	flow := testFlow(t)
+	code, _ := login(t, p, flow)
+
+	stolen := *flow
+	stolen.Verifier = randomText(t) + randomText(t)
+
+	_, err := p.Identify(context.Background(), code, &stolen)
+	assert.ErrorIs(t, err, ErrRejected, "code redeemed without the verifier")
+}
+
+func TestIdentifyClaims(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		nonce  string
+	}{
+		{name: "nonce of another login", nonce: "replayed"},
+		{name: "other audience", claims: jwt.MapClaims{"aud": "billing"}},
+		{name: "issued for another party", claims: jwt.MapClaims{"aud": []string{testClientID, "billing"}, "azp": "billing"}},
+		{name: "other issuer", claims: jwt.MapClaims{"iss": "https://evil.example.com"}},
+		{name: "expired", claims: jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}},
+		{name: "no expiry", claims: jwt.MapClaims{"exp": nil}},
+		{name: "no subject", claims: jwt.MapClaims{"sub": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			idp.claims = tt.claims
+			p := New(idp.config())
+
+			flow := testFlow(t)
+			code, _ := login(t, p, flow)
+			if tt.nonce != "" {
+				flow.Nonce = tt.nonce
+			}
+
+			_, err := p.Identify(context.Background(), code, flow)
+			assert.ErrorIs(t, err, ErrRejected)
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	idp := newMockIdP(t)
+	p := New(idp.config())
+
+	flow := testFlow(t)
+	login(t, p, flow)
+
+	// Signed by someone else with the kid of the IdP.
+	forged := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+		"iss": idp.server.URL, "sub": "agent-007", "aud": testClientID, "nonce": flow.Nonce,
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	forged.Header["kid"] = "key-1"
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	raw, err := forged.SignedString(key)
+	assert.NoError(t, err)
+
+	_, err = p.Verify(context.Background(), raw, flow.Nonce)
+	assert.ErrorIs(t, err, ErrRejected)
+
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, forged.Claims)
+	raw, err = hmac.SignedString([]byte("guess"))
+	assert.NoError(t, err)
+
+	_, err = p.Verify(context.Background(), raw, flow.Nonce)
+	assert.ErrorIs(t, err, ErrRejected)
+}
+
+func TestKeyRotation(t *testing.T) {
+	idp := newMockIdP(t)
+	p := New(idp.config())
+
+	flow := testFlow(t)
+	code, _ := login(t, p, flow)
+	_, err := p.Identify(context.Background(), code, flow)
+	assert.NoError(t, err)
+
+	idp.rotate("key-2")
+
+	flow = testFlow(t)
+	code, _ = login(t, p, flow)
+	_, err = p.Identify(context.Background(), code, flow)
+	assert.ErrorIs(t, err, ErrRejected, "JWKS read again before keysRefreshInterval")
+
+	p.now = func() time.Time { return time.Now().Add(keysRefreshInterval) }
+
+	flow = testFlow(t)
+	code, _ = login(t, p, flow)
+	_, err = p.Identify(context.Background(), code, flow)
+	assert.NoError(t, err, "token signed with the new key refused")
+}
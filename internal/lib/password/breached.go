@@ -0,0 +1,86 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// prefixLength is the length of the hash prefix, in hex digits.
+const prefixLength = 5
+
+//go:embed breached.txt
+var bundled string
+
+// List is a set of breached passwords, by the SHA-1 of the password. It is read
+// from lines "PREFIX:SUFFIX[:COUNT]", PREFIX being the first 5 hex digits of
+// the hash and SUFFIX the rest, as the Have I Been Pwned range API returns them
+// for each prefix. Empty lines and lines starting with "#" are skipped.
+type List struct {
+	hashes map[[sha1.Size]byte]struct{}
+}
+
+// BundledList returns the list of common breached passwords shipped with spycat.
+func BundledList() (*List, error) {
+	return ReadList(strings.NewReader(bundled))
+}
+
+// OpenList reads the list from the file at path.
+func OpenList(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadList(f)
+}
+
+// ReadList reads the list from r.
+func ReadList(r io.Reader) (*List, error) {
+	list := &List{hashes: make(map[[sha1.Size]byte]struct{})}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		prefix, rest, ok := strings.Cut(line, ":")
+		suffix, _, _ := strings.Cut(rest, ":")
+		if !ok || len(prefix) != prefixLength || len(prefix)+len(suffix) != 2*sha1.Size {
+			return nil, fmt.Errorf("line %d: want PREFIX:SUFFIX[:COUNT] of a SHA-1 hash", n)
+		}
+
+		var hash [sha1.Size]byte
+		if _, err := hex.Decode(hash[:], []byte(prefix+suffix)); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		list.hashes[hash] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Contains tells whether the password is in the list.
+func (l *List) Contains(password string) bool {
+	_, ok := l.hashes[sha1.Sum([]byte(password))]
+
+	return ok
+}
+
+// Len returns the number of passwords in the list.
+func (l *List) Len() int {
+	return len(l.hashes)
+}
@@ -0,0 +1,150 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/markraiter/spycat/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var errMalformedHash = errors.New("malformed password hash")
+
+// Hasher hashes new passwords with the algorithm and parameters of its config
+// and verifies passwords against hashes made with any of them. Argon2id hashes
+// are kept in the PHC string format, "$argon2id$v=19$m=<KiB>,t=<time>,p=<threads>$<salt>$<key>".
+type Hasher struct {
+	cfg config.PasswordHash
+}
+
+// NewHasher returns the hasher of cfg.
+func NewHasher(cfg config.PasswordHash) (*Hasher, error) {
+	switch cfg.Algorithm {
+	case Bcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be within %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case Argon2id:
+		if cfg.Argon2Memory < 8*uint32(cfg.Argon2Threads) || cfg.Argon2Time < 1 || cfg.Argon2Threads < 1 {
+			return nil, errors.New("argon2id needs a time and threads of 1 at least and 8 KiB of memory per thread")
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q, want %s or %s", cfg.Algorithm, Bcrypt, Argon2id)
+	}
+
+	return &Hasher{cfg: cfg}, nil
+}
+
+// Hash returns the hash of the password.
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	params := argon2Params{memory: h.cfg.Argon2Memory, time: h.cfg.Argon2Time, threads: h.cfg.Argon2Threads}
+	key := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, argon2KeyLength)
+
+	return params.encode(salt, key), nil
+}
+
+// Verify tells whether the password matches the hash. An empty hash, of a user
+// without a password, matches none.
+func (h *Hasher) Verify(hash, password string) (bool, error) {
+	switch {
+	case hash == "":
+		return false, nil
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+
+		other := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// NeedsRehash tells whether the hash was made with another algorithm or other
+// parameters than the ones new passwords are hashed with.
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if h.cfg.Algorithm == Bcrypt {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.cfg.BcryptCost
+	}
+
+	params, _, key, err := decodeArgon2(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.memory != h.cfg.Argon2Memory || params.time != h.cfg.Argon2Time ||
+		params.threads != h.cfg.Argon2Threads || len(key) != argon2KeyLength
+}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+func (p argon2Params) encode(salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != Argon2id {
+		return params, nil, nil, errMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errMalformedHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, errMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errMalformedHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errMalformedHash
+	}
+
+	return params, salt, key, nil
+}
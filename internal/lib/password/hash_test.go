@@ -0,0 +1,125 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func testHasher(t *testing.T, cfg config.PasswordHash) *Hasher {
+	t.Helper()
+
+	hasher, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hasher
+}
+
+func TestHasher(t *testing.T) {
+	bcryptCfg := config.PasswordHash{Algorithm: Bcrypt, BcryptCost: 4}
+	argon2Cfg := config.PasswordHash{Algorithm: Argon2id, Argon2Memory: 64, Argon2Time: 1, Argon2Threads: 1}
+
+	for _, cfg := range []config.PasswordHash{bcryptCfg, argon2Cfg} {
+		t.Run(cfg.Algorithm, func(t *testing.T) {
+			hasher := testHasher(t, cfg)
+
+			hash, err := hasher.Hash("correct horse battery staple")
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			ok, err := hasher.Verify(hash, "correct horse battery staple")
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = hasher.Verify(hash, "correct horse battery stapler")
+			assert.NoError(t, err)
+			assert.False(t, ok)
+
+			ok, err = hasher.Verify("", "")
+			assert.NoError(t, err)
+			assert.False(t, ok)
+
+			assert.False(t, hasher.NeedsRehash(hash))
+
+			other, err := hasher.Hash("correct horse battery staple")
+			assert.NoError(t, err)
+			assert.NotEqual(t, hash, other, "hashes are salted")
+		})
+	}
+
+	t.Run("argon2id PHC string", func(t *testing.T) {
+		hash, err := testHasher(t, argon2Cfg).Hash("secret")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+	})
+
+	t.Run("verify across algorithms", func(t *testing.T) {
+		old, err := testHasher(t, bcryptCfg).Hash("secret")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		hasher := testHasher(t, argon2Cfg)
+
+		ok, err := hasher.Verify(old, "secret")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, hasher.NeedsRehash(old))
+	})
+
+	t.Run("rehash on other parameters", func(t *testing.T) {
+		bcryptHash, err := testHasher(t, bcryptCfg).Hash("secret")
+		if !assert.NoError(t, err) {
+			return
+		}
+		argon2Hash, err := testHasher(t, argon2Cfg).Hash("secret")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.True(t, testHasher(t, config.PasswordHash{Algorithm: Bcrypt, BcryptCost: 5}).NeedsRehash(bcryptHash))
+		assert.True(t, testHasher(t, bcryptCfg).NeedsRehash(argon2Hash))
+
+		stronger := argon2Cfg
+		stronger.Argon2Time = 2
+		assert.True(t, testHasher(t, stronger).NeedsRehash(argon2Hash))
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		hasher := testHasher(t, argon2Cfg)
+
+		for _, hash := range []string{
+			"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+			"$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5",
+			"$argon2id$v=19$m=64;t=1;p=1$c2FsdA$a2V5",
+			"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$!!",
+			"plain text",
+		} {
+			ok, err := hasher.Verify(hash, "secret")
+			assert.Error(t, err, hash)
+			assert.False(t, ok, hash)
+			assert.True(t, hasher.NeedsRehash(hash), hash)
+		}
+	})
+}
+
+func TestNewHasher(t *testing.T) {
+	for _, cfg := range []config.PasswordHash{
+		{Algorithm: "md5"},
+		{Algorithm: Bcrypt, BcryptCost: 3},
+		{Algorithm: Bcrypt, BcryptCost: 32},
+		{Algorithm: Argon2id, Argon2Memory: 64, Argon2Time: 0, Argon2Threads: 1},
+		{Algorithm: Argon2id, Argon2Memory: 4, Argon2Time: 1, Argon2Threads: 1},
+	} {
+		_, err := NewHasher(cfg)
+		assert.Error(t, err, cfg)
+	}
+}
@@ -0,0 +1,124 @@
+// Package password checks new passwords against the password policy.
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/markraiter/spycat/internal/config"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// maxBytes is the longest password bcrypt hashes, kept with argon2id for
+	// passwords to stay usable if hashing goes back to bcrypt.
+	maxBytes = 72
+	// minContextLength is the shortest context word a password may not contain,
+	// shorter ones would refuse too many passwords.
+	minContextLength = 3
+)
+
+// Violation is a password breaking the policy, Rules tells which rules it breaks.
+type Violation struct {
+	Rules []string
+}
+
+func (v *Violation) Error() string {
+	return "password " + strings.Join(v.Rules, ", ")
+}
+
+// Policy checks new passwords. Lengths are counted in characters, after NFKC
+// normalization, and the character classes are those of Unicode.
+type Policy struct {
+	cfg      config.Password
+	breached *List
+}
+
+// New returns the policy of cfg, with the list of breached passwords it points
+// to when the breach check is on.
+func New(cfg config.Password) (*Policy, error) {
+	policy := &Policy{cfg: cfg}
+	if !cfg.BreachCheck {
+		return policy, nil
+	}
+
+	var err error
+	if cfg.BreachedFile != "" {
+		policy.breached, err = OpenList(cfg.BreachedFile)
+	} else {
+		policy.breached, err = BundledList()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("breached passwords: %w", err)
+	}
+
+	return policy, nil
+}
+
+// Check returns a *Violation when the password breaks the policy. The password
+// may not contain any of the context words, such as the username of the user,
+// compared regardless of case.
+func (p *Policy) Check(password string, context ...string) error {
+	var rules []string
+
+	normalized := norm.NFKC.String(password)
+	length := utf8.RuneCountInString(normalized)
+
+	if length < p.cfg.MinLength {
+		rules = append(rules, fmt.Sprintf("must be at least %d characters long", p.cfg.MinLength))
+	}
+	if p.cfg.MaxLength > 0 && length > p.cfg.MaxLength {
+		rules = append(rules, fmt.Sprintf("must be at most %d characters long", p.cfg.MaxLength))
+	}
+	if len(password) > maxBytes {
+		rules = append(rules, fmt.Sprintf("must be at most %d bytes long", maxBytes))
+	}
+
+	var upper, lower, number, special bool
+	for _, r := range normalized {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsNumber(r):
+			number = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			special = true
+		}
+	}
+
+	if p.cfg.RequireUpper && !upper {
+		rules = append(rules, "must contain an uppercase letter")
+	}
+	if p.cfg.RequireLower && !lower {
+		rules = append(rules, "must contain a lowercase letter")
+	}
+	if p.cfg.RequireNumber && !number {
+		rules = append(rules, "must contain a number")
+	}
+	if p.cfg.RequireSpecial && !special {
+		rules = append(rules, "must contain a punctuation mark or a symbol")
+	}
+
+	folded := strings.ToLower(normalized)
+	for _, word := range context {
+		word = strings.ToLower(norm.NFKC.String(word))
+		if utf8.RuneCountInString(word) >= minContextLength && strings.Contains(folded, word) {
+			rules = append(rules, "must not contain your username or email")
+			break
+		}
+	}
+
+	if p.breached != nil && p.breached.Contains(password) {
+		rules = append(rules, "is known from data breaches, choose another one")
+	}
+
+	if len(rules) > 0 {
+		return &Violation{Rules: rules}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/markraiter/spycat/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	nist, err := New(config.Password{MinLength: 8, MaxLength: 64, BreachCheck: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	classes, err := New(config.Password{MinLength: 8, MaxLength: 64, RequireUpper: true, RequireLower: true, RequireNumber: true, RequireSpecial: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name     string
+		policy   *Policy
+		password string
+		context  []string
+		want     []string
+	}{
+		{"passphrase", nist, "correct horse battery staple", nil, nil},
+		{"too short", nist, "kitten7", nil, []string{"must be at least 8 characters long"}},
+		{"too long", nist, strings.Repeat("a", 65), nil, []string{"must be at most 64 characters long"}},
+		{"characters not bytes", nist, "żółćźęśą", nil, nil},
+		{"too many bytes", nist, strings.Repeat("ż", 40), nil, []string{"must be at most 72 bytes long"}},
+		{"breached", nist, "password123", nil, []string{"is known from data breaches, choose another one"}},
+		{"contains username", nist, "xXgeorgeMonkeyXx", []string{"George"}, []string{"must not contain your username or email"}},
+		{"short context word", nist, "ab-tortoise-shell", []string{"ab"}, nil},
+		{"no classes required", nist, "lowercase only please", nil, nil},
+		{"all classes", classes, "Grüße-2024", nil, nil},
+		{"unicode classes", classes, "ÉCOLE école ٣!", nil, nil},
+		{"missing classes", classes, "lowercase only please", nil, []string{
+			"must contain an uppercase letter",
+			"must contain a number",
+			"must contain a punctuation mark or a symbol",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.password, tt.context...)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var violation *Violation
+			if assert.True(t, errors.As(err, &violation), "want a violation, got %v", err) {
+				assert.Equal(t, tt.want, violation.Rules)
+			}
+		})
+	}
+}
+
+func TestReadList(t *testing.T) {
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+	list, err := ReadList(strings.NewReader("# comment\n\n5BAA6:1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n5baa6:0000000000000000000000000000000000a\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 2, list.Len())
+	assert.True(t, list.Contains("password"))
+	assert.False(t, list.Contains("Password"))
+
+	for _, line := range []string{
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8",
+		"5BAA6:1E4C9B93F3F0682250B6CF8331B7EE68F",
+		"5BAA:61E4C9B93F3F0682250B6CF8331B7EE68FD8",
+		"5BAA6:1E4C9B93F3F0682250B6CF8331B7EE68FZZ",
+	} {
+		_, err := ReadList(strings.NewReader(line))
+		assert.Error(t, err, line)
+	}
+}
+
+func TestBundledList(t *testing.T) {
+	list, err := BundledList()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Greater(t, list.Len(), 100)
+	assert.True(t, list.Contains("qwerty123"))
+	assert.False(t, list.Contains("correct horse battery staple"))
+}
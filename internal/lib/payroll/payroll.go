@@ -0,0 +1,102 @@
+package payroll
+
+import (
+	"errors"
+	"time"
+
+	"github.com/markraiter/spycat/internal/domain"
+)
+
+const periodLayout = "2006-01"
+
+var ErrInvalidPeriod = errors.New("invalid period, expected YYYY-MM")
+
+// Line is the prorated pay of a single cat in one currency.
+type Line struct {
+	Currency string
+	Days     int
+	Amount   int64
+}
+
+// Period parses a "YYYY-MM" string and returns the first and the last day of the month.
+func Period(period string) (time.Time, time.Time, error) {
+	from, err := time.Parse(periodLayout, period)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidPeriod
+	}
+
+	return from, from.AddDate(0, 1, -1), nil
+}
+
+// Prorate computes the pay for the inclusive date range [from, to]
+// from the salary history of a single cat.
+//
+// The history must be sorted by EffectiveFrom. Every salary is treated as a
+// monthly amount that is paid for the number of days it was effective in the
+// period. Days under different currencies produce separate lines.
+func Prorate(history []domain.Salary, from, to time.Time) []Line {
+	return ProrateUntil(history, from, to, to)
+}
+
+// ProrateUntil is Prorate for a cat paid up to the last day only, such as a
+// cat deleted in the period. The days after it are not paid.
+func ProrateUntil(history []domain.Salary, from, to, last time.Time) []Line {
+	from, to = day(from), day(to)
+	periodDays := days(from, to.AddDate(0, 0, 1))
+	end := day(last).AddDate(0, 0, 1)
+	if end.After(to) {
+		end = to.AddDate(0, 0, 1)
+	}
+
+	var lines []Line
+	index := make(map[string]int)
+
+	for i, s := range history {
+		segStart := day(s.EffectiveFrom)
+		segEnd := end
+		if i+1 < len(history) {
+			if next := day(history[i+1].EffectiveFrom); next.Before(segEnd) {
+				segEnd = next
+			}
+		}
+		if segStart.Before(from) {
+			segStart = from
+		}
+		if !segEnd.After(segStart) {
+			continue
+		}
+
+		n := days(segStart, segEnd)
+
+		idx, ok := index[s.Currency]
+		if !ok {
+			idx = len(lines)
+			index[s.Currency] = idx
+			lines = append(lines, Line{Currency: s.Currency})
+		}
+
+		lines[idx].Days += n
+		lines[idx].Amount += share(s.Amount, n, periodDays)
+	}
+
+	return lines
+}
+
+// share returns amount*part/whole rounded half up.
+func share(amount int64, part, whole int) int64 {
+	if part == whole {
+		return amount
+	}
+
+	return (amount*int64(part)*2 + int64(whole)) / (int64(whole) * 2)
+}
+
+func day(t time.Time) time.Time {
+	y, m, d := t.Date()
+
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func days(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
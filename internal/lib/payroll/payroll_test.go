@@ -0,0 +1,89 @@
+package payroll
+
+import (
+	"testing"
+	"time"
+
+	"github.com/markraiter/spycat/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPeriod(t *testing.T) {
+	from, to, err := Period("2026-02")
+	assert.NoError(t, err)
+	assert.Equal(t, date(2026, time.February, 1), from)
+	assert.Equal(t, date(2026, time.February, 28), to)
+
+	_, _, err = Period("2026-13")
+	assert.ErrorIs(t, err, ErrInvalidPeriod)
+}
+
+func TestProrate(t *testing.T) {
+	from, to := date(2026, time.October, 1), date(2026, time.October, 31)
+
+	tests := []struct {
+		name    string
+		history []domain.Salary
+		want    []Line
+	}{
+		{
+			name:    "full month",
+			history: []domain.Salary{{Amount: 310000, Currency: "USD", EffectiveFrom: date(2026, time.January, 1)}},
+			want:    []Line{{Currency: "USD", Days: 31, Amount: 310000}},
+		},
+		{
+			name:    "starts mid month",
+			history: []domain.Salary{{Amount: 310000, Currency: "USD", EffectiveFrom: date(2026, time.October, 22)}},
+			want:    []Line{{Currency: "USD", Days: 10, Amount: 100000}},
+		},
+		{
+			name: "raise mid month",
+			history: []domain.Salary{
+				{Amount: 310000, Currency: "USD", EffectiveFrom: date(2026, time.January, 1)},
+				{Amount: 620000, Currency: "USD", EffectiveFrom: date(2026, time.October, 11)},
+			},
+			want: []Line{{Currency: "USD", Days: 31, Amount: 100000 + 420000}},
+		},
+		{
+			name: "currency change",
+			history: []domain.Salary{
+				{Amount: 310000, Currency: "USD", EffectiveFrom: date(2026, time.January, 1)},
+				{Amount: 3100, Currency: "JPY", EffectiveFrom: date(2026, time.October, 21)},
+			},
+			want: []Line{
+				{Currency: "USD", Days: 20, Amount: 200000},
+				{Currency: "JPY", Days: 11, Amount: 1100},
+			},
+		},
+		{
+			name:    "starts after period",
+			history: []domain.Salary{{Amount: 310000, Currency: "USD", EffectiveFrom: date(2026, time.November, 1)}},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Prorate(tt.history, from, to))
+		})
+	}
+}
+
+func TestProrateUntil(t *testing.T) {
+	from, to := date(2026, time.October, 1), date(2026, time.October, 31)
+	history := []domain.Salary{
+		{Amount: 310000, Currency: "USD", EffectiveFrom: date(2026, time.January, 1)},
+		{Amount: 620000, Currency: "USD", EffectiveFrom: date(2026, time.October, 21)},
+	}
+
+	assert.Equal(t, []Line{{Currency: "USD", Days: 10, Amount: 100000}},
+		ProrateUntil(history, from, to, date(2026, time.October, 10)))
+	assert.Equal(t, []Line{{Currency: "USD", Days: 25, Amount: 200000 + 100000}},
+		ProrateUntil(history, from, to, date(2026, time.October, 25)))
+	assert.Nil(t, ProrateUntil(history, from, to, date(2026, time.September, 30)), "paid after the last day")
+	assert.Equal(t, Prorate(history, from, to), ProrateUntil(history, from, to, date(2026, time.December, 1)))
+}
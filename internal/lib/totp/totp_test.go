@@ -0,0 +1,94 @@
+package totp
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	key, err := decode(rfcSecret)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, code(key, Step(time.Unix(tt.unix, 0)), 8))
+
+			got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want[2:], got)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+
+	step, ok := Validate(rfcSecret, "005924", now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	step, ok = Validate(rfcSecret, "005924", now.Add(Period), 1)
+	assert.True(t, ok, "previous step refused within skew")
+	assert.Equal(t, Step(now), step)
+
+	_, ok = Validate(rfcSecret, "005924", now.Add(2*Period), 1)
+	assert.False(t, ok, "code accepted outside skew")
+
+	_, ok = Validate(rfcSecret, "000000", now, 1)
+	assert.False(t, ok)
+
+	_, ok = Validate("not base32!", "005924", now, 1)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	code, err := Code(secret, time.Now())
+	assert.NoError(t, err)
+
+	_, ok := Validate(secret, code, time.Now(), 0)
+	assert.True(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("SpyCat", "alice@example.com", rfcSecret)
+
+	u, err := url.Parse(uri)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/SpyCat:alice@example.com", u.Path)
+	assert.Equal(t, rfcSecret, u.Query().Get("secret"))
+	assert.Equal(t, "SpyCat", u.Query().Get("issuer"))
+
+	png, err := QRCode(uri, 256)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(png, []byte("\x89PNG")))
+}